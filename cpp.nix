@@ -59,6 +59,9 @@
 }:
 
 let
+  standardTools = import ./lib/standard-tools.nix { inherit pkgs; };
+  container = import ./lib/container.nix { inherit pkgs; };
+
   # Select compiler package based on choice
   compilerPkg = if compiler == "clang" then pkgs.clang else pkgs.gcc;
 
@@ -141,7 +144,7 @@ let
 
       # Additional dev tools
       ccache # Compiler cache for faster builds
-    ] ++ extraDevTools;
+    ] ++ extraDevTools ++ standardTools.projectTools self;
 
     shellHook = ''
       echo "🔧 C++ Development Environment Ready!"
@@ -266,6 +269,7 @@ in
       default = devPackage;
       dev = devPackage;
       release = releasePackage;
+      image = container.layeredImage { name = projectName; package = releasePackage; };
     };
     apps = {
       default = devApp;
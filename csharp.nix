@@ -104,6 +104,7 @@ let
 
   # Import organizational standard tools and hooks
   standardTools = import ./lib/standard-tools.nix { inherit pkgs; };
+  container = import ./lib/container.nix { inherit pkgs; };
   buildHooks = import ./lib/build-hooks.nix { inherit pkgs; };
 
   # Use organizational standard tools
@@ -117,7 +118,7 @@ let
 
   # Combine with user extras
   allBuildTools = buildTools ++ extraBuildTools;
-  allGeneralTools = generalTools ++ extraGeneralTools;
+  allGeneralTools = generalTools ++ extraGeneralTools ++ standardTools.projectTools self;
 
   shellHook = ''
     echo "🚀 C# Development Environment Ready!"
@@ -395,6 +396,7 @@ let
     packages.default = devPackage;
     packages.dev = devPackage;
     packages.release = releasePackage;
+    packages.image = container.layeredImage { inherit name; package = releasePackage; };
     apps = {
       default = devApp;
       dev = devApp;
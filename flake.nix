@@ -66,14 +66,88 @@
               };
             };
           };
+
+          # Templates for nix flake new
+          flakeTemplates = {
+            csharp-console = {
+              path = ./templates/csharp/console;
+              description = "C# console application with .NET SDK";
+            };
+            rust-cli = {
+              path = ./templates/rust/cli;
+              description = "Rust CLI application with Cargo";
+            };
+            python-console = {
+              path = ./templates/python/console;
+              description = "Python console application with testing";
+            };
+            nim-cli = {
+              path = ./templates/nim/cli;
+              description = "Nim CLI application with Nimble";
+            };
+            zig-cli = {
+              path = ./templates/zig/cli;
+              description = "Zig CLI application with native build";
+            };
+            go-cli = {
+              path = ./templates/go/cli;
+              description = "Go CLI application with Go modules";
+            };
+            go-lambda = {
+              path = ./templates/go/lambda;
+              description = "Go AWS Lambda function with a local emulator";
+            };
+            cpp-cli = {
+              path = ./templates/cpp/cpp-cli;
+              description = "C++ CLI application with CMake";
+            };
+            cpp-lib = {
+              path = ./templates/cpp/lib;
+              description = "C++ library with a nixpkgs overlay and a consumer example";
+            };
+            polyglot-ffi = {
+              path = ./templates/polyglot/ffi;
+              description = "Rust core with Go (cgo) and Python (cffi) bindings";
+            };
+            python-datascience = {
+              path = ./templates/python/datascience;
+              description = "Python data science project with Jupyter and papermill";
+            };
+            rust-gui = {
+              path = ./templates/rust/gui;
+              description = "Rust desktop GUI application with egui";
+            };
+            zig-game = {
+              path = ./templates/zig/game;
+              description = "Zig game with raylib";
+            };
+            shell-scripts = {
+              path = ./templates/shell/scripts;
+              description = "Shell script project with shellcheck, shfmt, and bats";
+            };
+            terraform-infra = {
+              path = ./templates/terraform/infra;
+              description = "Infrastructure as code with OpenTofu and tflint";
+            };
+          };
+
+          # Structural checks for every template
+          checkTemplate = import ./lib/check-template.nix { inherit pkgs; };
+
           # Build glot CLI
           glot = pkgs.buildGoModule {
             pname = "glot";
             version = "1.2.0";
             src = ./src/glot;
-            vendorHash = "sha256-3khKUpxdVjIRvmMWmEAUPArYgLzkaH2ObD1C9F7XwnQ=";
+            vendorHash = "sha256-PTBfWgxqi0Mr+WaQe66IpFW3ekxFzhdp98P4fzgsXOc=";
             buildInputs = [ pkgs.go_1_23 ];
             nativeBuildInputs = [ pkgs.go_1_23 ];
+            # cmd/glot-dev is for maintainers, in the dev shell below
+            subPackages = [ "." ];
+            # man glot, man glot-build, ...
+            postInstall = ''
+              $out/bin/glot man $out/share/man/man1
+            '';
             meta = with pkgs.lib; {
               description = "Nix Polyglot Project Interface CLI";
               homepage = "https://github.com/ritzau/nix-polyglot";
@@ -81,11 +155,21 @@
               platforms = platforms.unix;
             };
           };
+
+          # Maintainers' tools, run as 'glot dev' (glot dev test-templates)
+          glot-dev = pkgs.buildGoModule {
+            pname = "glot-dev";
+            inherit (glot) version src vendorHash;
+            subPackages = [ "cmd/glot-dev" ];
+            meta = glot.meta // {
+              description = "Tools for nix-polyglot maintainers";
+            };
+          };
         in
         {
           # Packages
           packages = {
-            inherit glot;
+            inherit glot glot-dev;
             default = glot;
           };
 
@@ -93,6 +177,8 @@
           devShells.default = pkgs.mkShell {
             packages = with pkgs; [
               nixpkgs-fmt
+              glot
+              glot-dev
             ];
             shellHook = ''
               echo "Nix Polyglot Development Environment"
@@ -212,6 +298,14 @@
                 platforms = nixpkgs.lib.platforms.all;
               };
             };
+            new-go-lambda = {
+              type = "app";
+              program = "${templates.go-lambda}/bin/new-go-lambda-project";
+              meta = {
+                description = "Create a new Go AWS Lambda function";
+                platforms = nixpkgs.lib.platforms.all;
+              };
+            };
             new-cpp-cli = {
               type = "app";
               program = "${templates.cpp-cli}/bin/new-cpp-cli-project";
@@ -220,6 +314,62 @@
                 platforms = nixpkgs.lib.platforms.all;
               };
             };
+            new-cpp-lib = {
+              type = "app";
+              program = "${templates.cpp-lib}/bin/new-cpp-lib-project";
+              meta = {
+                description = "Create a new C++ library with a consumer example";
+                platforms = nixpkgs.lib.platforms.all;
+              };
+            };
+            new-polyglot-ffi = {
+              type = "app";
+              program = "${templates.polyglot-ffi}/bin/new-polyglot-ffi-project";
+              meta = {
+                description = "Create a new Rust core with Go and Python bindings";
+                platforms = nixpkgs.lib.platforms.all;
+              };
+            };
+            new-python-datascience = {
+              type = "app";
+              program = "${templates.python-datascience}/bin/new-python-datascience-project";
+              meta = {
+                description = "Create a new Python data science project with Jupyter";
+                platforms = nixpkgs.lib.platforms.all;
+              };
+            };
+            new-rust-gui = {
+              type = "app";
+              program = "${templates.rust-gui}/bin/new-rust-gui-project";
+              meta = {
+                description = "Create a new Rust desktop GUI application";
+                platforms = nixpkgs.lib.platforms.all;
+              };
+            };
+            new-zig-game = {
+              type = "app";
+              program = "${templates.zig-game}/bin/new-zig-game-project";
+              meta = {
+                description = "Create a new Zig game with raylib";
+                platforms = nixpkgs.lib.platforms.all;
+              };
+            };
+            new-shell-scripts = {
+              type = "app";
+              program = "${templates.shell-scripts}/bin/new-shell-scripts-project";
+              meta = {
+                description = "Create a new shell script project";
+                platforms = nixpkgs.lib.platforms.all;
+              };
+            };
+            new-terraform-infra = {
+              type = "app";
+              program = "${templates.terraform-infra}/bin/new-terraform-infra-project";
+              meta = {
+                description = "Create a new OpenTofu infrastructure project";
+                platforms = nixpkgs.lib.platforms.all;
+              };
+            };
             templates = {
               type = "app";
               program = "${templates.listTemplates}/bin/list-nix-polyglot-templates";
@@ -271,39 +421,15 @@
           # Checks
           checks = {
             pre-commit-check = git-hooks;
-          };
+          } // nixpkgs.lib.mapAttrs'
+            (name: template: {
+              name = "template-${name}";
+              value = checkTemplate template.path;
+            })
+            flakeTemplates;
 
           # Templates for nix flake new
-          templates = {
-            csharp-console = {
-              path = ./templates/csharp/console;
-              description = "C# console application with .NET SDK";
-            };
-            rust-cli = {
-              path = ./templates/rust/cli;
-              description = "Rust CLI application with Cargo";
-            };
-            python-console = {
-              path = ./templates/python/console;
-              description = "Python console application with testing";
-            };
-            nim-cli = {
-              path = ./templates/nim/cli;
-              description = "Nim CLI application with Nimble";
-            };
-            zig-cli = {
-              path = ./templates/zig/cli;
-              description = "Zig CLI application with native build";
-            };
-            go-cli = {
-              path = ./templates/go/cli;
-              description = "Go CLI application with Go modules";
-            };
-            cpp-cli = {
-              path = ./templates/cpp/cpp-cli;
-              description = "C++ CLI application with CMake";
-            };
-          };
+          templates = flakeTemplates;
         }
       )
     // {
@@ -329,6 +455,20 @@
             pkgs = import nixpkgs { inherit system; };
           };
 
+        # Layered container images, e.g. for flakes with their own packages
+        container =
+          system:
+          import ./lib/container.nix {
+            pkgs = import nixpkgs { inherit system; };
+          };
+
+        # Template structure check for template authors
+        checkTemplate =
+          system:
+          import ./lib/check-template.nix {
+            pkgs = import nixpkgs { inherit system; };
+          };
+
         # Expose script system for projects to import
         scripts =
           system:
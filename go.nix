@@ -16,12 +16,16 @@
   modulePath ? null
 , # Go version constraint
   goVersion ? "1.22"
+, # Build tags and linker flags, e.g. [ "-X main.version=1.0.0" ] (glot build --tags replaces the tags, --ldflags adds flags)
+  tags ? [ ]
+, ldflags ? [ ]
 ,
 }:
 
 let
   # Import organizational standard tools and build hooks
   standardTools = import ./lib/standard-tools.nix { inherit pkgs; };
+  container = import ./lib/container.nix { inherit pkgs; };
   buildHooks = import ./lib/build-hooks.nix { inherit pkgs; };
 
   # Use organizational standard tools
@@ -33,6 +37,7 @@ let
     pkgs.gopls # Go language server
     pkgs.golangci-lint # Go linter
     pkgs.gotools # Go tools (goimports, godoc, etc.)
+    pkgs.wasmtime # Runs the wasm output (glot run --wasm)
   ]
   ++ standardTools.commonBuildTools;
 
@@ -48,7 +53,7 @@ let
 
   # Combine with user extras
   allBuildTools = buildTools ++ goDevTools ++ extraBuildTools;
-  allGeneralTools = generalTools ++ extraGeneralTools;
+  allGeneralTools = generalTools ++ extraGeneralTools ++ standardTools.projectTools self;
 
   shellHook = ''
     echo "🐹 Go Development Environment"
@@ -106,6 +111,11 @@ let
 
     nativeBuildInputs = [ go ];
 
+    # Read by the build phase from the environment, as buildGoModule's own
+    # does, so that overrideAttrs changes them
+    inherit tags;
+    ldflags = pkgs.lib.optionals (buildMode == "release") [ "-s" "-w" ] ++ ldflags;
+
     # Go build system integration
     buildPhase = ''
       runHook preBuild
@@ -114,7 +124,7 @@ let
       export GOPROXY=direct
       export GOSUMDB=off
       
-      go build -v -o ${actualProjectName} ${if buildMode == "release" then "-ldflags='-s -w'" else ""}
+      go build -v -o ${actualProjectName} ''${tags:+-tags=''${tags// /,}} ''${ldflags:+-ldflags="$ldflags"}
       runHook postBuild
     '';
 
@@ -145,6 +155,42 @@ let
     # Release optimizations are handled in buildPhase
   });
 
+  # Static build - a release build without cgo, so nothing links to libc
+  staticBuild = pkgs.buildGoModule (baseBuildArgs // {
+    pname = "${actualProjectName}-static";
+    vendorHash = null;
+    ldflags = [ "-s" "-w" ] ++ ldflags;
+    env.CGO_ENABLED = "0";
+  });
+
+  # WebAssembly build - a WASI module for wasmtime and other runtimes
+  wasmBuild = pkgs.buildGoModule (baseBuildArgs // {
+    pname = "${actualProjectName}-wasm";
+    vendorHash = null;
+    ldflags = [ "-s" "-w" ] ++ ldflags;
+    env.CGO_ENABLED = "0";
+    # Tests can't run natively for the wasm target
+    doCheck = false;
+
+    buildPhase = ''
+      runHook preBuild
+      export GOCACHE=$TMPDIR/go-cache
+      export GOPATH="$TMPDIR/go"
+      export GOPROXY=direct
+      export GOSUMDB=off
+
+      GOOS=wasip1 GOARCH=wasm go build -v -o ${actualProjectName}.wasm ''${tags:+-tags=''${tags// /,}} ''${ldflags:+-ldflags="$ldflags"}
+      runHook postBuild
+    '';
+
+    installPhase = ''
+      runHook preInstall
+      mkdir -p $out/bin
+      cp ${actualProjectName}.wasm $out/bin/
+      runHook postInstall
+    '';
+  });
+
 in
 {
   # Standard nix-polyglot outputs
@@ -153,6 +199,12 @@ in
       default = devBuild;
       dev = devBuild;
       release = releaseBuild;
+      image = container.layeredImage { name = actualProjectName; package = releaseBuild; };
+      wasm = wasmBuild;
+    } // pkgs.lib.optionalAttrs pkgs.stdenv.hostPlatform.isLinux {
+      # Fully static binaries only exist on Linux (glot build --static)
+      static = staticBuild;
+    } // {
 
       # Also expose glot CLI
       glot = self.packages.${pkgs.system}.glot;
@@ -0,0 +1,73 @@
+{ pkgs, lib ? pkgs.lib }:
+
+# Structural check for a template directory, usable as a flake check by
+# template authors:
+#
+#   checks.my-template = nix-polyglot.lib.checkTemplate system ./templates/my-template;
+#
+# Verifies template.nix metadata, required files, that every listed file
+# exists, and that @placeholders@ in template files are substituted by one of
+# the template's nix files. `glot template lint` runs the same checks plus a
+# scaffold-build-test roundtrip.
+templatePath:
+let
+  template = import (templatePath + "/template.nix");
+  # Platform-specific files are checked like the others
+  files = lib.foldl' (all: platformFiles: all // platformFiles) (template.files or { })
+    (builtins.attrValues (template.platformFiles or { }));
+  name = template.name or (baseNameOf (toString templatePath));
+
+  requiredAttrs = [ "name" "description" "language" "files" ];
+  requiredFiles = [ "flake.nix" ".envrc" ".gitignore" ];
+
+  missingAttrs = builtins.filter (attr: !(template ? ${attr})) requiredAttrs;
+  missingFiles = builtins.filter (file: !(files ? ${file})) requiredFiles;
+  absentSources = builtins.filter (file: !builtins.pathExists files.${file}) (builtins.attrNames files);
+  hooks = template.postGenerate or [ ];
+  validHooks = builtins.isList hooks && builtins.all builtins.isString hooks;
+
+  # Substitutions may only use the variables `glot new` provides
+  templateVariables = [ "projectName" "modulePath" "packageName" ];
+  substitute = template.substitute or { };
+  # builtins.split returns the captured variable names as lists between the text
+  usedVariables = value: lib.flatten (builtins.filter builtins.isList (builtins.split "@([A-Za-z_][A-Za-z0-9_]*)@" value));
+  unknownVariables = lib.unique (builtins.filter (var: !(builtins.elem var templateVariables))
+    (lib.concatMap usedVariables (builtins.attrValues substitute)));
+
+  # Listed files that may contain placeholders (nix files substitute them)
+  contentFiles = lib.filterAttrs
+    (dest: source: !(lib.hasSuffix ".nix" dest) && builtins.pathExists source)
+    files;
+  nixFiles = lib.filterAttrs (dest: source: lib.hasSuffix ".nix" dest && builtins.pathExists source) files;
+
+  fail = message: ''
+    echo "❌ ${message}"
+    failed=1
+  '';
+in
+pkgs.runCommand "check-template-${name}" { } ''
+  failed=0
+
+  ${lib.concatMapStrings (attr: fail "template.nix is missing '${attr}'") missingAttrs}
+  ${lib.concatMapStrings (file: fail "required file '${file}' is not listed in template.nix") missingFiles}
+  ${lib.concatMapStrings (file: fail "'${file}' is listed but its source does not exist") absentSources}
+  ${lib.optionalString (!validHooks) (fail "postGenerate must be a list of shell commands")}
+  ${lib.concatMapStrings (var: fail "substitute uses unknown variable '@${var}@'") unknownVariables}
+
+  # Placeholders must be substituted by one of the template's nix files
+  cat /dev/null ${lib.concatStringsSep " " (lib.mapAttrsToList (_: source: "${source}") nixFiles)} > nix-sources
+  ${lib.concatStrings (lib.mapAttrsToList (dest: source: ''
+    for placeholder in $(grep -oI '@[A-Za-z_][A-Za-z0-9_]*@' ${source} | sort -u); do
+      if ! grep -qF "$placeholder" nix-sources; then
+        ${fail "${dest}: placeholder $placeholder is never substituted"}
+      fi
+    done
+  '') contentFiles)}
+
+  if [ "$failed" -ne 0 ]; then
+    echo "Template '${name}' failed checks"
+    exit 1
+  fi
+  echo "✅ Template '${name}' passed checks"
+  touch $out
+''
@@ -0,0 +1,26 @@
+{ pkgs }:
+
+{
+  # Container image of a package, as a script streaming the image tarball
+  # (`glot package` loads it into docker or podman). Every store path of the
+  # closure gets a layer of its own, ordered by how many paths depend on it,
+  # so shared dependencies come first and the package itself - the only path
+  # that changes with the source - comes last: a source-only change produces
+  # one small new layer and every dependency layer is reused.
+  layeredImage =
+    { name
+    , package
+    , program ? "${package}/bin/${name}"
+    , tag ? "latest"
+    , maxLayers ? 100
+    ,
+    }:
+    pkgs.dockerTools.streamLayeredImage {
+      name = pkgs.lib.toLower name;
+      inherit tag maxLayers;
+      config = {
+        Entrypoint = [ program ];
+        Env = [ "SSL_CERT_FILE=${pkgs.cacert}/etc/ssl/certs/ca-bundle.crt" ];
+      };
+    };
+}
@@ -20,6 +20,10 @@ let
     # Text processing
     ripgrep # Fast grep replacement
     fd # Fast find replacement
+
+    # Formatting (glot fmt --treefmt)
+    treefmt
+    nixpkgs-fmt
   ];
 
   # Common build/development tools that many languages can benefit from
@@ -84,4 +88,20 @@ in
 
   # Minimal set for lightweight environments
   getMinimalTools = generalTools ++ [ pkgs.figlet ]; # At minimum, keep the build formatting
+
+  # Extra tools a project lists in its glot.toml, by nixpkgs attribute path:
+  #   tools = [ "hyperfine" "nodePackages.prettier" ]
+  projectTools = src:
+    let
+      configFile = src + "/glot.toml";
+      config =
+        if builtins.pathExists configFile
+        then builtins.fromTOML (builtins.readFile configFile)
+        else { };
+      toPackage = name:
+        pkgs.lib.attrByPath (pkgs.lib.splitString "." name)
+          (throw "glot.toml: tool '${name}' is not a nixpkgs package")
+          pkgs;
+    in
+    map toPackage (config.tools or [ ]);
 }
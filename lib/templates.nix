@@ -1,6 +1,9 @@
 # Project Templates System - Directory Based
 # Templates are now stored in the templates/ directory for easy maintenance
 # Usage: nix run nix-polyglot#new-csharp myproject
+# `glot new` reads the same template directories directly and additionally
+# applies the templates' substitutions, platform-specific content and
+# post-generate hooks
 
 { pkgs, lib ? pkgs.lib }:
 
@@ -51,6 +54,10 @@ let
 
         Co-Authored-By: Claude <noreply@anthropic.com>"
         
+                ${lib.optionalString (templateConfig.postGenerate or [ ] != [ ]) ''
+                  echo "⚙️  Finish the setup in the dev shell ('glot new' runs these for you):"
+                  printf '  %s\n' ${lib.escapeShellArgs templateConfig.postGenerate}
+                ''}
                 echo ""
                 echo "✅ ${templateConfig.description} project '$PROJECT_NAME' created successfully!"
                 echo ""
@@ -74,21 +81,35 @@ in
 
   # Rust templates  
   rust-cli = mkTemplateFromDir ../templates/rust/cli;
+  rust-gui = mkTemplateFromDir ../templates/rust/gui;
 
   # Python templates
   python-console = mkTemplateFromDir ../templates/python/console;
+  python-datascience = mkTemplateFromDir ../templates/python/datascience;
 
   # Nim templates
   nim-cli = mkTemplateFromDir ../templates/nim/cli;
 
   # Zig templates
   zig-cli = mkTemplateFromDir ../templates/zig/cli;
+  zig-game = mkTemplateFromDir ../templates/zig/game;
 
   # Go templates
   go-cli = mkTemplateFromDir ../templates/go/cli;
+  go-lambda = mkTemplateFromDir ../templates/go/lambda;
 
   # C++ templates  
   cpp-cli = mkTemplateFromDir ../templates/cpp/cpp-cli;
+  cpp-lib = mkTemplateFromDir ../templates/cpp/lib;
+
+  # Polyglot templates
+  polyglot-ffi = mkTemplateFromDir ../templates/polyglot/ffi;
+
+  # Shell templates
+  shell-scripts = mkTemplateFromDir ../templates/shell/scripts;
+
+  # Infrastructure templates
+  terraform-infra = mkTemplateFromDir ../templates/terraform/infra;
 
   # Legacy aliases for backward compatibility
   csharp = mkTemplateFromDir ../templates/csharp/console;
@@ -112,10 +133,12 @@ in
       echo "  Rust Templates:"  
       echo "    rust           - Rust CLI application"
       echo "    rust-cli       - Rust CLI application (explicit)"
+      echo "    rust-gui       - Desktop GUI application with egui"
       echo ""
       echo "  Python Templates:"
       echo "    python         - Python console application with Poetry"
       echo "    python-console - Python console application (explicit)"
+      echo "    python-datascience - Data science project with Jupyter"
       echo ""
       echo "  Nim Templates:"
       echo "    nim            - Nim CLI application"
@@ -124,14 +147,26 @@ in
       echo "  Zig Templates:"
       echo "    zig            - Zig CLI application"
       echo "    zig-cli        - Zig CLI application (explicit)"
+      echo "    zig-game       - Game with raylib"
       echo ""
       echo "  Go Templates:"
       echo "    go             - Go CLI application"
       echo "    go-cli         - Go CLI application (explicit)"
+      echo "    go-lambda      - AWS Lambda function with a local emulator"
       echo ""
       echo "  C++ Templates:"
       echo "    cpp            - C++ CLI application with CMake"
       echo "    cpp-cli        - C++ CLI application (explicit)"
+      echo "    cpp-lib        - C++ library with an overlay and a consumer example"
+      echo ""
+      echo "  Polyglot Templates:"
+      echo "    polyglot-ffi   - Rust core with Go and Python bindings"
+      echo ""
+      echo "  Shell Templates:"
+      echo "    shell-scripts  - Bash scripts with shellcheck, shfmt, bats"
+      echo ""
+      echo "  Infrastructure Templates:"
+      echo "    terraform-infra - OpenTofu with tflint and tofu test"
       echo ""
       echo "Usage:"
       echo "  nix run nix-polyglot#new-csharp myproject"
@@ -148,6 +183,14 @@ in
       echo "  nix run nix-polyglot#new-zig-cli myproject"
       echo "  nix run nix-polyglot#new-go-cli myproject"
       echo "  nix run nix-polyglot#new-cpp-cli myproject"
+      echo "  nix run nix-polyglot#new-cpp-lib myproject"
+      echo "  nix run nix-polyglot#new-go-lambda myproject"
+      echo "  nix run nix-polyglot#new-polyglot-ffi myproject"
+      echo "  nix run nix-polyglot#new-python-datascience myproject"
+      echo "  nix run nix-polyglot#new-rust-gui myproject"
+      echo "  nix run nix-polyglot#new-zig-game myproject"
+      echo "  nix run nix-polyglot#new-shell-scripts myproject"
+      echo "  nix run nix-polyglot#new-terraform-infra myproject"
       echo ""
       echo "Each template includes:"
       echo "  • Complete flake.nix with nix-polyglot integration"
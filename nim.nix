@@ -24,6 +24,7 @@
 let
   # Import organizational standard tools and build hooks
   standardTools = import ./lib/standard-tools.nix { inherit pkgs; };
+  container = import ./lib/container.nix { inherit pkgs; };
   buildHooks = import ./lib/build-hooks.nix { inherit pkgs; };
 
   # Use organizational standard tools
@@ -45,7 +46,7 @@ let
 
   # Combine with user extras
   allBuildTools = buildTools ++ nimDevTools ++ extraBuildTools;
-  allGeneralTools = generalTools ++ extraGeneralTools;
+  allGeneralTools = generalTools ++ extraGeneralTools ++ standardTools.projectTools self;
 
   shellHook = ''
     echo "🎯 Nim Development Environment"
@@ -131,6 +132,7 @@ in
       default = devBuild;
       dev = devBuild;
       release = releaseBuild;
+      image = container.layeredImage { name = actualBinaryName; package = releaseBuild; };
 
       # Also expose glot CLI
       glot = self.packages.${pkgs.system}.glot;
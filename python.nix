@@ -100,6 +100,7 @@ let
 
   # Import organizational standard tools and hooks
   standardTools = import ./lib/standard-tools.nix { inherit pkgs; };
+  container = import ./lib/container.nix { inherit pkgs; };
   buildHooks = import ./lib/build-hooks.nix { inherit pkgs; };
 
   # Use organizational standard tools
@@ -117,7 +118,7 @@ let
 
   # Combine with user extras and standard tools
   allSystemPackages = standardTools.commonBuildTools ++ pythonTools ++ extraSystemPackages;
-  allGeneralTools = generalTools ++ extraBuildInputs;
+  allGeneralTools = generalTools ++ extraBuildInputs ++ standardTools.projectTools self;
 
   # Detect project structure
   isPoetryProject = buildSystem == "poetry" || pkgs.lib.hasSuffix "pyproject.toml" buildTarget;
@@ -132,6 +133,7 @@ let
     # Common dependencies for Poetry projects
     click
     pytest
+    coverage # glot test --coverage
     # Build tools
     build
     poetry-core
@@ -506,6 +508,7 @@ let
     packages.default = devPackage;
     packages.dev = devPackage;
     packages.release = releasePackage;
+    packages.image = container.layeredImage { name = appName; package = releasePackage; };
     apps = {
       default = devApp;
       dev = devApp;
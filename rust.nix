@@ -17,12 +17,16 @@
   binaryName ? null
 , # Build configuration - "dev" or "release"
   buildType ? "dev"
+, # Cargo features, for every cargo command of the build (glot build --features overrides them)
+  buildFeatures ? [ ]
+, buildNoDefaultFeatures ? false
 ,
 }:
 
 let
   # Import organizational standard tools and build hooks
   standardTools = import ./lib/standard-tools.nix { inherit pkgs; };
+  container = import ./lib/container.nix { inherit pkgs; };
   buildHooks = import ./lib/build-hooks.nix { inherit pkgs; };
 
   # Use organizational standard tools
@@ -41,11 +45,13 @@ let
     # Use the standard stable toolchain components
     rustfmt
     clippy
+    cargo-llvm-cov # glot test --coverage
+    wasmtime # Runs the wasm output (glot run --wasm)
   ];
 
   # Combine with user extras
   allBuildTools = buildTools ++ rustDevTools ++ extraBuildTools;
-  allGeneralTools = generalTools ++ extraGeneralTools;
+  allGeneralTools = generalTools ++ extraGeneralTools ++ standardTools.projectTools self;
 
   shellHook = ''
     echo "🦀 Rust Development Environment Ready!"
@@ -90,16 +96,25 @@ let
     in
     hasTestsDir || hasDocTests || (cargoToml ? dev-dependencies);
 
+  # Feature flags of the cargo commands run by hand, from the variables
+  # nixpkgs' cargo hooks read, so that overriding those covers every command
+  cargoBuildFeatureFlags = ''
+    ''${cargoBuildNoDefaultFeatures:+--no-default-features} ''${cargoBuildFeatures:+--features "$cargoBuildFeatures"}'';
+  cargoCheckFeatureFlags = ''
+    ''${cargoCheckNoDefaultFeatures:+--no-default-features} ''${cargoCheckFeatures:+--features "$cargoCheckFeatures"}'';
+
   # Common build configuration
   commonBuildConfig =
     if cargoHash == null then
-      throw "cargoHash is required for Rust builds. Generate it with: nix-prefetch-url --unpack <cargo-vendor-tarball>"
+      throw "cargoHash is required for Rust builds. Set it to lib.fakeHash and run: glot hash update"
     else
       {
         pname = packageName;
         version = packageVersion;
         src = self;
-        inherit cargoHash;
+        inherit cargoHash buildFeatures buildNoDefaultFeatures;
+        checkFeatures = buildFeatures;
+        checkNoDefaultFeatures = buildNoDefaultFeatures;
         nativeBuildInputs = with pkgs; [ fastfetch ];
         preUnpack = buildHooks.systemInfoHook;
         preInstall = buildHooks.installPhaseHook;
@@ -122,7 +137,7 @@ let
           buildPhase = ''
             runHook preBuild
             echo "Building with debug profile (unoptimized)"
-            cargo build -j $NIX_BUILD_CORES --target ${pkgs.rust.toRustTarget pkgs.stdenv.hostPlatform} --frozen --offline
+            cargo build -j $NIX_BUILD_CORES --target ${pkgs.rust.toRustTarget pkgs.stdenv.hostPlatform} --frozen --offline ${cargoBuildFeatureFlags}
             runHook postBuild
           '';
 
@@ -141,7 +156,7 @@ let
                 echo
                 echo Testing
                 echo =======
-                cargo test
+                cargo test ${cargoCheckFeatureFlags}
               ''
             else
               null;
@@ -183,7 +198,7 @@ let
                 echo
                 echo Testing
                 echo =======
-                cargo test --release
+                cargo test --release ${cargoCheckFeatureFlags}
               ''
             else
               null;
@@ -202,6 +217,42 @@ let
         }
       );
 
+  # Static build - a release build for musl with nixpkgs' static package
+  # set, whose default cargo build targets it
+  staticPackage =
+    if cargoHash == null then
+      throw "cargoHash is required for Rust builds"
+    else
+      pkgs.pkgsStatic.rustPlatform.buildRustPackage (
+        commonBuildConfig
+        // {
+          pname = "${packageName}-static";
+          # The tests run in the other variants
+          doCheck = false;
+        }
+      );
+
+  # WebAssembly build - a release build for WASI with nixpkgs' cross
+  # package set, whose rustc has the target's standard library
+  wasmPackage =
+    if cargoHash == null then
+      throw "cargoHash is required for Rust builds"
+    else
+      pkgs.pkgsCross.wasi32.rustPlatform.buildRustPackage (
+        commonBuildConfig
+        // {
+          pname = "${packageName}-wasm";
+          # Tests can't run natively for the wasm target
+          doCheck = false;
+          installPhase = ''
+            runHook preInstall
+            mkdir -p $out/bin
+            cp target/wasm32-wasi*/release/*.wasm $out/bin/
+            runHook postInstall
+          '';
+        }
+      );
+
   # Select package based on buildType parameter
   package = if buildType == "release" then releasePackage else devPackage;
 
@@ -214,6 +265,8 @@ let
           version = packageVersion;
           src = self;
           inherit cargoHash;
+          checkFeatures = buildFeatures;
+          checkNoDefaultFeatures = buildNoDefaultFeatures;
 
           # Only run tests, don't install anything
           dontInstall = true;
@@ -231,17 +284,22 @@ let
               command = "${cargo}/bin/cargo --version";
               label = "Cargo version";
             }}
-            cargo test --release --verbose
+            cargo test --release --verbose ${cargoCheckFeatureFlags}
           '';
         }
     else
       null;
 
   # Individual components for backward compatibility and extension
-  devShell = pkgs.mkShell {
+  devShell = pkgs.mkShell ({
     packages = allGeneralTools ++ allBuildTools;
     inherit shellHook;
-  };
+  }
+  # cargo-llvm-cov needs the LLVM tools matching rustc's LLVM
+  // pkgs.lib.optionalAttrs (rustc ? llvmPackages) {
+    LLVM_COV = "${rustc.llvmPackages.llvm}/bin/llvm-cov";
+    LLVM_PROFDATA = "${rustc.llvmPackages.llvm}/bin/llvm-profdata";
+  });
 
   devApp = {
     type = "app";
@@ -345,9 +403,16 @@ let
   # Default flake outputs structure - ready to use
   defaultOutputs = {
     devShells.default = devShell;
-    packages.default = devPackage;
-    packages.dev = devPackage;
-    packages.release = releasePackage;
+    packages = {
+      default = devPackage;
+      dev = devPackage;
+      release = releasePackage;
+      image = container.layeredImage { name = detectedBinaryName; package = releasePackage; };
+      wasm = wasmPackage;
+    } // pkgs.lib.optionalAttrs pkgs.stdenv.hostPlatform.isLinux {
+      # Fully static binaries only exist on Linux (glot build --static)
+      static = staticPackage;
+    };
     apps = {
       default = devApp;
       dev = devApp;
@@ -398,6 +463,8 @@ in
   inherit
     devPackage
     releasePackage
+    staticPackage
+    wasmPackage
     devApp
     releaseApp
     ;
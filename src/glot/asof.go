@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Link of the result of glot build --as-of, next to the usual result
+const asOfResult = "result-as-of"
+
+// Resolve --as-of to a commit: a git revision, or a date, meaning the last
+// commit of HEAD before it
+func resolveAsOf(asOf string) (string, error) {
+	if out, err := exec.Command("git", "rev-parse", "--verify", "--quiet", asOf+"^{commit}").Output(); err == nil {
+		return strings.TrimSpace(string(out)), nil
+	}
+	out, err := exec.Command("git", "rev-list", "-1", "--before="+asOf, "HEAD").Output()
+	if err != nil || len(bytes.TrimSpace(out)) == 0 {
+		return "", fmt.Errorf("%s", tr("AsOfUnknown", "AsOf", asOf))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// The lock files of the project at rev, by path relative to the project
+func lockFilesAt(rev string) (map[string][]byte, error) {
+	out, err := exec.Command("git", "ls-tree", "-r", "-z", "--name-only", rev, "--", ".").Output()
+	if err != nil {
+		return nil, err
+	}
+	files := map[string][]byte{}
+	for _, path := range strings.Split(string(out), "\x00") {
+		if path == "" || !slices.Contains(fingerprintLockFiles, filepath.Base(path)) {
+			continue
+		}
+		content, err := exec.Command("git", "show", rev+":./"+path).Output()
+		if err != nil {
+			return nil, fmt.Errorf("%s", tr("AsOfReadFailed", "File", path, "Rev", rev[:12], "Error", err))
+		}
+		files[path] = content
+	}
+	return files, nil
+}
+
+// Copy the working tree, as glot export sees it, into dir, with the lock
+// files of rev: those rev had replace today's, and those it didn't have are
+// left out. Returns the lock files that differ from the working tree.
+func overlayLockFiles(dir, rev string) ([]string, error) {
+	files, err := exportFiles()
+	if err != nil {
+		return nil, err
+	}
+	past, err := lockFilesAt(rev)
+	if err != nil {
+		return nil, err
+	}
+	var changed []string
+	for _, file := range files {
+		if slices.Contains(fingerprintLockFiles, filepath.Base(file)) {
+			if _, ok := past[file]; !ok {
+				changed = append(changed, file)
+				continue
+			}
+		}
+		if err := copyOverlayFile(file, filepath.Join(dir, file)); err != nil {
+			return nil, err
+		}
+	}
+	for _, file := range sortedKeys(past) {
+		current, _ := os.ReadFile(file)
+		if !bytes.Equal(current, past[file]) && !slices.Contains(changed, file) {
+			changed = append(changed, file)
+		}
+		target := filepath.Join(dir, file)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(target, past[file], 0644); err != nil {
+			return nil, err
+		}
+	}
+	slices.Sort(changed)
+	return changed, nil
+}
+
+// Copy a file or symlink of the working tree, keeping its mode
+func copyOverlayFile(source, target string) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	stat, err := os.Lstat(source)
+	if err != nil {
+		return err
+	}
+	if stat.Mode()&os.ModeSymlink != 0 {
+		link, err := os.Readlink(source)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(link, target)
+	}
+	content, err := os.ReadFile(source)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(target, content, stat.Mode().Perm())
+}
+
+// Build today's sources with the dependencies locked at a past revision or
+// date: in a temporary copy of the working tree with flake.lock and the
+// language lock files of then, linking the result as result-as-of
+func buildAsOf(asOf, variant string, strictLock bool, target, cross string, overrides []string) error {
+	rev, err := resolveAsOf(asOf)
+	if err != nil {
+		errorMsg(err.Error())
+		return err
+	}
+	date, _ := exec.Command("git", "show", "-s", "--format=%cs", rev).Output()
+	overlay, err := os.MkdirTemp("", "glot-as-of-")
+	if err != nil {
+		errorMsg(err.Error())
+		return err
+	}
+	defer os.RemoveAll(overlay)
+
+	changed, err := overlayLockFiles(overlay, rev)
+	if err != nil {
+		errorMsg(tr("AsOfOverlayFailed", "Error", err))
+		return err
+	}
+	info(tr("AsOfStarted", "Rev", rev[:12], "Date", strings.TrimSpace(string(date))))
+	if len(changed) == 0 {
+		warning(tr("AsOfUnchanged", "Rev", rev[:12]))
+	}
+	for _, file := range changed {
+		if _, err := os.Stat(filepath.Join(overlay, file)); err != nil {
+			info(tr("AsOfLockFileMissing", "File", file))
+		} else {
+			info(tr("AsOfLockFile", "File", file))
+		}
+	}
+
+	err = inProjectDir(overlay, func() error {
+		return buildCommand(variant, strictLock, target, cross, overrides)
+	})
+	if err != nil {
+		return err
+	}
+	out, err := filepath.EvalSymlinks(filepath.Join(overlay, "result"))
+	if err != nil {
+		errorMsg(err.Error())
+		return err
+	}
+	// A root of its own, as the overlay's result link goes with it
+	if err := runNix("build", out, "--out-link", asOfResult); err != nil {
+		errorMsg(err.Error())
+		return err
+	}
+	success(tr("AsOfLinked", "Link", asOfResult, "Rev", rev[:12]))
+	return nil
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// Run the attic client, from PATH or nixpkgs if it isn't installed
+func runAttic(args ...string) error {
+	if _, err := exec.LookPath("attic"); err == nil {
+		stdout := newRedactingWriter(logged(os.Stdout))
+		stderr := newRedactingWriter(logged(stderrOutput()))
+		defer stdout.Flush()
+		defer stderr.Flush()
+
+		cmd := exec.Command("attic", args...)
+		cmd.Stdout = stdout
+		cmd.Stderr = stderr
+		cmd.Stdin = os.Stdin
+		logCommand(cmd)
+		step := startStep("attic " + strings.Join(args, " "))
+		err := cmd.Run()
+		step.finish(err)
+		return err
+	}
+	return runNix(append([]string{"run", "nixpkgs#attic-client", "--"}, args...)...)
+}
+
+// Capture the output of the attic client
+func atticOutput(args ...string) ([]byte, error) {
+	if _, err := exec.LookPath("attic"); err == nil {
+		var stderr bytes.Buffer
+		cmd := exec.Command("attic", args...)
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+		}
+		return out, nil
+	}
+	return nixOutput(append([]string{"run", "nixpkgs#attic-client", "--"}, args...)...)
+}
+
+// Read the substituter endpoint and public key from `attic cache info`
+func atticCacheInfo(cache string) (endpoint, publicKey string, err error) {
+	out, err := atticOutput("cache", "info", cache)
+	if err != nil {
+		return "", "", fmt.Errorf("%s", tr("AtticQueryFailed", "Cache", cache, "Error", err))
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		key, value, found := strings.Cut(scanner.Text(), ":")
+		if !found {
+			continue
+		}
+		switch strings.TrimSpace(key) {
+		case "Binary Cache Endpoint":
+			endpoint = strings.TrimSpace(value)
+		case "Public Key":
+			publicKey = strings.TrimSpace(value)
+		}
+	}
+	if endpoint == "" {
+		return "", "", fmt.Errorf("%s", tr("AtticNoEndpoint", "Cache", cache))
+	}
+	return endpoint, publicKey, nil
+}
+
+func newCacheLoginCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "login <server> <endpoint> [token]",
+		Short: "Log in to an attic server or Cachix",
+		Long: "Log in to a self-hosted attic server. The token is read from ATTIC_TOKEN or prompted for if not given. " +
+			"'glot cache login cachix [token]' stores a Cachix auth token instead, read from CACHIX_AUTH_TOKEN or prompted for.",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 0 && args[0] == "cachix" {
+				return cobra.RangeArgs(1, 2)(cmd, args)
+			}
+			return cobra.RangeArgs(2, 3)(cmd, args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if args[0] == "cachix" {
+				return loginCachix(args[1:])
+			}
+			server, endpoint := args[0], args[1]
+			token := ""
+			if len(args) == 3 {
+				token = args[2]
+			} else if token = os.Getenv("ATTIC_TOKEN"); token == "" {
+				token = prompt(tr("AtticTokenPrompt"), "")
+			}
+			if token == "" {
+				err := fmt.Errorf("%s", tr("AtticTokenRequired"))
+				errorMsg(err.Error())
+				return err
+			}
+			if err := runAttic("login", server, endpoint, token); err != nil {
+				errorMsg(tr("AtticLoginFailed", "Endpoint", endpoint))
+				return err
+			}
+			success(tr("AtticLoggedIn", "Server", server))
+			info(tr("AtticLoginNext", "Server", server))
+			return nil
+		},
+	}
+}
+
+func newCacheUseCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "use <name|server:cache>",
+		Short: "Use a Cachix or attic cache for this project",
+		Long: "Configure nix to pull from a Cachix cache, or from an attic cache given as <server>:<cache>, record it " +
+			"in glot.toml and make it the target of 'glot cache push'.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkNix(); err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			cache := args[0]
+			if !strings.Contains(cache, ":") {
+				if err := useCachixCache(cache); err != nil {
+					errorMsg(err.Error())
+					return err
+				}
+				return nil
+			}
+
+			endpoint, publicKey, err := atticCacheInfo(cache)
+			if err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			// Sets up netrc and substituter for private caches in the user's nix.conf
+			if err := runAttic("use", cache); err != nil {
+				warning(tr("AtticUseFailed"))
+			}
+
+			if err := writeCachePushConfig(cachePushConfig{Type: "attic", URL: cache}); err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			pull := cacheConfig{Type: "attic", Substituter: endpoint, PublicKey: publicKey}
+			if err := writeConfigSection(configFile, "cache", pull); err != nil {
+				errorMsg(tr("CacheConfigFailed", "Config", configFile, "Error", err))
+				return err
+			}
+			success(tr("AtticUsing", "Cache", cache, "Config", configFile))
+			info(tr("CachePushHint"))
+			return nil
+		},
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Lint findings recorded by `glot lint --baseline create`, committed with
+// the project so everyone fails only on new findings
+const lintBaselineFile = "glot-lint-baseline.json"
+
+type lintBaseline struct {
+	Version int `json:"version"`
+	// Finding ("file: message", without line numbers) to how often it occurs
+	Findings map[string]int `json:"findings"`
+}
+
+var (
+	ansiEscape = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+	// path:line[:column][:] message, as printed by go vet, ruff, cppcheck
+	// and most linters, and clippy's "--> path:line:column"
+	findingLocation = regexp.MustCompile(`^\s*(?:--> )?((?:[\w.@+\-]+/)*[\w.@+\-]+\.\w+):(\d+)(?::\d+)?:?\s*(.*)$`)
+	// path(line,column): message, as printed by dotnet
+	findingParenLocation = regexp.MustCompile(`^\s*(\S+\.\w+)\((\d+),\d+\):\s*(.*)$`)
+	// Header line of a multi-line diagnostic (clippy, tflint)
+	findingHeader = regexp.MustCompile(`^(?:warning|error|Warning|Error)(?:\[[\w:-]+\])?: (.+)$`)
+)
+
+// A finding of a linter, or a failure a test runner located
+type lintFinding struct {
+	file    string
+	line    int
+	message string
+}
+
+// Findings in the output of linters and test runners, in order
+func parseFindings(output string) []lintFinding {
+	var findings []lintFinding
+	header := ""
+	for _, line := range strings.Split(ansiEscape.ReplaceAllString(output, ""), "\n") {
+		line = strings.TrimRight(line, " \r")
+		if m := findingHeader.FindStringSubmatch(line); m != nil {
+			header = m[1]
+			continue
+		}
+		m := findingLocation.FindStringSubmatch(line)
+		if m == nil {
+			m = findingParenLocation.FindStringSubmatch(line)
+		}
+		if m == nil {
+			continue
+		}
+		message := strings.TrimSpace(m[3])
+		if message == "" {
+			message = header
+		}
+		number, _ := strconv.Atoi(m[2])
+		findings = append(findings, lintFinding{m[1], number, message})
+	}
+	return findings
+}
+
+// Findings in linter output. Line numbers are left out, so findings survive
+// edits elsewhere in the file.
+func lintFindings(output string) map[string]int {
+	findings := map[string]int{}
+	for _, finding := range parseFindings(output) {
+		findings[finding.file+": "+finding.message]++
+	}
+	return findings
+}
+
+func loadLintBaseline() (*lintBaseline, error) {
+	content, err := os.ReadFile(lintBaselineFile)
+	if err != nil {
+		return nil, err
+	}
+	var baseline lintBaseline
+	if err := json.Unmarshal(content, &baseline); err != nil {
+		return nil, fmt.Errorf("%s", tr("ConfigInvalid", "File", lintBaselineFile, "Error", err))
+	}
+	return &baseline, nil
+}
+
+func saveLintBaseline(findings map[string]int) error {
+	content, err := json.MarshalIndent(lintBaseline{Version: 1, Findings: findings}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(lintBaselineFile, append(content, '\n'), 0644)
+}
+
+// Findings beyond those in the baseline, one entry per extra occurrence
+func newFindings(findings, baseline map[string]int) []string {
+	var added []string
+	for finding, count := range findings {
+		for i := baseline[finding]; i < count; i++ {
+			added = append(added, finding)
+		}
+	}
+	sort.Strings(added)
+	return added
+}
+
+// Run fn with the output of the commands it runs also captured
+func captureOutput(fn func() error) (string, error) {
+	var output bytes.Buffer
+	previous := activeLog
+	if previous != nil {
+		activeLog = io.MultiWriter(previous, &output)
+	} else {
+		activeLog = &output
+	}
+	defer func() { activeLog = previous }()
+	err := fn()
+	return output.String(), err
+}
+
+// The baseline mode: as given, or "use" when the project has a baseline
+func lintBaselineMode(mode string) (string, error) {
+	switch mode {
+	case "create", "use", "ignore":
+		return mode, nil
+	case "":
+		if _, err := os.Stat(lintBaselineFile); err == nil {
+			return "use", nil
+		}
+		return "ignore", nil
+	}
+	return "", fmt.Errorf("%s", tr("BaselineUnknownMode", "Mode", mode))
+}
+
+// Lint with lint, recording its findings as the baseline
+func createLintBaseline(lint func() error) error {
+	output, err := captureOutput(lint)
+	findings := lintFindings(output)
+	if err != nil && len(findings) == 0 {
+		warning(tr("BaselineNoFindings"))
+	}
+	if err := saveLintBaseline(findings); err != nil {
+		return fmt.Errorf("%s", tr("BaselineWriteFailed", "File", lintBaselineFile, "Error", err))
+	}
+	total := 0
+	for _, count := range findings {
+		total += count
+	}
+	success(trn("BaselineRecorded", total, "File", lintBaselineFile))
+	return nil
+}
+
+// Lint with lint, failing only on findings the baseline doesn't have
+func lintWithBaseline(lint func() error) error {
+	baseline, err := loadLintBaseline()
+	if err != nil {
+		return fmt.Errorf("%s", tr("BaselineReadFailed", "Error", err))
+	}
+	output, lintErr := captureOutput(lint)
+	findings := lintFindings(output)
+
+	if fixed := newFindings(baseline.Findings, findings); len(fixed) > 0 {
+		info(trn("BaselineFixed", len(fixed)))
+	}
+	if lintErr == nil {
+		return nil
+	}
+	if len(findings) == 0 {
+		return fmt.Errorf("%s", tr("BaselineUnrecognized", "Error", lintErr))
+	}
+	added := newFindings(findings, baseline.Findings)
+	if len(added) == 0 {
+		info(tr("BaselineAllKnown", "File", lintBaselineFile))
+		return nil
+	}
+	fmt.Println()
+	fmt.Println(plain("❌ " + trn("BaselineNewFindings", len(added), "File", lintBaselineFile)))
+	for _, finding := range added {
+		fmt.Printf("  %s\n", finding)
+	}
+	return fmt.Errorf("%s", trn("BaselineNewLintFindings", len(added)))
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Homebrew's on_<os> and on_<arch> blocks of the nix systems, in the order
+// of the formula
+var brewPlatforms = []struct{ system, os, arch string }{
+	{"aarch64-darwin", "macos", "arm"},
+	{"x86_64-darwin", "macos", "intel"},
+	{"aarch64-linux", "linux", "arm"},
+	{"x86_64-linux", "linux", "intel"},
+}
+
+// A tap given as user/repo, which Homebrew finds at
+// github.com/user/homebrew-repo
+var brewTapShorthand = regexp.MustCompile(`^[A-Za-z0-9_.-]+/[A-Za-z0-9_.-]+$`)
+
+// Git URL of a tap: a URL or path as it is, or user/repo on GitHub
+func brewTapURL(tap string) string {
+	if !brewTapShorthand.MatchString(tap) || strings.HasPrefix(tap, ".") {
+		return tap
+	}
+	user, repo, _ := strings.Cut(tap, "/")
+	return fmt.Sprintf("https://github.com/%s/homebrew-%s", user, strings.TrimPrefix(repo, "homebrew-"))
+}
+
+// Separators of the words of a formula's class name
+var brewClassSeparators = regexp.MustCompile(`[^A-Za-z0-9]+`)
+
+// Ruby class of a formula: my-app becomes MyApp
+func brewClassName(name string) string {
+	var class strings.Builder
+	for _, part := range brewClassSeparators.Split(name, -1) {
+		if part != "" {
+			class.WriteString(strings.ToUpper(part[:1]) + part[1:])
+		}
+	}
+	return class.String()
+}
+
+// The tarball of this release's version for a system in releaseDir, if
+// any, so a formula covers the systems released from one dist/, e.g. in CI
+func (r *release) tarball(system string) (string, bool) {
+	path := filepath.Join(releaseDir, fmt.Sprintf("%s-%s-%s.tar.gz", r.Project, r.Version, system))
+	_, err := os.Stat(path)
+	return path, err == nil
+}
+
+// A Homebrew formula installing the release's binaries from the published
+// tarballs. It has no bottles: the tarballs are the builds.
+func (r *release) brewFormula(urlTemplate string, config packageConfig) (string, error) {
+	binaries, err := r.binaries()
+	if err != nil {
+		return "", err
+	}
+	var f strings.Builder
+	fmt.Fprintf(&f, "class %s < Formula\n", brewClassName(r.Project))
+	if config.Description != "" {
+		fmt.Fprintf(&f, "  desc %q\n", config.Description)
+	}
+	if config.Homepage != "" {
+		fmt.Fprintf(&f, "  homepage %q\n", config.Homepage)
+	}
+	fmt.Fprintf(&f, "  version %q\n", r.Version)
+	if config.License != "" {
+		fmt.Fprintf(&f, "  license %q\n", config.License)
+	}
+
+	var blocks []string
+	for i, platform := range brewPlatforms {
+		if tarball, ok := r.tarball(platform.system); ok {
+			sum, err := fileSHA256(tarball)
+			if err != nil {
+				return "", err
+			}
+			url := strings.NewReplacer("{version}", r.Version, "{file}", filepath.Base(tarball)).Replace(urlTemplate)
+			blocks = append(blocks, fmt.Sprintf("    on_%s do\n      url %q\n      sha256 %q\n    end\n", platform.arch, url, sum))
+		}
+		// The last of the OS's platforms
+		if i+1 == len(brewPlatforms) || brewPlatforms[i+1].os != platform.os {
+			if len(blocks) > 0 {
+				fmt.Fprintf(&f, "\n  on_%s do\n%s  end\n", platform.os, strings.Join(blocks, "\n"))
+			}
+			blocks = nil
+		}
+	}
+
+	// Homebrew enters the tarball's single directory before installing
+	f.WriteString("\n  def install\n    bin.install Dir[\"*\"]\n  end\n")
+	f.WriteString("\n  test do\n")
+	for _, binary := range binaries {
+		fmt.Fprintf(&f, "    assert_predicate bin/%q, :executable?\n", filepath.Base(binary))
+	}
+	f.WriteString("  end\nend\n")
+	return f.String(), nil
+}
+
+// Write the release's formula to a tap repository and push it
+func (r *release) publishBrewFormula(tap string) error {
+	formula, err := r.brewFormula(currentConfig.Release.URL, currentConfig.Package)
+	if err != nil {
+		return err
+	}
+	checkout, err := os.MkdirTemp("", "glot-tap-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(checkout)
+
+	info(tr("BrewUpdating", "Tap", tap))
+	if err := gitIn("", "clone", "--depth", "1", brewTapURL(tap), checkout); err != nil {
+		return fmt.Errorf("%s", tr("BrewCloneFailed", "Error", err))
+	}
+	path := filepath.Join("Formula", strings.ToLower(r.Project)+".rb")
+	if err := os.MkdirAll(filepath.Join(checkout, "Formula"), 0755); err != nil {
+		return err
+	}
+	if current, err := os.ReadFile(filepath.Join(checkout, path)); err == nil && string(current) == formula {
+		info(tr("BrewUpToDate", "Formula", path))
+		return nil
+	}
+	if err := os.WriteFile(filepath.Join(checkout, path), []byte(formula), 0644); err != nil {
+		return err
+	}
+	if err := gitIn(checkout, "add", path); err != nil {
+		return err
+	}
+	if err := gitIn(checkout, "commit", "-m", fmt.Sprintf("%s %s", strings.ToLower(r.Project), r.Version)); err != nil {
+		return err
+	}
+	if err := gitIn(checkout, "push", "origin", "HEAD"); err != nil {
+		return fmt.Errorf("%s", tr("BrewPushFailed", "Error", err))
+	}
+	success(tr("BrewPushed", "Formula", path, "Version", r.Version, "Tap", tap))
+	return nil
+}
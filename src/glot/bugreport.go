@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// Lines of the failing log included in the markdown report
+const bugReportLogLines = 80
+
+// Last recorded run that failed, if any
+func lastFailedRun() *runRecord {
+	records, err := listRunRecords()
+	if err != nil {
+		return nil
+	}
+	for _, record := range records {
+		if !record.Success {
+			return &record
+		}
+	}
+	return nil
+}
+
+// Operating system description, preferring uname when available
+func osDescription() string {
+	if out, err := exec.Command("uname", "-srm").Output(); err == nil {
+		return strings.TrimSpace(string(out))
+	}
+	return runtime.GOOS + "/" + runtime.GOARCH
+}
+
+// Last n lines of content
+func tailLines(content string, n int) string {
+	lines := strings.Split(strings.TrimRight(content, "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Collect the bug report; markdown is ready to paste into an issue and
+// files holds the full details for the bundle. Everything is redacted.
+func collectBugReport() (markdown string, files []bundleFile) {
+	var md strings.Builder
+	section := func(title, lang, body string) {
+		fmt.Fprintf(&md, "\n### %s\n\n```%s\n%s\n```\n", title, lang, strings.TrimSpace(body))
+	}
+
+	nixVersion := "not found"
+	if out, err := nixOutput("--version"); err == nil {
+		nixVersion = strings.TrimSpace(string(out))
+	}
+
+	md.WriteString("## glot bug report\n\n")
+	fmt.Fprintf(&md, "- **glot:** %s\n", version)
+	fmt.Fprintf(&md, "- **nix:** %s\n", nixVersion)
+	fmt.Fprintf(&md, "- **OS:** %s\n", osDescription())
+	if system, err := currentSystem(); err == nil {
+		fmt.Fprintf(&md, "- **system:** %s\n", system)
+	}
+
+	if content, err := os.ReadFile(configFile); err == nil {
+		section(configFile, "toml", string(content))
+		files = append(files, bundleFile{configFile, redactLog(content)})
+	}
+
+	if _, err := os.Stat("flake.nix"); err == nil {
+		if out, err := nixOutput("flake", "metadata"); err == nil {
+			section("Flake metadata", "", string(out))
+		} else {
+			section("Flake metadata", "", err.Error())
+		}
+		if out, err := nixOutput("flake", "metadata", "--json"); err == nil {
+			files = append(files, bundleFile{"flake-metadata.json", redactLog(out)})
+		}
+	}
+
+	if record := lastFailedRun(); record != nil {
+		log, err := os.ReadFile(filepath.Join(logsDir, record.ID+".log"))
+		if err == nil {
+			title := fmt.Sprintf("Last failure: glot %s (%s, %s)", record.Command, record.ID, record.Started.Format("2006-01-02 15:04"))
+			section(title, "", tailLines(string(log), bugReportLogLines))
+			files = append(files, bundleFile{record.ID + ".log", redactLog(log)})
+		}
+	} else {
+		md.WriteString("\nNo failed glot runs recorded in " + logsDir + ".\n")
+	}
+
+	if path := lastCrashReport(); path != "" {
+		// Crash reports are redacted when they are written
+		if report, err := os.ReadFile(path); err == nil {
+			section("Last crash: "+filepath.Base(path), "", tailLines(string(report), bugReportLogLines))
+			files = append(files, bundleFile{"crash-" + filepath.Base(path), report})
+		}
+	}
+
+	markdown = string(redactLog([]byte(md.String())))
+	files = append([]bundleFile{{"report.md", []byte(markdown)}}, files...)
+	return markdown, files
+}
+
+func newBugReportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "bug-report",
+		Short: "Collect details for a bug report",
+		Long: "Print a redacted markdown report with glot, nix and OS versions, project config, " +
+			"flake metadata, the last failing command's log and the last crash report, ready to paste into a GitHub issue.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkNixInstalled(); err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+
+			markdown, files := collectBugReport()
+
+			output, _ := cmd.Flags().GetString("output")
+			if output == "" {
+				fmt.Print(markdown)
+				return nil
+			}
+			dir := "glot-bug-report-" + time.Now().Format("20060102-150405")
+			if err := writeBundle(output, dir, time.Now(), files); err != nil {
+				errorMsg(tr("WriteFailed", "File", output, "Error", err))
+				return err
+			}
+			success(tr("BugReportWritten", "File", output))
+			info(tr("BugReportReview"))
+			return nil
+		},
+	}
+	cmd.Flags().StringP("output", "o", "", "Write a .tar.gz bundle with the report and full logs instead of printing markdown")
+	return cmd
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// Outcome of building one target of glot build --all
+type targetBuild struct {
+	target   string
+	err      error
+	duration time.Duration
+}
+
+// Writer prefixing each line with the target it comes from, so the output
+// of concurrent builds stays readable. Lines are written whole.
+type prefixWriter struct {
+	mu      *sync.Mutex // Shared by the writers of one output
+	out     io.Writer
+	prefix  string
+	pending []byte
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.pending = append(w.pending, p...)
+	for {
+		i := bytes.IndexByte(w.pending, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		w.mu.Lock()
+		_, err := fmt.Fprintf(w.out, "%s%s\n", w.prefix, w.pending[:i])
+		w.mu.Unlock()
+		w.pending = w.pending[i+1:]
+		if err != nil {
+			return 0, err
+		}
+	}
+}
+
+// Write out a last line without a newline
+func (w *prefixWriter) Flush() {
+	if len(w.pending) > 0 {
+		w.Write([]byte("\n"))
+	}
+}
+
+// Build every package of the flake with the given overrides, at most jobs
+// at a time (default: one per CPU), and print a summary of the builds
+func buildAll(jobs int, overrides []string) error {
+	targets, err := flakeTargets(false)
+	if err != nil {
+		return fmt.Errorf("%s", tr("DistributeListFailed", "Error", err))
+	}
+	if len(targets) == 0 {
+		return fmt.Errorf("%s", tr("BuildAllNoPackages"))
+	}
+	if jobs <= 0 {
+		jobs = runtime.NumCPU()
+	}
+	jobs = min(jobs, len(targets))
+	info(trn("BuildAllStarted", len(targets), "Jobs", jobs))
+
+	out := newRedactingWriter(logged(stderrOutput()))
+	defer out.Flush()
+	var mu sync.Mutex
+	width := 0
+	for _, target := range targets {
+		width = max(width, len(target))
+	}
+
+	results := make([]targetBuild, len(targets))
+	slots := make(chan struct{}, jobs)
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func() {
+			defer recoverCrash()
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			w := &prefixWriter{mu: &mu, out: out, prefix: fmt.Sprintf("[%-*s] ", width, target)}
+			cmd := exec.Command("nix", withNixGlobalArgs(append(append([]string{"build"}, overriddenInstallable(".#"+target, overrides)...), "--no-link", "--print-build-logs"))...)
+			cmd.Stdout = w
+			cmd.Stderr = w
+			logCommand(cmd)
+			started := time.Now()
+			err := cmd.Run()
+			w.Flush()
+			results[i] = targetBuild{target, err, time.Since(started)}
+		}()
+	}
+	wg.Wait()
+	out.Flush()
+
+	failed := 0
+	fmt.Println()
+	fmt.Println(plain("📊 " + tr("BuildAllSummary")))
+	for _, result := range results {
+		status := "✅"
+		if result.err != nil {
+			status = "❌"
+			failed++
+		}
+		fmt.Printf(plain("  %s %-*s %8s\n"), status, width, result.target, result.duration.Round(100*time.Millisecond))
+	}
+	if failed > 0 {
+		return fmt.Errorf("%s", trn("BuildAllFailed", failed, "Total", len(targets)))
+	}
+	success(trn("BuildAllCompleted", len(targets)))
+	return nil
+}
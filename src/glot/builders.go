@@ -0,0 +1,492 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// buildersConfig is the [builders] section of glot.toml: remote builders of
+// the project's nix commands, lines of nix's machines format
+type buildersConfig struct {
+	Machines []string `toml:"machines"`
+}
+
+// A remote builder, a line of nix's machines format:
+// uri systems ssh-key max-jobs speed-factor features mandatory-features host-key
+type remoteBuilder struct {
+	URI         string
+	Systems     []string
+	Key         string
+	MaxJobs     int
+	SpeedFactor int
+	Features    []string
+	Mandatory   []string
+	HostKey     string // base64 of the host's public key, as in known_hosts
+}
+
+// Parse nix's builders setting: machines separated by ; or newlines, and
+// machines files named with @
+func parseMachines(builders string) []remoteBuilder {
+	var machines []remoteBuilder
+	for _, entry := range strings.FieldsFunc(builders, func(r rune) bool { return r == ';' || r == '\n' }) {
+		entry = strings.TrimSpace(entry)
+		if file, ok := strings.CutPrefix(entry, "@"); ok {
+			if content, err := os.ReadFile(file); err == nil {
+				machines = append(machines, parseMachines(string(content))...)
+			}
+			continue
+		}
+		fields := strings.Fields(entry)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		field := func(i int) string {
+			if i < len(fields) && fields[i] != "-" {
+				return fields[i]
+			}
+			return ""
+		}
+		list := func(i int) []string {
+			if value := field(i); value != "" {
+				return strings.Split(value, ",")
+			}
+			return nil
+		}
+		machine := remoteBuilder{URI: fields[0], Systems: list(1), Key: field(2), Features: list(5), Mandatory: list(6), HostKey: field(7)}
+		machine.MaxJobs, _ = strconv.Atoi(field(3))
+		machine.SpeedFactor, _ = strconv.Atoi(field(4))
+		machines = append(machines, machine)
+	}
+	return machines
+}
+
+// The builder as a line of nix's machines format
+func (b remoteBuilder) String() string {
+	value := func(s string) string {
+		if s == "" {
+			return "-"
+		}
+		return s
+	}
+	number := func(n int) string {
+		if n <= 0 {
+			return "-"
+		}
+		return strconv.Itoa(n)
+	}
+	return strings.Join([]string{
+		b.URI, value(strings.Join(b.Systems, ",")), value(b.Key), number(b.MaxJobs), number(b.SpeedFactor),
+		value(strings.Join(b.Features, ",")), value(strings.Join(b.Mandatory, ",")), value(b.HostKey),
+	}, " ")
+}
+
+// The user@host of the builder's URI, for ssh
+func (b remoteBuilder) sshHost() string {
+	host := b.URI
+	for _, scheme := range []string{"ssh-ng://", "ssh://"} {
+		host = strings.TrimPrefix(host, scheme)
+	}
+	host, _, _ = strings.Cut(host, "?")
+	return host
+}
+
+// The store URI to reach the builder at, with its key
+func (b remoteBuilder) storeURI() string {
+	uri := b.URI
+	if !strings.Contains(uri, "://") {
+		uri = "ssh://" + uri
+	}
+	if b.Key != "" && !strings.Contains(uri, "ssh-key=") {
+		separator := "?"
+		if strings.Contains(uri, "?") {
+			separator = "&"
+		}
+		uri += separator + "ssh-key=" + b.Key
+	}
+	return uri
+}
+
+// The builders of glot's nix commands: glot.toml's, which replace the
+// machine's, or nix's own, with where they come from
+func configuredBuilders() ([]remoteBuilder, string) {
+	if len(currentConfig.Builders.Machines) > 0 {
+		return parseMachines(strings.Join(currentConfig.Builders.Machines, "\n")), configFile
+	}
+	builders, _ := nixSetting("builders")
+	return parseMachines(builders), "nix.conf"
+}
+
+// Use the builders of glot.toml for glot's nix commands
+func applyBuilders() {
+	if machines := currentConfig.Builders.Machines; len(machines) > 0 {
+		nixGlobalArgs = append(nixGlobalArgs, "--builders", strings.Join(machines, ";"))
+	}
+}
+
+// What probing a builder over ssh found
+type builderProbe struct {
+	system   string // e.g. aarch64-darwin, from uname
+	nix      string // nix --version, empty without nix on the PATH of ssh sessions
+	features []string
+}
+
+// uname -sm of the systems nix builds for
+var unameSystems = map[string]string{
+	"Linux x86_64": "x86_64-linux", "Linux aarch64": "aarch64-linux", "Linux arm64": "aarch64-linux",
+	"Linux armv7l": "armv7l-linux", "Linux i686": "i686-linux", "Linux riscv64": "riscv64-linux",
+	"Darwin x86_64": "x86_64-darwin", "Darwin arm64": "aarch64-darwin",
+}
+
+// Options of ssh for probing: never prompt, give up on unreachable hosts
+func sshArgs(b remoteBuilder) []string {
+	args := []string{"-o", "BatchMode=yes", "-o", "ConnectTimeout=10"}
+	if b.Key != "" {
+		args = append(args, "-i", b.Key)
+	}
+	return append(args, b.sshHost())
+}
+
+// Connect to the builder as ssh does for nix, and find its system, nix and
+// system features
+func probeBuilder(b remoteBuilder) (*builderProbe, error) {
+	script := `uname -sm; nix --version 2>/dev/null || echo; ` +
+		`nix --extra-experimental-features nix-command config show system-features 2>/dev/null || ` +
+		`nix --extra-experimental-features nix-command show-config 2>/dev/null | sed -n 's/^system-features = //p'`
+	cmd := exec.Command("ssh", append(sshArgs(b), script)...)
+	logCommand(cmd)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+		return nil, fmt.Errorf("%s", tr("BuildersSSHFailed", "Host", b.sshHost(), "Output", lines[len(lines)-1]))
+	}
+	lines := strings.Split(strings.TrimRight(string(out), "\n"), "\n")
+	for len(lines) < 3 {
+		lines = append(lines, "")
+	}
+	probe := &builderProbe{nix: strings.TrimSpace(lines[1]), features: strings.Fields(lines[2])}
+	system, ok := unameSystems[strings.TrimSpace(lines[0])]
+	if !ok {
+		return nil, fmt.Errorf("%s", tr("BuildersUnknownSystem", "Host", b.sshHost(), "Uname", strings.TrimSpace(lines[0])))
+	}
+	probe.system = system
+	return probe, nil
+}
+
+// The host's ed25519 key, base64 encoded for the machines format, so the
+// nix daemon, which connects as root, needs no known_hosts entry
+func builderHostKey(b remoteBuilder) string {
+	host := b.sshHost()
+	if _, after, ok := strings.Cut(host, "@"); ok {
+		host = after
+	}
+	out, err := exec.Command("ssh-keyscan", "-t", "ed25519", host).Output()
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		if fields := strings.Fields(line); len(fields) == 3 && !strings.HasPrefix(line, "#") {
+			return base64.StdEncoding.EncodeToString([]byte(fields[1] + " " + fields[2]))
+		}
+	}
+	return ""
+}
+
+// Check the builder end to end: ssh, then nix's own connection to its store
+func testBuilder(b remoteBuilder) (*builderProbe, error) {
+	probe, err := probeBuilder(b)
+	if err != nil {
+		return nil, err
+	}
+	if probe.nix == "" {
+		return probe, fmt.Errorf("%s", tr("BuildersNoNix", "Host", b.sshHost()))
+	}
+	if _, err := nixOutput("store", "info", "--store", b.storeURI()); err != nil {
+		if _, err := nixOutput("store", "ping", "--store", b.storeURI()); err != nil {
+			return probe, fmt.Errorf("%s", tr("BuildersStoreUnreachable", "URI", b.URI, "Error", err))
+		}
+	}
+	return probe, nil
+}
+
+// Whether the nix daemon trusts this user, who can only then pass builders;
+// unknown with nix before store info --json
+func nixUserTrusted() (trusted, known bool) {
+	out, err := nixOutput("store", "info", "--json")
+	if err != nil {
+		return false, false
+	}
+	var info struct {
+		Trusted *int `json:"trusted"`
+	}
+	if json.Unmarshal(out, &info) != nil || info.Trusted == nil {
+		return false, false
+	}
+	return *info.Trusted == 1, true
+}
+
+// The user's nix.conf
+func userNixConf() string {
+	if files := os.Getenv("NIX_USER_CONF_FILES"); files != "" {
+		return filepath.SplitList(files)[0]
+	}
+	return filepath.Join(configHome(), "nix", "nix.conf")
+}
+
+// Machines file of the builders glot adds for the user, which the user's
+// nix.conf names in builders
+func userMachinesFile() string {
+	return filepath.Join(configHome(), "glot", "machines")
+}
+
+var nixConfBuilders = regexp.MustCompile(`(?m)^\s*builders\s*=\s*(.*)$`)
+
+// Point the user's nix.conf at the machines file, with substitutes on the
+// builders, unless it names other builders
+func linkUserMachinesFile() error {
+	path := userNixConf()
+	content, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	text := string(content)
+	setting := "@" + userMachinesFile()
+	if m := nixConfBuilders.FindStringSubmatch(text); m != nil {
+		if strings.TrimSpace(m[1]) == setting {
+			return nil
+		}
+		return fmt.Errorf("%s", tr("BuildersNixConfTaken", "Path", path, "Builders", strings.TrimSpace(m[1]), "Setting", setting))
+	}
+	if text != "" && !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	text += "builders = " + setting + "\n"
+	if !regexp.MustCompile(`(?m)^\s*builders-use-substitutes\s*=`).MatchString(text) {
+		text += "builders-use-substitutes = true\n"
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(text), 0644)
+}
+
+// Replace the builders of a host in the machines, adding b
+func withBuilder(machines []string, b remoteBuilder) []string {
+	var kept []string
+	for _, machine := range parseMachines(strings.Join(machines, "\n")) {
+		if machine.sshHost() != b.sshHost() {
+			kept = append(kept, machine.String())
+		}
+	}
+	return append(kept, b.String())
+}
+
+// Save machines to the user's machines file or glot.toml
+func saveMachines(project bool, machines []string) error {
+	if project {
+		return writeConfigSection(configFile, "builders", buildersConfig{Machines: machines})
+	}
+	path := userMachinesFile()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	content := "# Remote builders added by glot builders add\n" + strings.Join(machines, "\n") + "\n"
+	return os.WriteFile(path, []byte(content), 0644)
+}
+
+// The machines glot manages, of glot.toml or the user's machines file
+func managedMachines(project bool) []string {
+	if project {
+		return currentConfig.Builders.Machines
+	}
+	content, _ := os.ReadFile(userMachinesFile())
+	var machines []string
+	for _, machine := range parseMachines(string(content)) {
+		machines = append(machines, machine.String())
+	}
+	return machines
+}
+
+func newBuildersCmd() *cobra.Command {
+	buildersCmd := &cobra.Command{
+		Use:   "builders",
+		Short: "Set up and check remote builders",
+		Long: "Add remote machines nix builds on, for other systems (a Mac for darwin packages) or more capacity, " +
+			"and check that they still work.",
+	}
+
+	addCmd := &cobra.Command{
+		Use:   "add <user@host>",
+		Short: "Add a remote builder after checking it",
+		Long: "Check that ssh reaches the host without a prompt and that it has nix, detect its system and features, and add it as a builder: " +
+			"to a machines file the user's nix.conf names, or with --project to glot.toml, for glot's nix commands in the project.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkNixInstalled(); err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			uri := args[0]
+			if !strings.Contains(uri, "://") {
+				uri = "ssh-ng://" + uri
+			}
+			builder := remoteBuilder{URI: uri, SpeedFactor: 1}
+			builder.Key, _ = cmd.Flags().GetString("key")
+			builder.MaxJobs, _ = cmd.Flags().GetInt("remote-jobs")
+			builder.SpeedFactor, _ = cmd.Flags().GetInt("speed-factor")
+			systems, _ := cmd.Flags().GetStringSlice("system")
+			project, _ := cmd.Flags().GetBool("project")
+
+			info(tr("BuildersConnecting", "Host", builder.sshHost()))
+			probe, err := testBuilder(builder)
+			if err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			success(tr("BuildersProbed", "Host", builder.sshHost(), "System", probe.system, "Nix", probe.nix))
+			if len(systems) == 0 {
+				systems = []string{probe.system}
+			}
+			builder.Systems = systems
+			builder.Features = probe.features
+			if builder.MaxJobs <= 0 {
+				builder.MaxJobs = 1
+			}
+			if builder.HostKey = builderHostKey(builder); builder.HostKey == "" {
+				warning(tr("BuildersNoHostKey", "Host", builder.sshHost()))
+			}
+
+			if !project {
+				if err := linkUserMachinesFile(); err != nil {
+					errorMsg(err.Error())
+					return err
+				}
+			}
+			if err := saveMachines(project, withBuilder(managedMachines(project), builder)); err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			if project {
+				success(tr("BuildersAddedProject", "URI", builder.URI, "Config", configFile, "Systems", strings.Join(systems, ", ")))
+			} else {
+				success(tr("BuildersAdded", "URI", builder.URI, "File", userMachinesFile(), "Systems", strings.Join(systems, ", ")))
+			}
+			if trusted, known := nixUserTrusted(); known && !trusted {
+				warning(tr("BuildersUntrusted"))
+			}
+			if builder.Key != "" {
+				info(tr("BuildersKeyReadable", "Key", builder.Key))
+			}
+			return nil
+		},
+	}
+	addCmd.Flags().StringSlice("system", nil, "Systems to build on the host (default: its own)")
+	addCmd.Flags().String("key", "", "SSH private key to connect with (default: ssh's)")
+	addCmd.Flags().Int("remote-jobs", 1, "Builds the host runs at once, its max-jobs")
+	addCmd.Flags().Int("speed-factor", 1, "Preference for the host over slower builders")
+	addCmd.Flags().Bool("project", false, "Add the builder to glot.toml, for glot's nix commands in this project")
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the remote builders",
+		Long:  "List the builders of glot's nix commands: those of glot.toml, which replace the machine's, or nix's own.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			builders, source := configuredBuilders()
+			if len(builders) == 0 {
+				info(tr("BuildersNone"))
+				return nil
+			}
+			info(tr("BuildersFrom", "Source", source))
+			for _, b := range builders {
+				capacity := tr("BuildersRow", "Jobs", max(b.MaxJobs, 1), "Speed", max(b.SpeedFactor, 1))
+				fmt.Printf("  %-32s %-28s %-20s %s\n", b.URI, strings.Join(b.Systems, ","), capacity, strings.Join(b.Features, ","))
+			}
+			return nil
+		},
+	}
+
+	testCmd := &cobra.Command{
+		Use:   "test [host...]",
+		Short: "Check that the remote builders work",
+		Long:  "Connect to each builder, or those given, over ssh and with nix, and check that it builds the systems it is configured for.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkNixInstalled(); err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			builders, _ := configuredBuilders()
+			failed := 0
+			tested := 0
+			for _, b := range builders {
+				if len(args) > 0 && !slices.Contains(args, b.sshHost()) && !slices.Contains(args, b.URI) {
+					continue
+				}
+				tested++
+				started := time.Now()
+				probe, err := testBuilder(b)
+				if err != nil {
+					logMessage(levelError, "error", "❌ ", fmt.Sprintf("%s: %s", b.URI, err))
+					failed++
+					continue
+				}
+				if len(b.Systems) > 0 && !slices.Contains(b.Systems, probe.system) {
+					warning(tr("BuildersWrongSystem", "URI", b.URI, "System", probe.system, "Systems", strings.Join(b.Systems, ",")))
+					continue
+				}
+				success(fmt.Sprintf("%s: %s, %s (%s)", b.URI, probe.system, probe.nix, time.Since(started).Round(time.Millisecond)))
+			}
+			if tested == 0 {
+				err := fmt.Errorf("%s", tr("BuildersNoneToTest"))
+				errorMsg(err.Error())
+				return err
+			}
+			if failed > 0 {
+				err := fmt.Errorf("%s", tr("BuildersFailed", "Failed", failed, "Tested", tested))
+				errorMsg(err.Error())
+				return err
+			}
+			return nil
+		},
+	}
+
+	removeCmd := &cobra.Command{
+		Use:   "remove <user@host>",
+		Short: "Remove a remote builder glot added",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			project, _ := cmd.Flags().GetBool("project")
+			var kept []string
+			for _, machine := range parseMachines(strings.Join(managedMachines(project), "\n")) {
+				if machine.sshHost() != args[0] && machine.URI != args[0] {
+					kept = append(kept, machine.String())
+				}
+			}
+			if len(kept) == len(managedMachines(project)) {
+				err := fmt.Errorf("%s", tr("BuildersNotAdded", "Builder", args[0]))
+				errorMsg(err.Error())
+				return err
+			}
+			if err := saveMachines(project, kept); err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			success(tr("BuildersRemoved", "Builder", args[0]))
+			return nil
+		},
+	}
+	removeCmd.Flags().Bool("project", false, "Remove the builder from glot.toml")
+
+	buildersCmd.AddCommand(addCmd, listCmd, testCmd, removeCmd)
+	return buildersCmd
+}
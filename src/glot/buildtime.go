@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// Durations of the successful builds of each installable, newest last
+var buildHistoryFile = filepath.Join(stateDir, "build-history.json")
+
+// How many builds of an installable the history keeps, and the ETA averages
+const (
+	buildHistoryLimit = 20
+	buildETASamples   = 5
+)
+
+type buildHistoryEntry struct {
+	Finished   time.Time `json:"finished"`
+	DurationMs int64     `json:"duration_ms"`
+	Commit     string    `json:"commit,omitempty"`
+	// Derivations built and store paths downloaded, known when nix's log
+	// was rendered
+	Built      *int64 `json:"built,omitempty"`
+	Downloaded *int64 `json:"downloaded,omitempty"`
+}
+
+func loadBuildHistory() map[string][]buildHistoryEntry {
+	history := map[string][]buildHistoryEntry{}
+	if content, err := os.ReadFile(buildHistoryFile); err == nil {
+		json.Unmarshal(content, &history)
+	}
+	return history
+}
+
+// Add a successful build of installable to the history. Problems writing
+// it never fail the build.
+func recordBuildTime(installable string, duration time.Duration, summary *nixBuildSummary) {
+	history := loadBuildHistory()
+	entry := buildHistoryEntry{Finished: time.Now(), DurationMs: duration.Milliseconds(), Commit: gitCommit()}
+	if summary != nil {
+		entry.Built, entry.Downloaded = &summary.Built, &summary.Downloaded
+	}
+	entries := append(history[installable], entry)
+	if len(entries) > buildHistoryLimit {
+		entries = entries[len(entries)-buildHistoryLimit:]
+	}
+	history[installable] = entries
+	if err := ensureStateDir(stateDir); err != nil {
+		debug(fmt.Sprintf("Not recording the build time: %s", err))
+		return
+	}
+	content, _ := json.MarshalIndent(history, "", "  ")
+	if err := os.WriteFile(buildHistoryFile, append(content, '\n'), 0644); err != nil {
+		debug(fmt.Sprintf("Not recording the build time: %s", err))
+	}
+}
+
+// How long the last build of installable took, and the average of the
+// recent ones to estimate the next; false without history
+func buildTimes(installable string) (last, estimate time.Duration, ok bool) {
+	entries := loadBuildHistory()[installable]
+	if len(entries) == 0 {
+		return 0, 0, false
+	}
+	recent := entries[max(0, len(entries)-buildETASamples):]
+	var total int64
+	for _, entry := range recent {
+		total += entry.DurationMs
+	}
+	last = time.Duration(entries[len(entries)-1].DurationMs) * time.Millisecond
+	estimate = time.Duration(total/int64(len(recent))) * time.Millisecond
+	return last, estimate, true
+}
+
+// The live line of a running build at the bottom of the terminal: how long
+// it has been running, and how long it will probably take. Output written
+// through statusWriter goes above it.
+type buildStatus struct {
+	mu       sync.Mutex
+	started  time.Time
+	estimate time.Duration // Zero without history
+	detail   string        // What nix is doing, from its log
+	drawn    bool
+	midLine  bool
+	done     chan struct{}
+}
+
+// The build status being shown, nil if none
+var activeStatus atomic.Pointer[buildStatus]
+
+// Whether the terminal can show a status line: stderr is one, and glot's
+// output isn't plain, quiet or machine-readable
+func statusLineEnabled() bool {
+	stat, err := os.Stderr.Stat()
+	return err == nil && stat.Mode()&os.ModeCharDevice != 0 &&
+		!plainOutput && jsonOut == nil && progressOut == nil && verbosity >= levelInfo
+}
+
+// Show the status line until stop, if the terminal can
+func startBuildStatus(estimate time.Duration) *buildStatus {
+	if !statusLineEnabled() {
+		return nil
+	}
+	s := &buildStatus{started: time.Now(), estimate: estimate, done: make(chan struct{})}
+	activeStatus.Store(s)
+	go func() {
+		defer recoverCrash()
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.done:
+				return
+			case <-ticker.C:
+				s.mu.Lock()
+				if !s.midLine {
+					s.draw()
+				}
+				s.mu.Unlock()
+			}
+		}
+	}()
+	return s
+}
+
+// Remove the status line. Safe on nil.
+func (s *buildStatus) stop() {
+	if s == nil {
+		return
+	}
+	activeStatus.Store(nil)
+	close(s.done)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clear()
+}
+
+func (s *buildStatus) draw() {
+	elapsed := time.Since(s.started).Round(time.Second)
+	text := tr("BuildStatusElapsed", "Elapsed", elapsed)
+	switch {
+	case s.estimate == 0:
+	case elapsed < s.estimate:
+		text = tr("BuildStatusETA", "Elapsed", elapsed, "Remaining", (s.estimate - elapsed).Round(time.Second))
+	default:
+		text = tr("BuildStatusOverdue", "Elapsed", elapsed, "Estimate", s.estimate.Round(time.Second))
+	}
+	if s.detail != "" {
+		text += " · " + s.detail
+	}
+	// A line wider than the terminal wraps, and can't be cleared with \r
+	line := []rune("⏱️  " + text)
+	if width := terminalWidth(); len(line) >= width {
+		line = append(line[:width-2], '…')
+	}
+	fmt.Fprint(os.Stderr, "\r\033[K"+string(line))
+	s.drawn = true
+}
+
+// Set what nix is doing, and show it now if draw
+func (s *buildStatus) setDetail(detail string, draw bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.detail = detail
+	if draw && !s.midLine {
+		s.draw()
+	}
+}
+
+// Columns of the terminal on stderr, 80 if it can't tell
+func terminalWidth() int {
+	var size struct{ rows, cols, x, y uint16 }
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, os.Stderr.Fd(), uintptr(syscall.TIOCGWINSZ), uintptr(unsafe.Pointer(&size)))
+	if errno != 0 || size.cols == 0 {
+		return 80
+	}
+	return int(size.cols)
+}
+
+func (s *buildStatus) clear() {
+	if s.drawn {
+		fmt.Fprint(os.Stderr, "\r\033[K")
+		s.drawn = false
+	}
+}
+
+// Writer that clears the status line for the output, and draws it again
+// below once a line is complete
+type statusWriter struct {
+	status *buildStatus
+	w      io.Writer
+}
+
+func (w statusWriter) Write(p []byte) (int, error) {
+	w.status.mu.Lock()
+	defer w.status.mu.Unlock()
+	w.status.clear()
+	n, err := w.w.Write(p)
+	if len(p) > 0 {
+		w.status.midLine = p[len(p)-1] != '\n'
+	}
+	if !w.status.midLine {
+		w.status.draw()
+	}
+	return n, err
+}
+
+// w, or while a build status is shown, w writing above it
+func withBuildStatus(w io.Writer) io.Writer {
+	if s := activeStatus.Load(); s != nil {
+		return statusWriter{s, w}
+	}
+	return w
+}
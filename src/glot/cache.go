@@ -0,0 +1,396 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/spf13/cobra"
+)
+
+// Local, uncommitted cache state: push config, credentials and signing keys
+var (
+	cacheDir       = filepath.Join(stateDir, "cache")
+	cachePushFile  = filepath.Join(stateDir, "cache.toml")
+	cacheCredsFile = filepath.Join(cacheDir, "credentials")
+)
+
+const defaultS3Region = "us-east-1"
+
+// Endpoint for Google Cloud Storage's S3-compatible API (used with HMAC keys)
+const gcsEndpoint = "storage.googleapis.com"
+
+// cacheConfig is the [cache] section of glot.toml, committed so everyone on
+// the project pulls from the same binary cache
+type cacheConfig struct {
+	Type        string `toml:"type"`        // s3, gcs, attic or cachix
+	Substituter string `toml:"substituter"` // Store URL nix substitutes from
+	PublicKey   string `toml:"public-key"`  // Keys the cache's paths are signed with, separated by spaces
+}
+
+// cachePushConfig is .glot/cache.toml, the local push side of the cache
+type cachePushConfig struct {
+	Type      string `toml:"type"`
+	URL       string `toml:"url"`                  // Store URL to copy to
+	SecretKey string `toml:"secret-key,omitempty"` // Signing key for pushed paths
+	Profile   string `toml:"profile,omitempty"`    // AWS profile in the credentials file
+}
+
+// Pull from the project's binary cache in every nix invocation
+func applyCacheOption() {
+	if currentConfig.Cache.Substituter == "" {
+		return
+	}
+	nixGlobalArgs = append(nixGlobalArgs, "--extra-substituters", currentConfig.Cache.Substituter)
+	if currentConfig.Cache.PublicKey != "" {
+		nixGlobalArgs = append(nixGlobalArgs, "--extra-trusted-public-keys", currentConfig.Cache.PublicKey)
+	}
+}
+
+// Build an s3:// store URL for nix
+func s3StoreURL(bucket, region, endpoint, profile string) string {
+	query := url.Values{}
+	if region != "" {
+		query.Set("region", region)
+	}
+	if endpoint != "" {
+		query.Set("endpoint", endpoint)
+	}
+	if profile != "" {
+		query.Set("profile", profile)
+	}
+	if len(query) == 0 {
+		return "s3://" + bucket
+	}
+	return "s3://" + bucket + "?" + query.Encode()
+}
+
+// Add a query parameter to a store URL
+func withStoreParam(storeURL, key, value string) string {
+	separator := "?"
+	if strings.Contains(storeURL, "?") {
+		separator = "&"
+	}
+	return storeURL + separator + key + "=" + url.QueryEscape(value)
+}
+
+// Generate a nix signing key pair, returning the public key
+func generateSigningKey(name, secretPath string) (string, error) {
+	secret, err := nixOutput("key", "generate-secret", "--key-name", name)
+	if err != nil {
+		return "", fmt.Errorf("%s", tr("CacheKeyGenerateFailed", "Error", err))
+	}
+	if err := os.WriteFile(secretPath, secret, 0600); err != nil {
+		return "", err
+	}
+	cmd := exec.Command("nix", withNixGlobalArgs([]string{"key", "convert-secret-to-public"})...)
+	cmd.Stdin = bytes.NewReader(secret)
+	public, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("%s", tr("CachePublicKeyFailed", "Error", err))
+	}
+	return strings.TrimSpace(string(public)), nil
+}
+
+// Write AWS-style credentials for pushing, readable only by the user
+func writeCacheCredentials(profile, accessKey, secretKey string) error {
+	if err := os.MkdirAll(cacheDir, 0700); err != nil {
+		return err
+	}
+	content := fmt.Sprintf("[%s]\naws_access_key_id = %s\naws_secret_access_key = %s\n", profile, accessKey, secretKey)
+	return os.WriteFile(cacheCredsFile, []byte(content), 0600)
+}
+
+// Replace (or add) a [section] in a TOML file, keeping the rest of the file
+// and its comments intact
+func writeConfigSection(path, section string, value interface{}) error {
+	var body strings.Builder
+	if err := toml.NewEncoder(&body).Encode(value); err != nil {
+		return err
+	}
+	block := fmt.Sprintf("[%s]\n%s", section, body.String())
+
+	existing, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	content := string(existing)
+	header := regexp.MustCompile(`(?m)^\[` + regexp.QuoteMeta(section) + `\]\s*$`)
+	if loc := header.FindStringIndex(content); loc != nil {
+		end := len(content)
+		if next := regexp.MustCompile(`(?m)^\[`).FindStringIndex(content[loc[1]:]); next != nil {
+			end = loc[1] + next[0]
+		}
+		content = content[:loc[0]] + block + "\n" + content[end:]
+	} else {
+		if content != "" && !strings.HasSuffix(content, "\n\n") {
+			content = strings.TrimRight(content, "\n") + "\n\n"
+		}
+		content += block
+	}
+	return os.WriteFile(path, []byte(strings.TrimRight(content, "\n")+"\n"), 0644)
+}
+
+// Save the local push configuration
+func writeCachePushConfig(push cachePushConfig) error {
+	if err := ensureStateDir(stateDir); err != nil {
+		return err
+	}
+	f, err := os.Create(cachePushFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return toml.NewEncoder(f).Encode(push)
+}
+
+// Load the local push configuration written by `glot cache setup`
+func loadCachePushConfig() (*cachePushConfig, error) {
+	var config cachePushConfig
+	if _, err := toml.DecodeFile(cachePushFile, &config); err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%s", tr("CacheNoPushConfig"))
+		}
+		return nil, fmt.Errorf("%s", tr("ConfigInvalid", "File", cachePushFile, "Error", err))
+	}
+	return &config, nil
+}
+
+// Interactive setup of an S3 (or S3-compatible GCS) binary cache
+func setupObjectStoreCache(cmd *cobra.Command, kind string) error {
+	flag := func(name string) string {
+		value, _ := cmd.Flags().GetString(name)
+		return value
+	}
+
+	fmt.Printf("%s\n\n", tr("CacheSetupIntro", "Kind", strings.ToUpper(kind)))
+
+	bucket := flag("bucket")
+	if bucket == "" {
+		bucket = prompt(tr("CacheBucketPrompt"), "")
+	}
+	if bucket == "" {
+		return fmt.Errorf("%s", tr("CacheBucketRequired"))
+	}
+
+	region, endpoint := flag("region"), flag("endpoint")
+	if kind == "gcs" {
+		endpoint = gcsEndpoint
+	} else {
+		if region == "" {
+			region = prompt(tr("CacheRegionPrompt"), defaultS3Region)
+		}
+		if endpoint == "" {
+			endpoint = prompt(tr("CacheEndpointPrompt"), "")
+		}
+	}
+
+	keyName := flag("key-name")
+	if keyName == "" {
+		keyName = prompt(tr("CacheKeyNamePrompt"), bucket+"-1")
+	}
+
+	profile := flag("profile")
+	accessKey := flag("access-key-id")
+	if accessKey == "" {
+		label := tr("CacheAccessKeyPrompt")
+		if kind == "gcs" {
+			label = tr("CacheHMACKeyPrompt")
+		}
+		accessKey = prompt(label, "")
+	}
+	secretAccessKey := ""
+	if accessKey != "" {
+		if profile == "" {
+			profile = "glot-cache"
+		}
+		secretAccessKey = os.Getenv("GLOT_CACHE_SECRET_ACCESS_KEY")
+		if secretAccessKey == "" {
+			secretAccessKey = prompt(tr("CacheSecretPrompt"), "")
+		}
+		if secretAccessKey == "" {
+			return fmt.Errorf("%s", tr("CacheSecretRequired"))
+		}
+	} else if profile == "" {
+		profile = prompt(tr("CacheProfilePrompt"), "default")
+	}
+	fmt.Println()
+
+	if err := ensureStateDir(cacheDir); err != nil {
+		return err
+	}
+	secretPath, err := filepath.Abs(filepath.Join(cacheDir, keyName+".sec"))
+	if err != nil {
+		return err
+	}
+	info(tr("CacheGeneratingKey", "Key", keyName))
+	publicKey, err := generateSigningKey(keyName, secretPath)
+	if err != nil {
+		return err
+	}
+
+	if accessKey != "" {
+		if err := writeCacheCredentials(profile, accessKey, secretAccessKey); err != nil {
+			return fmt.Errorf("%s", tr("CacheCredentialsFailed", "Error", err))
+		}
+		success(tr("CacheCredentialsWritten", "File", cacheCredsFile))
+	}
+
+	storeURL := s3StoreURL(bucket, region, endpoint, profile)
+	push := cachePushConfig{Type: kind, URL: storeURL, SecretKey: secretPath, Profile: profile}
+	if err := writeCachePushConfig(push); err != nil {
+		return err
+	}
+	success(tr("CachePushConfigWritten", "File", cachePushFile))
+
+	// Pulling needs no profile of the pusher's
+	pull := cacheConfig{Type: kind, Substituter: s3StoreURL(bucket, region, endpoint, ""), PublicKey: publicKey}
+	if err := writeConfigSection(configFile, "cache", pull); err != nil {
+		return fmt.Errorf("%s", tr("CacheConfigFailed", "Config", configFile, "Error", err))
+	}
+	success(tr("CacheSubstituterWritten", "Config", configFile))
+
+	fmt.Println()
+	info(tr("CacheFlakeConfig"))
+	fmt.Printf("\n  nixConfig = {\n    extra-substituters = [ \"%s\" ];\n    extra-trusted-public-keys = [ \"%s\" ];\n  };\n\n", pull.Substituter, publicKey)
+	info(tr("CacheTrustedUsers"))
+	info(tr("CachePushHint"))
+	return nil
+}
+
+// Copy build results, and with shell the dev shell's closure, to the
+// configured cache
+func pushToCache(installables []string, shell bool) error {
+	push, err := loadCachePushConfig()
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(cacheCredsFile); err == nil {
+		os.Setenv("AWS_SHARED_CREDENTIALS_FILE", cacheCredsFile)
+	}
+
+	var paths []string
+	if len(installables) > 0 {
+		info(tr("CacheBuildingPaths"))
+		out, err := nixOutput(append([]string{"build", "--no-link", "--print-out-paths"}, installables...)...)
+		if err != nil {
+			return fmt.Errorf("%s", tr("CacheBuildFailed", "Error", err))
+		}
+		paths = strings.Fields(string(out))
+	}
+	if shell {
+		closure, err := devShellClosure()
+		if err != nil {
+			return err
+		}
+		paths = append(paths, closure)
+	}
+
+	switch push.Type {
+	case "attic":
+		info(trn("CachePushingAttic", len(paths), "Cache", push.URL))
+		return runAttic(append([]string{"push", push.URL}, paths...)...)
+	case "cachix":
+		info(trn("CachePushingCachix", len(paths), "Cache", push.URL))
+		return runCachix(append([]string{"push", push.URL}, paths...)...)
+	}
+
+	target := push.URL
+	if push.SecretKey != "" {
+		target = withStoreParam(target, "secret-key", push.SecretKey)
+	}
+	info(trn("CachePushing", len(paths), "Cache", push.URL))
+	return runNix(append([]string{"copy", "--to", target}, paths...)...)
+}
+
+// The default package and dev shell, which glot export copies
+func defaultPushInstallables() []string {
+	installables := []string{"."}
+	if system, err := currentSystem(); err == nil {
+		installables = append(installables, fmt.Sprintf(".#devShells.%s.default", system))
+	}
+	return installables
+}
+
+// Installables of glot cache push's arguments: flake outputs by name, like
+// release, or installables as nix takes them
+func pushInstallables(args []string) []string {
+	var installables []string
+	for _, arg := range args {
+		if !strings.ContainsAny(arg, "#:/") && !strings.HasPrefix(arg, ".") {
+			arg = ".#" + arg
+		}
+		installables = append(installables, arg)
+	}
+	return installables
+}
+
+func newCacheCmd() *cobra.Command {
+	cacheCmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Manage the project's binary cache",
+		Long:  "Set up and push to a binary cache (S3, GCS, attic or Cachix) shared by everyone working on the project.",
+	}
+
+	setupCmd := &cobra.Command{
+		Use:       "setup <s3|gcs>",
+		Short:     "Set up an S3 or GCS binary cache",
+		Long:      "Walk through bucket configuration and signing key generation, then write push credentials locally (.glot/) and the substituter to glot.toml.",
+		Args:      cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		ValidArgs: []string{"s3", "gcs"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkNix(); err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			if err := setupObjectStoreCache(cmd, args[0]); err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			success(tr("CacheConfigured"))
+			return nil
+		},
+	}
+	setupCmd.Flags().String("bucket", "", "Bucket name")
+	setupCmd.Flags().String("region", "", "Bucket region (S3)")
+	setupCmd.Flags().String("endpoint", "", "S3-compatible endpoint, e.g. for MinIO or R2")
+	setupCmd.Flags().String("profile", "", "AWS profile to push with")
+	setupCmd.Flags().String("access-key-id", "", "Access key ID to store for pushing (secret from GLOT_CACHE_SECRET_ACCESS_KEY or prompt)")
+	setupCmd.Flags().String("key-name", "", "Name of the signing key (default: <bucket>-1)")
+
+	pushCmd := &cobra.Command{
+		Use:   "push [targets...]",
+		Short: "Push build results to the binary cache",
+		Long: "Build the given targets or installables (default: the default package and the dev shell) and copy them, " +
+			"with everything they need, signed, to the configured cache. The dev shell is pushed as the closure nix develop " +
+			"needs, so CI and new teammates download the whole shell instead of building it.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkNix(); err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			shell, _ := cmd.Flags().GetBool("shell")
+			if len(args) == 0 {
+				args, shell = []string{"."}, true
+			}
+			if err := pushToCache(pushInstallables(args), shell); err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			success(tr("CachePushed"))
+			return nil
+		},
+	}
+
+	pushCmd.Flags().Bool("shell", false, "Also push the dev shell's closure (default without targets)")
+
+	cacheCmd.AddCommand(setupCmd, pushCmd, newCacheLoginCmd(), newCacheUseCmd())
+	return cacheCmd
+}
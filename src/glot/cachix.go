@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Cachix's API, which describes a cache
+const cachixAPI = "https://app.cachix.org/api/v1/cache/"
+
+// Run the cachix client, from PATH or nixpkgs if it isn't installed
+func runCachix(args ...string) error {
+	if _, err := exec.LookPath("cachix"); err == nil {
+		return runTool("cachix", args...)
+	}
+	return runNix(append([]string{"run", "nixpkgs#cachix", "--"}, args...)...)
+}
+
+// A Cachix cache, as its API describes it
+type cachixCache struct {
+	URI        string   `json:"uri"`
+	PublicKeys []string `json:"publicSigningKeys"`
+	Public     bool     `json:"isPublic"`
+}
+
+// Look up a Cachix cache. Private caches need CACHIX_AUTH_TOKEN.
+func cachixCacheInfo(name string) (*cachixCache, error) {
+	if offlineMode {
+		return nil, fmt.Errorf("%s", tr("CachixOffline", "Cache", name))
+	}
+	req, err := http.NewRequest("GET", cachixAPI+name, nil)
+	if err != nil {
+		return nil, err
+	}
+	if token := os.Getenv("CACHIX_AUTH_TOKEN"); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := (&http.Client{Timeout: 30 * time.Second}).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s", tr("CachixQueryFailed", "Error", err))
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusUnauthorized:
+		return nil, fmt.Errorf("%s", tr("CachixNoCache", "Cache", name))
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("%s", tr("CachixQueryFailed", "Error", resp.Status))
+	}
+	var cache cachixCache
+	if err := json.NewDecoder(resp.Body).Decode(&cache); err != nil {
+		return nil, fmt.Errorf("%s", tr("CachixBadResponse", "Error", err))
+	}
+	if cache.URI == "" {
+		cache.URI = "https://" + name + ".cachix.org"
+	}
+	return &cache, nil
+}
+
+// Store a Cachix auth token for pushing, from the argument, CACHIX_AUTH_TOKEN
+// or a prompt
+func loginCachix(args []string) error {
+	token := ""
+	if len(args) > 0 {
+		token = args[0]
+	} else if token = os.Getenv("CACHIX_AUTH_TOKEN"); token == "" {
+		token = prompt(tr("CachixTokenPrompt"), "")
+	}
+	if token == "" {
+		err := fmt.Errorf("%s", tr("CachixTokenRequired"))
+		errorMsg(err.Error())
+		return err
+	}
+	if err := runCachix("authtoken", token); err != nil {
+		errorMsg(tr("CachixTokenFailed"))
+		return err
+	}
+	success(tr("CachixLoggedIn"))
+	info(tr("CachixLoginNext"))
+	return nil
+}
+
+// Use a Cachix cache for the project: pull from it in glot's nix commands
+// and push to it
+func useCachixCache(name string) error {
+	cache, err := cachixCacheInfo(name)
+	if err != nil {
+		return err
+	}
+	if !cache.Public {
+		// Writes the netrc nix needs to read a private cache
+		if err := runCachix("use", name); err != nil {
+			warning(tr("CachixUseFailed"))
+		}
+	}
+	if err := writeCachePushConfig(cachePushConfig{Type: "cachix", URL: name}); err != nil {
+		return err
+	}
+	pull := cacheConfig{Type: "cachix", Substituter: cache.URI, PublicKey: strings.Join(cache.PublicKeys, " ")}
+	if err := writeConfigSection(configFile, "cache", pull); err != nil {
+		return fmt.Errorf("%s", tr("CacheConfigFailed", "Config", configFile, "Error", err))
+	}
+	success(tr("CachixUsing", "Cache", name, "Config", configFile))
+	info(tr("CacheFlakeConfig"))
+	fmt.Printf("\n  nixConfig = {\n    extra-substituters = [ \"%s\" ];\n    extra-trusted-public-keys = [ \"%s\" ];\n  };\n\n", pull.Substituter, strings.Join(cache.PublicKeys, "\" \""))
+	info(tr("CachixPushHint"))
+	return nil
+}
+
+// The dev shell's closure: the profile glot keeps of it, which holds every
+// package nix develop needs, not only the shell derivation's small output
+func devShellClosure() (string, error) {
+	if _, err := cachedShellEnv("", false); err != nil {
+		return "", fmt.Errorf("%s", tr("CachixNoDevShell", "Error", err))
+	}
+	path, err := filepath.EvalSymlinks(filepath.Join(shellEnvDir, "default-profile"))
+	if err != nil {
+		return "", fmt.Errorf("%s", tr("CachixNoProfile", "Error", err))
+	}
+	return path, nil
+}
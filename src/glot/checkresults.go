@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// The results of the last glot check on each branch, for glot check
+// --diff-last
+var checkResultsDir = filepath.Join(stateDir, "check-results")
+
+// A glot check run: the outcome and findings of each step it got to
+type checkRun struct {
+	Branch string            `json:"branch"`
+	Commit string            `json:"commit"`
+	Time   time.Time         `json:"time"`
+	Steps  []checkStepResult `json:"steps"`
+}
+
+type checkStepResult struct {
+	Step   string `json:"step"`
+	Passed bool   `json:"passed"`
+	// Finding ("file: message", without line numbers, as in the lint
+	// baseline) to how often the step's output had it
+	Findings map[string]int `json:"findings,omitempty"`
+}
+
+// The branch checked out, "detached" without one
+func checkBranch() string {
+	out, err := exec.Command("git", "symbolic-ref", "--short", "-q", "HEAD").Output()
+	if branch := strings.TrimSpace(string(out)); err == nil && branch != "" {
+		return branch
+	}
+	return "detached"
+}
+
+func checkResultsFile(branch string) string {
+	return filepath.Join(checkResultsDir, unsafeBranchChars.ReplaceAllString(branch, "-")+".json")
+}
+
+// The last recorded run on the branch, nil if there is none
+func lastCheckRun(branch string) *checkRun {
+	content, err := os.ReadFile(checkResultsFile(branch))
+	if err != nil {
+		return nil
+	}
+	var run checkRun
+	if err := json.Unmarshal(content, &run); err != nil {
+		debug(fmt.Sprintf("Ignoring %s: %s", checkResultsFile(branch), err))
+		return nil
+	}
+	return &run
+}
+
+// The result of a step, nil if the run didn't get to it
+func (r *checkRun) step(name string) *checkStepResult {
+	if r == nil {
+		return nil
+	}
+	for i := range r.Steps {
+		if r.Steps[i].Step == name {
+			return &r.Steps[i]
+		}
+	}
+	return nil
+}
+
+// Problems writing the results never fail the check
+func (r *checkRun) save() {
+	if err := ensureStateDir(checkResultsDir); err != nil {
+		debug(fmt.Sprintf("Not recording the check's results: %s", err))
+		return
+	}
+	r.Time = time.Now()
+	r.Commit = gitCommit()
+	content, _ := json.MarshalIndent(r, "", "  ")
+	if err := os.WriteFile(checkResultsFile(r.Branch), append(content, '\n'), 0644); err != nil {
+		debug(fmt.Sprintf("Not recording the check's results: %s", err))
+	}
+}
+
+// Report what this run found that last didn't: steps failing that passed,
+// and findings beyond last's. Steps this run didn't get to are left out.
+func reportCheckDiff(run, last *checkRun) {
+	fmt.Println()
+	if last == nil {
+		info(tr("CheckDiffNoLast", "Branch", run.Branch))
+		return
+	}
+	fmt.Println(plain("🔍 " + tr("CheckDiffHeader", "Branch", last.Branch, "Commit", last.Commit, "Time", last.Time.Local().Format("2006-01-02 15:04"))))
+	introduced, fixed := 0, 0
+	for _, result := range run.Steps {
+		previous := last.step(result.Step)
+		if !result.Passed && (previous == nil || previous.Passed) {
+			fmt.Println(plain("  ❌ " + tr("CheckDiffNewFailure", "Step", result.Step)))
+			introduced++
+		}
+		var before map[string]int
+		if previous != nil {
+			before = previous.Findings
+			fixed += len(newFindings(previous.Findings, result.Findings))
+		}
+		for _, finding := range newFindings(result.Findings, before) {
+			fmt.Println(plain(fmt.Sprintf("  ⚠️  %s: %s", result.Step, finding)))
+			introduced++
+		}
+	}
+	if fixed > 0 {
+		fmt.Println(plain("  ✅ " + trn("CheckDiffFixed", fixed)))
+	}
+	if introduced == 0 {
+		success(tr("CheckDiffNone"))
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// checkConfig is the [check] section of glot.toml
+type checkConfig struct {
+	// Globs of the files each step of glot check depends on, by step, e.g.
+	// test = ["src/**", "tests/**"] (default: every file of the project)
+	Inputs map[string][]string `toml:"inputs"`
+}
+
+// The steps of glot check, in order
+var checkSteps = []string{"fmt", "lint", "test", "build", "smoke"}
+
+// Inputs of every step, whatever [check.inputs] says
+var checkBaseInputs = []string{"flake.nix", "flake.lock", configFile}
+
+// Steps of glot check that passed, with the hash of their inputs after
+// each, for glot check --resume
+var checkStateFile = filepath.Join(stateDir, "check-state.json")
+
+// A step of glot check that passed
+type checkStepState struct {
+	Inputs   string       `json:"inputs"` // Hash of the step's input files once it passed
+	Finished time.Time    `json:"finished"`
+	Metrics  checkMetrics `json:"metrics"` // What the step measured, for the gates
+}
+
+// The steps of a glot check run, and with --resume, the passed steps of the
+// last runs to skip
+type checkProgress struct {
+	resume  bool
+	steps   map[string]checkStepState
+	results *checkRun // This run's outcome of each step
+	last    *checkRun // The last run on the branch
+}
+
+func loadCheckProgress(resume bool) *checkProgress {
+	branch := checkBranch()
+	progress := &checkProgress{
+		resume:  resume,
+		steps:   map[string]checkStepState{},
+		results: &checkRun{Branch: branch},
+		last:    lastCheckRun(branch),
+	}
+	if content, err := os.ReadFile(checkStateFile); err == nil {
+		json.Unmarshal(content, &progress.steps)
+	}
+	return progress
+}
+
+// Record this run's results, and with diff, report what is new since the
+// last run on the branch
+func (p *checkProgress) finish(diff bool) {
+	p.results.save()
+	if diff {
+		reportCheckDiff(p.results, p.last)
+	}
+}
+
+// Hash of what a step depends on: the content of its input files, as the
+// flake sees them, and the glot running it
+func checkInputsHash(step string) (string, error) {
+	files, err := projectFiles()
+	if err != nil {
+		return "", err
+	}
+	sort.Strings(files)
+	patterns := currentConfig.Check.Inputs[step]
+	hash := sha256.New()
+	fmt.Fprintf(hash, "glot %s\n", version)
+	for _, file := range files {
+		if len(patterns) > 0 && !slices.Contains(checkBaseInputs, file) && !matchAnyGlob(patterns, file) {
+			continue
+		}
+		stat, err := os.Lstat(file)
+		if err != nil {
+			// Deleted but not yet staged
+			continue
+		}
+		switch {
+		case stat.Mode()&os.ModeSymlink != 0:
+			target, _ := os.Readlink(file)
+			fmt.Fprintf(hash, "link %s %s\n", file, target)
+		case stat.Mode().IsRegular():
+			f, err := os.Open(file)
+			if err != nil {
+				return "", err
+			}
+			fmt.Fprintf(hash, "file %s %o %d\n", file, stat.Mode().Perm()&0111, stat.Size())
+			_, err = io.Copy(hash, f)
+			f.Close()
+			if err != nil {
+				return "", err
+			}
+		}
+	}
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Run a step of glot check, or with --resume skip it if it passed before
+// and its input files are the same as then. The metrics of a skipped
+// step are the ones it measured when it passed.
+func (p *checkProgress) run(name string, metrics *checkMetrics, step func(*checkMetrics) error) error {
+	inputs, hashErr := checkInputsHash(name)
+	if hashErr != nil {
+		debug(fmt.Sprintf("Not resuming %s: %s", name, hashErr))
+	}
+	if previous, ok := p.steps[name]; p.resume && hashErr == nil && ok && previous.Inputs == inputs {
+		info(tr("CheckStepSkipped", "Step", name, "Finished", previous.Finished.Local().Format("15:04:05")))
+		metrics.merge(previous.Metrics)
+		// Same files, so the same findings as when it last ran
+		result := checkStepResult{Step: name, Passed: true}
+		if last := p.last.step(name); last != nil {
+			result.Findings = last.Findings
+		}
+		p.results.Steps = append(p.results.Steps, result)
+		return nil
+	}
+	// Until it passes again
+	delete(p.steps, name)
+	measured := &checkMetrics{}
+	output, err := captureOutput(func() error { return step(measured) })
+	p.results.Steps = append(p.results.Steps, checkStepResult{Step: name, Passed: err == nil, Findings: lintFindings(output)})
+	if err != nil {
+		p.save()
+		return err
+	}
+	metrics.merge(*measured)
+	// Hashed again: formatting changes the files, and a step passes for the
+	// files it leaves behind
+	if inputs, err := checkInputsHash(name); err == nil {
+		p.steps[name] = checkStepState{Inputs: inputs, Finished: time.Now(), Metrics: *measured}
+	}
+	p.save()
+	return nil
+}
+
+// Problems writing the state never fail the check
+func (p *checkProgress) save() {
+	if err := ensureStateDir(stateDir); err != nil {
+		debug(fmt.Sprintf("Not recording the check's progress: %s", err))
+		return
+	}
+	content, _ := json.MarshalIndent(p.steps, "", "  ")
+	if err := os.WriteFile(checkStateFile, append(content, '\n'), 0644); err != nil {
+		debug(fmt.Sprintf("Not recording the check's progress: %s", err))
+	}
+}
+
+// Take the metrics other measured
+func (m *checkMetrics) merge(other checkMetrics) {
+	if other.LintWarnings != nil {
+		m.LintWarnings = other.LintWarnings
+	}
+	if other.Coverage != nil {
+		m.Coverage = other.Coverage
+	}
+	if other.BinarySize != nil {
+		m.BinarySize = other.BinarySize
+	}
+	if other.BuildTimeMs != nil {
+		m.BuildTimeMs = other.BuildTimeMs
+	}
+}
+
+func validateCheckConfig(path string, check checkConfig) error {
+	for step, patterns := range check.Inputs {
+		if !slices.Contains(checkSteps, step) {
+			return fmt.Errorf("%s", tr("ConfigInvalid", "File", path, "Error", tr("CheckInputsUnknownStep", "Step", step, "Steps", strings.Join(checkSteps, ", "))))
+		}
+		for _, pattern := range patterns {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				return fmt.Errorf("%s", tr("ConfigInvalid", "File", path, "Error", tr("CheckInputsBadGlob", "Step", step, "Pattern", pattern)))
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,302 @@
+// glot-dev is the nix-polyglot maintainers' tool, a glot plugin run as
+// 'glot dev' from the repository's dev shell. It isn't part of the glot
+// package.
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// The flake input templates use for nix-polyglot, replaced with the local
+// tree so that the matrix tests the working copy
+const polyglotInputURL = "github:ritzau/nix-polyglot"
+
+// The glot commands run in each generated project, in order
+var defaultSteps = []string{"build", "test", "check"}
+
+// Outcome of a step of a template's run
+type stepResult struct {
+	status   string // ok, failed or skipped
+	duration time.Duration
+}
+
+// A template's run: its project, log and the result of every step
+type templateRun struct {
+	name    string
+	dir     string
+	log     string
+	results map[string]stepResult
+	failed  string // The step that failed, empty if none
+}
+
+// The nix-polyglot tree to test: --polyglot, the project glot runs the
+// plugin in, or the current directory
+func polyglotRoot(flag string) (string, error) {
+	root := flag
+	if root == "" {
+		root = os.Getenv("GLOT_PROJECT_ROOT")
+	}
+	if root == "" {
+		root = "."
+	}
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(filepath.Join(root, "templates")); err != nil {
+		return "", fmt.Errorf("%s is not a nix-polyglot checkout (no templates/) - run in one or pass --polyglot", root)
+	}
+	return root, nil
+}
+
+// Names of the templates under root, sorted
+func templateNames(root string) ([]string, error) {
+	manifests, _ := filepath.Glob(filepath.Join(root, "templates", "*", "*", "template.nix"))
+	var names []string
+	for _, manifest := range manifests {
+		out, err := exec.Command("nix", "eval", "--raw", "--file", manifest, "name").Output()
+		if err != nil {
+			return nil, fmt.Errorf("%s doesn't evaluate: %w", manifest, err)
+		}
+		names = append(names, string(out))
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// The glot to test with: --glot, the glot running the plugin, or PATH's
+func glotBinary(flag string) string {
+	if flag != "" {
+		return flag
+	}
+	if bin := os.Getenv("GLOT_BIN"); bin != "" {
+		return bin
+	}
+	return "glot"
+}
+
+// Run a command for a template, its output appended to the template's log
+func (r *templateRun) command(dir string, env []string, name string, args ...string) error {
+	log, err := os.OpenFile(r.log, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer log.Close()
+	fmt.Fprintf(log, "\n$ %s %s\n", name, strings.Join(args, " "))
+	cmd := exec.Command(name, args...)
+	cmd.Dir = dir
+	cmd.Env = env
+	cmd.Stdout = log
+	cmd.Stderr = log
+	return cmd.Run()
+}
+
+// Generate the template's project against the local tree and run the steps
+// in it, stopping at the first that fails. Each run has its own directory
+// and nix cache, so runs don't share state or contend for locks.
+func (r *templateRun) run(root, glot string, steps []string) {
+	env := append(os.Environ(),
+		"XDG_CACHE_HOME="+r.dir+".cache",
+		"NO_COLOR=1",
+	)
+	for _, step := range append([]string{"new"}, steps...) {
+		if r.failed != "" {
+			r.results[step] = stepResult{status: "skipped"}
+			continue
+		}
+		started := time.Now()
+		var err error
+		if step == "new" {
+			err = r.generate(root, glot, env)
+		} else {
+			err = r.command(r.dir, env, glot, strings.Fields(step)...)
+		}
+		result := stepResult{status: "ok", duration: time.Since(started)}
+		if err != nil {
+			result.status = "failed"
+			r.failed = step
+		}
+		r.results[step] = result
+	}
+}
+
+// glot new, with the project's nix-polyglot input pointed at root
+func (r *templateRun) generate(root, glot string, env []string) error {
+	if err := r.command(root, env, glot, "new", r.name, r.dir); err != nil {
+		return err
+	}
+	flake := filepath.Join(r.dir, "flake.nix")
+	content, err := os.ReadFile(flake)
+	if err != nil {
+		return err
+	}
+	local := strings.ReplaceAll(string(content), polyglotInputURL, "path:"+root)
+	if err := os.WriteFile(flake, []byte(local), 0644); err != nil {
+		return err
+	}
+	return r.command(r.dir, env, "nix", "flake", "lock")
+}
+
+// Print the matrix: a row per template, a column per step
+func printMatrix(runs []*templateRun, steps []string) {
+	columns := append([]string{"new"}, steps...)
+	width := len("template")
+	for _, run := range runs {
+		width = max(width, len(run.name))
+	}
+	header := fmt.Sprintf("%-*s", width, "template")
+	for _, column := range columns {
+		header += fmt.Sprintf("  %-10s", column)
+	}
+	fmt.Printf("\n%s\n", strings.TrimRight(header, " "))
+	for _, run := range runs {
+		line := fmt.Sprintf("%-*s", width, run.name)
+		for _, column := range columns {
+			result := run.results[column]
+			cell := "-"
+			switch result.status {
+			case "ok":
+				cell = "ok " + result.duration.Round(time.Second).String()
+			case "failed":
+				cell = "FAILED"
+			}
+			line += fmt.Sprintf("  %-10s", cell)
+		}
+		fmt.Println(strings.TrimRight(line, " "))
+	}
+}
+
+func newTestTemplatesCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "test-templates [template...]",
+		Short: "Generate every template and run build, test and check in each, in parallel",
+		Long: "Generate a project from every template (or the named ones) with glot new, point its nix-polyglot " +
+			"input at this checkout, and run glot build, test and check in it. Templates run in parallel, each in " +
+			"its own directory with its own nix cache, and a matrix reports every step. Projects of failed " +
+			"templates are kept with their logs.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rootFlag, _ := cmd.Flags().GetString("polyglot")
+			root, err := polyglotRoot(rootFlag)
+			if err != nil {
+				return err
+			}
+			names, err := templateNames(root)
+			if err != nil {
+				return err
+			}
+			for _, name := range args {
+				if !slices.Contains(names, name) {
+					return fmt.Errorf("unknown template '%s' (available: %s)", name, strings.Join(names, ", "))
+				}
+			}
+			if len(args) > 0 {
+				names = args
+			}
+			steps, _ := cmd.Flags().GetStringSlice("steps")
+			jobs, _ := cmd.Flags().GetInt("jobs")
+			if jobs < 1 {
+				return fmt.Errorf("--jobs must be at least 1")
+			}
+			keep, _ := cmd.Flags().GetBool("keep")
+			glotFlag, _ := cmd.Flags().GetString("glot")
+			glot := glotBinary(glotFlag)
+
+			work, _ := cmd.Flags().GetString("work-dir")
+			if work == "" {
+				if work, err = os.MkdirTemp("", "glot-templates-"); err != nil {
+					return err
+				}
+			} else if err := os.MkdirAll(work, 0755); err != nil {
+				return err
+			}
+			fmt.Printf("Testing %d templates of %s in %s, %d at a time\n", len(names), root, work, jobs)
+
+			runs := make([]*templateRun, len(names))
+			queue := make(chan *templateRun)
+			var wg sync.WaitGroup
+			var mu sync.Mutex
+			for range jobs {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for run := range queue {
+						started := time.Now()
+						run.run(root, glot, steps)
+						mu.Lock()
+						if run.failed != "" {
+							fmt.Printf("❌ %s: %s failed after %s\n", run.name, run.failed, time.Since(started).Round(time.Second))
+						} else {
+							fmt.Printf("✅ %s passed in %s\n", run.name, time.Since(started).Round(time.Second))
+						}
+						mu.Unlock()
+					}
+				}()
+			}
+			for i, name := range names {
+				dir := filepath.Join(work, name)
+				if err := os.RemoveAll(dir); err != nil {
+					return err
+				}
+				runs[i] = &templateRun{name: name, dir: dir, log: dir + ".log", results: map[string]stepResult{}}
+				os.Remove(runs[i].log)
+				queue <- runs[i]
+			}
+			close(queue)
+			wg.Wait()
+
+			printMatrix(runs, steps)
+			fmt.Println()
+			var failed []string
+			for _, run := range runs {
+				if run.failed != "" {
+					failed = append(failed, run.name)
+					fmt.Printf("%s: 'glot %s' failed, see %s (project in %s)\n", run.name, run.failed, run.log, run.dir)
+				} else if !keep {
+					os.RemoveAll(run.dir)
+					os.RemoveAll(run.dir + ".cache")
+					os.Remove(run.log)
+				}
+			}
+			if len(failed) > 0 {
+				return fmt.Errorf("%d of %d templates failed: %s", len(failed), len(runs), strings.Join(failed, ", "))
+			}
+			if !keep {
+				os.Remove(work)
+			}
+			fmt.Printf("All %d templates passed\n", len(runs))
+			return nil
+		},
+	}
+	cmd.Flags().Int("jobs", max(1, runtime.NumCPU()/2), "Templates tested at a time")
+	cmd.Flags().StringSlice("steps", defaultSteps, "glot commands run in each project, in order")
+	cmd.Flags().String("work-dir", "", "Directory for the generated projects and their logs (default: a new temporary one)")
+	cmd.Flags().Bool("keep", false, "Keep the projects and logs of templates that passed too")
+	cmd.Flags().String("polyglot", "", "nix-polyglot checkout to test (default: the current project)")
+	cmd.Flags().String("glot", "", "glot to test with (default: the glot running this plugin, or PATH's)")
+	return cmd
+}
+
+func main() {
+	root := &cobra.Command{
+		Use:           "glot-dev",
+		Short:         "Tools for nix-polyglot maintainers",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+	root.AddCommand(newTestTemplatesCmd())
+	if err := root.Execute(); err != nil {
+		fmt.Fprintln(os.Stderr, "❌ Error:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/spf13/cobra"
+)
+
+// Manifest in the project root where templates declare extra glot commands
+const commandsManifest = "glot-commands.toml"
+
+// templateCommand is an extra subcommand declared by a template, e.g.
+//
+//	[[command]]
+//	name = "assets build"
+//	short = "Build web assets"
+//	run = "npm run build"
+type templateCommand struct {
+	Name  string `toml:"name"`
+	Short string `toml:"short"`
+	Long  string `toml:"long"`
+	Run   string `toml:"run"` // Shell command run inside the dev shell
+	App   string `toml:"app"` // Flake app to run instead of a shell command
+	// glot.toml tasks to run first
+	Depends []string `toml:"depends"`
+}
+
+type commandsFile struct {
+	Commands []templateCommand `toml:"command"`
+}
+
+// Load template-defined commands from the manifest, if the project has one
+func loadTemplateCommands(path string) ([]templateCommand, error) {
+	var file commandsFile
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil, nil
+	}
+	if _, err := toml.DecodeFile(path, &file); err != nil {
+		return nil, fmt.Errorf("%s", tr("ConfigInvalid", "File", path, "Error", err))
+	}
+	if err := validateCommands(path, file.Commands); err != nil {
+		return nil, err
+	}
+	return file.Commands, nil
+}
+
+// Check that every command has a name and something to run
+func validateCommands(path string, commands []templateCommand) error {
+	for i, command := range commands {
+		if len(strings.Fields(command.Name)) == 0 {
+			return fmt.Errorf("%s", tr("ConfigInvalid", "File", path, "Error", tr("CommandsNoName", "Number", i+1)))
+		}
+		if (command.Run == "") == (command.App == "") {
+			return fmt.Errorf("%s", tr("ConfigInvalid", "File", path, "Error", tr("CommandsRunOrApp", "Command", command.Name)))
+		}
+	}
+	return nil
+}
+
+// Run a template-defined command with the given arguments
+func runTemplateCommand(command templateCommand, args []string) error {
+	if err := checkNix(); err != nil {
+		errorMsg(err.Error())
+		return err
+	}
+	var err error
+	if command.App != "" {
+		err = runNix(append([]string{"run", ".#" + command.App, "--"}, args...)...)
+	} else {
+		// Arguments are available to the script as "$@"
+		err = runInDevShell(append([]string{"bash", "-c", command.Run, "glot-" + strings.Join(strings.Fields(command.Name), "-")}, args...)...)
+	}
+	if err != nil {
+		errorMsg(tr("CommandsFailed", "Command", command.Name))
+	}
+	return err
+}
+
+// Find a direct subcommand by name
+func findSubcommand(parent *cobra.Command, name string) *cobra.Command {
+	for _, sub := range parent.Commands() {
+		if sub.Name() == name {
+			return sub
+		}
+	}
+	return nil
+}
+
+// Register commands declared in source (glot-commands.toml or glot.toml) on
+// the root command. Multi-word names ("assets build") create intermediate
+// group commands as needed. Commands never replace built-in ones.
+func registerTemplateCommands(root *cobra.Command, source string, commands []templateCommand) {
+	for _, command := range commands {
+		words := strings.Fields(command.Name)
+
+		parent := root
+		for _, word := range words[:len(words)-1] {
+			group := findSubcommand(parent, word)
+			if group == nil {
+				group = &cobra.Command{
+					Use:   word,
+					Short: fmt.Sprintf("%s commands (from %s)", word, source),
+				}
+				parent.AddCommand(group)
+			}
+			parent = group
+		}
+
+		name := words[len(words)-1]
+		if findSubcommand(parent, name) != nil {
+			warning(tr("CommandsExists", "Command", command.Name, "File", source))
+			continue
+		}
+
+		long := command.Long
+		if long == "" {
+			long = command.Short
+		}
+		parent.AddCommand(&cobra.Command{
+			Use:                name + " [args...]",
+			Short:              command.Short,
+			Long:               long,
+			DisableFlagParsing: true,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				if len(args) > 0 && (args[0] == "-h" || args[0] == "--help") {
+					return cmd.Help()
+				}
+				return runTask(command, args)
+			},
+		})
+	}
+}
@@ -0,0 +1,402 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// Where glot compare checks out the base revision and links build results
+var compareDir = filepath.Join(stateDir, "compare")
+
+// Test result lines of the test runners glot knows
+var testResultPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`^\s*--- (?P<status>PASS|FAIL|SKIP): (?P<name>\S+)`),                            // go test -v
+	regexp.MustCompile(`^test (?P<name>\S+) \.\.\. (?P<status>ok|FAILED|ignored)$`),                    // cargo test
+	regexp.MustCompile(`^(?P<name>\S+::\S+) (?P<status>PASSED|FAILED|SKIPPED|ERROR)`),                  // pytest -v
+	regexp.MustCompile(`^\s*(?P<status>Passed|Failed|Skipped) (?P<name>\S+)`),                          // dotnet test
+	regexp.MustCompile(`^\s*\d+/\d+ Test\s+#\d+: (?P<name>\S+) \.+\s*(?P<status>Passed|\*\*\*Failed)`), // ctest
+}
+
+var testStatuses = map[string]string{
+	"PASS": "passed", "ok": "passed", "PASSED": "passed", "Passed": "passed",
+	"FAIL": "failed", "FAILED": "failed", "ERROR": "failed", "Failed": "failed", "***Failed": "failed",
+	"SKIP": "skipped", "ignored": "skipped", "SKIPPED": "skipped", "Skipped": "skipped",
+}
+
+// Benchmark result lines, with the benchmark's name, time per iteration
+// and its unit
+var benchmarkPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`^(Benchmark\S+?)(?:-\d+)?\s+\d+\s+([\d.]+) (ns)/op`),                  // go test -bench
+	regexp.MustCompile(`^test (\S+)\s+\.\.\. bench:\s+([\d,.]+) (ns)/iter`),                   // cargo bench (libtest)
+	regexp.MustCompile(`^(\S+)\s+time:\s+\[[\d.]+ \S+ ([\d.]+) (ns|µs|us|ms|s) [\d.]+ \S+\]`), // criterion
+}
+
+var nanosecondsPer = map[string]float64{"ns": 1, "µs": 1e3, "us": 1e3, "ms": 1e6, "s": 1e9}
+
+// Benchmark commands of the languages that have a standard benchmark runner
+var benchmarkCommands = map[string][]string{
+	"go":   {"go", "test", "-run", "^$", "-bench", ".", "./..."},
+	"rust": {"cargo", "bench"},
+}
+
+// What glot compare measured of one revision
+type revisionReport struct {
+	label        string
+	buildErr     error
+	binaries     map[string]int64  // Name in result/bin to size
+	dependencies map[string]string // Runtime closure: package name to version
+	testErr      error
+	tests        map[string]string // Test name to passed, failed or skipped
+	benchmarks   map[string]float64
+}
+
+func parseTestResults(output string) map[string]string {
+	tests := map[string]string{}
+	for _, line := range strings.Split(ansiEscape.ReplaceAllString(output, ""), "\n") {
+		line = strings.TrimRight(line, " \r")
+		for _, pattern := range testResultPatterns {
+			if m := pattern.FindStringSubmatch(line); m != nil {
+				tests[m[pattern.SubexpIndex("name")]] = testStatuses[m[pattern.SubexpIndex("status")]]
+				break
+			}
+		}
+	}
+	return tests
+}
+
+// Nanoseconds per iteration of each benchmark in the output
+func parseBenchmarks(output string) map[string]float64 {
+	benchmarks := map[string]float64{}
+	for _, line := range strings.Split(ansiEscape.ReplaceAllString(output, ""), "\n") {
+		for _, pattern := range benchmarkPatterns {
+			if m := pattern.FindStringSubmatch(strings.TrimSpace(line)); m != nil {
+				value, err := strconv.ParseFloat(strings.ReplaceAll(m[2], ",", ""), 64)
+				if err == nil {
+					benchmarks[m[1]] = value * nanosecondsPer[m[3]]
+				}
+				break
+			}
+		}
+	}
+	return benchmarks
+}
+
+// Run the flake's bench app, or the language's benchmark runner
+func runBenchmarks() error {
+	if flakeHasApp("bench") {
+		return runNix("run", ".#bench")
+	}
+	language, err := detectLanguage()
+	if err != nil {
+		return err
+	}
+	command, ok := benchmarkCommands[language.Name()]
+	if !ok {
+		return fmt.Errorf("%s", tr("CompareNoBenchmarks", "Language", language.Name()))
+	}
+	return runInDevShell(command...)
+}
+
+// Run fn in another project directory, with that project's glot.toml
+func inProjectDir(dir string, fn func() error) error {
+	previousDir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	if err := os.Chdir(dir); err != nil {
+		return err
+	}
+	previousConfig := currentConfig
+	defer func() {
+		currentConfig = previousConfig
+		os.Chdir(previousDir)
+	}()
+	config, err := loadProjectConfig(configFile)
+	if err != nil {
+		warning(err.Error())
+	}
+	currentConfig = config
+	return fn()
+}
+
+// Build, test and optionally benchmark the project in dir, linking the build
+// result to out
+func measureRevision(label, dir, out string, bench bool) *revisionReport {
+	report := &revisionReport{label: label, binaries: map[string]int64{}, dependencies: map[string]string{}}
+	inProjectDir(dir, func() error {
+		info(tr("CompareBuilding", "Label", label))
+		if report.buildErr = runNix("build", "--out-link", out); report.buildErr == nil {
+			entries, _ := os.ReadDir(filepath.Join(out, "bin"))
+			for _, entry := range entries {
+				if stat, err := os.Stat(filepath.Join(out, "bin", entry.Name())); err == nil && !stat.IsDir() {
+					report.binaries[entry.Name()] = stat.Size()
+				}
+			}
+			if paths, err := nixOutput("path-info", "--recursive", out); err == nil {
+				for _, path := range strings.Fields(string(paths)) {
+					// <hash>-<name>-<version>
+					if _, pkg, ok := strings.Cut(filepath.Base(path), "-"); ok {
+						name, version := splitPackageName(pkg)
+						report.dependencies[name] = version
+					}
+				}
+			}
+		}
+
+		info(tr("CompareTesting", "Label", label))
+		var output string
+		output, report.testErr = captureOutput(func() error { return runTests(testOptions{Verbose: true}) })
+		report.tests = parseTestResults(output)
+
+		if bench {
+			info(tr("CompareBenchmarking", "Label", label))
+			output, err := captureOutput(runBenchmarks)
+			if err != nil {
+				warning(tr("CompareBenchmarksFailed", "Label", label, "Error", err))
+			}
+			report.benchmarks = parseBenchmarks(output)
+		}
+		return nil
+	})
+	return report
+}
+
+// Check out a revision in a detached worktree at dir, returning the
+// project's directory in it and a function removing it again
+func checkoutRevision(rev, dir string) (string, func(), error) {
+	top, err := exec.Command("git", "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return "", nil, fmt.Errorf("%s", tr("CompareNotGit"))
+	}
+	prefix, _ := exec.Command("git", "rev-parse", "--show-prefix").Output()
+	if err := ensureStateDir(filepath.Dir(dir)); err != nil {
+		return "", nil, err
+	}
+	worktree, err := filepath.Abs(dir)
+	if err != nil {
+		return "", nil, err
+	}
+	// A worktree left behind by an interrupted run
+	gitIn(".", "worktree", "remove", "--force", worktree)
+	os.RemoveAll(worktree)
+	if err := gitIn(strings.TrimSpace(string(top)), "worktree", "add", "--detach", worktree, rev); err != nil {
+		return "", nil, err
+	}
+	remove := func() {
+		if err := gitIn(".", "worktree", "remove", "--force", worktree); err != nil {
+			warning(tr("CompareWorktreeRemoveFailed", "Dir", worktree, "Error", err))
+		}
+	}
+	return filepath.Join(worktree, strings.TrimSpace(string(prefix))), remove, nil
+}
+
+func formatDuration(ns float64) string {
+	for _, unit := range []string{"s", "ms", "µs"} {
+		if ns >= nanosecondsPer[unit] {
+			return fmt.Sprintf("%.2f%s", ns/nanosecondsPer[unit], unit)
+		}
+	}
+	return fmt.Sprintf("%.0fns", ns)
+}
+
+func percentChange(before, after float64) string {
+	if before == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (%+.1f%%)", 100*(after-before)/before)
+}
+
+// Print how current differs from base, returning the tests that regressed
+func reportComparison(base, current *revisionReport) []string {
+	fmt.Println()
+	fmt.Printf(plain("📋 %s → %s\n"), base.label, current.label)
+
+	fmt.Println("\n" + tr("CompareBuild"))
+	status := func(err error) string {
+		if err != nil {
+			return tr("CompareFailed")
+		}
+		return tr("CompareOK")
+	}
+	fmt.Printf(plain("  %s → %s\n"), status(base.buildErr), status(current.buildErr))
+
+	fmt.Println("\n" + tr("CompareTests"))
+	var regressed, lines []string
+	names := map[string]bool{}
+	for name := range base.tests {
+		names[name] = true
+	}
+	for name := range current.tests {
+		names[name] = true
+	}
+	counts := func(tests map[string]string) string {
+		n := map[string]int{}
+		for _, status := range tests {
+			n[status]++
+		}
+		return tr("CompareCounts", "Passed", n["passed"], "Failed", n["failed"], "Skipped", n["skipped"])
+	}
+	for _, name := range slices.Sorted(maps.Keys(names)) {
+		then, inBase := base.tests[name]
+		now, inCurrent := current.tests[name]
+		switch {
+		case !inBase:
+			lines = append(lines, fmt.Sprintf("  + %s (%s)", name, now))
+		case !inCurrent:
+			lines = append(lines, fmt.Sprintf("  - %s", name))
+		case then != now:
+			lines = append(lines, fmt.Sprintf(plain("  ~ %s: %s → %s"), name, then, now))
+			if then == "passed" && now == "failed" {
+				regressed = append(regressed, name)
+			}
+		}
+	}
+	fmt.Printf(plain("  %s → %s\n"), counts(base.tests), counts(current.tests))
+	if len(names) == 0 {
+		fmt.Println(plain("  " + tr("CompareNoTestResults", "Before", status(base.testErr), "After", status(current.testErr))))
+	}
+	for _, line := range lines {
+		fmt.Println(line)
+	}
+
+	if len(base.binaries)+len(current.binaries) > 0 {
+		fmt.Println("\n" + tr("CompareBinaries"))
+		sizes := map[string]bool{}
+		for name := range base.binaries {
+			sizes[name] = true
+		}
+		for name := range current.binaries {
+			sizes[name] = true
+		}
+		for _, name := range slices.Sorted(maps.Keys(sizes)) {
+			then, inBase := base.binaries[name]
+			now, inCurrent := current.binaries[name]
+			switch {
+			case !inBase:
+				fmt.Printf("  + %s %s\n", name, formatSize(now))
+			case !inCurrent:
+				fmt.Printf("  - %s %s\n", name, formatSize(then))
+			default:
+				fmt.Printf(plain("    %s %s → %s%s\n"), name, formatSize(then), formatSize(now), percentChange(float64(then), float64(now)))
+			}
+		}
+	}
+
+	if len(base.benchmarks)+len(current.benchmarks) > 0 {
+		fmt.Println("\n" + tr("CompareBenchmarks"))
+		benchmarks := map[string]bool{}
+		for name := range base.benchmarks {
+			benchmarks[name] = true
+		}
+		for name := range current.benchmarks {
+			benchmarks[name] = true
+		}
+		for _, name := range slices.Sorted(maps.Keys(benchmarks)) {
+			then, inBase := base.benchmarks[name]
+			now, inCurrent := current.benchmarks[name]
+			switch {
+			case !inBase:
+				fmt.Printf("  + %s %s\n", name, formatDuration(now))
+			case !inCurrent:
+				fmt.Printf("  - %s %s\n", name, formatDuration(then))
+			default:
+				fmt.Printf(plain("    %s %s → %s%s\n"), name, formatDuration(then), formatDuration(now), percentChange(then, now))
+			}
+		}
+	}
+
+	var dependencyLines []string
+	packages := map[string]string{}
+	maps.Copy(packages, base.dependencies)
+	maps.Copy(packages, current.dependencies)
+	for _, name := range sortedKeys(packages) {
+		then, inBase := base.dependencies[name]
+		now, inCurrent := current.dependencies[name]
+		switch {
+		case !inBase:
+			dependencyLines = append(dependencyLines, fmt.Sprintf("  + %s %s", name, now))
+		case !inCurrent:
+			dependencyLines = append(dependencyLines, fmt.Sprintf("  - %s %s", name, then))
+		case then != now:
+			dependencyLines = append(dependencyLines, fmt.Sprintf(plain("  ~ %s %s → %s"), name, then, now))
+		}
+	}
+	fmt.Println("\n" + tr("CompareDependencies"))
+	if len(dependencyLines) == 0 {
+		fmt.Println("  " + tr("CompareUnchanged"))
+	}
+	for _, line := range dependencyLines {
+		fmt.Println(line)
+	}
+	return regressed
+}
+
+func newCompareCmd() *cobra.Command {
+	compareCmd := &cobra.Command{
+		Use:   "compare <base-ref>",
+		Short: "Compare the working tree with another revision",
+		Long: "Build and test a git revision in a temporary worktree and the working tree, and report how " +
+			"test results, binary sizes, benchmarks and runtime dependencies differ - a local pre-merge impact report. " +
+			"Fails when the working tree doesn't build or tests that pass on the base revision fail.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkNix(); err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			rev, err := exec.Command("git", "rev-parse", "--verify", "--quiet", args[0]+"^{commit}").Output()
+			if err != nil {
+				err = fmt.Errorf("%s", tr("CompareNotRevision", "Rev", args[0]))
+				errorMsg(err.Error())
+				return err
+			}
+			bench, _ := cmd.Flags().GetBool("bench")
+			keep, _ := cmd.Flags().GetBool("keep-worktree")
+
+			baseDir, remove, err := checkoutRevision(strings.TrimSpace(string(rev)), filepath.Join(compareDir, "base"))
+			if err != nil {
+				errorMsg(tr("CompareCheckoutFailed", "Rev", args[0], "Error", err))
+				return err
+			}
+			if keep {
+				info(tr("CompareKeeping", "Rev", args[0], "Dir", baseDir))
+			} else {
+				defer remove()
+			}
+
+			resultLink := func(name string) string {
+				link, _ := filepath.Abs(filepath.Join(compareDir, name))
+				return link
+			}
+			base := measureRevision(args[0], baseDir, resultLink("result-base"), bench)
+			current := measureRevision(tr("CompareWorkingTree"), ".", resultLink("result-current"), bench)
+
+			regressed := reportComparison(base, current)
+			fmt.Println()
+			if current.buildErr != nil {
+				err := fmt.Errorf("%s", tr("CompareBuildBroken"))
+				errorMsg(err.Error())
+				return err
+			}
+			if len(regressed) > 0 {
+				err := fmt.Errorf("%s", trn("CompareRegressed", len(regressed), "Rev", args[0]))
+				errorMsg(err.Error())
+				return err
+			}
+			success(tr("CompareNoRegressions", "Rev", args[0]))
+			return nil
+		},
+	}
+	compareCmd.Flags().Bool("bench", false, "Also run and compare benchmarks (the flake's bench app, go test -bench or cargo bench)")
+	compareCmd.Flags().Bool("keep-worktree", false, "Keep the base revision's worktree in "+compareDir+" for inspection")
+	return compareCmd
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// Marks the completion snippet glot adds to shell startup files
+const completionRCMarker = "# glot shell completion"
+
+// Where a shell loads completions from and how to check that it does
+type completionShell struct {
+	name     string
+	file     func() string
+	generate func(root *cobra.Command, file string) error
+	// Startup file snippet, for shells that don't load the file by themselves
+	rc func(file string) (rcFile, snippet string)
+	// Shell script that succeeds if the completion in $GLOT_COMPLETION_FILE loads
+	verify string
+}
+
+func dataHome() string {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return dir
+	}
+	return expandHome("~/.local/share")
+}
+
+func configHome() string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return dir
+	}
+	return expandHome("~/.config")
+}
+
+var completionShells = map[string]completionShell{
+	"bash": {
+		name: "bash",
+		// Loaded on demand by bash-completion
+		file: func() string { return filepath.Join(dataHome(), "bash-completion", "completions", "glot") },
+		generate: func(root *cobra.Command, file string) error {
+			return root.GenBashCompletionFileV2(file, true)
+		},
+		rc: func(file string) (string, string) {
+			if bashCompletionInstalled() {
+				return "", ""
+			}
+			return expandHome("~/.bashrc"), fmt.Sprintf("[ -f %q ] && source %q", file, file)
+		},
+		verify: `source "$GLOT_COMPLETION_FILE" && complete -p glot`,
+	},
+	"zsh": {
+		name: "zsh",
+		file: func() string { return filepath.Join(dataHome(), "zsh", "site-functions", "_glot") },
+		generate: func(root *cobra.Command, file string) error {
+			return root.GenZshCompletionFile(file)
+		},
+		// The directory is rarely on fpath, so add it before compinit runs
+		rc: func(file string) (string, string) {
+			rcFile := expandHome("~/.zshrc")
+			if dir := os.Getenv("ZDOTDIR"); dir != "" {
+				rcFile = filepath.Join(dir, ".zshrc")
+			}
+			return rcFile, fmt.Sprintf("fpath=(%q $fpath)\nautoload -Uz compinit && compinit", filepath.Dir(file))
+		},
+		verify: `fpath=("${GLOT_COMPLETION_FILE:h}" $fpath); autoload -Uz compinit && compinit -u -D && [[ -n ${_comps[glot]} ]]`,
+	},
+	"fish": {
+		name: "fish",
+		// Autoloaded by fish
+		file: func() string { return filepath.Join(configHome(), "fish", "completions", "glot.fish") },
+		generate: func(root *cobra.Command, file string) error {
+			return root.GenFishCompletionFile(file, true)
+		},
+		rc:     func(string) (string, string) { return "", "" },
+		verify: `source $GLOT_COMPLETION_FILE; and complete --do-complete "glot " >/dev/null`,
+	},
+}
+
+// bash-completion loads user completions lazily, so a file in its directory
+// is all it needs
+func bashCompletionInstalled() bool {
+	candidates := []string{
+		"/usr/share/bash-completion/bash_completion",
+		"/etc/bash_completion",
+		"/etc/profile.d/bash_completion.sh",
+		"/run/current-system/sw/share/bash-completion/bash_completion",
+		expandHome("~/.nix-profile/share/bash-completion/bash_completion"),
+	}
+	for _, candidate := range candidates {
+		if _, err := os.Stat(candidate); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Detect the user's shell from $SHELL
+func detectShell() (string, error) {
+	shell := filepath.Base(os.Getenv("SHELL"))
+	if _, ok := completionShells[shell]; !ok {
+		return "", fmt.Errorf("%s", tr("CompletionUnsupportedShell", "Shell", shell))
+	}
+	return shell, nil
+}
+
+// Append snippet to rcFile unless glot added it before
+func addRCSnippet(rcFile, snippet string) (bool, error) {
+	content, err := os.ReadFile(rcFile)
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	if bytes.Contains(content, []byte(completionRCMarker)) {
+		return false, nil
+	}
+	f, err := os.OpenFile(rcFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	_, err = fmt.Fprintf(f, "\n%s\n%s\n", completionRCMarker, snippet)
+	return err == nil, err
+}
+
+// Check that the completion file loads in the shell and that the glot the
+// shell finds answers completion requests
+func verifyCompletion(shell completionShell, file string) []string {
+	var problems []string
+	if _, err := exec.LookPath(shell.name); err != nil {
+		problems = append(problems, tr("CompletionShellMissing", "Shell", shell.name))
+	} else {
+		check := exec.Command(shell.name, "-c", shell.verify)
+		check.Env = append(os.Environ(), "GLOT_COMPLETION_FILE="+file)
+		if out, err := check.CombinedOutput(); err != nil {
+			problems = append(problems, tr("CompletionLoadFailed", "Shell", shell.name, "File", file, "Output", strings.TrimSpace(string(out))))
+		}
+	}
+
+	// Completion runs `glot __complete`, so it needs glot on PATH. In
+	// nix-polyglot projects that is the binary direnv caches for the project.
+	path, err := exec.LookPath("glot")
+	if err != nil {
+		problems = append(problems, tr("CompletionNoGlot"))
+		return problems
+	}
+	if out, err := exec.Command(path, cobra.ShellCompRequestCmd, "").Output(); err != nil || !bytes.Contains(out, []byte(":")) {
+		problems = append(problems, tr("CompletionNoAnswer", "Path", path))
+	}
+	return problems
+}
+
+// Add `glot completion install` to cobra's completion command
+func addCompletionInstallCmd(rootCmd *cobra.Command) {
+	rootCmd.InitDefaultCompletionCmd()
+	completionCmd, _, err := rootCmd.Find([]string{"completion"})
+	if err != nil || completionCmd == rootCmd {
+		return
+	}
+
+	installCmd := &cobra.Command{
+		Use:   "install [bash|zsh|fish]",
+		Short: "Install shell completion for the current user",
+		Long: "Write the completion script where the shell loads it from (default: the shell in $SHELL), " +
+			"add a startup file snippet when the shell needs one, and check that the completion loads.",
+		Args:      cobra.MaximumNArgs(1),
+		ValidArgs: []string{"bash", "zsh", "fish"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := ""
+			if len(args) == 1 {
+				name = args[0]
+			} else {
+				detected, err := detectShell()
+				if err != nil {
+					errorMsg(err.Error())
+					return err
+				}
+				name = detected
+			}
+			shell, ok := completionShells[name]
+			if !ok {
+				err := fmt.Errorf("%s", tr("CompletionUnknownShell", "Shell", name))
+				errorMsg(err.Error())
+				return err
+			}
+
+			file := shell.file()
+			if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			if err := shell.generate(cmd.Root(), file); err != nil {
+				errorMsg(tr("WriteFailed", "File", file, "Error", err))
+				return err
+			}
+			success(tr("CompletionWritten", "Shell", shell.name, "File", file))
+
+			if rcFile, snippet := shell.rc(file); rcFile != "" {
+				added, err := addRCSnippet(rcFile, snippet)
+				if err != nil {
+					errorMsg(tr("CompletionRCFailed", "File", rcFile, "Error", err))
+					return err
+				}
+				if added {
+					success(tr("CompletionRCAdded", "File", rcFile))
+				}
+			}
+
+			if problems := verifyCompletion(shell, file); len(problems) > 0 {
+				for _, problem := range problems {
+					warning(problem)
+				}
+			} else {
+				success(tr("CompletionVerified"))
+			}
+			info(tr("CompletionNewShell"))
+			return nil
+		},
+	}
+	completionCmd.AddCommand(installCmd)
+}
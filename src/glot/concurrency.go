@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// Lock held by the glot command running in the project, so that concurrent
+// invocations (an editor's check racing a manual build) take turns
+var projectLockFile = filepath.Join(stateDir, "lock")
+
+// What a command does when another holds the project lock: wait for it,
+// fail at once, or wait and take its result if it is the same command
+const (
+	concurrencyQueue = "queue"
+	concurrencyFail  = "fail"
+	concurrencyShare = "share"
+)
+
+// Mode given with --concurrency, over [concurrency] in glot.toml
+var concurrencyOption string
+
+// lockHolder describes the command holding the project lock, written to the
+// lock file for the commands waiting on it
+type lockHolder struct {
+	PID     int       `json:"pid"`
+	Command string    `json:"command"`
+	Argv    []string  `json:"argv"`
+	RunID   string    `json:"run_id"`
+	Started time.Time `json:"started"`
+}
+
+func (h lockHolder) String() string {
+	if h.PID == 0 {
+		return tr("ConcurrencyUnknownHolder")
+	}
+	return tr("ConcurrencyHolder", "Command", shellQuote(append([]string{"glot"}, h.Argv...)),
+		"PID", h.PID, "Duration", time.Since(h.Started).Round(time.Second))
+}
+
+// Concurrency mode of a command: --concurrency, then the command's entry
+// in [concurrency], then its default entry, then queue
+func concurrencyMode(command string) (string, error) {
+	mode := concurrencyOption
+	if mode == "" {
+		mode = currentConfig.Concurrency[command]
+	}
+	if mode == "" {
+		mode = currentConfig.Concurrency["default"]
+	}
+	if mode == "" {
+		return concurrencyQueue, nil
+	}
+	if !slices.Contains([]string{concurrencyQueue, concurrencyFail, concurrencyShare}, mode) {
+		return "", fmt.Errorf("%s", tr("ConcurrencyUnknownMode", "Mode", mode, "Command", command))
+	}
+	return mode, nil
+}
+
+// The project lock, once taken. The zero value holds nothing, for when the
+// lock file can't be used.
+type projectLock struct {
+	file *os.File
+}
+
+// Take the project lock for a run of command, as its concurrency mode says.
+// A non-nil record is the result of the same command finishing while this
+// one waited, shared instead of running; the lock is then already released.
+func acquireProjectLock(command string) (*projectLock, *runRecord, error) {
+	mode, err := concurrencyMode(command)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := ensureStateDir(stateDir); err != nil {
+		warning(tr("ConcurrencyNoStateDir", "Dir", stateDir, "Error", err))
+		return &projectLock{}, nil, nil
+	}
+	file, err := os.OpenFile(projectLockFile, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		warning(tr("ConcurrencyOpenFailed", "File", projectLockFile, "Error", err))
+		return &projectLock{}, nil, nil
+	}
+
+	var holder lockHolder
+	err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		holder = readLockHolder()
+		if mode == concurrencyFail {
+			file.Close()
+			return nil, nil, fmt.Errorf("%s", tr("ConcurrencyBusy", "Holder", holder))
+		}
+		info(tr("ConcurrencyWaiting", "Holder", holder))
+		err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX)
+	}
+	if err != nil {
+		file.Close()
+		warning(tr("ConcurrencyLockFailed", "File", projectLockFile, "Error", err))
+		return &projectLock{}, nil, nil
+	}
+	lock := &projectLock{file}
+
+	// The holder's record is written before it releases the lock; without
+	// one, it was interrupted and this command runs after all
+	if mode == concurrencyShare && holder.RunID != "" && holder.Command == command && slices.Equal(holder.Argv, os.Args[1:]) {
+		if record, err := findRunRecord(holder.RunID); err == nil {
+			lock.release()
+			return lock, record, nil
+		}
+	}
+	return lock, nil, nil
+}
+
+// Tell the commands waiting for the lock which run holds it
+func (l *projectLock) hold(command, runID string) {
+	if l.file == nil {
+		return
+	}
+	content, _ := json.Marshal(lockHolder{PID: os.Getpid(), Command: command, Argv: os.Args[1:], RunID: runID, Started: time.Now()})
+	l.file.Truncate(0)
+	l.file.WriteAt(content, 0)
+}
+
+func (l *projectLock) release() {
+	if l.file == nil {
+		return
+	}
+	l.file.Truncate(0)
+	syscall.Flock(int(l.file.Fd()), syscall.LOCK_UN)
+	l.file.Close()
+	l.file = nil
+}
+
+// The command holding the project lock, as far as it has written it
+func readLockHolder() lockHolder {
+	var holder lockHolder
+	if content, err := os.ReadFile(projectLockFile); err == nil {
+		json.Unmarshal(content, &holder)
+	}
+	return holder
+}
+
+// Report the result of a run shared with this one
+func sharedResult(record *runRecord) error {
+	if record.Success {
+		success(tr("ConcurrencyShared", "Command", record.Command, "ID", record.ID))
+		return nil
+	}
+	err := errors.New(record.Error)
+	errorMsg(tr("ConcurrencySharedFailed", "Command", record.Command, "ID", record.ID))
+	return err
+}
+
+// Use --concurrency for the commands taking the project lock
+func applyConcurrencyOption(cmd *cobra.Command) {
+	concurrencyOption, _ = cmd.Flags().GetString("concurrency")
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/spf13/cobra"
+)
+
+// Optional per-project configuration in the project root
+const configFile = "glot.toml"
+
+// projectConfig is the contents of glot.toml
+type projectConfig struct {
+	Store          string                  `toml:"store"`           // Default for --store
+	Impure         bool                    `toml:"impure"`          // Evaluate the flake with --impure, for env-dependent builds
+	Language       string                  `toml:"language"`        // Overrides language detection
+	Locale         string                  `toml:"locale"`          // Language of glot's messages, e.g. de (default: LANG)
+	DefaultTarget  string                  `toml:"default-target"`  // Flake output built and run when no target or variant is given
+	DefaultVariant string                  `toml:"default-variant"` // Variant used without --release or --variant (default: debug)
+	Variants       map[string]string       `toml:"variants"`        // Build variants and their flake outputs
+	Tools          []string                `toml:"tools"`           // Extra nixpkgs packages, added to the dev shell by the flake
+	Tasks          []templateCommand       `toml:"task"`            // Project commands, like those of glot-commands.toml
+	Format         formatConfig            `toml:"format"`
+	Watch          watchConfig             `toml:"watch"`
+	Redact         redactConfig            `toml:"redact"`
+	Lock           lockConfig              `toml:"lock"`
+	Resources      resourcesConfig         `toml:"resources"`
+	Cache          cacheConfig             `toml:"cache"`
+	Builders       buildersConfig          `toml:"builders"`
+	Private        privateConfig           `toml:"private"`
+	Policy         policyConfig            `toml:"policy"`
+	Gates          gatesConfig             `toml:"gates"`
+	Smoke          smokeConfig             `toml:"smoke"`
+	Check          checkConfig             `toml:"check"`
+	Package        packageConfig           `toml:"package"`
+	Secrets        secretsConfig           `toml:"secrets"`
+	Release        releaseConfig           `toml:"release"`
+	Concurrency    map[string]string       `toml:"concurrency"` // Mode per command while another runs: queue, fail or share
+	Deploy         map[string]deployConfig `toml:"deploy"`      // Environments of glot deploy
+}
+
+// Configuration of the project in the current directory, loaded at startup
+var currentConfig = &projectConfig{}
+
+// Load the project configuration, returning an empty one if there is none
+func loadProjectConfig(path string) (*projectConfig, error) {
+	var config projectConfig
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return &config, nil
+	}
+	if _, err := toml.DecodeFile(path, &config); err != nil {
+		return &projectConfig{}, fmt.Errorf("%s", tr("ConfigInvalid", "File", path, "Error", err))
+	}
+	if err := validateCommands(path, config.Tasks); err != nil {
+		config.Tasks = nil
+		return &config, err
+	}
+	if err := validateFormatConfig(path, config.Format); err != nil {
+		config.Format = formatConfig{}
+		return &config, err
+	}
+	if err := validateTaskDependencies(path, config.Tasks); err != nil {
+		config.Tasks = nil
+		return &config, err
+	}
+	if err := validateGatesConfig(path, config.Gates); err != nil {
+		config.Gates = gatesConfig{}
+		return &config, err
+	}
+	if err := validateSmokeConfig(path, config.Smoke); err != nil {
+		config.Smoke = smokeConfig{}
+		return &config, err
+	}
+	if err := validateCheckConfig(path, config.Check); err != nil {
+		config.Check = checkConfig{}
+		return &config, err
+	}
+	if err := validateSecretsConfig(path, config.Secrets); err != nil {
+		config.Secrets = secretsConfig{}
+		return &config, err
+	}
+	if err := validateDeployConfig(path, config.Deploy); err != nil {
+		config.Deploy = nil
+		return &config, err
+	}
+	return &config, nil
+}
+
+// Flake outputs of the built-in variants
+var defaultVariants = map[string]string{"debug": "dev", "release": "release"}
+
+// Names of the configured variants, built-in ones included
+func (c *projectConfig) variantNames() []string {
+	variants := map[string]string{}
+	for name, output := range defaultVariants {
+		variants[name] = output
+	}
+	for name, output := range c.Variants {
+		variants[name] = output
+	}
+	return sortedKeys(variants)
+}
+
+// Flake installable to build or run, and the name of the target or variant
+// for messages. An explicit target wins, with an explicit variant other than
+// debug as a suffix (mytool-release), then an explicit variant, then
+// default-target, then the default variant.
+func (c *projectConfig) installable(target, variant string) (ref, name string, err error) {
+	if target != "" {
+		if variant != "" && variant != "debug" && !strings.Contains(target, ".") {
+			target += "-" + variant
+		}
+		return ".#" + target, target, nil
+	}
+	if variant == "" && c.DefaultTarget != "" {
+		return ".#" + c.DefaultTarget, c.DefaultTarget, nil
+	}
+	if variant == "" {
+		variant = c.DefaultVariant
+	}
+	if variant == "" {
+		variant = "debug"
+	}
+	output, ok := c.Variants[variant]
+	if !ok {
+		output, ok = defaultVariants[variant]
+	}
+	if !ok {
+		return "", "", fmt.Errorf("%s", tr("ConfigUnknownVariant", "Variant", variant, "Variants", strings.Join(c.variantNames(), ", ")))
+	}
+	return ".#" + output, variant, nil
+}
+
+// Add --release and --variant to a build or run command
+func addVariantFlags(cmd *cobra.Command, verb string) {
+	cmd.Flags().Bool("release", false, verb+" release variant (same as --variant release)")
+	cmd.Flags().String("variant", "", verb+" a variant from glot.toml (default: default-target, default-variant or debug)")
+}
+
+// Variant requested on the command line, empty for the configured default
+func selectedVariant(cmd *cobra.Command) string {
+	if variant, _ := cmd.Flags().GetString("variant"); variant != "" {
+		return variant
+	}
+	if release, _ := cmd.Flags().GetBool("release"); release {
+		return "release"
+	}
+	return ""
+}
+
+// Project settings from glot.toml worth showing in glot info
+func (c *projectConfig) summary() []string {
+	var lines []string
+	if c.DefaultTarget != "" {
+		lines = append(lines, tr("InfoDefaultTarget", "Target", c.DefaultTarget))
+	}
+	if len(c.Variants) > 0 || c.DefaultVariant != "" {
+		variant := c.DefaultVariant
+		if variant == "" {
+			variant = "debug"
+		}
+		lines = append(lines, tr("InfoVariants", "Variants", strings.Join(c.variantNames(), ", "), "Default", variant))
+	}
+	if len(c.Tools) > 0 {
+		lines = append(lines, tr("InfoExtraTools", "Tools", strings.Join(c.Tools, ", ")))
+	}
+	if len(c.Tasks) > 0 {
+		names := make([]string, len(c.Tasks))
+		for i, task := range c.Tasks {
+			names[i] = task.Name
+		}
+		lines = append(lines, tr("InfoTasks", "Tasks", strings.Join(names, ", ")))
+	}
+	return lines
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/spf13/cobra"
+)
+
+// Example flake of a library project, using the library as an input
+const consumerDir = "consumer"
+
+// Whether a flake declares an input, as name.url = or name = { ... }
+func declaresInput(flake []byte, name string) bool {
+	pattern := regexp.MustCompile(`(?m)^\s*"?` + regexp.QuoteMeta(name) + `"?(\.url)?\s*=`)
+	return pattern.Match(flake)
+}
+
+func newVerifyConsumerCmd() *cobra.Command {
+	verifyCmd := &cobra.Command{
+		Use:   "verify-consumer",
+		Short: "Build the consumer example against the local library",
+		Long: "Build the example flake in consumer/ with its input for the library overridden by the project directory, " +
+			"proving that other flakes can use the library through its overlay or lib outputs before it is published. " +
+			"Like 'nix build .', the build sees the tracked files of the working tree, changes included.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkNix(); err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			dir, _ := cmd.Flags().GetString("dir")
+			flake, err := os.ReadFile(filepath.Join(dir, "flake.nix"))
+			if err != nil {
+				err := fmt.Errorf("%s", tr("ConsumerNoExample", "Dir", dir))
+				errorMsg(err.Error())
+				return err
+			}
+			cwd, err := os.Getwd()
+			if err != nil {
+				return err
+			}
+			input, _ := cmd.Flags().GetString("input")
+			if input == "" {
+				input = filepath.Base(cwd)
+			}
+			if !declaresInput(flake, input) {
+				err := fmt.Errorf("%s", tr("ConsumerNoInput", "Dir", dir, "Input", input))
+				errorMsg(err.Error())
+				return err
+			}
+
+			info(tr("ConsumerBuilding", "Dir", dir, "Input", input))
+			if err := runNix("build", "./"+dir, "--override-input", input, cwd, "--no-link"); err != nil {
+				errorMsg(tr("ConsumerFailed", "Dir", dir, "Input", input))
+				return err
+			}
+			success(tr("ConsumerBuilds", "Dir", dir, "Input", input))
+			return nil
+		},
+	}
+	verifyCmd.Flags().String("dir", consumerDir, "Directory of the example flake using the library")
+	verifyCmd.Flags().String("input", "", "The example's input for the library (default: the project directory's name)")
+	return verifyCmd
+}
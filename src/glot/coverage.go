@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Where glot test --coverage writes reports: a directory per language with
+// the tool's data and an html/ report, and an index.html linking them
+var coverageDir = filepath.Join(stateDir, "coverage")
+
+// Empty the coverage directory of a language, returning its absolute path
+func prepareCoverageDir(language string) (string, error) {
+	if err := ensureStateDir(coverageDir); err != nil {
+		return "", err
+	}
+	out, err := filepath.Abs(filepath.Join(coverageDir, language))
+	if err != nil {
+		return "", err
+	}
+	if err := os.RemoveAll(out); err != nil {
+		return "", err
+	}
+	return out, os.MkdirAll(filepath.Join(out, "html"), 0755)
+}
+
+// Coverage of one language's last run
+type coverageResult struct {
+	Language string
+	Unit     string // What was counted: lines or statements
+	Covered  int
+	Total    int
+	Report   string // HTML report, relative to coverageDir
+}
+
+func (r coverageResult) Percent() float64 {
+	if r.Total == 0 {
+		return 0
+	}
+	return 100 * float64(r.Covered) / float64(r.Total)
+}
+
+// Sum the LH/LF line counts of an lcov file
+func readLcov(file string) (covered, total int, err error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if value, ok := strings.CutPrefix(line, "LF:"); ok {
+			n, _ := strconv.Atoi(value)
+			total += n
+		} else if value, ok := strings.CutPrefix(line, "LH:"); ok {
+			n, _ := strconv.Atoi(value)
+			covered += n
+		}
+	}
+	return covered, total, scanner.Err()
+}
+
+// Count the statements of a Go cover profile, each block once even when
+// several packages' tests cover it
+func readCoverProfile(file string) (covered, total int, err error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+	type block struct {
+		statements int
+		hit        bool
+	}
+	blocks := map[string]*block{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// name.go:line.column,line.column statements count
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 || strings.HasPrefix(fields[0], "mode:") {
+			continue
+		}
+		statements, _ := strconv.Atoi(fields[1])
+		count, _ := strconv.Atoi(fields[2])
+		b, ok := blocks[fields[0]]
+		if !ok {
+			b = &block{statements: statements}
+			blocks[fields[0]] = b
+		}
+		b.hit = b.hit || count > 0
+	}
+	for _, b := range blocks {
+		total += b.statements
+		if b.hit {
+			covered += b.statements
+		}
+	}
+	return covered, total, scanner.Err()
+}
+
+// Coverage of every language with data in coverageDir
+func collectCoverage() []coverageResult {
+	var results []coverageResult
+	entries, _ := os.ReadDir(coverageDir)
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		dir := filepath.Join(coverageDir, entry.Name())
+		result := coverageResult{Language: entry.Name(), Report: filepath.ToSlash(filepath.Join(entry.Name(), "html", "index.html"))}
+		var err error
+		if _, statErr := os.Stat(filepath.Join(dir, "lcov.info")); statErr == nil {
+			result.Unit = "lines"
+			result.Covered, result.Total, err = readLcov(filepath.Join(dir, "lcov.info"))
+		} else if _, statErr := os.Stat(filepath.Join(dir, "cover.out")); statErr == nil {
+			result.Unit = "statements"
+			result.Covered, result.Total, err = readCoverProfile(filepath.Join(dir, "cover.out"))
+		} else {
+			continue
+		}
+		if err != nil {
+			warning(tr("CoverageReadFailed", "Language", entry.Name(), "Error", err))
+			continue
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+var coverageIndex = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Coverage</title></head>
+<body>
+<h1>Coverage</h1>
+<table>
+<tr><th>Language</th><th>Coverage</th><th>Covered</th></tr>
+{{range .}}<tr><td><a href="{{.Report}}">{{.Language}}</a></td><td>{{printf "%.1f" .Percent}}%</td><td>{{.Covered}} of {{.Total}} {{.Unit}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// Message of the covered-of-total summary, by coverage unit
+var coverageUnitMessages = map[string]string{
+	"lines":      "CoverageLines",
+	"statements": "CoverageStatements",
+}
+
+// Print the coverage of every language and write the index linking their
+// HTML reports
+func reportCoverage() error {
+	results := collectCoverage()
+	if len(results) == 0 {
+		return fmt.Errorf("%s", tr("CoverageNoData", "Dir", coverageDir))
+	}
+	fmt.Println()
+	fmt.Println(plain("📊 " + tr("CoverageHeader")))
+	for _, result := range results {
+		fmt.Printf("  %-12s %5.1f%%  %s\n", result.Language, result.Percent(), trn(coverageUnitMessages[result.Unit], result.Total, "Covered", result.Covered))
+	}
+
+	index := filepath.Join(coverageDir, "index.html")
+	f, err := os.Create(index)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := coverageIndex.Execute(f, results); err != nil {
+		return err
+	}
+	info(tr("CoverageHTMLReport", "File", index))
+	return nil
+}
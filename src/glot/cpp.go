@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+	"regexp"
+)
+
+var cmakeProjectPattern = regexp.MustCompile(`(?i)project\s*\(\s*([A-Za-z0-9_.+-]+)`)
+
+type cppLanguage struct{ toolchain }
+
+func newCppLanguage() Language {
+	return cppLanguage{toolchain{
+		name:       "cpp",
+		markers:    []string{"CMakeLists.txt"},
+		lsp:        []string{"clangd"},
+		lspPackage: "clang-tools",
+		format:     []string{"bash", "-c", `find . -path ./build -prune -o \( -name '*.c' -o -name '*.cc' -o -name '*.cpp' -o -name '*.h' -o -name '*.hpp' \) -exec clang-format -i {} +`},
+		lint:       []string{"cppcheck", "--enable=warning,style", "--error-exitcode=1", "src"},
+		test:       []string{"bash", "-c", "cmake -B build && cmake --build build && ctest --test-dir build --output-on-failure"},
+		testWith: func(options testOptions) ([]string, error) {
+			command := []string{"bash", "-c", `cmake -B build && cmake --build build && ctest --test-dir build --output-on-failure "$@"`, "glot-test"}
+			if options.Verbose {
+				command = append(command, "-V")
+			}
+			if options.Filter != "" {
+				command = append(command, "-R", options.Filter)
+			}
+			return append(command, options.Args...), nil
+		},
+		artifacts: []string{"build/"},
+	}}
+}
+
+func (l cppLanguage) Info() []string {
+	if name, _ := l.Project(); name != "" {
+		return []string{tr("InfoCMakeProject", "Name", name)}
+	}
+	return nil
+}
+
+func (cppLanguage) Project() (string, string) {
+	content, err := os.ReadFile("CMakeLists.txt")
+	if err != nil {
+		return "", ""
+	}
+	if m := cmakeProjectPattern.FindSubmatch(content); m != nil {
+		return string(m[1]), ""
+	}
+	return "", ""
+}
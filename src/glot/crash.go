@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	runtimedebug "runtime/debug"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Reports of glot's crashes, kept locally: glot sends nothing anywhere
+var crashDir = filepath.Join(stateDir, "crash")
+
+// Exit status of a crash, EX_SOFTWARE of sysexits.h
+const crashExitCode = 70
+
+// Where glot's issues are reported
+const issuesURL = "https://github.com/ritzau/nix-polyglot/issues"
+
+// Report a panic: write the stack and context to crashDir, point the user
+// at it and at glot bug-report, and exit. Deferred first in main and in
+// every goroutine glot starts, since a panic only unwinds its own goroutine.
+func recoverCrash() {
+	crash := recover()
+	if crash == nil {
+		return
+	}
+	stack := runtimedebug.Stack()
+	path, err := writeCrashReport(crash, stack)
+	// Written directly: the logging itself may be what crashed
+	fmt.Fprintf(os.Stderr, "\n%s\n", plain(fmt.Sprintf("💥 glot crashed: %v", crash)))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not save the crash report (%s), so here is the stack:\n%s\n", err, stack)
+	} else {
+		fmt.Fprintf(os.Stderr, "The stack and context are in %s\n", path)
+	}
+	fmt.Fprintf(os.Stderr, "Please report it: run 'glot bug-report -o report.tar.gz' and attach the bundle to an issue at %s\n", issuesURL)
+	os.Exit(crashExitCode)
+}
+
+// Write a crash report to crashDir, or the temporary directory if the
+// project's isn't writable. Everything is redacted like shared logs.
+func writeCrashReport(crash any, stack []byte) (string, error) {
+	now := time.Now()
+	var report strings.Builder
+	fmt.Fprintf(&report, "glot %s crashed at %s\n\n", version, now.Format(time.RFC3339))
+	fmt.Fprintf(&report, "Panic: %v\n\n", crash)
+	fmt.Fprintf(&report, "Command: %s\n", shellQuote(os.Args))
+	fmt.Fprintf(&report, "Go: %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	fmt.Fprintf(&report, "OS: %s\n", osDescription())
+	if cwd, err := os.Getwd(); err == nil {
+		fmt.Fprintf(&report, "Directory: %s\n", cwd)
+	}
+	if commit := gitCommit(); commit != "" {
+		fmt.Fprintf(&report, "Commit: %s\n", commit)
+	}
+	fmt.Fprintf(&report, "\nStack:\n%s", stack)
+	content := redactLog([]byte(report.String()))
+
+	name := now.Format("20060102-150405") + ".log"
+	dir := crashDir
+	if err := ensureStateDir(dir); err != nil {
+		dir = filepath.Join(os.TempDir(), "glot-crash")
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return "", err
+		}
+	}
+	path := filepath.Join(dir, name)
+	return path, os.WriteFile(path, content, 0644)
+}
+
+// Path of the project's latest crash report, if any
+func lastCrashReport() string {
+	reports, _ := filepath.Glob(filepath.Join(crashDir, "*.log"))
+	if len(reports) == 0 {
+		return ""
+	}
+	// Named by time, so the last in order is the latest
+	sort.Strings(reports)
+	return reports[len(reports)-1]
+}
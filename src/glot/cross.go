@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Whether the Linux kernel runs binaries of system through binfmt_misc,
+// as qemu-user registers them (qemu-aarch64, aarch64-linux, ...)
+func binfmtRegistered(system string) bool {
+	arch, _, _ := strings.Cut(system, "-")
+	entries, _ := filepath.Glob("/proc/sys/fs/binfmt_misc/*" + arch + "*")
+	return len(entries) > 0
+}
+
+// What the user can do to build for system on a native machine
+func systemSupportHint(native, system string) string {
+	nativeOS := native[strings.Index(native, "-")+1:]
+	systemOS := system[strings.Index(system, "-")+1:]
+	switch {
+	case nativeOS == "linux" && systemOS == "linux" && binfmtRegistered(system):
+		return tr("SystemHintExtraPlatforms", "System", system)
+	case nativeOS == "linux" && systemOS == "linux":
+		return tr("SystemHintBinfmt", "System", system)
+	case nativeOS == "darwin" && system == "x86_64-darwin":
+		return tr("SystemHintRosetta")
+	case nativeOS == "darwin" && systemOS == "linux":
+		return tr("SystemHintLinuxBuilder", "System", system)
+	}
+	return tr("SystemHintBuilder", "System", system)
+}
+
+// Build for another system, if given, with nix's --system, after checking
+// that nix can: natively, through emulation in extra-platforms, or on a
+// remote builder
+func useBuildSystem(system string) error {
+	if system == "" {
+		return nil
+	}
+	if strings.Count(system, "-") != 1 {
+		return fmt.Errorf("%s", tr("SystemInvalid", "System", system))
+	}
+	native, err := currentSystem()
+	if err != nil || native == system {
+		return nil
+	}
+	extra, _ := nixSetting("extra-platforms")
+	var builders []string
+	machines, _ := configuredBuilders()
+	for _, machine := range machines {
+		builders = append(builders, machine.Systems...)
+	}
+	if !slices.Contains(strings.Fields(extra), system) && !slices.Contains(builders, system) {
+		return fmt.Errorf("%s", tr("SystemUnsupported", "System", system, "Native", native,
+			"Hint", systemSupportHint(native, system)))
+	}
+	debug(fmt.Sprintf("Building for %s on %s", system, native))
+	nixGlobalArgs = append(nixGlobalArgs, "--system", system)
+	return nil
+}
+
+// The flake's cross output for ref: the package <output>-<target>, built on
+// this machine for target, e.g. .#release-aarch64-linux. The output must
+// exist and evaluate, which needs nixpkgs to have a toolchain for target.
+func crossInstallable(ref, target string) (string, string, error) {
+	output := strings.TrimPrefix(ref, ".#")
+	if strings.Contains(output, ".") {
+		return "", "", fmt.Errorf("%s", tr("CrossAttributePath", "Output", output))
+	}
+	name := output + "-" + target
+	if err := checkTarget(name, false); err != nil {
+		return "", "", fmt.Errorf("%s", tr("CrossNoOutput", "Output", name, "Target", target))
+	}
+	if _, err := nixOutput("eval", "--raw", ".#"+name+".drvPath"); err != nil {
+		lines := strings.Split(strings.TrimSpace(err.Error()), "\n")
+		return "", "", fmt.Errorf("%s", tr("CrossNoToolchain", "Target", target, "Error", strings.TrimSpace(lines[len(lines)-1])))
+	}
+	return ".#" + name, name, nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+type csharpLanguage struct{ toolchain }
+
+func newCSharpLanguage() Language {
+	return csharpLanguage{toolchain{
+		name:       "csharp",
+		markers:    []string{"*.csproj", "*.sln"},
+		lsp:        []string{"csharp-ls"},
+		lspPackage: "csharp-ls",
+		format:     []string{"dotnet", "format"},
+		lint:       []string{"dotnet", "format", "--verify-no-changes"},
+		test:       []string{"dotnet", "test"},
+		testWith: func(options testOptions) ([]string, error) {
+			command := []string{"dotnet", "test"}
+			if options.Verbose {
+				command = append(command, "--logger", "console;verbosity=detailed")
+			}
+			if options.Filter != "" {
+				command = append(command, "--filter", options.Filter)
+			}
+			return append(command, options.Args...), nil
+		},
+		artifacts: []string{"bin/", "obj/"},
+	}}
+}
+
+func (csharpLanguage) Info() []string {
+	projects, _ := filepath.Glob("*.csproj")
+	if len(projects) == 0 {
+		return nil
+	}
+	return []string{tr("InfoCSharpProjects", "Projects", strings.Join(projects, ", "))}
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// deployConfig is an environment of [deploy.<env>] in glot.toml
+type deployConfig struct {
+	// fly, cloudrun, k8s, nixos, or the name of a glot-deploy-<backend> plugin
+	Backend string `toml:"backend"`
+	// Reference the image is pushed to, with {version} replaced, e.g.
+	// registry.fly.io/app:{version} (container backends)
+	Image string `toml:"image"`
+	// Flake output with the image (default: image), or the NixOS configuration
+	// of nixos (default: the environment's name)
+	Artifact string `toml:"artifact"`
+	// Fly app, Cloud Run service, or Kubernetes deployment
+	App       string `toml:"app"`
+	Region    string `toml:"region"`    // Cloud Run region
+	Project   string `toml:"project"`   // Google Cloud project
+	Context   string `toml:"context"`   // kubectl context
+	Namespace string `toml:"namespace"` // Kubernetes namespace
+	Container string `toml:"container"` // Container of the deployment to update (default: app)
+	Host      string `toml:"host"`      // SSH target of nixos, e.g. root@staging.example.com
+	// glot commands that must pass on the deployed commit first, e.g. "check"
+	Requires []string `toml:"requires"`
+	// Shell commands run after the deployment, which fail it if they fail
+	Smoke []string `toml:"smoke"`
+	// Settings of a plugin backend, passed as GLOT_DEPLOY_<NAME>
+	Options map[string]string `toml:"options"`
+}
+
+// Where glot deploy keeps the image tarballs it pushes
+var deployDir = filepath.Join(stateDir, "deploy")
+
+// A built-in deployment backend: whether it deploys the image, and how
+type deployBackend struct {
+	image  bool
+	deploy func(env string, config deployConfig, image string) error
+}
+
+var deployBackends = map[string]deployBackend{
+	"fly":      {true, deployFly},
+	"cloudrun": {true, deployCloudRun},
+	"k8s":      {true, deployKubernetes},
+	"nixos":    {false, deployNixOS},
+}
+
+// Run a deployment tool from PATH, or else from nixpkgs' package
+func runDeployTool(tool, pkg string, args ...string) error {
+	if _, err := exec.LookPath(tool); err == nil {
+		return runTool(tool, args...)
+	}
+	return runNix(append([]string{"shell", "nixpkgs#" + pkg, "--command", tool}, args...)...)
+}
+
+func deployFly(env string, config deployConfig, image string) error {
+	args := []string{"deploy", "--image", image}
+	if config.App != "" {
+		args = append(args, "--app", config.App)
+	}
+	return runDeployTool("flyctl", "flyctl", args...)
+}
+
+func deployCloudRun(env string, config deployConfig, image string) error {
+	if config.App == "" {
+		return fmt.Errorf("%s", tr("DeployNoCloudRunApp", "Env", env))
+	}
+	args := []string{"run", "deploy", config.App, "--image", image, "--quiet"}
+	if config.Region != "" {
+		args = append(args, "--region", config.Region)
+	}
+	if config.Project != "" {
+		args = append(args, "--project", config.Project)
+	}
+	return runDeployTool("gcloud", "google-cloud-sdk", args...)
+}
+
+func deployKubernetes(env string, config deployConfig, image string) error {
+	if config.App == "" {
+		return fmt.Errorf("%s", tr("DeployNoK8sApp", "Env", env))
+	}
+	var kubectl []string
+	if config.Context != "" {
+		kubectl = append(kubectl, "--context", config.Context)
+	}
+	if config.Namespace != "" {
+		kubectl = append(kubectl, "--namespace", config.Namespace)
+	}
+	container := config.Container
+	if container == "" {
+		container = "app"
+	}
+	deployment := "deployment/" + config.App
+	if err := runDeployTool("kubectl", "kubectl", append(kubectl, "set", "image", deployment, container+"="+image)...); err != nil {
+		return err
+	}
+	info(tr("DeployWaitingRollout", "Deployment", deployment))
+	return runDeployTool("kubectl", "kubectl", append(kubectl, "rollout", "status", deployment, "--timeout", "5m")...)
+}
+
+func deployNixOS(env string, config deployConfig, image string) error {
+	if config.Host == "" {
+		return fmt.Errorf("%s", tr("DeployNoHost", "Env", env))
+	}
+	configuration := config.Artifact
+	if configuration == "" {
+		configuration = env
+	}
+	return runDeployTool("nixos-rebuild", "nixos-rebuild", "switch", "--flake", ".#"+configuration,
+		"--target-host", config.Host, "--use-remote-sudo")
+}
+
+// Environment describing the deployment to plugin backends and smoke commands
+func deployEnv(env string, config deployConfig, image string) []string {
+	vars := append(pluginEnv(), "GLOT_DEPLOY_ENV="+env, "GLOT_DEPLOY_VERSION="+releaseVersion())
+	for name, value := range map[string]string{"IMAGE": image, "APP": config.App, "HOST": config.Host} {
+		if value != "" {
+			vars = append(vars, "GLOT_DEPLOY_"+name+"="+value)
+		}
+	}
+	for _, name := range sortedKeys(config.Options) {
+		vars = append(vars, "GLOT_DEPLOY_"+strings.ToUpper(strings.ReplaceAll(name, "-", "_"))+"="+config.Options[name])
+	}
+	return vars
+}
+
+// Deploy with glot-deploy-<backend>, from PATH or the dev shell
+func deployWithPlugin(env string, config deployConfig, image string) error {
+	plugin := pluginPrefix + "deploy-" + config.Backend
+	var cmd *exec.Cmd
+	if _, err := exec.LookPath(plugin); err == nil {
+		cmd = exec.Command(plugin, env)
+	} else if devShellHas(plugin) {
+		cmd = exec.Command("nix", withNixGlobalArgs([]string{"develop", "--command", plugin, env})...)
+	} else {
+		return fmt.Errorf("%s", tr("DeployUnknownBackend", "Backend", config.Backend, "Plugin", plugin))
+	}
+	cmd.Env = deployEnv(env, config, image)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = newRedactingWriter(logged(os.Stdout))
+	cmd.Stderr = newRedactingWriter(logged(stderrOutput()))
+	logCommand(cmd)
+	return cmd.Run()
+}
+
+// Whether a plugin backend deploys the image: only if the environment says
+// where to push it
+func deploysImage(config deployConfig) bool {
+	if backend, ok := deployBackends[config.Backend]; ok {
+		return backend.image
+	}
+	return config.Image != ""
+}
+
+// Build the environment's image and push it to its reference with skopeo,
+// returning the reference
+func pushDeployImage(env string, config deployConfig) (string, error) {
+	if config.Image == "" {
+		return "", fmt.Errorf("%s", tr("DeployNoImage", "Env", env))
+	}
+	image := strings.ReplaceAll(config.Image, "{version}", releaseVersion())
+	output := config.Artifact
+	if output == "" {
+		output = "image"
+	}
+	info(tr("DeployBuildingOutput", "Output", output))
+	script, err := buildImageOutput(output)
+	if err != nil {
+		return "", err
+	}
+	if err := ensureStateDir(deployDir); err != nil {
+		return "", err
+	}
+	tarball := filepath.Join(deployDir, env+".tar")
+	defer os.Remove(tarball)
+	if err := streamImage(script, tarball); err != nil {
+		return "", fmt.Errorf("%s", tr("DeployImageWriteFailed", "Error", err))
+	}
+	if err := smokeTestImage(tarball); err != nil {
+		return "", fmt.Errorf("%s", tr("DeployNotPushing", "Image", image, "Error", err))
+	}
+	info(tr("DeployPushing", "Image", image))
+	if err := runDeployTool("skopeo", "skopeo", "copy", "docker-archive:"+tarball, "docker://"+image); err != nil {
+		return "", fmt.Errorf("%s", tr("DeployPushFailed", "Image", image, "Error", err))
+	}
+	return image, nil
+}
+
+// Make sure the glot commands an environment requires pass on this commit:
+// a passing run recorded for the commit of a clean worktree counts,
+// otherwise the command runs now
+func checkDeployPreconditions(env string, requires []string) error {
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	status, _ := exec.Command("git", "status", "--porcelain").Output()
+	commit := gitCommit()
+	records, _ := listRunRecords()
+	for _, required := range requires {
+		words := strings.Fields(required)
+		if len(words) == 0 {
+			continue
+		}
+		if len(words) == 1 && len(status) == 0 && commit != "" {
+			if record := lastRunOf(records, words[0]); record != nil && record.Commit == commit && record.Success {
+				success(tr("DeployRequiredPassed", "Required", required, "Commit", commit, "Run", record.ID))
+				continue
+			}
+		}
+		info(tr("DeployRunningRequired", "Required", required, "Env", env))
+		if err := runTool(self, words...); err != nil {
+			return fmt.Errorf("%s", tr("DeployRequiredFailed", "Required", required, "Env", env))
+		}
+	}
+	return nil
+}
+
+// The latest run of a command among records, newest first
+func lastRunOf(records []runRecord, command string) *runRecord {
+	for i := range records {
+		if records[i].Command == command {
+			return &records[i]
+		}
+	}
+	return nil
+}
+
+// Run the environment's smoke commands against the deployment
+func runDeploySmoke(env string, config deployConfig, image string) error {
+	var failed []string
+	for _, command := range config.Smoke {
+		info(tr("DeploySmokeTest", "Command", command))
+		cmd := exec.Command("sh", "-c", command)
+		cmd.Env = deployEnv(env, config, image)
+		cmd.Stdout = newRedactingWriter(logged(os.Stdout))
+		cmd.Stderr = newRedactingWriter(logged(stderrOutput()))
+		logCommand(cmd)
+		if err := cmd.Run(); err != nil {
+			warning(tr("DeploySmokeTestFailed", "Status", exitStatus(err), "Command", command))
+			failed = append(failed, command)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%s", tr("DeploySmokeTestsFailed", "Env", env, "Failed", len(failed), "Total", len(config.Smoke)))
+	}
+	if len(config.Smoke) > 0 {
+		success(tr("DeploySmokeTestsPassed"))
+	}
+	return nil
+}
+
+// Deploy to an environment: build, smoke-test and push its image if the
+// backend deploys one, or else smoke-test the release binary, deploy, and
+// run its smoke tests
+func deploy(env string, config deployConfig) error {
+	image := ""
+	if deploysImage(config) {
+		var err error
+		if image, err = pushDeployImage(env, config); err != nil {
+			return err
+		}
+	} else if err := smokeTestRelease(); err != nil {
+		return fmt.Errorf("%s", tr("DeployNotDeploying", "Env", env, "Error", err))
+	}
+	info(tr("DeployDeploying", "Env", env, "Backend", config.Backend))
+	deployFn := deployWithPlugin
+	if backend, ok := deployBackends[config.Backend]; ok {
+		deployFn = backend.deploy
+	}
+	if err := deployFn(env, config, image); err != nil {
+		return fmt.Errorf("%s", tr("DeployFailed", "Env", env, "Error", err))
+	}
+	success(tr("DeployDeployed", "Version", releaseVersion(), "Env", env))
+	return runDeploySmoke(env, config, image)
+}
+
+func newDeployCmd() *cobra.Command {
+	deployCmd := &cobra.Command{
+		Use:   "deploy <env>",
+		Short: "Deploy to an environment of glot.toml",
+		Long: "Deploy the project to an environment declared in [deploy.<env>] of glot.toml: run the glot commands it " +
+			"requires unless they passed on this commit, build, smoke-test and push the image for fly, cloudrun and k8s, deploy " +
+			"with the backend - or with a glot-deploy-<backend> plugin - and run the environment's smoke tests.",
+		Args: cobra.ExactArgs(1),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			return sortedKeys(currentConfig.Deploy), cobra.ShellCompDirectiveNoFileComp
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			env := args[0]
+			config, ok := currentConfig.Deploy[env]
+			if !ok {
+				err := fmt.Errorf("%s", tr("DeployUnknownEnv", "Env", env, "Config", configFile))
+				if len(currentConfig.Deploy) > 0 {
+					err = fmt.Errorf("%s", tr("DeployUnknownEnvAvailable", "Env", env, "Config", configFile, "Available", strings.Join(sortedKeys(currentConfig.Deploy), ", ")))
+				}
+				errorMsg(err.Error())
+				return err
+			}
+			if err := checkNix(); err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			if status, _ := exec.Command("git", "status", "--porcelain").Output(); len(status) > 0 {
+				warning(tr("DeployUncommitted"))
+			}
+			if skip, _ := cmd.Flags().GetBool("skip-preconditions"); skip {
+				warning(tr("DeployNotChecking", "Env", env))
+			} else if err := checkDeployPreconditions(env, config.Requires); err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			return recordRun("deploy", args, func() error {
+				if err := deploy(env, config); err != nil {
+					errorMsg(err.Error())
+					return err
+				}
+				return nil
+			})
+		},
+	}
+	deployCmd.Flags().Bool("skip-preconditions", false, "Deploy without running the glot commands the environment requires")
+	return deployCmd
+}
+
+func validateDeployConfig(path string, deploy map[string]deployConfig) error {
+	for _, env := range sortedKeys(deploy) {
+		if deploy[env].Backend == "" {
+			return fmt.Errorf("%s", tr("DeployNoBackend", "Path", path, "Env", env))
+		}
+	}
+	return nil
+}
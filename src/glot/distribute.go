@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Build logs of glot build --distribute, one per system
+var distributeDir = filepath.Join(stateDir, "distribute")
+
+// nix's note of a derivation it builds, with the builder it builds on:
+// building '/nix/store/...-hello.drv' on 'ssh-ng://me@mac'...
+var buildingLine = regexp.MustCompile(`building '[^']+'(?: on '([^']+)')?`)
+
+// Outcome of building the target for one system of glot build --distribute
+type systemBuild struct {
+	system   string
+	builders []remoteBuilder // Those the build may use, none to build locally
+	where    []string        // Builders nix built on, "local" for this machine
+	built    bool            // Whether nix built anything, rather than substituting
+	err      error
+	duration time.Duration
+	result   string // The out link
+}
+
+// Records where nix builds, from the lines of its log
+type buildWatcher struct {
+	build   *systemBuild
+	pending []byte
+}
+
+func (w *buildWatcher) Write(p []byte) (int, error) {
+	w.pending = append(w.pending, p...)
+	for {
+		i := bytes.IndexByte(w.pending, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		if m := buildingLine.FindSubmatch(w.pending[:i]); m != nil {
+			w.build.built = true
+			where := "local"
+			if len(m[1]) > 0 {
+				where = string(m[1])
+			}
+			if !slices.Contains(w.build.where, where) {
+				w.build.where = append(w.build.where, where)
+			}
+		}
+		w.pending = w.pending[i+1:]
+	}
+}
+
+// Systems the flake has the package name for
+func flakePackageSystems(name string) ([]string, error) {
+	out, err := nixOutput("flake", "show", "--json", "--all-systems")
+	if err != nil {
+		// nix before 2.16 shows every system without being asked
+		if out, err = nixOutput("flake", "show", "--json"); err != nil {
+			return nil, err
+		}
+	}
+	var show struct {
+		Packages map[string]map[string]json.RawMessage `json:"packages"`
+	}
+	if err := json.Unmarshal(out, &show); err != nil {
+		return nil, fmt.Errorf("unexpected nix flake show output: %w", err)
+	}
+	var systems []string
+	for _, system := range sortedKeys(show.Packages) {
+		if _, ok := show.Packages[system][name]; ok {
+			systems = append(systems, system)
+		}
+	}
+	return systems, nil
+}
+
+// Build the target for every system of the flake at once: this machine's
+// systems locally, the others on the builders for them, each logged to
+// .glot/distribute/<system>.log and linked as result-<system>
+func buildDistributed(target, variant string, overrides []string) error {
+	builders, _ := configuredBuilders()
+	if len(builders) == 0 {
+		return fmt.Errorf("%s", tr("DistributeNoBuilders"))
+	}
+	ref, _, err := currentConfig.installable(target, variant)
+	if err != nil {
+		return err
+	}
+	name := strings.TrimPrefix(ref, ".#")
+	if strings.Contains(name, ".") {
+		return fmt.Errorf("%s", tr("DistributeNotPackage", "Target", name))
+	}
+	systems, err := flakePackageSystems(name)
+	if err != nil {
+		return fmt.Errorf("%s", tr("DistributeListFailed", "Error", err))
+	}
+	native, err := currentSystem()
+	if err != nil {
+		return err
+	}
+	extra, _ := nixSetting("extra-platforms")
+	local := append([]string{native}, strings.Fields(extra)...)
+
+	var builds []*systemBuild
+	for _, system := range systems {
+		build := &systemBuild{system: system}
+		for _, b := range builders {
+			if slices.Contains(b.Systems, system) {
+				build.builders = append(build.builders, b)
+			}
+		}
+		switch {
+		case system == native || len(build.builders) == 0 && slices.Contains(local, system):
+			build.builders = nil
+		case len(build.builders) == 0:
+			warning(tr("DistributeSkipped", "System", system))
+			continue
+		}
+		builds = append(builds, build)
+	}
+	if len(builds) == 0 {
+		return fmt.Errorf("%s", tr("DistributeNoSystems", "Target", name))
+	}
+	if err := ensureStateDir(distributeDir); err != nil {
+		return err
+	}
+	info(trn("DistributeStarted", len(builds), "Target", name))
+
+	out := newRedactingWriter(logged(stderrOutput()))
+	defer out.Flush()
+	var mu sync.Mutex
+	width := 0
+	for _, build := range builds {
+		width = max(width, len(build.system))
+	}
+	var wg sync.WaitGroup
+	for _, build := range builds {
+		wg.Add(1)
+		go func() {
+			defer recoverCrash()
+			defer wg.Done()
+			build.result = "result-" + build.system
+			args := append([]string{"build"}, overriddenInstallable(".#packages."+build.system+"."+name, overrides)...)
+			args = append(args, "--out-link", build.result, "--print-build-logs")
+			if len(build.builders) > 0 {
+				// Only the builders for the system, whatever else nix has
+				var machines []string
+				for _, b := range build.builders {
+					machines = append(machines, b.String())
+				}
+				args = append(args, "--builders", strings.Join(machines, ";"))
+			}
+
+			w := &prefixWriter{mu: &mu, out: out, prefix: fmt.Sprintf("[%-*s] ", width, build.system)}
+			writers := []io.Writer{w, &buildWatcher{build: build}}
+			systemLog, err := os.Create(filepath.Join(distributeDir, build.system+".log"))
+			if err == nil {
+				defer systemLog.Close()
+				writers = append(writers, systemLog)
+			}
+			cmd := exec.Command("nix", withNixGlobalArgs(args)...)
+			cmd.Stdout = io.MultiWriter(writers...)
+			cmd.Stderr = cmd.Stdout
+			logCommand(cmd)
+			started := time.Now()
+			build.err = cmd.Run()
+			w.Flush()
+			build.duration = time.Since(started)
+		}()
+	}
+	wg.Wait()
+	out.Flush()
+
+	failed := 0
+	fmt.Println()
+	fmt.Println(plain("📊 " + tr("DistributeSummary")))
+	for _, build := range builds {
+		status := "✅"
+		if build.err != nil {
+			status = "❌"
+			failed++
+		}
+		where := strings.Join(build.where, ", ")
+		switch {
+		case !build.built && build.err == nil:
+			where = tr("DistributeSubstituted")
+		case !build.built:
+			where = "-"
+		}
+		result := build.result
+		if build.err != nil {
+			result = filepath.Join(distributeDir, build.system+".log")
+		}
+		fmt.Printf(plain("  %s %-*s %8s  %-28s %s\n"), status, width, build.system, build.duration.Round(100*time.Millisecond), where, result)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%s", trn("DistributeFailed", failed, "Total", len(builds)))
+	}
+	success(trn("DistributeCompleted", len(builds), "Target", name))
+	return nil
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// Oldest nix with flakes and the nix command glot uses
+var minNixVersion = [2]int{2, 4}
+
+// Free space in /nix below which builds start failing
+const (
+	nixDiskWarning = 5 << 30
+	nixDiskFailure = 1 << 30
+)
+
+// Outcome of a doctor check
+type diagnosis struct {
+	status string // ok, warning or failure
+	detail string // What the check found
+	fix    string // How to fix a warning or failure
+}
+
+func healthy(detail string) diagnosis { return diagnosis{"ok", detail, ""} }
+
+// A check of glot's environment
+type doctorCheck struct {
+	name string
+	run  func() diagnosis
+	// Whether the check runs nix, so it is skipped if nix doesn't work
+	nix bool
+}
+
+// Checks in order, nix itself first
+var doctorChecks = []doctorCheck{
+	{"nix", checkNixVersion, false},
+	{"flakes", checkFlakesEnabled, true},
+	{"nix store", checkNixStore, true},
+	{"direnv", checkDirenv, false},
+	{"disk space", checkNixDiskSpace, false},
+	{"substituters", checkSubstituters, true},
+	{"toolchains", checkToolchains, true},
+}
+
+// Major and minor version of `nix --version` output, e.g. nix (Nix) 2.24.9
+func parseNixVersion(output string) ([2]int, bool) {
+	fields := strings.Fields(output)
+	if len(fields) == 0 {
+		return [2]int{}, false
+	}
+	parts := strings.Split(fields[len(fields)-1], ".")
+	if len(parts) < 2 {
+		return [2]int{}, false
+	}
+	major, err1 := strconv.Atoi(parts[0])
+	minor, err2 := strconv.Atoi(parts[1])
+	return [2]int{major, minor}, err1 == nil && err2 == nil
+}
+
+func checkNixVersion() diagnosis {
+	if err := checkNixInstalled(); err != nil {
+		return diagnosis{"failure", tr("DoctorNixMissing"), tr("DoctorNixMissingFix")}
+	}
+	out, err := exec.Command("nix", "--version").Output()
+	if err != nil {
+		return diagnosis{"failure", tr("DoctorNixVersionFailed"), tr("DoctorNixVersionFailedFix")}
+	}
+	versionLine := strings.TrimSpace(string(out))
+	if v, ok := parseNixVersion(versionLine); ok && (v[0] < minNixVersion[0] || v[0] == minNixVersion[0] && v[1] < minNixVersion[1]) {
+		return diagnosis{"failure", tr("DoctorNixTooOld", "Version", versionLine),
+			tr("DoctorNixTooOldFix", "Minimum", fmt.Sprintf("%d.%d", minNixVersion[0], minNixVersion[1]))}
+	}
+	return healthy(versionLine)
+}
+
+func checkFlakesEnabled() diagnosis {
+	missing, err := missingNixFeatures()
+	if err != nil {
+		return diagnosis{"failure", tr("DoctorNixConfigUnreadable"), tr("DoctorNixConfigFix")}
+	}
+	if len(missing) > 0 {
+		fix := tr("DoctorFlakesFix")
+		if autoNixFeatures() {
+			return diagnosis{"warning", tr("DoctorFlakesAuto", "Features", strings.Join(missing, ", ")), fix}
+		}
+		return diagnosis{"failure", tr("DoctorFlakesDisabled", "Features", strings.Join(missing, ", ")), fix}
+	}
+	return healthy(tr("DoctorFlakesEnabled"))
+}
+
+func checkNixStore() diagnosis {
+	args := withNixGlobalArgs([]string{"store", "info"})
+	if _, err := nixConfigOutput(args...); err != nil {
+		// nix store info is called nix store ping before nix 2.19
+		args = withNixGlobalArgs([]string{"store", "ping"})
+		if _, err := nixConfigOutput(args...); err != nil {
+			return diagnosis{"failure", tr("DoctorStoreUnreachable"), tr("DoctorStoreUnreachableFix")}
+		}
+	}
+	return healthy(tr("DoctorStoreReachable"))
+}
+
+func checkDirenv() diagnosis {
+	path, err := exec.LookPath("direnv")
+	if err != nil {
+		return diagnosis{"warning", tr("DoctorDirenvMissing"), tr("DoctorDirenvMissingFix")}
+	}
+	return healthy(path)
+}
+
+func checkNixDiskSpace() diagnosis {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs("/nix", &stat); err != nil {
+		return diagnosis{"warning", tr("DoctorDiskUnchecked", "Error", err), tr("DoctorDiskUncheckedFix")}
+	}
+	free := int64(stat.Bavail) * int64(stat.Bsize)
+	detail := tr("DoctorDiskFree", "Size", formatSize(free))
+	fix := tr("DoctorDiskFix")
+	switch {
+	case free < nixDiskFailure:
+		return diagnosis{"failure", detail, fix}
+	case free < nixDiskWarning:
+		return diagnosis{"warning", detail, fix}
+	}
+	return healthy(detail)
+}
+
+func checkSubstituters() diagnosis {
+	value, err := nixSetting("substituters")
+	if err != nil {
+		return diagnosis{"warning", tr("DoctorSubstitutersUnreadable"), tr("DoctorNixConfigFix")}
+	}
+	client := &http.Client{Timeout: 5 * time.Second}
+	var reachable, unreachable []string
+	for _, substituter := range strings.Fields(value) {
+		if !strings.HasPrefix(substituter, "http") {
+			continue
+		}
+		resp, err := client.Get(strings.TrimSuffix(substituter, "/") + "/nix-cache-info")
+		if err == nil {
+			resp.Body.Close()
+		}
+		if err != nil || resp.StatusCode != http.StatusOK {
+			unreachable = append(unreachable, substituter)
+		} else {
+			reachable = append(reachable, substituter)
+		}
+	}
+	switch {
+	case len(unreachable) > 0 && len(reachable) == 0:
+		return diagnosis{"failure", tr("DoctorSubstitutersNone", "Substituters", strings.Join(unreachable, ", ")), tr("DoctorSubstitutersNoneFix")}
+	case len(unreachable) > 0:
+		return diagnosis{"warning", tr("DoctorSubstitutersSome", "Substituters", strings.Join(unreachable, ", ")), tr("DoctorSubstitutersSomeFix")}
+	case len(reachable) == 0:
+		return healthy(tr("DoctorSubstitutersNoHTTP"))
+	}
+	return healthy(strings.Join(reachable, ", "))
+}
+
+// The tools of the project's languages, checked in the dev shell in one go
+func checkToolchains() diagnosis {
+	if _, err := os.Stat("flake.nix"); err != nil {
+		return healthy(tr("DoctorNotInProject"))
+	}
+	roots, err := findLanguageRoots()
+	if err != nil || len(roots) == 0 {
+		return healthy(tr("DoctorNoLanguage"))
+	}
+	var tools, names []string
+	for _, root := range roots {
+		if !slices.Contains(names, root.language.Name()) {
+			names = append(names, root.language.Name())
+		}
+		for _, tool := range root.language.Tools() {
+			if !slices.Contains(tools, tool) {
+				tools = append(tools, tool)
+			}
+		}
+	}
+	script := `for tool; do command -v "$tool" >/dev/null || echo "$tool"; done`
+	argv := devShellCommand(append([]string{"sh", "-c", script, "glot-doctor"}, tools...))
+	check := exec.Command(argv[0], argv[1:]...)
+	logCommand(check)
+	out, err := check.Output()
+	if err != nil {
+		return diagnosis{"failure", tr("DoctorShellFails"), tr("DoctorShellFailsFix")}
+	}
+	if missing := strings.Fields(string(out)); len(missing) > 0 {
+		return diagnosis{"failure", tr("DoctorToolsMissing", "Tools", strings.Join(missing, ", ")), tr("DoctorToolsMissingFix", "Config", configFile)}
+	}
+	return healthy(fmt.Sprintf("%s: %s", strings.Join(names, ", "), strings.Join(tools, ", ")))
+}
+
+// Run the checks, printing each result and how to fix problems. Returns
+// the number of failed checks.
+func runDoctor() int {
+	failures := 0
+	nixWorks := true
+	for _, check := range doctorChecks {
+		if check.nix && !nixWorks {
+			info(tr("DoctorSkipped", "Check", check.name))
+			continue
+		}
+		result := check.run()
+		if check.name == "nix" {
+			nixWorks = result.status == "ok"
+		}
+		message := fmt.Sprintf("%s: %s", check.name, result.detail)
+		switch result.status {
+		case "ok":
+			success(message)
+			continue
+		case "warning":
+			warning(message)
+		default:
+			logMessage(levelError, "error", "❌ ", message)
+			failures++
+		}
+		info(tr("DoctorFix", "Fix", result.fix))
+	}
+	return failures
+}
+
+func newDoctorCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "doctor",
+		Short: "Diagnose the nix and glot environment",
+		Long: "Check nix and its version, that flakes are enabled, that the store and nix daemon are reachable, direnv, " +
+			"free disk space in /nix, the binary caches, and in a project that the dev shell has its languages' tools. " +
+			"Each problem comes with how to fix it.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if failures := runDoctor(); failures > 0 {
+				err := fmt.Errorf("%s", trn("DoctorFailed", failures, "Total", len(doctorChecks)))
+				errorMsg(err.Error())
+				return err
+			}
+			success(tr("DoctorHealthy"))
+			return nil
+		},
+	}
+}
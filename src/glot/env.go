@@ -0,0 +1,232 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// Variables that differ between every evaluation and say nothing about the environment
+var volatileEnvVars = map[string]bool{
+	"NIX_BUILD_TOP": true, "TMP": true, "TMPDIR": true, "TEMP": true, "TEMPDIR": true,
+	"NIX_LOG_FD": true, "SOURCE_DATE_EPOCH": true, "shellHook": true,
+}
+
+var storePathPattern = regexp.MustCompile(`/nix/store/[0-9a-z]{32}-([^/:\s"']+)`)
+
+// Dev shell environment as printed by `nix print-dev-env --json`
+type devEnv map[string]string
+
+// Evaluate the dev shell environment of a flake reference
+func loadDevEnv(args ...string) (devEnv, error) {
+	out, err := nixOutput(append([]string{"print-dev-env", "--json"}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	var printed struct {
+		Variables map[string]struct {
+			Value json.RawMessage `json:"value"`
+		} `json:"variables"`
+	}
+	if err := json.Unmarshal(out, &printed); err != nil {
+		return nil, fmt.Errorf("unexpected print-dev-env output: %w", err)
+	}
+	env := devEnv{}
+	for name, variable := range printed.Variables {
+		var value string
+		// Arrays and associative arrays are bash internals, not environment
+		if volatileEnvVars[name] || json.Unmarshal(variable.Value, &value) != nil {
+			continue
+		}
+		env[name] = value
+	}
+	return env, nil
+}
+
+// Arguments selecting the dev shell of another revision: a lock file to use
+// with the current flake, or a git ref of the current repository
+func devEnvArgsFor(other string) ([]string, error) {
+	if stat, err := os.Stat(other); err == nil && !stat.IsDir() {
+		return []string{"--reference-lock-file", other, "--no-write-lock-file", "."}, nil
+	}
+	git := func(args ...string) (string, error) {
+		out, err := exec.Command("git", args...).Output()
+		return strings.TrimSpace(string(out)), err
+	}
+	rev, err := git("rev-parse", "--verify", "--quiet", other+"^{commit}")
+	if err != nil {
+		return nil, fmt.Errorf("%s", tr("EnvUnknownRef", "Ref", other))
+	}
+	top, err := git("rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	ref := fmt.Sprintf("git+file://%s?rev=%s", top, rev)
+	if prefix, _ := git("rev-parse", "--show-prefix"); prefix != "" {
+		ref += "&dir=" + strings.TrimSuffix(prefix, "/")
+	}
+	return []string{ref}, nil
+}
+
+// Replace store path hashes so paths compare by name and version
+func withoutStoreHashes(value string) string {
+	return storePathPattern.ReplaceAllString(value, "/nix/store/…-$1")
+}
+
+// Split a store path name into package name and version like nix does: the
+// version starts at the first dash followed by a non-letter
+func splitPackageName(name string) (string, string) {
+	for i := 0; i < len(name)-1; i++ {
+		if name[i] == '-' && !(name[i+1] >= 'a' && name[i+1] <= 'z' || name[i+1] >= 'A' && name[i+1] <= 'Z') {
+			return name[:i], name[i+1:]
+		}
+	}
+	return name, ""
+}
+
+// Tools on the dev shell PATH, by package name
+func devEnvTools(env devEnv) map[string]string {
+	tools := map[string]string{}
+	for _, m := range storePathPattern.FindAllStringSubmatch(env["PATH"], -1) {
+		name, version := splitPackageName(m[1])
+		tools[name] = version
+	}
+	return tools
+}
+
+// Print tool and variable differences between two environments, returning
+// whether there were any
+func reportDevEnvDiff(current, other devEnv, label string, values bool) bool {
+	changed := false
+	section := func(title string) {
+		if !changed {
+			fmt.Println(tr("EnvChangesFrom", "Ref", label))
+		}
+		changed = true
+		fmt.Printf("\n%s:\n", title)
+	}
+
+	currentTools, otherTools := devEnvTools(current), devEnvTools(other)
+	names := map[string]string{}
+	for name := range currentTools {
+		names[name] = ""
+	}
+	for name := range otherTools {
+		names[name] = ""
+	}
+	var toolLines []string
+	for _, name := range sortedKeys(names) {
+		now, inCurrent := currentTools[name]
+		then, inOther := otherTools[name]
+		switch {
+		case !inOther:
+			toolLines = append(toolLines, fmt.Sprintf("  + %s %s", name, now))
+		case !inCurrent:
+			toolLines = append(toolLines, fmt.Sprintf("  - %s %s", name, then))
+		case now != then:
+			toolLines = append(toolLines, fmt.Sprintf(plain("  ~ %s %s → %s"), name, then, now))
+		}
+	}
+	if len(toolLines) > 0 {
+		section(tr("EnvTools"))
+		fmt.Println(strings.Join(toolLines, "\n"))
+	}
+
+	vars := map[string]string{}
+	for name := range current {
+		vars[name] = ""
+	}
+	for name := range other {
+		vars[name] = ""
+	}
+	var varLines []string
+	rebuilt := 0
+	for _, name := range sortedKeys(vars) {
+		now, inCurrent := current[name]
+		then, inOther := other[name]
+		switch {
+		case !inOther:
+			varLines = append(varLines, fmt.Sprintf("  + %s", name))
+		case !inCurrent:
+			varLines = append(varLines, fmt.Sprintf("  - %s", name))
+		case now == then:
+			continue
+		case withoutStoreHashes(now) == withoutStoreHashes(then):
+			rebuilt++
+			continue
+		default:
+			varLines = append(varLines, fmt.Sprintf("  ~ %s", name))
+		}
+		if values {
+			if inOther {
+				varLines = append(varLines, "      "+tr("EnvBefore", "Value", then))
+			}
+			if inCurrent {
+				varLines = append(varLines, "      "+tr("EnvAfter", "Value", now))
+			}
+		}
+	}
+	if len(varLines) > 0 {
+		section(tr("EnvVariables"))
+		fmt.Println(strings.Join(varLines, "\n"))
+	}
+	if rebuilt > 0 {
+		section(tr("EnvStorePaths"))
+		fmt.Println("  " + trn("EnvRebuilt", rebuilt))
+	}
+	return changed
+}
+
+func newEnvCmd() *cobra.Command {
+	envCmd := &cobra.Command{
+		Use:   "env",
+		Short: "Inspect the dev shell environment",
+	}
+
+	diffCmd := &cobra.Command{
+		Use:   "diff <git-ref|lockfile>",
+		Short: "Compare the dev shell with another revision",
+		Long: "Show how the dev shell environment (tool versions, environment variables, store paths) " +
+			"of the working tree differs from a git revision or from the current flake with another lock file.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkNix(); err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			otherArgs, err := devEnvArgsFor(args[0])
+			if err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+
+			info(tr("EnvEvaluatingCurrent"))
+			current, err := loadDevEnv(".")
+			if err != nil {
+				errorMsg(tr("EnvEvaluateFailed", "Error", err))
+				return err
+			}
+			info(tr("EnvEvaluatingOther", "Ref", args[0]))
+			other, err := loadDevEnv(otherArgs...)
+			if err != nil {
+				errorMsg(tr("EnvEvaluateOtherFailed", "Ref", args[0], "Error", err))
+				return err
+			}
+
+			values, _ := cmd.Flags().GetBool("values")
+			if !reportDevEnvDiff(current, other, args[0], values) {
+				success(tr("EnvIdentical", "Ref", args[0]))
+			}
+			return nil
+		},
+	}
+	diffCmd.Flags().Bool("values", false, "Show old and new values of changed variables")
+
+	envCmd.AddCommand(diffCmd)
+	return envCmd
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+
+	"github.com/spf13/cobra"
+)
+
+func newExecCmd() *cobra.Command {
+	execCmd := &cobra.Command{
+		Use:     "exec [--] <command> [args...]",
+		Aliases: []string{"x"},
+		Short:   "Run a command in the dev shell",
+		Long: "Run any command in the project's dev shell, like 'nix develop --command', with the shell's cached environment. " +
+			"glot is replaced by the command, so the command gets the terminal, stdin and signals directly and glot exits with its exit code. " +
+			"Flags after the command are the command's.",
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkNix(); err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			argv := devShellCommand(args)
+			path, err := exec.LookPath(argv[0])
+			if err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			logCommand(&exec.Cmd{Path: path, Args: argv})
+			err = syscall.Exec(path, argv, os.Environ())
+			errorMsg(tr("ToolRunFailed", "Tool", argv[0], "Error", err))
+			return err
+		},
+	}
+	// Everything after the command belongs to it: glot x go test -v
+	execCmd.Flags().SetInterspersed(false)
+	return execCmd
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// Paths never exported when the project isn't a git repository
+var exportSkip = map[string]bool{
+	".git":    true,
+	".glot":   true,
+	".cache":  true,
+	".direnv": true,
+}
+
+// Files to export: tracked and untracked-but-not-ignored files in a git
+// repository, otherwise everything except local state and build results
+func exportFiles() ([]string, error) {
+	out, err := exec.Command("git", "ls-files", "-z", "--cached", "--others", "--exclude-standard").Output()
+	if err == nil {
+		var files []string
+		for _, file := range strings.Split(string(out), "\x00") {
+			// Deleted but not yet staged files are still listed
+			if _, err := os.Lstat(file); file != "" && err == nil {
+				files = append(files, file)
+			}
+		}
+		return files, nil
+	}
+
+	warning(tr("ExportNotGit"))
+	var files []string
+	err = filepath.WalkDir(".", func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if exportSkip[path] || path == "result" || strings.HasPrefix(path, "result-") {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.IsDir() {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// Add a file or symlink from disk to the archive under name
+func addFileToTar(tw *tar.Writer, path, name string) error {
+	stat, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	link := ""
+	if stat.Mode()&os.ModeSymlink != 0 {
+		if link, err = os.Readlink(path); err != nil {
+			return err
+		}
+	}
+	header, err := tar.FileInfoHeader(stat, link)
+	if err != nil {
+		return err
+	}
+	header.Name = filepath.ToSlash(name)
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	if !stat.Mode().IsRegular() {
+		return nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// Copy the flake inputs and the closure of the default package and dev shell
+// into a file:// binary cache that can be used without network access
+func exportClosure(dir string) error {
+	storeURL := "file://" + dir
+	info(tr("ExportArchiving"))
+	if err := runNix("flake", "archive", "--to", storeURL); err != nil {
+		return fmt.Errorf("%s", tr("ExportArchiveFailed", "Error", err))
+	}
+	info(tr("ExportCopyingClosure"))
+	if err := runNix(append([]string{"copy", "--to", storeURL}, defaultPushInstallables()...)...); err != nil {
+		return fmt.Errorf("%s", tr("ExportCopyClosureFailed", "Error", err))
+	}
+	return nil
+}
+
+// Write the project (and optionally its closure) to a .tar.gz archive
+func exportProject(output string, withClosure bool) error {
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	name := filepath.Base(wd)
+	prefix := fmt.Sprintf("%s-%s", name, time.Now().Format("20060102"))
+
+	files, err := exportFiles()
+	if err != nil {
+		return fmt.Errorf("%s", tr("ExportListFailed", "Error", err))
+	}
+
+	var closureDir string
+	if withClosure {
+		if closureDir, err = os.MkdirTemp("", "glot-export-"); err != nil {
+			return err
+		}
+		defer os.RemoveAll(closureDir)
+		if err := exportClosure(closureDir); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+
+	outputPath, _ := filepath.Abs(output)
+	for _, file := range files {
+		if abs, _ := filepath.Abs(file); abs == outputPath {
+			continue
+		}
+		if err := addFileToTar(tw, file, filepath.Join(prefix, "source", file)); err != nil {
+			return fmt.Errorf("%s", tr("ExportAddFailed", "File", file, "Error", err))
+		}
+	}
+
+	if withClosure {
+		err := filepath.WalkDir(closureDir, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil || entry.IsDir() {
+				return err
+			}
+			rel, _ := filepath.Rel(closureDir, path)
+			return addFileToTar(tw, path, filepath.Join(prefix, "nix-cache", rel))
+		})
+		if err != nil {
+			return fmt.Errorf("%s", tr("ExportAddClosureFailed", "Error", err))
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func newExportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "export [flags]",
+		Short: "Export the project as an archive",
+		Long: "Write a clean .tar.gz of the project (respecting .gitignore) for auditors or " +
+			"air-gapped reviewers, optionally with a binary cache of its dependency closure.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			withClosure, _ := cmd.Flags().GetBool("with-closure")
+			if withClosure {
+				if err := checkNix(); err != nil {
+					errorMsg(err.Error())
+					return err
+				}
+			}
+
+			output, _ := cmd.Flags().GetString("output")
+			if output == "" {
+				wd, _ := os.Getwd()
+				kind := "source"
+				if withClosure {
+					kind = "closure"
+				}
+				output = fmt.Sprintf("%s-%s-%s.tar.gz", filepath.Base(wd), time.Now().Format("20060102"), kind)
+			}
+
+			info(tr("ExportStarted", "File", output))
+			if err := exportProject(output, withClosure); err != nil {
+				os.Remove(output)
+				errorMsg(err.Error())
+				return err
+			}
+			success(tr("ExportDone", "File", output))
+			if withClosure {
+				info(tr("ExportOfflineHint"))
+			}
+			return nil
+		},
+	}
+	cmd.Flags().Bool("source-only", false, "Export only the source (default)")
+	cmd.Flags().Bool("with-closure", false, "Also bundle flake inputs and the dependency closure as a file:// binary cache")
+	cmd.Flags().StringP("output", "o", "", "Archive path (default: <project>-<date>-<source|closure>.tar.gz)")
+	cmd.MarkFlagsMutuallyExclusive("source-only", "with-closure")
+	return cmd
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Sources searched for nix-polyglot templates, in order of preference
+var polyglotSources = []string{
+	".", // Current directory (if we're in nix-polyglot dev)
+	"path:/Users/ritzau/src/slask/nix/polyglot/nix-polyglot", // Hardcoded dev path
+	"github:ritzau/nix-polyglot",                             // GitHub fallback
+}
+
+// templateFeature is a composable fragment layered on top of a base template
+type templateFeature struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Files       map[string]string `json:"files"`
+	Append      map[string]string `json:"append"`
+	// Whether its files replace the project's, as organization defaults do
+	overwrite bool
+}
+
+// Locate a local copy of the nix-polyglot source tree containing the templates
+func resolvePolyglotRoot() (string, error) {
+	for _, source := range polyglotSources {
+		out, err := nixOutput("flake", "metadata", "--json", source)
+		if err != nil {
+			continue
+		}
+		var metadata struct {
+			Path string `json:"path"`
+		}
+		if err := json.Unmarshal(out, &metadata); err != nil || metadata.Path == "" {
+			continue
+		}
+		if info, err := os.Stat(filepath.Join(metadata.Path, "templates")); err == nil && info.IsDir() {
+			return metadata.Path, nil
+		}
+	}
+	return "", fmt.Errorf("%s", tr("FeaturesNoTemplates"))
+}
+
+// List the feature names available under root
+func listTemplateFeatures(root string) []string {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, entry := range entries {
+		if _, err := os.Stat(filepath.Join(root, entry.Name(), "feature.nix")); err == nil {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Evaluate a feature manifest (feature.nix) into file mappings
+func loadTemplateFeature(root, name string) (*templateFeature, error) {
+	manifest := filepath.Join(root, name, "feature.nix")
+	if _, err := os.Stat(manifest); err != nil {
+		return nil, fmt.Errorf("%s", tr("FeaturesUnknown", "Feature", name,
+			"Available", strings.Join(listTemplateFeatures(root), ", ")))
+	}
+	out, err := nixOutput("eval", "--json", "--file", manifest, "--apply",
+		`f: { inherit (f) name description; files = builtins.mapAttrs (_: toString) (f.files or { }); append = builtins.mapAttrs (_: toString) (f.append or { }); }`)
+	if err != nil {
+		return nil, fmt.Errorf("%s", tr("FeaturesEvalFailed", "Feature", name, "Error", err))
+	}
+	var feature templateFeature
+	if err := json.Unmarshal(out, &feature); err != nil {
+		return nil, fmt.Errorf("%s", tr("FeaturesInvalid", "Feature", name, "Error", err))
+	}
+	return &feature, nil
+}
+
+// Load all requested features, failing before anything is written. The
+// organization's features/, if given, take precedence over nix-polyglot's.
+func loadTemplateFeatures(polyglotRoot string, names []string, org *orgDefaults) ([]*templateFeature, error) {
+	root := filepath.Join(polyglotRoot, "templates", "features")
+	var features []*templateFeature
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		featureRoot := root
+		if org != nil {
+			if _, err := os.Stat(filepath.Join(org.Root, "features", name, "feature.nix")); err == nil {
+				featureRoot = filepath.Join(org.Root, "features")
+			}
+		}
+		feature, err := loadTemplateFeature(featureRoot, name)
+		if err != nil {
+			return nil, err
+		}
+		features = append(features, feature)
+	}
+	return features, nil
+}
+
+// Read a feature file and substitute template placeholders
+func renderFeatureFile(source, projectName string) ([]byte, error) {
+	content, err := os.ReadFile(source)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.ReplaceAll(content, []byte("@projectName@"), []byte(projectName)), nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
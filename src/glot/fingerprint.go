@@ -0,0 +1,187 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// Lock files hashed into the fingerprint when present
+var fingerprintLockFiles = []string{
+	"flake.lock",
+	"Cargo.lock",
+	"go.sum",
+	"poetry.lock",
+	"uv.lock",
+	"package-lock.json",
+	"packages.lock.json",
+	"nimble.lock",
+	"build.zig.zon",
+}
+
+// fingerprint identifies the environment a project is built with. Two machines
+// with the same fingerprint evaluate to the same dev shell derivation from the
+// same locked inputs.
+type fingerprint struct {
+	Hash       string            `json:"hash"`
+	System     string            `json:"system"`
+	Components map[string]string `json:"components"`
+}
+
+// Compute the fingerprint of the project in the current directory
+func computeFingerprint() (*fingerprint, error) {
+	system, err := currentSystem()
+	if err != nil {
+		return nil, fmt.Errorf("%s", tr("FingerprintNoSystem", "Error", err))
+	}
+
+	components := map[string]string{}
+
+	// The derivation path hashes the whole dev shell closure
+	drv, err := nixOutput("eval", "--raw", fmt.Sprintf(".#devShells.%s.default.drvPath", system))
+	if err != nil {
+		return nil, fmt.Errorf("%s", tr("FingerprintShellFailed", "Error", err))
+	}
+	components["devShell"] = strings.TrimSpace(string(drv))
+
+	// Locked flake inputs, by content hash
+	out, err := nixOutput("flake", "metadata", "--json")
+	if err != nil {
+		return nil, fmt.Errorf("%s", tr("FingerprintMetadataFailed", "Error", err))
+	}
+	var metadata struct {
+		Locks struct {
+			Nodes map[string]struct {
+				Locked struct {
+					NarHash string `json:"narHash"`
+				} `json:"locked"`
+			} `json:"nodes"`
+		} `json:"locks"`
+	}
+	if err := json.Unmarshal(out, &metadata); err != nil {
+		return nil, fmt.Errorf("invalid flake metadata: %w", err)
+	}
+	for name, node := range metadata.Locks.Nodes {
+		if node.Locked.NarHash != "" {
+			components["input:"+name] = node.Locked.NarHash
+		}
+	}
+
+	for _, file := range fingerprintLockFiles {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		sum := sha256.Sum256(content)
+		components["file:"+file] = "sha256:" + hex.EncodeToString(sum[:])
+	}
+
+	hash := sha256.New()
+	fmt.Fprintf(hash, "system %s\n", system)
+	for _, key := range sortedKeys(components) {
+		fmt.Fprintf(hash, "%s %s\n", key, components[key])
+	}
+
+	return &fingerprint{
+		Hash:       "sha256:" + hex.EncodeToString(hash.Sum(nil)),
+		System:     system,
+		Components: components,
+	}, nil
+}
+
+// Read a fingerprint previously written with `glot fingerprint --output`
+func readFingerprint(path string) (*fingerprint, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var fp fingerprint
+	if err := json.Unmarshal(content, &fp); err != nil {
+		return nil, fmt.Errorf("%s", tr("FingerprintInvalidFile", "File", path, "Error", err))
+	}
+	return &fp, nil
+}
+
+// Print the components that differ between two fingerprints
+func reportFingerprintDiff(local, other *fingerprint) {
+	if local.System != other.System {
+		fmt.Println("  " + tr("FingerprintSystemDiffers", "Local", local.System, "Other", other.System))
+	}
+	all := map[string]string{}
+	for key := range local.Components {
+		all[key] = ""
+	}
+	for key := range other.Components {
+		all[key] = ""
+	}
+	for _, key := range sortedKeys(all) {
+		mine, theirs := local.Components[key], other.Components[key]
+		switch {
+		case mine == theirs:
+			continue
+		case mine == "":
+			fmt.Println("  " + tr("FingerprintMissingLocally", "Component", key))
+		case theirs == "":
+			fmt.Println("  " + tr("FingerprintMissingInComparison", "Component", key))
+		default:
+			fmt.Println("  " + tr("FingerprintComponentDiffers", "Component", key, "Local", mine, "Other", theirs))
+		}
+	}
+}
+
+func newFingerprintCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "fingerprint",
+		Short: "Fingerprint the build environment",
+		Long: "Hash the dev shell derivation, locked flake inputs and project lock files, " +
+			"so two machines can verify they build with identical environments.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkNix(); err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+
+			fp, err := computeFingerprint()
+			if err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+
+			if output, _ := cmd.Flags().GetString("output"); output != "" {
+				content, _ := json.MarshalIndent(fp, "", "  ")
+				if err := os.WriteFile(output, append(content, '\n'), 0644); err != nil {
+					errorMsg(tr("WriteFailed", "File", output, "Error", err))
+					return err
+				}
+				info(tr("FingerprintWritten", "File", output))
+			}
+
+			compare, _ := cmd.Flags().GetString("compare")
+			if compare == "" {
+				fmt.Println(fp.Hash)
+				return nil
+			}
+
+			other, err := readFingerprint(compare)
+			if err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			if other.Hash == fp.Hash {
+				success(tr("FingerprintMatches", "File", compare, "Hash", fp.Hash))
+				return nil
+			}
+			errorMsg(tr("FingerprintDiffers", "File", compare))
+			reportFingerprintDiff(fp, other)
+			return fmt.Errorf("%s", tr("FingerprintMismatch"))
+		},
+	}
+	cmd.Flags().StringP("output", "o", "", "Write the fingerprint as JSON to a file")
+	cmd.Flags().String("compare", "", "Compare against a fingerprint file from another machine")
+	return cmd
+}
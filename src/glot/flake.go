@@ -0,0 +1,236 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// Nix system of the current machine (e.g. x86_64-linux)
+func currentSystem() (string, error) {
+	out, err := nixOutput("eval", "--raw", "--impure", "--expr", "builtins.currentSystem")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// Names of the apps the project flake exposes for the current system
+func flakeAppNames() []string {
+	system, err := currentSystem()
+	if err != nil {
+		return nil
+	}
+	out, err := nixOutput("eval", "--json", ".#apps."+system, "--apply", "builtins.attrNames")
+	if err != nil {
+		return nil
+	}
+	var names []string
+	if err := json.Unmarshal(out, &names); err != nil {
+		return nil
+	}
+	return names
+}
+
+// Check whether the project flake exposes the named app
+func flakeHasApp(name string) bool {
+	for _, app := range flakeAppNames() {
+		if app == name {
+			debug(fmt.Sprintf("The flake has a '%s' app", name))
+			return true
+		}
+	}
+	debug(fmt.Sprintf("The flake has no '%s' app", name))
+	return false
+}
+
+// Names in each per-system output of the project flake (packages, apps,
+// devShells, ...) for the current system, from nix flake show
+func flakeSystemOutputs() (map[string][]string, error) {
+	system, err := currentSystem()
+	if err != nil {
+		return nil, err
+	}
+	out, err := nixOutput("flake", "show", "--json")
+	if err != nil {
+		return nil, err
+	}
+	var show map[string]json.RawMessage
+	if err := json.Unmarshal(out, &show); err != nil {
+		return nil, fmt.Errorf("unexpected nix flake show output: %w", err)
+	}
+	outputs := map[string][]string{}
+	for name, value := range show {
+		// Outputs that aren't per system, like lib or overlays, don't parse
+		var systems map[string]map[string]json.RawMessage
+		if json.Unmarshal(value, &systems) == nil && systems[system] != nil {
+			outputs[name] = sortedKeys(systems[system])
+		}
+	}
+	return outputs, nil
+}
+
+// Names of the dev shells the project flake exposes for the current system
+func flakeDevShells() ([]string, error) {
+	outputs, err := flakeSystemOutputs()
+	return outputs["devShells"], err
+}
+
+// Targets of glot build, the flake's packages, or of glot run, its apps and
+// packages
+func flakeTargets(run bool) ([]string, error) {
+	outputs, err := flakeSystemOutputs()
+	if err != nil {
+		return nil, err
+	}
+	targets := outputs["packages"]
+	if run {
+		for _, app := range outputs["apps"] {
+			if !slices.Contains(targets, app) {
+				targets = append(targets, app)
+			}
+		}
+		slices.Sort(targets)
+	}
+	return targets, nil
+}
+
+// Check that a target of glot build or run is one of the flake's, listing
+// them if not. Attribute paths like checks.x86_64-linux.lint are left to
+// nix, as are all targets when the flake can't be shown.
+func checkTarget(target string, run bool) error {
+	if strings.Contains(target, ".") {
+		return nil
+	}
+	targets, err := flakeTargets(run)
+	if err != nil {
+		debug(fmt.Sprintf("Not checking the target: %s", err))
+		return nil
+	}
+	if slices.Contains(targets, target) {
+		return nil
+	}
+	id := "TargetNotPackage"
+	if run {
+		id = "TargetNotAppOrPackage"
+	}
+	if len(targets) == 0 {
+		return fmt.Errorf("%s", tr(id+"None", "Target", target))
+	}
+	return fmt.Errorf("%s", tr(id, "Target", target, "Available", strings.Join(targets, ", ")))
+}
+
+// Run nix with the nix command enabled, which works before nix.conf enables it
+func nixConfigOutput(args ...string) (string, error) {
+	cmd := exec.Command("nix", append([]string{"--extra-experimental-features", "nix-command"}, args...)...)
+	logCommand(cmd)
+	out, err := cmd.Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+// A nix setting: `nix config show` since nix 2.20, `nix show-config` before
+func nixSetting(name string) (string, error) {
+	if value, err := nixConfigOutput("config", "show", name); err == nil {
+		return value, nil
+	}
+	out, err := nixConfigOutput("show-config")
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(out, "\n") {
+		if key, value, ok := strings.Cut(line, " = "); ok && key == name {
+			return value, nil
+		}
+	}
+	return "", fmt.Errorf("%s", tr("NixNoSetting", "Name", name))
+}
+
+// Experimental features glot's nix commands need
+var nixFeatures = []string{"nix-command", "flakes"}
+
+// The features nix.conf doesn't enable
+func missingNixFeatures() ([]string, error) {
+	enabled, err := nixSetting("experimental-features")
+	if err != nil {
+		return nil, err
+	}
+	var missing []string
+	for _, feature := range nixFeatures {
+		if !slices.Contains(strings.Fields(enabled), feature) {
+			missing = append(missing, feature)
+		}
+	}
+	return missing, nil
+}
+
+// Whether glot enables missing features itself: unless GLOT_AUTO_FEATURES=0
+func autoNixFeatures() bool {
+	return os.Getenv("GLOT_AUTO_FEATURES") != "0"
+}
+
+// Enable the features nix.conf lacks for all of glot's nix invocations,
+// instead of failing with nix's error about experimental features
+func applyNixFeatures() {
+	if !autoNixFeatures() || checkNixInstalled() != nil {
+		return
+	}
+	missing, err := missingNixFeatures()
+	if err != nil || len(missing) == 0 {
+		return
+	}
+	debug(fmt.Sprintf("Enabling %s, which nix.conf doesn't", strings.Join(missing, " and ")))
+	nixGlobalArgs = append(nixGlobalArgs, "--extra-experimental-features", strings.Join(missing, " "))
+}
+
+// A nix string literal
+func nixString(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "${", `\${`, "\n", `\n`).Replace(value) + `"`
+}
+
+// A nix list of string literals
+func nixStringList(values []string) string {
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = nixString(value)
+	}
+	return "[ " + strings.Join(quoted, " ") + " ]"
+}
+
+// Arguments of nix build for a flake reference like .#release: the
+// reference itself, or, given attribute overrides like `tags = [ "x" ];`,
+// an expression building the package with them through overrideAttrs
+func overriddenInstallable(ref string, overrides []string) []string {
+	if len(overrides) == 0 {
+		return []string{ref}
+	}
+	path := strings.TrimPrefix(ref, ".#")
+	var attrs strings.Builder
+	attrs.WriteString("(builtins.getFlake (toString ./.))")
+	if !strings.Contains(path, ".") {
+		attrs.WriteString(".packages.${builtins.currentSystem}")
+	}
+	for _, name := range strings.Split(path, ".") {
+		attrs.WriteString("." + nixString(name))
+	}
+	expr := fmt.Sprintf("%s.overrideAttrs (old: { %s })", attrs.String(), strings.Join(overrides, " "))
+	return []string{"--impure", "--expr", expr}
+}
+
+// Overrides of the built packages given on the command line of glot build:
+// cargo features and go build flags
+func selectedBuildOverrides(cmd *cobra.Command) ([]string, error) {
+	features, err := selectedFeatures(cmd)
+	if err != nil {
+		return nil, err
+	}
+	goFlags, err := selectedGoBuildFlags(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return append(features.overrides(), goFlags.overrides()...), nil
+}
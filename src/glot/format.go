@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// formatConfig is the [format] section of glot.toml: formatters run by
+// glot fmt after nix fmt, e.g.
+//
+//	[[format.pipeline]]
+//	files = ["*.go"]
+//	run = ["gofumpt -w", "goimports -w"]
+//
+//	[[format.pipeline]]
+//	files = ["docs/**/*.md", "*.yaml"]
+//	exclude = ["CHANGELOG.md"]
+//	run = ["prettier --write"]
+type formatConfig struct {
+	// Skip nix fmt and run only the pipeline
+	Override bool `toml:"override"`
+	// Format with treefmt and treefmt.toml instead of nix fmt
+	Treefmt  bool          `toml:"treefmt"`
+	Pipeline []formatStage `toml:"pipeline"`
+}
+
+// formatStage runs formatters, in order, on the files matching a glob
+type formatStage struct {
+	Files   []string `toml:"files"`   // Globs; without a slash they match file names in any directory
+	Exclude []string `toml:"exclude"` // Globs of files to leave alone
+	Run     []string `toml:"run"`     // Commands run in the dev shell with the files appended
+}
+
+// Files are passed to formatters in batches to stay below argument limits
+const formatBatchSize = 500
+
+// Check that every stage has files and commands
+func validateFormatConfig(path string, config formatConfig) error {
+	for i, stage := range config.Pipeline {
+		if len(stage.Files) == 0 || len(stage.Run) == 0 {
+			return fmt.Errorf("%s", tr("ConfigInvalid", "File", path, "Error", tr("FormatPipelineStage", "Number", i+1)))
+		}
+	}
+	return nil
+}
+
+// Whether a slash-separated file path matches a glob, where ** matches any
+// number of directories and globs without a slash match the file name
+func matchGlob(pattern, file string) bool {
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(file))
+		return ok
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(file, "/"))
+}
+
+func matchSegments(pattern, file []string) bool {
+	if len(pattern) == 0 {
+		return len(file) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(file); i++ {
+			if matchSegments(pattern[1:], file[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(file) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], file[0]); !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], file[1:])
+}
+
+func matchAnyGlob(patterns []string, file string) bool {
+	for _, pattern := range patterns {
+		if matchGlob(pattern, file) {
+			return true
+		}
+	}
+	return false
+}
+
+// Files of the project: those git tracks or would track, as the flake sees them
+func projectFiles() ([]string, error) {
+	out, err := exec.Command("git", "ls-files", "--cached", "--others", "--exclude-standard").Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s", tr("FormatNeedsGit", "Error", err))
+	}
+	var files []string
+	for _, file := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if file != "" {
+			files = append(files, filepath.ToSlash(file))
+		}
+	}
+	return files, nil
+}
+
+// Run the configured pipeline stages in order
+func runFormatPipeline(stages []formatStage) error {
+	if len(stages) == 0 {
+		return nil
+	}
+	files, err := projectFiles()
+	if err != nil {
+		return err
+	}
+	for _, stage := range stages {
+		var matched []string
+		for _, file := range files {
+			if matchAnyGlob(stage.Files, file) && !matchAnyGlob(stage.Exclude, file) {
+				matched = append(matched, file)
+			}
+		}
+		if len(matched) == 0 {
+			continue
+		}
+		for _, command := range stage.Run {
+			info(trn("FormatRunning", len(matched), "Command", command, "Patterns", strings.Join(stage.Files, ", ")))
+			for start := 0; start < len(matched); start += formatBatchSize {
+				batch := matched[start:min(start+formatBatchSize, len(matched))]
+				script := command + ` "$@"`
+				if err := runInDevShell(append([]string{"bash", "-c", script, "glot-fmt"}, batch...)...); err != nil {
+					return fmt.Errorf("%s", tr("FormatStageFailed", "Command", command, "Error", err))
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Format the project: treefmt in treefmt mode, nix fmt unless glot.toml
+// overrides it, then the glot.toml pipeline
+func formatProject() error {
+	config := currentConfig.Format
+	switch {
+	case config.Treefmt:
+		if err := runTreefmt(); err != nil {
+			return err
+		}
+	case !config.Override:
+		if err := runNix("fmt"); err != nil {
+			return err
+		}
+	}
+	return runFormatPipeline(config.Pipeline)
+}
+
+// What formatProject runs, for the workspace summary
+func formatRootName() string {
+	base := "nix fmt"
+	if currentConfig.Format.Treefmt {
+		base = "treefmt"
+	}
+	switch {
+	case len(currentConfig.Format.Pipeline) == 0:
+		return base
+	case currentConfig.Format.Override && !currentConfig.Format.Treefmt:
+		return "format pipeline"
+	}
+	return base + " + pipeline"
+}
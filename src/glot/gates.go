@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// gatesConfig is the [gates] section of glot.toml: limits glot check
+// evaluates once all checks pass
+type gatesConfig struct {
+	MaxLintWarnings *int     `toml:"max-lint-warnings"` // Lint findings allowed
+	MinCoverage     *float64 `toml:"min-coverage"`      // Test coverage in percent; runs the tests with coverage
+	MaxBinarySize   string   `toml:"max-binary-size"`   // Largest file in result/bin, e.g. "20MB"
+	MaxBuildTime    string   `toml:"max-build-time"`    // Duration of nix build, e.g. "5m"
+}
+
+func (g gatesConfig) configured() bool {
+	return g.MaxLintWarnings != nil || g.MinCoverage != nil || g.MaxBinarySize != "" || g.MaxBuildTime != ""
+}
+
+var sizeUnits = []struct {
+	suffix string
+	bytes  int64
+}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}}
+
+// Parse a size like "20MB", "512KB" or "1048576"
+func parseSize(size string) (int64, error) {
+	size = strings.ToUpper(strings.TrimSpace(size))
+	multiplier := int64(1)
+	for _, unit := range sizeUnits {
+		if number, ok := strings.CutSuffix(size, unit.suffix); ok {
+			size, multiplier = strings.TrimSpace(number), unit.bytes
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(size, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%s", tr("GatesInvalidSize", "Size", size))
+	}
+	return int64(n * float64(multiplier)), nil
+}
+
+func formatSize(bytes int64) string {
+	for _, unit := range sizeUnits {
+		if bytes >= unit.bytes && unit.bytes > 1 {
+			return fmt.Sprintf("%.1f%s", float64(bytes)/float64(unit.bytes), unit.suffix)
+		}
+	}
+	return fmt.Sprintf("%dB", bytes)
+}
+
+func validateGatesConfig(path string, gates gatesConfig) error {
+	if gates.MaxBinarySize != "" {
+		if _, err := parseSize(gates.MaxBinarySize); err != nil {
+			return fmt.Errorf("%s", tr("ConfigInvalid", "File", path, "Error", fmt.Sprintf("gates.max-binary-size: %s", err)))
+		}
+	}
+	if gates.MaxBuildTime != "" {
+		if _, err := time.ParseDuration(gates.MaxBuildTime); err != nil {
+			return fmt.Errorf("%s", tr("ConfigInvalid", "File", path, "Error", fmt.Sprintf("gates.max-build-time: %s", err)))
+		}
+	}
+	if gates.MinCoverage != nil && (*gates.MinCoverage < 0 || *gates.MinCoverage > 100) {
+		return fmt.Errorf("%s", tr("ConfigInvalid", "File", path, "Error", tr("GatesCoverageRange")))
+	}
+	return nil
+}
+
+// Where glot check records its metrics, one JSON line per run
+var metricsFile = filepath.Join(stateDir, "metrics", "check.jsonl")
+
+// Metrics of one glot check run; nil when not measured
+type checkMetrics struct {
+	Time         time.Time `json:"time"`
+	Commit       string    `json:"commit,omitempty"`
+	LintWarnings *int      `json:"lint_warnings,omitempty"`
+	Coverage     *float64  `json:"coverage,omitempty"`
+	BinarySize   *int64    `json:"binary_size,omitempty"`
+	BuildTimeMs  *int64    `json:"build_time_ms,omitempty"`
+}
+
+// Lint, counting the findings. Within a warnings budget, lint failures
+// with findings are left to the gate.
+func (m *checkMetrics) lint() error {
+	output, err := captureOutput(runLint)
+	count := 0
+	for _, n := range lintFindings(output) {
+		count += n
+	}
+	m.LintWarnings = &count
+	if err != nil && (currentConfig.Gates.MaxLintWarnings == nil || count == 0) {
+		return err
+	}
+	return nil
+}
+
+// Test, with coverage when a gate needs it
+func (m *checkMetrics) test() error {
+	if currentConfig.Gates.MinCoverage == nil {
+		return runTests(testOptions{})
+	}
+	if err := runTests(testOptions{Coverage: true}); err != nil {
+		return err
+	}
+	covered, total := 0, 0
+	for _, result := range collectCoverage() {
+		covered += result.Covered
+		total += result.Total
+	}
+	if total > 0 {
+		percent := 100 * float64(covered) / float64(total)
+		m.Coverage = &percent
+	}
+	return nil
+}
+
+// Build, timing it and measuring the largest binary
+func (m *checkMetrics) build() error {
+	started := time.Now()
+	if err := runNix("build"); err != nil {
+		return err
+	}
+	duration := time.Since(started).Milliseconds()
+	m.BuildTimeMs = &duration
+	entries, _ := os.ReadDir(filepath.Join("result", "bin"))
+	for _, entry := range entries {
+		stat, err := os.Stat(filepath.Join("result", "bin", entry.Name()))
+		if err != nil || stat.IsDir() {
+			continue
+		}
+		if size := stat.Size(); m.BinarySize == nil || size > *m.BinarySize {
+			m.BinarySize = &size
+		}
+	}
+	return nil
+}
+
+// The metrics of the last recorded glot check run, nil if there is none
+func lastCheckMetrics() *checkMetrics {
+	f, err := os.Open(metricsFile)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var last *checkMetrics
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var metrics checkMetrics
+		if json.Unmarshal(scanner.Bytes(), &metrics) == nil {
+			last = &metrics
+		}
+	}
+	return last
+}
+
+func recordCheckMetrics(metrics *checkMetrics) error {
+	if err := ensureStateDir(filepath.Dir(metricsFile)); err != nil {
+		return err
+	}
+	metrics.Time = time.Now()
+	metrics.Commit = gitCommit()
+	line, err := json.Marshal(metrics)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(metricsFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = fmt.Fprintf(f, "%s\n", line)
+	return err
+}
+
+// One evaluated gate
+type gateResult struct {
+	name   string
+	value  string
+	limit  string
+	trend  string
+	passed bool
+}
+
+// Change since the previous run, for metrics measured in both
+func trend[T int | int64 | float64](current, previous *T, format func(T) string) string {
+	if current == nil || previous == nil {
+		return ""
+	}
+	diff := *current - *previous
+	switch {
+	case diff > 0:
+		return "+" + format(diff)
+	case diff < 0:
+		return "-" + format(-diff)
+	}
+	return "±0"
+}
+
+func evaluateGates(gates gatesConfig, metrics, previous *checkMetrics) []gateResult {
+	if previous == nil {
+		previous = &checkMetrics{}
+	}
+	var results []gateResult
+	if gates.MaxLintWarnings != nil && metrics.LintWarnings != nil {
+		results = append(results, gateResult{
+			name:   tr("GateLintWarnings"),
+			value:  strconv.Itoa(*metrics.LintWarnings),
+			limit:  "≤ " + strconv.Itoa(*gates.MaxLintWarnings),
+			trend:  trend(metrics.LintWarnings, previous.LintWarnings, strconv.Itoa),
+			passed: *metrics.LintWarnings <= *gates.MaxLintWarnings,
+		})
+	}
+	if gates.MinCoverage != nil {
+		result := gateResult{name: tr("GateCoverage"), value: tr("GateNone"), limit: fmt.Sprintf("≥ %.1f%%", *gates.MinCoverage)}
+		if metrics.Coverage != nil {
+			result.value = fmt.Sprintf("%.1f%%", *metrics.Coverage)
+			result.trend = trend(metrics.Coverage, previous.Coverage, func(p float64) string { return fmt.Sprintf("%.1f%%", p) })
+			result.passed = *metrics.Coverage >= *gates.MinCoverage
+		}
+		results = append(results, result)
+	}
+	if gates.MaxBinarySize != "" {
+		limit, _ := parseSize(gates.MaxBinarySize)
+		result := gateResult{name: tr("GateBinarySize"), value: tr("GateNone"), limit: "≤ " + formatSize(limit), passed: true}
+		if metrics.BinarySize != nil {
+			result.value = formatSize(*metrics.BinarySize)
+			result.trend = trend(metrics.BinarySize, previous.BinarySize, formatSize)
+			result.passed = *metrics.BinarySize <= limit
+		}
+		results = append(results, result)
+	}
+	if gates.MaxBuildTime != "" && metrics.BuildTimeMs != nil {
+		limit, _ := time.ParseDuration(gates.MaxBuildTime)
+		milliseconds := func(ms int64) string {
+			return (time.Duration(ms) * time.Millisecond).Round(100 * time.Millisecond).String()
+		}
+		results = append(results, gateResult{
+			name:   tr("GateBuildTime"),
+			value:  milliseconds(*metrics.BuildTimeMs),
+			limit:  "≤ " + limit.String(),
+			trend:  trend(metrics.BuildTimeMs, previous.BuildTimeMs, milliseconds),
+			passed: time.Duration(*metrics.BuildTimeMs)*time.Millisecond <= limit,
+		})
+	}
+	return results
+}
+
+// Record the metrics of a passing glot check and evaluate the project's
+// gates against them. Failed gates are warnings unless enforce is set.
+func checkGates(metrics *checkMetrics, enforce bool) error {
+	previous := lastCheckMetrics()
+	if err := recordCheckMetrics(metrics); err != nil {
+		warning(tr("GatesRecordFailed", "Error", err))
+	}
+	gates := currentConfig.Gates
+	if !gates.configured() {
+		return nil
+	}
+	results := evaluateGates(gates, metrics, previous)
+	failed := 0
+	fmt.Println()
+	fmt.Println(plain("📏 " + tr("GatesHeader")))
+	for _, result := range results {
+		mark := "✅"
+		if !result.passed {
+			mark = "❌"
+			failed++
+		}
+		line := fmt.Sprintf("  %s %-14s %10s  (%s)", mark, result.name, result.value, result.limit)
+		if result.trend != "" {
+			line += "  " + tr("GatesTrend", "Trend", result.trend)
+		}
+		fmt.Println(plain(line))
+	}
+	if failed == 0 {
+		return nil
+	}
+	if !enforce {
+		warning(trn("GatesFailedWarning", failed))
+		return nil
+	}
+	return fmt.Errorf("%s", trn("GatesFailed", failed))
+}
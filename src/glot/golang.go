@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+type goLanguage struct{ toolchain }
+
+func newGoLanguage() Language {
+	return goLanguage{toolchain{
+		name:       "go",
+		markers:    []string{"go.mod"},
+		lsp:        []string{"gopls"},
+		lspPackage: "gopls",
+		format:     []string{"gofmt", "-l", "-w", "."},
+		lint:       []string{"go", "vet", "./..."},
+		test:       []string{"go", "test", "./..."},
+		testWith: func(options testOptions) ([]string, error) {
+			command := []string{"go", "test"}
+			if options.Verbose {
+				command = append(command, "-v")
+			}
+			if options.Filter != "" {
+				command = append(command, "-run", options.Filter)
+			}
+			// Test all packages unless the arguments name some
+			packages := false
+			for _, arg := range options.Args {
+				packages = packages || !strings.HasPrefix(arg, "-")
+			}
+			if !packages {
+				command = append(command, "./...")
+			}
+			return append(command, options.Args...), nil
+		},
+		coverage: func(command []string, out string) ([]string, [][]string) {
+			profile := filepath.Join(out, "cover.out")
+			test := append([]string{"go", "test", "-coverprofile=" + profile}, command[2:]...)
+			return test, [][]string{{"go", "tool", "cover", "-html=" + profile, "-o", filepath.Join(out, "html", "index.html")}}
+		},
+		update: []string{"bash", "-c", "go get -u ./... && go mod tidy"},
+		// ./... stops at nested modules
+		nested: true,
+	}}
+}
+
+// Module path and Go version declared in go.mod
+func readGoMod() (module, goVersion string) {
+	content, err := os.ReadFile("go.mod")
+	if err != nil {
+		return "", ""
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "module" {
+			module = fields[1]
+		} else if len(fields) == 2 && fields[0] == "go" {
+			goVersion = fields[1]
+		}
+	}
+	return module, goVersion
+}
+
+func (goLanguage) Info() []string {
+	module, goVersion := readGoMod()
+	var lines []string
+	if module != "" {
+		lines = append(lines, tr("InfoGoModule", "Module", module))
+	}
+	if goVersion != "" {
+		lines = append(lines, tr("InfoGoVersion", "Version", goVersion))
+	}
+	return lines
+}
+
+func (goLanguage) Project() (string, string) {
+	module, _ := readGoMod()
+	if module == "" {
+		return "", ""
+	}
+	return path.Base(module), module
+}
+
+// Go build flags of glot build --tags and --ldflags
+type goBuildFlags struct {
+	tags    []string
+	ldflags []string
+}
+
+func (f goBuildFlags) empty() bool {
+	return len(f.tags) == 0 && len(f.ldflags) == 0
+}
+
+// Attributes of the package overriding its buildGoModule tags and adding
+// to its ldflags, which go.nix passes to its go build like buildGoModule
+// does. The flake's ldflags, like -s -w of release builds, are kept.
+func (f goBuildFlags) overrides() []string {
+	var overrides []string
+	if len(f.tags) > 0 {
+		overrides = append(overrides, "tags = "+nixStringList(f.tags)+";")
+	}
+	if len(f.ldflags) > 0 {
+		overrides = append(overrides, "ldflags = (old.ldflags or [ ]) ++ "+nixStringList(f.ldflags)+";")
+	}
+	return overrides
+}
+
+// Flags given with --tags and --ldflags, which only a Go module has
+func selectedGoBuildFlags(cmd *cobra.Command) (goBuildFlags, error) {
+	var f goBuildFlags
+	tags, _ := cmd.Flags().GetStringSlice("tags")
+	for _, tag := range tags {
+		// go build also takes space separated tags
+		f.tags = append(f.tags, strings.Fields(tag)...)
+	}
+	// One element, which go build splits itself, so quoted values like
+	// -X 'main.name=a b' stay whole
+	if ldflags, _ := cmd.Flags().GetString("ldflags"); strings.TrimSpace(ldflags) != "" {
+		f.ldflags = []string{ldflags}
+	}
+	if f.empty() {
+		return f, nil
+	}
+	if _, err := os.Stat("go.mod"); err != nil {
+		return f, fmt.Errorf("%s", tr("GoFlagsNeedModule"))
+	}
+	return f, nil
+}
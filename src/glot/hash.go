@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// Dependency hash attributes and the passthru attribute holding the fixed-output
+// derivation that fetches the dependencies
+var dependencyHashes = map[string]string{
+	"vendorHash":  "goModules",
+	"cargoHash":   "cargoDeps",
+	"npmDepsHash": "npmDeps",
+}
+
+var (
+	hashAttrPattern = regexp.MustCompile(`\b(vendorHash|cargoHash|npmDepsHash)(\s*=\s*)("sha256-[A-Za-z0-9+/=]*"|[A-Za-z0-9_.]*fakeHash)`)
+	hashMismatch    = regexp.MustCompile(`specified:\s*(\S+)\s+got:\s*(\S+)`)
+)
+
+// Placeholder nix reports as the specified hash for lib.fakeHash
+const fakeHash = "sha256-AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="
+
+// A dependency hash declared in one of the project's nix files
+type hashAttr struct {
+	file  string
+	name  string
+	value string
+}
+
+// Find dependency hashes in flake.nix and the other nix files at the project root
+func findHashAttrs() ([]hashAttr, error) {
+	files, err := filepath.Glob("*.nix")
+	if err != nil {
+		return nil, err
+	}
+	var attrs []hashAttr
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range hashAttrPattern.FindAllStringSubmatch(string(content), -1) {
+			attrs = append(attrs, hashAttr{file: file, name: m[1], value: strings.Trim(m[3], `"`)})
+		}
+	}
+	return attrs, nil
+}
+
+// Replace the hash nix reported as specified with the one it got, in the
+// first attribute named name (any name if empty) that has that value
+func patchHash(attrs []hashAttr, name, specified, got string) (*hashAttr, error) {
+	for i := range attrs {
+		attr := &attrs[i]
+		isFake := strings.HasSuffix(attr.value, "fakeHash") || attr.value == fakeHash
+		if name != "" && attr.name != name {
+			continue
+		}
+		if attr.value != specified && !(specified == fakeHash && isFake) {
+			continue
+		}
+		content, err := os.ReadFile(attr.file)
+		if err != nil {
+			return nil, err
+		}
+		done := false
+		patched := hashAttrPattern.ReplaceAllStringFunc(string(content), func(match string) string {
+			m := hashAttrPattern.FindStringSubmatch(match)
+			if done || m[1] != attr.name || strings.Trim(m[3], `"`) != attr.value {
+				return match
+			}
+			done = true
+			return m[1] + m[2] + `"` + got + `"`
+		})
+		if err := os.WriteFile(attr.file, []byte(patched), 0644); err != nil {
+			return nil, err
+		}
+		attr.value = got
+		return attr, nil
+	}
+	return nil, fmt.Errorf("%s", tr("HashNoMatch", "Hash", specified))
+}
+
+// A derivation to build to check hashes, and the hash attribute it checks
+// (empty when building the whole package)
+type hashTarget struct {
+	installable string
+	attr        string
+}
+
+// The fetch derivations when the package exposes them, otherwise the package
+func hashCheckTargets(pkg string, attrs []hashAttr) []hashTarget {
+	seen := map[string]bool{}
+	var targets []hashTarget
+	for _, attr := range attrs {
+		target := hashTarget{fmt.Sprintf(".#%s.%s", pkg, dependencyHashes[attr.name]), attr.name}
+		if _, err := nixOutput("eval", "--raw", target.installable+".drvPath"); err != nil {
+			target = hashTarget{".#" + pkg, ""}
+		}
+		if !seen[target.installable] {
+			seen[target.installable] = true
+			targets = append(targets, target)
+		}
+	}
+	return targets
+}
+
+// Build the targets, fixing one hash mismatch at a time until they build.
+// In check mode, stop at the first mismatch instead of patching.
+func updateDependencyHashes(pkg string, check bool) (updated []string, err error) {
+	attrs, err := findHashAttrs()
+	if err != nil {
+		return nil, err
+	}
+	if len(attrs) == 0 {
+		return nil, fmt.Errorf("%s", tr("HashNoAttrs"))
+	}
+
+	for _, target := range hashCheckTargets(pkg, attrs) {
+		// Each attribute can fail at most once, plus the final successful build
+		for attempt := 0; attempt <= len(attrs); attempt++ {
+			info(tr("HashBuilding", "Installable", target.installable))
+			args := []string{"build", "--no-link", target.installable}
+			// A fetch derivation already in the store is reused without checking
+			// its hash, which is how stale hashes go unnoticed
+			if _, err := nixOutput("path-info", target.installable); err == nil && target.attr != "" {
+				args = append(args, "--rebuild")
+			}
+			_, err := nixOutput(args...)
+			if err == nil {
+				break
+			}
+			m := hashMismatch.FindStringSubmatch(err.Error())
+			if m == nil {
+				return updated, fmt.Errorf("%s", tr("HashBuildFailed", "Installable", target.installable, "Error", err))
+			}
+			if check {
+				return updated, fmt.Errorf("%s", tr("HashOutdated", "Specified", m[1], "Got", m[2]))
+			}
+			attr, err := patchHash(attrs, target.attr, m[1], m[2])
+			if err != nil {
+				return updated, err
+			}
+			success(tr("HashUpdatedIn", "Attr", attr.name, "File", attr.file))
+			updated = append(updated, attr.name)
+		}
+	}
+	return updated, nil
+}
+
+func newHashCmd() *cobra.Command {
+	hashCmd := &cobra.Command{
+		Use:   "hash",
+		Short: "Manage dependency hashes",
+	}
+
+	updateCmd := &cobra.Command{
+		Use:   "update [package]",
+		Short: "Recompute vendorHash/cargoHash/npmDepsHash",
+		Long: "Build the dependency fetch derivations of a package (default: default) and patch " +
+			"vendorHash, cargoHash and npmDepsHash in the project's nix files when nix reports a hash mismatch.",
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkNix(); err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			pkg := "default"
+			if len(args) == 1 {
+				pkg = args[0]
+			}
+			check, _ := cmd.Flags().GetBool("check")
+
+			updated, err := updateDependencyHashes(pkg, check)
+			if err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			if len(updated) == 0 {
+				success(tr("HashUpToDate"))
+			} else {
+				success(tr("HashUpdated", "Attrs", strings.Join(updated, ", ")))
+			}
+			return nil
+		},
+	}
+	updateCmd.Flags().Bool("check", false, "Only report out-of-date hashes, don't patch them")
+
+	hashCmd.AddCommand(updateCmd)
+	return hashCmd
+}
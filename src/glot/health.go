@@ -0,0 +1,515 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// The last glot health score, for glot stats export
+var healthFile = filepath.Join(stateDir, "health.json")
+
+// What measuring a health signal found
+type healthResult struct {
+	score  float64 // From 0 to 1, or negative if the signal couldn't be measured
+	detail string
+	action string // What would raise the score, empty at a full score
+}
+
+// A signal of glot health and its share of the score
+type healthSignal struct {
+	name    string
+	weight  int
+	measure func(options healthOptions) healthResult
+}
+
+type healthOptions struct {
+	rebuild bool // Rebuild the default package to check it is reproducible
+}
+
+var healthSignals = []healthSignal{
+	{"lock", 20, measureLockHealth},
+	{"audit", 20, measureAuditHealth},
+	{"coverage", 15, measureCoverageHealth},
+	{"lint", 15, measureLintHealth},
+	{"reproducibility", 15, measureReproducibility},
+	{"ci", 15, measureCIHealth},
+}
+
+func unmeasured(detail, action string) healthResult {
+	return healthResult{-1, detail, action}
+}
+
+// Fresh when no input is older than [lock] max-age-days; each stale input
+// costs a quarter
+func measureLockHealth(healthOptions) healthResult {
+	issues := flakeLockIssues(lockMaxAge())
+	switch {
+	case len(issues) == 0:
+		return healthResult{1, tr("HealthLockFresh", "Days", int(lockMaxAge().Hours()/24)), ""}
+	case slices.Contains(issues, tr("LockMissing")) || slices.Contains(issues, tr("LockIncomplete")):
+		return healthResult{0, issues[0], tr("HealthLockMissingAction")}
+	}
+	return healthResult{math.Max(0, 1-0.25*float64(len(issues))), strings.Join(issues, ", "), tr("HealthLockStaleAction")}
+}
+
+// Vulnerability scanners by language, run in the dev shell when it has them
+var auditors = map[string]struct {
+	tool    string
+	command []string
+	// The vulnerabilities the scanner reported, from its output and exit
+	// code; false when they don't tell, as when the scan itself failed
+	count func(out []byte, code int) (int, bool)
+}{
+	"rust":       {"cargo-audit", []string{"cargo", "audit", "--json"}, cargoAuditCount},
+	"go":         {"govulncheck", []string{"govulncheck", "./..."}, govulncheckCount},
+	"python":     {"pip-audit", []string{"pip-audit", "--format", "json"}, pipAuditCount},
+	"javascript": {"npm", []string{"npm", "audit", "--json"}, npmAuditCount},
+}
+
+func cargoAuditCount(out []byte, code int) (int, bool) {
+	var report struct {
+		Vulnerabilities *struct {
+			Count int `json:"count"`
+		} `json:"vulnerabilities"`
+	}
+	if json.Unmarshal(out, &report) != nil || report.Vulnerabilities == nil {
+		return 0, false
+	}
+	return report.Vulnerabilities.Count, true
+}
+
+// govulncheck exits with 3 when the code calls vulnerable functions
+var govulncheckFindings = regexp.MustCompile(`Your code is affected by (\d+) vulnerabilit`)
+
+func govulncheckCount(out []byte, code int) (int, bool) {
+	switch code {
+	case 0:
+		return 0, true
+	case 3:
+		if m := govulncheckFindings.FindSubmatch(out); m != nil {
+			count, _ := strconv.Atoi(string(m[1]))
+			return count, true
+		}
+		return 1, true
+	}
+	return 0, false
+}
+
+// pip-audit's report, a list of dependencies in older versions
+func pipAuditCount(out []byte, code int) (int, bool) {
+	type dependency struct {
+		Vulns []json.RawMessage `json:"vulns"`
+	}
+	var report struct {
+		Dependencies []dependency `json:"dependencies"`
+	}
+	if json.Unmarshal(out, &report) != nil {
+		if json.Unmarshal(out, &report.Dependencies) != nil {
+			return 0, false
+		}
+	}
+	count := 0
+	for _, dependency := range report.Dependencies {
+		count += len(dependency.Vulns)
+	}
+	return count, true
+}
+
+func npmAuditCount(out []byte, code int) (int, bool) {
+	var report struct {
+		Metadata *struct {
+			Vulnerabilities struct {
+				Total int `json:"total"`
+			} `json:"vulnerabilities"`
+		} `json:"metadata"`
+	}
+	if json.Unmarshal(out, &report) != nil || report.Metadata == nil {
+		return 0, false
+	}
+	return report.Metadata.Vulnerabilities.Total, true
+}
+
+// Clean when the scanners of the project's language roots find nothing.
+// Scans that fail, rather than report findings, leave it unmeasured.
+func measureAuditHealth(healthOptions) healthResult {
+	roots, err := findLanguageRoots()
+	if err != nil {
+		return unmeasured(err.Error(), "")
+	}
+	var audited []languageRoot
+	for _, root := range roots {
+		if _, ok := auditors[root.language.Name()]; ok {
+			audited = append(audited, root)
+		}
+	}
+	if len(audited) == 0 {
+		return unmeasured(tr("HealthAuditNoScanner"), "")
+	}
+	if offlineMode {
+		return unmeasured(tr("HealthAuditOffline"), "")
+	}
+	env, tools, err := devShellTools()
+	if err != nil {
+		return unmeasured(tr("HealthAuditShellFailed", "Error", err), "")
+	}
+
+	var scanned, failed, missing []string
+	found, firstFinding, firstFailed := 0, "", ""
+	for _, root := range audited {
+		auditor := auditors[root.language.Name()]
+		if _, ok := tools[auditor.tool]; !ok {
+			if !slices.Contains(missing, auditor.tool) {
+				missing = append(missing, auditor.tool)
+			}
+			continue
+		}
+		name := strings.Join(auditor.command, " ")
+		if root.dir != "." {
+			name = tr("HealthAuditIn", "Command", name, "Dir", root.dir)
+		}
+		cmd := exec.Command(env.Bash, env.commandArgs(auditor.command)...)
+		cmd.Dir = root.dir
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		logCommand(cmd)
+		out, err := cmd.Output()
+		debug(fmt.Sprintf("%s:\n%s%s", name, out, stderr.Bytes()))
+		code := 0
+		if exit, ok := err.(*exec.ExitError); ok {
+			code = exit.ExitCode()
+		} else if err != nil {
+			code = -1
+		}
+		count, ok := auditor.count(out, code)
+		switch {
+		case !ok:
+			failed = append(failed, name)
+			if firstFailed == "" {
+				firstFailed = glotToolCommand(auditor.command, root.dir)
+			}
+		case count > 0:
+			found += count
+			scanned = append(scanned, name)
+			if firstFinding == "" {
+				firstFinding = glotToolCommand(auditor.command, root.dir)
+			}
+		default:
+			scanned = append(scanned, name)
+		}
+	}
+	switch {
+	case found > 0:
+		return healthResult{0, trn("HealthAuditFindings", found, "Scans", strings.Join(scanned, ", ")), tr("HealthAuditFindingsAction", "Command", firstFinding)}
+	case len(failed) > 0:
+		return unmeasured(tr("HealthAuditFailed", "Scans", strings.Join(failed, ", ")), tr("HealthAuditFailedAction", "Command", firstFailed))
+	case len(scanned) == 0:
+		return unmeasured(tr("HealthAuditMissing", "Tools", strings.Join(missing, ", ")), tr("HealthAuditMissingAction", "Tools", strings.Join(missing, ", ")))
+	}
+	return healthResult{1, tr("HealthAuditClean", "Scans", strings.Join(scanned, ", ")), ""}
+}
+
+// The glot tool command line running a scanner in a language root
+func glotToolCommand(command []string, dir string) string {
+	line := "glot tool " + strings.Join(command, " ")
+	if dir != "." {
+		line = "cd " + dir + " && " + line
+	}
+	return line
+}
+
+// How the last check's metrics came about, for the details
+func checkMetricsSource(metrics *checkMetrics) string {
+	return tr("HealthLastCheck", "Date", metrics.Time.Format("2006-01-02"))
+}
+
+// The share of the code covered, relative to gates.min-coverage if set
+func measureCoverageHealth(healthOptions) healthResult {
+	metrics := lastCheckMetrics()
+	if metrics == nil || metrics.Coverage == nil {
+		return unmeasured(tr("HealthNoCoverage"), tr("HealthNoCoverageAction"))
+	}
+	target := 100.0
+	if currentConfig.Gates.MinCoverage != nil && *currentConfig.Gates.MinCoverage > 0 {
+		target = *currentConfig.Gates.MinCoverage
+	}
+	detail := fmt.Sprintf("%.1f%% (%s)", *metrics.Coverage, checkMetricsSource(metrics))
+	if *metrics.Coverage >= target {
+		return healthResult{1, detail, ""}
+	}
+	return healthResult{*metrics.Coverage / target, detail, tr("HealthCoverageAction", "Coverage", fmt.Sprintf("%.1f%%", *metrics.Coverage), "Target", fmt.Sprintf("%.0f%%", target))}
+}
+
+// Full without lint findings; each costs a twentieth
+func measureLintHealth(healthOptions) healthResult {
+	metrics := lastCheckMetrics()
+	if metrics == nil || metrics.LintWarnings == nil {
+		return unmeasured(tr("HealthNoLint"), tr("HealthNoLintAction"))
+	}
+	count := *metrics.LintWarnings
+	detail := trn("HealthLintFindings", count, "Source", checkMetricsSource(metrics))
+	if count == 0 {
+		return healthResult{1, detail, ""}
+	}
+	return healthResult{math.Max(0, 1-float64(count)/20), detail, trn("HealthLintAction", count)}
+}
+
+// Whether builds depend only on what is committed: flake.lock tracked,
+// pure evaluation, and with --rebuild, a rebuild giving the same output
+func measureReproducibility(options healthOptions) healthResult {
+	passed, total := 0, 2
+	var problems []string
+	action := ""
+	if err := exec.Command("git", "ls-files", "--error-unmatch", "flake.lock").Run(); err == nil {
+		passed++
+	} else {
+		problems = append(problems, tr("HealthLockNotCommitted"))
+		action = tr("HealthLockNotCommittedAction")
+	}
+	if !currentConfig.Impure {
+		passed++
+	} else {
+		problems = append(problems, tr("HealthImpure", "Config", configFile))
+		if action == "" {
+			action = tr("HealthImpureAction", "Config", configFile)
+		}
+	}
+	if options.rebuild {
+		total++
+		if _, err := nixOutput("build", "--no-link", "."); err != nil {
+			problems = append(problems, tr("HealthBuildFails"))
+		} else if _, err := nixOutput("build", "--no-link", "--rebuild", "."); err != nil {
+			problems = append(problems, tr("HealthRebuildDiffers"))
+			if action == "" {
+				action = tr("HealthRebuildAction")
+			}
+		} else {
+			passed++
+		}
+	}
+	if len(problems) > 0 {
+		return healthResult{float64(passed) / float64(total), strings.Join(problems, ", "), action}
+	}
+	detail := tr("HealthReproducible")
+	if options.rebuild {
+		detail += ", " + tr("HealthIdenticalRebuild")
+	}
+	return healthResult{1, detail, ""}
+}
+
+// CI configuration of the common providers
+var ciConfigs = []string{
+	".github/workflows/*.yml", ".github/workflows/*.yaml", ".gitlab-ci.yml", ".circleci/config.yml", "Jenkinsfile",
+	".buildkite/pipeline.yml", "azure-pipelines.yml", "bitbucket-pipelines.yml", ".woodpecker.yml", ".woodpecker/*.yml",
+}
+
+// Full for CI that runs glot check, half for CI that doesn't
+func measureCIHealth(healthOptions) healthResult {
+	var configs []string
+	runsCheck := false
+	for _, pattern := range ciConfigs {
+		matches, _ := filepath.Glob(pattern)
+		for _, path := range matches {
+			configs = append(configs, path)
+			content, _ := os.ReadFile(path)
+			if strings.Contains(string(content), "glot check") || strings.Contains(string(content), "glot -- check") {
+				runsCheck = true
+			}
+		}
+	}
+	switch {
+	case len(configs) == 0:
+		return healthResult{0, tr("HealthNoCI"), tr("HealthNoCIAction")}
+	case !runsCheck:
+		return healthResult{0.5, tr("HealthCINoCheck", "Configs", strings.Join(configs, ", ")), tr("HealthCINoCheckAction")}
+	}
+	return healthResult{1, tr("HealthCIRunsCheck", "Configs", strings.Join(configs, ", ")), ""}
+}
+
+// A measured signal and what raising it to full is worth
+type healthReport struct {
+	signal healthSignal
+	result healthResult
+}
+
+func (r healthReport) gain() float64 {
+	if r.result.score < 0 {
+		return 0
+	}
+	return float64(r.signal.weight) * (1 - r.result.score)
+}
+
+// The weight of the measured signals
+func measuredWeight(reports []healthReport) float64 {
+	var weights float64
+	for _, report := range reports {
+		if report.result.score >= 0 {
+			weights += float64(report.signal.weight)
+		}
+	}
+	return weights
+}
+
+// The weighted score of the measured signals, from 0 to 100
+func healthScore(reports []healthReport) int {
+	weights := measuredWeight(reports)
+	if weights == 0 {
+		return 0
+	}
+	var sum float64
+	for _, report := range reports {
+		if report.result.score >= 0 {
+			sum += report.result.score * float64(report.signal.weight)
+		}
+	}
+	return int(math.Round(100 * sum / weights))
+}
+
+// The last recorded health score
+type healthRecord struct {
+	Time   time.Time      `json:"time"`
+	Commit string         `json:"commit,omitempty"`
+	Score  int            `json:"score"`
+	Scores map[string]int `json:"scores"` // Of the measured signals, from 0 to 100
+}
+
+func recordHealth(score int, reports []healthReport) error {
+	if err := ensureStateDir(stateDir); err != nil {
+		return err
+	}
+	record := healthRecord{Time: time.Now(), Commit: gitCommit(), Score: score, Scores: map[string]int{}}
+	for _, report := range reports {
+		if report.result.score >= 0 {
+			record.Scores[report.signal.name] = int(math.Round(100 * report.result.score))
+		}
+	}
+	content, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(healthFile, append(content, '\n'), 0644)
+}
+
+// The last recorded health score, nil if glot health never ran
+func lastHealth() *healthRecord {
+	content, err := os.ReadFile(healthFile)
+	if err != nil {
+		return nil
+	}
+	var record healthRecord
+	if json.Unmarshal(content, &record) != nil {
+		return nil
+	}
+	return &record
+}
+
+func newHealthCmd() *cobra.Command {
+	names := make([]string, len(healthSignals))
+	for i, signal := range healthSignals {
+		names[i] = signal.name
+	}
+	cmd := &cobra.Command{
+		Use:   "health",
+		Short: "Score the project's health, with what to do next",
+		Long: "Score the project from 0 to 100 on the freshness of flake.lock, vulnerability scanner findings, test coverage and lint findings " +
+			"of the last glot check, reproducibility and CI, and list the actions that raise the score most first. " +
+			"Signals that can't be measured don't count. The score is recorded in " + healthFile + " for glot stats export.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkNix(); err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			skip, _ := cmd.Flags().GetStringSlice("skip")
+			for _, name := range skip {
+				if !slices.Contains(names, name) {
+					err := fmt.Errorf("%s", tr("HealthUnknownSignal", "Signal", name, "Available", strings.Join(names, ", ")))
+					errorMsg(err.Error())
+					return err
+				}
+			}
+			var options healthOptions
+			options.rebuild, _ = cmd.Flags().GetBool("rebuild")
+			scoreOnly, _ := cmd.Flags().GetBool("score")
+
+			var reports []healthReport
+			for _, signal := range healthSignals {
+				if slices.Contains(skip, signal.name) {
+					continue
+				}
+				reports = append(reports, healthReport{signal, signal.measure(options)})
+			}
+			score := healthScore(reports)
+			if err := recordHealth(score, reports); err != nil {
+				warning(tr("HealthRecordFailed", "Error", err))
+			}
+
+			if scoreOnly {
+				fmt.Println(score)
+			} else {
+				for _, report := range reports {
+					result := report.result
+					if result.score < 0 {
+						info(tr("HealthNotMeasured", "Signal", report.signal.name, "Detail", result.detail))
+						continue
+					}
+					message := tr("HealthSignal", "Signal", report.signal.name, "Score", int(math.Round(100*result.score)), "Detail", result.detail)
+					switch {
+					case result.score >= 1:
+						success(message)
+					case result.score >= 0.5:
+						warning(message)
+					default:
+						logMessage(levelError, "error", "❌ ", message)
+					}
+				}
+
+				// The biggest gains first, then the actions of unmeasured signals
+				actions := slices.Clone(reports)
+				sort.SliceStable(actions, func(i, j int) bool { return actions[i].gain() > actions[j].gain() })
+				fmt.Println()
+				fmt.Println(tr("HealthScore", "Score", score))
+				next := 0
+				for _, report := range actions {
+					if report.result.action == "" {
+						continue
+					}
+					next++
+					if next == 1 {
+						fmt.Println()
+						fmt.Println(tr("HealthNextActions"))
+					}
+					if gain := 100 * report.gain() / measuredWeight(reports); gain >= 0.5 {
+						fmt.Printf("  %d. %s\n", next, tr("HealthActionGain", "Action", report.result.action, "Gain", fmt.Sprintf("%.0f", gain)))
+					} else {
+						fmt.Printf("  %d. %s\n", next, report.result.action)
+					}
+				}
+			}
+
+			if minimum, _ := cmd.Flags().GetInt("min-score"); score < minimum {
+				err := fmt.Errorf("%s", tr("HealthBelowMinimum", "Score", score, "Minimum", minimum))
+				errorMsg(err.Error())
+				return err
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringSlice("skip", nil, "Signals not to measure: "+strings.Join(names, ", "))
+	cmd.Flags().Bool("rebuild", false, "Also rebuild the default package to check it builds the same output")
+	cmd.Flags().Bool("score", false, "Print only the score")
+	cmd.Flags().Int("min-score", 0, "Fail when the score is below this, e.g. in CI")
+	return cmd
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"embed"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+	"golang.org/x/text/language"
+)
+
+// Message catalogs, one active.<lang>.toml per language. English is the
+// source: every message has an entry there, and translations fall back to it.
+//
+//go:embed locales/*.toml
+var localeFiles embed.FS
+
+var (
+	messageBundle = loadMessageBundle()
+	localizer     = i18n.NewLocalizer(messageBundle, systemLocale())
+)
+
+func loadMessageBundle() *i18n.Bundle {
+	bundle := i18n.NewBundle(language.English)
+	bundle.RegisterUnmarshalFunc("toml", toml.Unmarshal)
+	entries, _ := localeFiles.ReadDir("locales")
+	for _, entry := range entries {
+		if _, err := bundle.LoadMessageFileFS(localeFiles, path.Join("locales", entry.Name())); err != nil {
+			panic(err)
+		}
+	}
+	return bundle
+}
+
+// Language of the user's messages, as gettext picks it: LC_ALL, then
+// LC_MESSAGES, then LANG. de_DE.UTF-8 becomes de-DE; C and POSIX are English.
+func systemLocale() string {
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if value := os.Getenv(name); value != "" {
+			return localeTag(value)
+		}
+	}
+	return "en"
+}
+
+// BCP 47 tag of a POSIX locale name
+func localeTag(locale string) string {
+	locale, _, _ = strings.Cut(locale, ".")
+	locale, _, _ = strings.Cut(locale, "@")
+	if locale == "C" || locale == "POSIX" {
+		return "en"
+	}
+	return strings.ReplaceAll(locale, "_", "-")
+}
+
+// Use GLOT_LOCALE or locale in glot.toml over the system locale
+func applyLocale() {
+	locale := os.Getenv("GLOT_LOCALE")
+	if locale == "" {
+		locale = currentConfig.Locale
+	}
+	if locale != "" {
+		localizer = i18n.NewLocalizer(messageBundle, localeTag(locale), systemLocale())
+	}
+}
+
+// A message of the catalog in the user's language, with its {{.Name}}
+// fields taken from name-value pairs: tr("BuildStarted", "Target", ".#dev")
+func tr(id string, fields ...any) string {
+	return localize(&i18n.LocalizeConfig{MessageID: id, TemplateData: templateData(fields)})
+}
+
+// A message with plural forms, chosen by count, which is also {{.Count}}
+func trn(id string, count int, fields ...any) string {
+	data := templateData(fields)
+	data["Count"] = count
+	return localize(&i18n.LocalizeConfig{MessageID: id, PluralCount: count, TemplateData: data})
+}
+
+func templateData(fields []any) map[string]any {
+	data := map[string]any{}
+	for i := 0; i+1 < len(fields); i += 2 {
+		if name, ok := fields[i].(string); ok {
+			data[name] = fields[i+1]
+		}
+	}
+	return data
+}
+
+// A message missing from the catalog shows as its ID rather than nothing
+func localize(config *i18n.LocalizeConfig) string {
+	message, err := localizer.Localize(config)
+	if err != nil && message == "" {
+		debug("Message not in the catalog: " + config.MessageID)
+		return config.MessageID
+	}
+	return message
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// Template files that make up the nix-polyglot integration, as opposed to
+// the project's sources
+var integrationFiles = []string{"flake.nix", ".envrc", ".editorconfig"}
+
+// Entries for nix and direnv output that an existing .gitignore needs
+var nixGitignoreEntries = []string{"result", "result-*", ".direnv/"}
+
+// The template for a language: its short name, or the first template for it
+func defaultTemplate(templates []*templateManifest, language string) *templateManifest {
+	if template := findTemplate(templates, language); template != nil {
+		return template
+	}
+	for _, template := range templates {
+		if template.Language == language {
+			return template
+		}
+	}
+	return nil
+}
+
+// Add the nix and direnv entries missing from .gitignore
+func addNixGitignoreEntries(dir string) ([]string, error) {
+	file := filepath.Join(dir, ".gitignore")
+	content, err := os.ReadFile(file)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	present := map[string]bool{}
+	for _, line := range strings.Split(string(content), "\n") {
+		present[strings.TrimSpace(line)] = true
+	}
+	var missing []string
+	for _, entry := range nixGitignoreEntries {
+		if !present[entry] && !present["/"+entry] {
+			missing = append(missing, entry)
+		}
+	}
+	if len(missing) == 0 {
+		return nil, nil
+	}
+
+	text := "\n# Nix build results and direnv\n" + strings.Join(missing, "\n") + "\n"
+	if len(content) > 0 && !strings.HasSuffix(string(content), "\n") {
+		text = "\n" + text
+	}
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	_, err = f.WriteString(text)
+	return missing, err
+}
+
+func newInitCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "init",
+		Short: "Add nix-polyglot to an existing project",
+		Long: "Detect the language of the project in the current directory and add the flake.nix, .envrc " +
+			"and .editorconfig of the language's template, named after the project. Sources are left untouched.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Like 'new', init only needs nix installed, not flake.nix present
+			if err := checkNixInstalled(); err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			force, _ := cmd.Flags().GetBool("force")
+			if _, err := os.Stat("flake.nix"); err == nil && !force {
+				err := fmt.Errorf("%s", tr("InitFlakeExists"))
+				errorMsg(err.Error())
+				return err
+			}
+
+			root, err := resolvePolyglotRoot()
+			if err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			templates, err := loadTemplates(root)
+			if err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+
+			var template *templateManifest
+			name, modulePath := "", ""
+			if templateName, _ := cmd.Flags().GetString("template"); templateName != "" {
+				if template = findTemplate(templates, templateName); template == nil {
+					err := fmt.Errorf("%s", tr("NewUnknownTemplate", "Template", templateName))
+					errorMsg(err.Error())
+					return err
+				}
+				for _, language := range languages {
+					if language.Name() == template.Language {
+						name, modulePath = language.Project()
+					}
+				}
+			} else {
+				language, err := detectLanguage()
+				if err != nil {
+					err = fmt.Errorf("%s", tr("InitNoLanguage", "Error", err))
+					errorMsg(err.Error())
+					return err
+				}
+				if template = defaultTemplate(templates, language.Name()); template == nil {
+					err := fmt.Errorf("%s", tr("InitNoTemplate", "Language", language.Name()))
+					errorMsg(err.Error())
+					return err
+				}
+				info(tr("InitDetected", "Language", language.Name()))
+				name, modulePath = language.Project()
+			}
+
+			cwd, err := os.Getwd()
+			if err != nil {
+				return err
+			}
+			if name == "" {
+				name = filepath.Base(cwd)
+			}
+			if module, _ := cmd.Flags().GetString("module"); module != "" {
+				modulePath = module
+			}
+			vars := projectVariables(name, modulePath)
+
+			var written []string
+			for _, dest := range integrationFiles {
+				if _, ok := template.Files[dest]; !ok {
+					continue
+				}
+				if _, err := os.Stat(dest); err == nil && !force {
+					warning(tr("InitKeeping", "File", dest))
+					continue
+				}
+				if err := writeTemplateFile(template, ".", dest, vars, nil); err != nil {
+					errorMsg(tr("WriteFailed", "File", dest, "Error", err))
+					return err
+				}
+				success(tr("InitCreated", "File", dest, "Template", template.Name))
+				written = append(written, dest)
+			}
+			added, err := addNixGitignoreEntries(".")
+			if err != nil {
+				warning(tr("InitGitignoreFailed", "Error", err))
+			} else if len(added) > 0 {
+				success(tr("InitGitignoreAdded", "Entries", strings.Join(added, ", ")))
+				written = append(written, ".gitignore")
+			}
+
+			// Flakes only see files tracked by git
+			if gitIn(".", "rev-parse", "--git-dir") == nil {
+				if len(written) > 0 {
+					if err := gitIn(".", append([]string{"add", "--"}, written...)...); err != nil {
+						warning(tr("InitStageFailed", "Error", err))
+					}
+				}
+				if out, _ := exec.Command("git", "ls-files", "--others", "--exclude-standard").Output(); len(out) > 0 {
+					warning(tr("InitUntracked"))
+				}
+			}
+
+			fmt.Println()
+			fmt.Println(plain("📋 " + tr("NextSteps")))
+			fmt.Printf("  %-20s # %s\n", "direnv allow", tr("NextStepDirenv"))
+			for _, hook := range template.PostGenerate {
+				fmt.Printf("  %-20s # %s\n", hook, tr("NextStepHook"))
+			}
+			fmt.Printf("  %-20s # %s\n", "glot build", tr("NextStepBuildDev"))
+			fmt.Printf("  %-20s # %s\n", "glot build --release", tr("NextStepBuildRelease"))
+			fmt.Println("  git commit -m 'Add nix-polyglot'")
+			return nil
+		},
+	}
+	cmd.Flags().String("template", "", "Template to take the files from (default: detected from the project's language)")
+	cmd.Flags().String("module", "", "Module path (default: from go.mod, or example.com/<name>)")
+	cmd.Flags().Bool("force", false, "Replace existing flake.nix, .envrc and .editorconfig")
+	return cmd
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+type javascriptLanguage struct{ toolchain }
+
+func newJavaScriptLanguage() Language {
+	return javascriptLanguage{toolchain{
+		name:       "javascript",
+		markers:    []string{"package.json"},
+		lsp:        []string{"typescript-language-server", "--stdio"},
+		lspPackage: "typescript-language-server",
+		format:     []string{"npx", "--no-install", "prettier", "--write", "."},
+		// Projects declare their linter as an npm script
+		lint: []string{"npm", "run", "lint", "--if-present"},
+		test: []string{"npm", "test"},
+		// npm passes the arguments after -- to the test script
+		testWith: func(options testOptions) ([]string, error) {
+			if options.Filter != "" {
+				return nil, fmt.Errorf("%s", tr("LanguageNoNpmFilter"))
+			}
+			command := []string{"npm", "test"}
+			if len(options.Args) > 0 {
+				command = append(append(command, "--"), options.Args...)
+			}
+			return command, nil
+		},
+		update:    []string{"npm", "update"},
+		artifacts: []string{"node_modules/", "dist/"},
+		// Packages of a monorepo are linted from their own directories
+		nested: true,
+	}}
+}
+
+type packageManifest struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+func readPackageJSON() (packageManifest, error) {
+	var manifest packageManifest
+	content, err := os.ReadFile("package.json")
+	if err != nil {
+		return manifest, err
+	}
+	err = json.Unmarshal(content, &manifest)
+	return manifest, err
+}
+
+func (javascriptLanguage) Info() []string {
+	manifest, err := readPackageJSON()
+	if err != nil || manifest.Name == "" {
+		return nil
+	}
+	return []string{tr("InfoPackageVersion", "Name", manifest.Name, "Version", manifest.Version)}
+}
+
+func (javascriptLanguage) Project() (string, string) {
+	manifest, _ := readPackageJSON()
+	return manifest.Name, ""
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// The flake package of a Lambda function: bootstrap, the function's
+// executable for Lambda's OS-only runtimes, and function.zip, the archive
+// to deploy, with bootstrap at its root
+const lambdaOutput = "lambda"
+
+// Where Lambda's runtime interface emulator takes invocations
+const lambdaInvokePath = "/2015-03-31/functions/function/invocations"
+
+// How long the emulator may take to start, fetching it included
+const lambdaStartTimeout = 2 * time.Minute
+
+// Build the flake's lambda package, returning its store path
+func buildLambda() (string, error) {
+	if err := checkTarget(lambdaOutput, false); err != nil {
+		return "", fmt.Errorf("%s", tr("LambdaNoPackage", "Output", lambdaOutput))
+	}
+	out, err := nixOutput("build", ".#"+lambdaOutput, "--no-link", "--print-out-paths")
+	if err != nil {
+		return "", fmt.Errorf("%s", tr("LambdaBuildFailed", "Output", lambdaOutput, "Error", err))
+	}
+	return strings.Fields(string(out))[0], nil
+}
+
+// Write the function's deployable archive to output, or to releaseDir.
+// Returns the path of the archive.
+func packageLambda(output string) (string, error) {
+	path, err := buildLambda()
+	if err != nil {
+		return "", err
+	}
+	content, err := os.ReadFile(filepath.Join(path, "function.zip"))
+	if err != nil {
+		return "", fmt.Errorf("%s", tr("LambdaNoZip", "Output", lambdaOutput, "Error", err))
+	}
+	if output == "" {
+		if err := ensureReleaseDir(); err != nil {
+			return "", err
+		}
+		cwd, err := os.Getwd()
+		if err != nil {
+			return "", err
+		}
+		name := strings.ToLower(filepath.Base(cwd))
+		output = filepath.Join(releaseDir, fmt.Sprintf("%s-%s-lambda.zip", name, releaseVersion()))
+	}
+	return output, os.WriteFile(output, content, 0644)
+}
+
+// The event to invoke the function with: the file, stdin for -, or an
+// empty object
+func lambdaEvent(file string) ([]byte, error) {
+	switch file {
+	case "":
+		return []byte("{}"), nil
+	case "-":
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(file)
+}
+
+// Command running Lambda's runtime interface emulator: from PATH, as the
+// go-lambda template's dev shell has it, or else from nixpkgs
+func lambdaEmulator(args ...string) *exec.Cmd {
+	if _, err := exec.LookPath("aws-lambda-rie"); err == nil {
+		return exec.Command("aws-lambda-rie", args...)
+	}
+	nixArgs := append([]string{"shell", "nixpkgs#aws-lambda-rie", "--command", "aws-lambda-rie"}, args...)
+	return exec.Command("nix", withNixGlobalArgs(nixArgs)...)
+}
+
+// Invoke the function once in the runtime interface emulator, printing its
+// response. The function's and the emulator's logs go to stderr.
+func invokeLambdaLocally(event []byte) error {
+	path, err := buildLambda()
+	if err != nil {
+		return err
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	emulator := lambdaEmulator("--runtime-interface-emulator-address", address, filepath.Join(path, "bootstrap"))
+	logs := newRedactingWriter(logged(stderrOutput()))
+	defer logs.Flush()
+	emulator.Stdout = logs
+	emulator.Stderr = logs
+	logCommand(emulator)
+	if err := emulator.Start(); err != nil {
+		return fmt.Errorf("%s", tr("LambdaEmulatorFailed", "Error", err))
+	}
+	exited := make(chan error, 1)
+	go func() {
+		defer recoverCrash()
+		exited <- emulator.Wait()
+	}()
+	defer func() {
+		emulator.Process.Signal(os.Interrupt)
+		select {
+		case <-exited:
+		case <-time.After(5 * time.Second):
+			emulator.Process.Kill()
+		}
+	}()
+
+	// The emulator starts the function with the first invocation, so the
+	// invocation waits for the emulator to listen
+	deadline := time.Now().Add(lambdaStartTimeout)
+	for {
+		select {
+		case err := <-exited:
+			exited <- err
+			return fmt.Errorf("%s", tr("LambdaEmulatorExited", "Status", exitStatus(err)))
+		default:
+		}
+		if conn, err := net.Dial("tcp", address); err == nil {
+			conn.Close()
+			break
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%s", tr("LambdaEmulatorTimeout", "Address", address, "Timeout", lambdaStartTimeout))
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	response, err := http.Post("http://"+address+lambdaInvokePath, "application/json", bytes.NewReader(event))
+	if err != nil {
+		return fmt.Errorf("%s", tr("LambdaInvokeFailed", "Error", err))
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+	logs.Flush()
+	fmt.Println(strings.TrimSpace(string(body)))
+
+	// Errors of the function are responses too, with its error's type
+	var failure struct {
+		ErrorType    string `json:"errorType"`
+		ErrorMessage string `json:"errorMessage"`
+	}
+	if json.Unmarshal(body, &failure) == nil && failure.ErrorType != "" {
+		return fmt.Errorf("%s", tr("LambdaFunctionFailed", "Type", failure.ErrorType, "Message", failure.ErrorMessage))
+	}
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s", tr("LambdaEmulatorStatus", "Status", response.Status))
+	}
+	return nil
+}
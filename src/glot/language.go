@@ -0,0 +1,239 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Language drives a language's toolchain for the commands the flake doesn't
+// provide an app for. Adding a language means adding a file implementing it
+// and listing it in languages.
+type Language interface {
+	Name() string
+	// Whether the project in the current directory uses the language
+	Detect() bool
+	Format() error
+	Lint() error
+	Test(options testOptions) error
+	// Remove build artifacts
+	Clean() error
+	UpdateDeps() error
+	// Extra lines for glot info, e.g. the package name
+	Info() []string
+	// Name and module path declared by the project's manifest, empty if it
+	// declares none
+	Project() (name, modulePath string)
+	// The language's toolchain rooted at dir, relative to the project root,
+	// for workspaces with several language roots
+	At(dir string) Language
+	// Whether nested directories with the language's markers are projects
+	// of their own rather than part of the enclosing one
+	NestedProjects() bool
+	// Commands the language's tasks run, which the dev shell provides
+	Tools() []string
+	// Language server speaking LSP on stdio, and the nixpkgs package with it
+	LanguageServer() (command []string, pkg string)
+}
+
+// Supported languages, in detection order. Polyglot projects are detected by
+// their first match, so languages that host others (e.g. a Cargo workspace
+// with bindings) come first.
+var languages = []Language{
+	newRustLanguage(),
+	newGoLanguage(),
+	newZigLanguage(),
+	newNimLanguage(),
+	newPythonLanguage(),
+	newCSharpLanguage(),
+	newCppLanguage(),
+	newJavaScriptLanguage(),
+	newTerraformLanguage(),
+}
+
+// Returned by languages that have no command for a task
+type unsupportedTaskError struct {
+	task     string
+	language string
+}
+
+func (e unsupportedTaskError) Error() string {
+	return tr("LanguageNoCommand", "Task", e.task, "Language", e.language)
+}
+
+// Options of glot test for the test runner
+type testOptions struct {
+	Filter   string   // Run only the tests matching the pattern
+	Verbose  bool     // Show the output of passing tests too
+	Coverage bool     // Collect coverage into .glot/coverage/<language>
+	Args     []string // Passed on to the test runner
+}
+
+// toolchain implements Language with commands run in the dev shell.
+// Languages embed it and override the methods that need more.
+type toolchain struct {
+	name    string
+	markers []string // Glob patterns at the project root identifying the language
+	format  []string
+	lint    []string
+	test    []string
+	// Test command for the options of glot test; nil appends the arguments
+	// to test and supports no filter
+	testWith func(options testOptions) ([]string, error)
+	// Coverage variant of a test command, writing to the directory out, and
+	// the commands that report on it; nil if glot can't collect coverage
+	coverage   func(command []string, out string) (test []string, reports [][]string)
+	update     []string
+	artifacts  []string // Build artifacts removed by glot clean
+	nested     bool     // Nested roots are separate projects, like Go modules
+	lsp        []string // Language server, run by glot lsp
+	lspPackage string   // nixpkgs package of the language server
+	dir        string   // Root relative to the project root, "" for the project root
+}
+
+func (t toolchain) Name() string { return t.name }
+
+func (t toolchain) Detect() bool {
+	for _, marker := range t.markers {
+		if matches, _ := filepath.Glob(filepath.Join(t.dir, marker)); len(matches) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (t toolchain) run(task string, command []string) error {
+	if len(command) == 0 {
+		return unsupportedTaskError{task, t.name}
+	}
+	if t.dir != "" && t.dir != "." {
+		// One dev shell serves the whole project; enter the root inside it
+		command = append([]string{"bash", "-c", `cd "$1" && shift && exec "$@"`, "glot", t.dir}, command...)
+	}
+	return runInDevShell(command...)
+}
+
+func (t toolchain) Format() error     { return t.run("format", t.format) }
+func (t toolchain) Lint() error       { return t.run("lint", t.lint) }
+func (t toolchain) UpdateDeps() error { return t.run("update", t.update) }
+func (t toolchain) Info() []string    { return nil }
+
+func (t toolchain) Test(options testOptions) error {
+	if len(t.test) == 0 {
+		return unsupportedTaskError{"test", t.name}
+	}
+	command := append(append([]string{}, t.test...), options.Args...)
+	if t.testWith != nil {
+		var err error
+		if command, err = t.testWith(options); err != nil {
+			return err
+		}
+	} else if options.Filter != "" {
+		return fmt.Errorf("%s", tr("LanguageNoFilter", "Language", t.name))
+	}
+	if !options.Coverage {
+		return t.run("test", command)
+	}
+
+	if t.coverage == nil {
+		return fmt.Errorf("%s", tr("LanguageNoCoverage", "Language", t.name))
+	}
+	out, err := prepareCoverageDir(t.name)
+	if err != nil {
+		return err
+	}
+	command, reports := t.coverage(command, out)
+	if err := t.run("test", command); err != nil {
+		return err
+	}
+	for _, report := range reports {
+		if err := t.run("coverage", report); err != nil {
+			return fmt.Errorf("%s", tr("LanguageCoverageReportFailed", "Error", err))
+		}
+	}
+	return nil
+}
+
+func (t toolchain) Project() (string, string) { return "", "" }
+
+func (t toolchain) At(dir string) Language {
+	t.dir = dir
+	return t
+}
+
+func (t toolchain) NestedProjects() bool { return t.nested }
+
+func (t toolchain) LanguageServer() ([]string, string) { return t.lsp, t.lspPackage }
+
+func (t toolchain) Tools() []string {
+	var tools []string
+	for _, command := range [][]string{t.format, t.lint, t.test, t.update} {
+		if len(command) > 0 && !slices.Contains(tools, command[0]) {
+			tools = append(tools, command[0])
+		}
+	}
+	return tools
+}
+
+func (t toolchain) Clean() error {
+	for _, pattern := range t.artifacts {
+		matches, _ := filepath.Glob(pattern)
+		for _, match := range matches {
+			if err := os.RemoveAll(match); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Names of the supported languages, in detection order
+func languageNames() []string {
+	names := make([]string, len(languages))
+	for i, language := range languages {
+		names[i] = language.Name()
+	}
+	return names
+}
+
+// The project language: the one set in glot.toml, otherwise detected from
+// the files at the project root
+func detectLanguage() (Language, error) {
+	if name := currentConfig.Language; name != "" {
+		for _, language := range languages {
+			if language.Name() == name {
+				debug(fmt.Sprintf("Language %s, from %s", name, configFile))
+				return language, nil
+			}
+		}
+		return nil, fmt.Errorf("%s", tr("LanguageUnknown", "Language", name, "File", configFile, "Languages", strings.Join(languageNames(), ", ")))
+	}
+	for _, language := range languages {
+		if language.Detect() {
+			debug(fmt.Sprintf("Detected language %s", language.Name()))
+			return language, nil
+		}
+	}
+	return nil, fmt.Errorf("%s", tr("LanguageNotDetected"))
+}
+
+// Run a task for the detected language, explaining why when it can't
+func runLanguageTask(task string, run func(Language) error) error {
+	language, err := detectLanguage()
+	if err != nil {
+		err = fmt.Errorf("%w - %s", err, tr("LanguageAddApp", "Task", task))
+		errorMsg(err.Error())
+		return err
+	}
+	err = run(language)
+	// A failing tool has said why; other errors need explaining
+	var exitError *exec.ExitError
+	if err != nil && !errors.As(err, &exitError) {
+		errorMsg(err.Error())
+	}
+	return err
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Formats of `glot package --format` besides the container image, for users
+// without nix. Each contains the release build with its whole closure.
+var linuxPackageFormats = []string{"appimage", "deb", "rpm"}
+
+// nix bundler turning a flake output into a self-contained AppImage
+const appImageBundler = "github:ralismark/nix-appimage"
+
+// Link to the last AppImage bundle
+var appImageLink = filepath.Join(stateDir, "appimage")
+
+// Release versions that are tags, not commits
+var taggedVersion = regexp.MustCompile(`^[0-9]+([.-]|$)`)
+
+// Version of a package: deb and rpm versions must start with a digit, so
+// untagged builds become 0~<commit>
+func packageVersion() string {
+	version := releaseVersion()
+	if !taggedVersion.MatchString(version) {
+		version = "0~" + version
+	}
+	// Neither allows '-' in the version, which separates the revision
+	return strings.ReplaceAll(version, "-", ".")
+}
+
+// Build the release output as an AppImage, deb or rpm, written to output or
+// to releaseDir. Returns the path of the package.
+func buildLinuxPackage(format, output string) (string, error) {
+	system, err := currentSystem()
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasSuffix(system, "-linux") {
+		return "", fmt.Errorf("%s", tr("LinuxPackageNotLinux", "Format", format, "System", system))
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	name := strings.ToLower(filepath.Base(cwd))
+	version := packageVersion()
+	if output == "" {
+		if err := ensureReleaseDir(); err != nil {
+			return "", err
+		}
+		extension := map[string]string{"appimage": ".AppImage", "deb": ".deb", "rpm": ".rpm"}[format]
+		output = filepath.Join(releaseDir, fmt.Sprintf("%s-%s-%s%s", name, version, system, extension))
+	}
+	if format == "appimage" {
+		return output, bundleAppImage(output)
+	}
+
+	out, err := nixOutput("build", ".#release", "--no-link", "--print-out-paths")
+	if err != nil {
+		return "", fmt.Errorf("%s", tr("ReleaseBuildFailed", "Error", err))
+	}
+	roots := strings.Fields(string(out))
+	out, err = nixOutput(append([]string{"path-info", "--recursive"}, roots...)...)
+	if err != nil {
+		return "", err
+	}
+	closure := strings.Fields(string(out))
+
+	// The binaries go on PATH through /usr/bin links into the store paths
+	links, err := os.MkdirTemp("", "glot-package-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(links)
+	binaries := 0
+	for _, root := range roots {
+		entries, err := os.ReadDir(filepath.Join(root, "bin"))
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if err := os.Symlink(filepath.Join(root, "bin", entry.Name()), filepath.Join(links, entry.Name())); err != nil {
+				return "", err
+			}
+			binaries++
+		}
+	}
+	if binaries == 0 {
+		return "", fmt.Errorf("%s", tr("ReleaseNoBinaries"))
+	}
+
+	args := []string{"-s", "dir", "-t", format, "-n", name, "-v", version, "--architecture", "native", "-p", output, "--force"}
+	args = append(args, packageMetadataArgs(currentConfig.Package)...)
+	args = append(args, links+"/=/usr/bin/")
+	for _, path := range closure {
+		args = append(args, path+"="+path)
+	}
+	tools := []string{"nixpkgs#fpm"}
+	if format == "rpm" {
+		tools = append(tools, "nixpkgs#rpm")
+	}
+	shell := append(append([]string{"shell"}, tools...), "--command", "fpm")
+	if err := runNix(append(shell, args...)...); err != nil {
+		return "", fmt.Errorf("%s", tr("LinuxPackageFpmFailed", "Format", format, "Error", err))
+	}
+	return output, nil
+}
+
+// fpm options for the metadata in [package], warning about what package
+// managers show but glot.toml doesn't set
+func packageMetadataArgs(config packageConfig) []string {
+	var args []string
+	if config.Description != "" {
+		args = append(args, "--description", config.Description)
+	} else {
+		warning(tr("LinuxPackageNoDescription", "Config", configFile))
+	}
+	if config.Maintainer != "" {
+		args = append(args, "--maintainer", config.Maintainer)
+	} else {
+		warning(tr("LinuxPackageNoMaintainer", "Config", configFile))
+	}
+	if config.License != "" {
+		args = append(args, "--license", config.License)
+	}
+	if config.Homepage != "" {
+		args = append(args, "--url", config.Homepage)
+	}
+	return args
+}
+
+// Bundle the release output into an AppImage with its closure
+func bundleAppImage(output string) error {
+	if err := ensureStateDir(stateDir); err != nil {
+		return err
+	}
+	link, err := filepath.Abs(appImageLink)
+	if err != nil {
+		return err
+	}
+	if err := runNix("bundle", "--bundler", appImageBundler, "--out-link", link, ".#release"); err != nil {
+		return fmt.Errorf("%s", tr("LinuxPackageBundleFailed", "Error", err))
+	}
+	bundle := link
+	if stat, err := os.Stat(link); err == nil && stat.IsDir() {
+		matches, _ := filepath.Glob(filepath.Join(link, "*.AppImage"))
+		if len(matches) == 0 {
+			return fmt.Errorf("%s", tr("LinuxPackageNoAppImage", "Bundle", link))
+		}
+		bundle = matches[0]
+	}
+	return copyExecutable(bundle, output)
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Flake inputs locked longer ago than this are reported as stale
+const defaultLockMaxAgeDays = 90
+
+// lockConfig is the [lock] section of glot.toml
+type lockConfig struct {
+	MaxAgeDays int  `toml:"max-age-days"` // Age after which locked inputs are stale
+	Strict     bool `toml:"strict"`       // Treat a stale lock as an error, like --strict-lock
+}
+
+// A node of flake.lock: an input, where it is locked and what the flake
+// asked for
+type lockNode struct {
+	Inputs map[string]json.RawMessage `json:"inputs"`
+	Locked struct {
+		LastModified int64  `json:"lastModified"`
+		Type         string `json:"type"`
+		Owner        string `json:"owner"`
+		Repo         string `json:"repo"`
+		Rev          string `json:"rev"`
+	} `json:"locked"`
+	Original struct {
+		Type string `json:"type"`
+		ID   string `json:"id"`
+		Ref  string `json:"ref"`
+	} `json:"original"`
+}
+
+// Describe problems with flake.lock: missing, incomplete, inputs older
+// than maxAge, or inputs taken from the flake registry (like nixpkgs) that
+// the registry now resolves elsewhere. An empty result means the lock looks
+// fresh.
+func flakeLockIssues(maxAge time.Duration) []string {
+	if _, err := os.Stat("flake.lock"); os.IsNotExist(err) {
+		return []string{tr("LockMissing")}
+	}
+
+	out, err := nixOutput("flake", "metadata", "--json", "--no-update-lock-file")
+	if err != nil {
+		if strings.Contains(err.Error(), "lock file") {
+			return []string{tr("LockIncomplete")}
+		}
+		return nil
+	}
+	var metadata struct {
+		Locks struct {
+			Root  string              `json:"root"`
+			Nodes map[string]lockNode `json:"nodes"`
+		} `json:"locks"`
+	}
+	if err := json.Unmarshal(out, &metadata); err != nil {
+		return nil
+	}
+
+	var issues []string
+	root := metadata.Locks.Nodes[metadata.Locks.Root]
+	for input, ref := range root.Inputs {
+		// Inputs that follow another input are locked through that one
+		var node string
+		if json.Unmarshal(ref, &node) != nil {
+			continue
+		}
+		locked := metadata.Locks.Nodes[node]
+		if resolved := registryMovement(locked); resolved != "" {
+			issues = append(issues, tr("LockInputMoved", "Input", input, "Ref", resolved))
+		}
+		modified := locked.Locked.LastModified
+		if modified == 0 {
+			continue
+		}
+		age := time.Since(time.Unix(modified, 0))
+		if age > maxAge {
+			issues = append(issues, trn("LockInputAge", int(age.Hours()/24), "Input", input))
+		}
+	}
+	sort.Strings(issues)
+	return issues
+}
+
+// A full git revision, as flake references pin them
+var revPattern = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// Where the registry now takes an input the flake names by registry id,
+// like nixpkgs, if that isn't where the lock has it: another repository,
+// or another revision of an entry pinned to one. "" if it hasn't moved, or
+// the input isn't from the registry.
+func registryMovement(node lockNode) string {
+	if node.Original.Type != "indirect" || node.Original.ID == "" {
+		return ""
+	}
+	ref := node.Original.ID
+	if node.Original.Ref != "" {
+		ref += "/" + node.Original.Ref
+	}
+	out, err := nixOutput("registry", "resolve", ref)
+	if err != nil {
+		debug(fmt.Sprintf("Not checking %s against the registry: %s", ref, err))
+		return ""
+	}
+	resolved := strings.TrimSpace(string(out))
+	repo, rev := flakeRefSource(resolved)
+	if rev != "" && node.Locked.Rev != "" && rev != node.Locked.Rev {
+		return resolved
+	}
+	if locked := node.Locked.Type + ":" + node.Locked.Owner + "/" + node.Locked.Repo; repo != "" && node.Locked.Owner != "" && !strings.EqualFold(repo, locked) {
+		return resolved
+	}
+	return ""
+}
+
+// The repository (github:owner/repo) and pinned revision of a flake
+// reference, each "" if the reference doesn't have one
+func flakeRefSource(ref string) (repo, rev string) {
+	if before, query, ok := strings.Cut(ref, "?"); ok {
+		ref = before
+		if values, err := url.ParseQuery(query); err == nil {
+			rev = values.Get("rev")
+		}
+	}
+	scheme, path, ok := strings.Cut(ref, ":")
+	if !ok || (scheme != "github" && scheme != "gitlab" && scheme != "sourcehut") {
+		return "", rev
+	}
+	parts := strings.Split(path, "/")
+	if len(parts) < 2 {
+		return "", rev
+	}
+	if len(parts) > 2 && revPattern.MatchString(parts[2]) {
+		rev = parts[2]
+	}
+	return scheme + ":" + parts[0] + "/" + parts[1], rev
+}
+
+// Age after which locked inputs are stale, from glot.toml
+func lockMaxAge() time.Duration {
+	maxAgeDays := currentConfig.Lock.MaxAgeDays
+	if maxAgeDays <= 0 {
+		maxAgeDays = defaultLockMaxAgeDays
+	}
+	return time.Duration(maxAgeDays) * 24 * time.Hour
+}
+
+// Warn about a stale flake.lock, or fail if strict
+func checkFlakeLock(strict bool) error {
+	strict = strict || currentConfig.Lock.Strict
+
+	issues := flakeLockIssues(lockMaxAge())
+	if len(issues) == 0 {
+		return nil
+	}
+	for _, issue := range issues {
+		if strict {
+			errorMsg(tr("LockStaleIssue", "Issue", issue))
+		} else {
+			warning(tr("LockStaleIssue", "Issue", issue))
+		}
+	}
+	if strict {
+		return fmt.Errorf("%s", tr("LockStale"))
+	}
+	info(tr("LockRefreshHint"))
+	return nil
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/spf13/cobra"
+)
+
+// Message levels, from always shown to shown only with -vv
+type logLevel int
+
+const (
+	levelError   logLevel = iota
+	levelWarning          // Shown with -q
+	levelInfo             // success and info messages; the default
+	levelCommand          // -v: every command glot runs, ready to paste
+	levelDebug            // -vv: glot's decisions, with timestamps
+)
+
+var (
+	verbosity = levelInfo
+	// ASCII-only output with [tag] prefixes instead of emoji: --plain, or
+	// NO_COLOR, TERM=dumb or CI
+	plainOutput bool
+	// --log-file: every message at every level, and the tools' output,
+	// with timestamps; nil unless given
+	logFile io.Writer
+)
+
+// Writer prefixing every line with the time it was written
+type timestampWriter struct {
+	mu        sync.Mutex
+	w         io.Writer
+	midLine   bool
+	timestamp func() string
+}
+
+func newTimestampWriter(w io.Writer) *timestampWriter {
+	return &timestampWriter{w: w, timestamp: func() string { return time.Now().Format(time.RFC3339Nano) }}
+}
+
+func (t *timestampWriter) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var out []byte
+	for _, c := range p {
+		if !t.midLine {
+			out = append(out, t.timestamp()+" "...)
+			t.midLine = true
+		}
+		out = append(out, c)
+		if c == '\n' {
+			t.midLine = false
+		}
+	}
+	if _, err := t.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Print a message of the given level where it belongs: the terminal if the
+// verbosity shows it, the run and --log-file logs, and --json and progress
+// events
+func logMessage(level logLevel, status, prefix, msg string) {
+	if plainOutput {
+		prefix = plainTags[status] + " "
+	}
+	line := plain(prefix+outputRedactor.redactString(msg)) + "\n"
+	var w io.Writer = os.Stdout
+	if level <= levelWarning {
+		w = stderrOutput()
+	}
+	w = withBuildStatus(w)
+	if jsonOut == nil && level <= verbosity {
+		if verbosity >= levelDebug {
+			fmt.Fprint(w, time.Now().Format("15:04:05.000")+" "+line)
+		} else {
+			fmt.Fprint(w, line)
+		}
+		if activeLog != nil {
+			fmt.Fprint(activeLog, line)
+		}
+	}
+	if logFile != nil {
+		fmt.Fprint(logFile, line)
+	}
+	if level <= levelWarning {
+		progressMessage(status, msg)
+	}
+	if level <= levelInfo {
+		emitMessage(status, msg)
+	}
+}
+
+func success(msg string) {
+	logMessage(levelInfo, "success", "✅ ", msg)
+}
+
+func info(msg string) {
+	logMessage(levelInfo, "info", "ℹ️  ", msg)
+}
+
+func warning(msg string) {
+	logMessage(levelWarning, "warning", "⚠️  ", msg)
+}
+
+func errorMsg(msg string) {
+	logMessage(levelError, "error", "❌ "+tr("ErrorLabel")+": ", msg)
+}
+
+func debug(msg string) {
+	logMessage(levelDebug, "debug", "🔍 ", msg)
+}
+
+// Echo a command about to run, quoted so it can be pasted into a shell
+func logCommand(cmd *exec.Cmd) {
+	line := shellQuote(cmd.Args)
+	if cmd.Dir != "" {
+		line = "(cd " + shellQuote([]string{cmd.Dir}) + " && " + line + ")"
+	}
+	logMessage(levelCommand, "command", "$ ", line)
+}
+
+// Tags replacing the message prefixes in plain output
+var plainTags = map[string]string{
+	"success": "[ok]", "info": "[info]", "warning": "[warn]", "error": "[error]", "debug": "[debug]", "command": "[cmd]",
+}
+
+// Symbols of glot's reports and their ASCII replacements
+var plainSymbols = strings.NewReplacer(
+	"✅ ", "[ok] ", "✅", "[ok]", "❌ ", "[fail] ", "❌", "[fail]", "⚠️  ", "[warn] ", "ℹ️  ", "[info] ", "⏭️  ", "[skip] ",
+	"📋 ", "", "📊 ", "", "📏 ", "", "📦 ", "", "🔍 ", "",
+	"→", "->", "≤", "<=", "≥", ">=", "±", "+-", "…", "...", "µ", "u",
+)
+
+// Text as printed: unchanged, or in plain output with symbols replaced and
+// anything else outside ASCII as '?'
+func plain(text string) string {
+	if !plainOutput {
+		return text
+	}
+	return strings.Map(func(r rune) rune {
+		if r > unicode.MaxASCII {
+			return '?'
+		}
+		return r
+	}, plainSymbols.Replace(text))
+}
+
+// Whether the environment asks for plain output: NO_COLOR
+// (https://no-color.org), a dumb terminal or a CI system
+func plainEnvironment() bool {
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return true
+	}
+	for _, variable := range []string{"CI", "GITHUB_ACTIONS", "GITLAB_CI", "BUILDKITE", "JENKINS_URL", "TF_BUILD", "TEAMCITY_VERSION"} {
+		if os.Getenv(variable) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// How a command ended, for debug messages
+func exitStatus(err error) string {
+	if err == nil {
+		return "ok"
+	}
+	return err.Error()
+}
+
+// Quote arguments for a POSIX shell, leaving plain ones as they are
+func shellQuote(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if arg != "" && strings.Trim(arg, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./=:,+@%#") == "" {
+			quoted[i] = arg
+		} else {
+			quoted[i] = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+		}
+	}
+	return strings.Join(quoted, " ")
+}
+
+// Apply -v/-vv, -q and --log-file
+func applyLogging(cmd *cobra.Command) error {
+	verbose, _ := cmd.Flags().GetCount("verbose")
+	quiet, _ := cmd.Flags().GetBool("quiet")
+	if verbose > 0 && quiet {
+		return fmt.Errorf("%s", tr("LoggingVerboseQuiet"))
+	}
+	verbosity = levelInfo + logLevel(min(verbose, 2))
+	if quiet {
+		verbosity = levelWarning
+	}
+	plainOutput = plainEnvironment()
+	if cmd.Flags().Changed("plain") {
+		plainOutput, _ = cmd.Flags().GetBool("plain")
+		if plainOutput {
+			// Most tools leave out colors with NO_COLOR
+			os.Setenv("NO_COLOR", "1")
+		}
+	}
+	if path, _ := cmd.Flags().GetString("log-file"); path != "" {
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return fmt.Errorf("%s", tr("LoggingFileFailed", "Error", err))
+		}
+		// Output is redacted before it reaches logged; the command line
+		// is redacted here
+		logFile = newTimestampWriter(f)
+		fmt.Fprintf(logFile, "glot %s: %s\n", version, shellQuote(outputRedactor.redactArgs(os.Args[1:])))
+	}
+	return nil
+}
+
+func addLoggingFlags(rootCmd *cobra.Command) {
+	rootCmd.PersistentFlags().CountP("verbose", "v", "Show the commands glot runs (-v), and its decisions with timestamps (-vv)")
+	rootCmd.PersistentFlags().BoolP("quiet", "q", false, "Show only warnings and errors")
+	rootCmd.PersistentFlags().Bool("plain", false, "ASCII-only output with [tag] prefixes (default with NO_COLOR, TERM=dumb or in CI; --plain=false disables)")
+	rootCmd.PersistentFlags().String("log-file", "", "Append all messages and tool output, with timestamps, to this file")
+}
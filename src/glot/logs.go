@@ -0,0 +1,374 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// Directory where glot keeps per-project state
+const stateDir = ".glot"
+
+var logsDir = filepath.Join(stateDir, "logs")
+
+// Default paste service for `glot logs share --upload`
+const defaultPasteURL = "https://0x0.st"
+
+// runRecord is the metadata stored next to each captured log
+type runRecord struct {
+	ID       string    `json:"id"`
+	Command  string    `json:"command"`
+	Args     []string  `json:"args,omitempty"`
+	Started  time.Time `json:"started"`
+	Duration string    `json:"duration"`
+	Success  bool      `json:"success"`
+	Error    string    `json:"error,omitempty"`
+	Commit   string    `json:"commit,omitempty"`
+	Glot     string    `json:"glot"`
+}
+
+// Log file that output of the running command is copied to, if any
+var activeLog io.Writer
+
+// Writer for subprocess output that also goes to the active log and the
+// --log-file
+func logged(w io.Writer) io.Writer {
+	writers := []io.Writer{w}
+	for _, log := range []io.Writer{activeLog, logFile} {
+		if log != nil {
+			writers = append(writers, log)
+		}
+	}
+	if len(writers) == 1 {
+		return w
+	}
+	return io.MultiWriter(writers...)
+}
+
+// Make sure the state directory exists and stays out of version control
+func ensureStateDir(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	ignore := filepath.Join(stateDir, ".gitignore")
+	if _, err := os.Stat(ignore); os.IsNotExist(err) {
+		return os.WriteFile(ignore, []byte("*\n"), 0644)
+	}
+	return nil
+}
+
+// Run fn while capturing all command output to .glot/logs, holding the
+// project lock. Logging problems never fail the command itself.
+func recordRun(command string, args []string, fn func() error) error {
+	if os.Getenv(sandboxedEnv) != "" {
+		// The glot outside the sandbox holds the lock and records the run,
+		// this one's output included
+		return fn()
+	}
+	lock, shared, err := acquireProjectLock(command)
+	if err != nil {
+		errorMsg(err.Error())
+		return err
+	}
+	if shared != nil {
+		return sharedResult(shared)
+	}
+	// Released after the record is written, for the commands sharing it
+	defer lock.release()
+
+	started := time.Now()
+	id := started.Format("20060102-150405") + "-" + command
+	lock.hold(command, id)
+
+	if err := ensureStateDir(logsDir); err != nil {
+		warning(tr("LogsDirFailed", "Dir", logsDir, "Error", err))
+		return fn()
+	}
+	runLog, err := os.Create(filepath.Join(logsDir, id+".log"))
+	if err != nil {
+		warning(tr("LogsFileFailed", "Error", err))
+		return fn()
+	}
+	activeLog = runLog
+	runErr := fn()
+	activeLog = nil
+	runLog.Close()
+
+	record := runRecord{
+		ID:       id,
+		Command:  command,
+		Args:     outputRedactor.redactArgs(args),
+		Started:  started,
+		Duration: time.Since(started).Round(time.Millisecond).String(),
+		Success:  runErr == nil,
+		Commit:   gitCommit(),
+		Glot:     version,
+	}
+	if runErr != nil {
+		record.Error = outputRedactor.redactString(runErr.Error())
+	}
+	content, _ := json.MarshalIndent(record, "", "  ")
+	if err := os.WriteFile(filepath.Join(logsDir, id+".json"), append(content, '\n'), 0644); err != nil {
+		warning(tr("LogsMetadataFailed", "Error", err))
+	}
+	return runErr
+}
+
+// Current git commit of the project, if it is a git repository
+func gitCommit() string {
+	out, err := exec.Command("git", "rev-parse", "--short", "HEAD").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// Load all run records, newest first
+func listRunRecords() ([]runRecord, error) {
+	paths, err := filepath.Glob(filepath.Join(logsDir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var records []runRecord
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var record runRecord
+		if json.Unmarshal(content, &record) == nil {
+			records = append(records, record)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Started.After(records[j].Started)
+	})
+	return records, nil
+}
+
+// Find a run by ID, accepting a unique prefix or "last"
+func findRunRecord(id string) (*runRecord, error) {
+	records, err := listRunRecords()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%s", tr("LogsNone"))
+	}
+	if id == "last" {
+		return &records[0], nil
+	}
+	var matches []runRecord
+	for _, record := range records {
+		if record.ID == id {
+			return &record, nil
+		}
+		if strings.HasPrefix(record.ID, id) {
+			matches = append(matches, record)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("%s", tr("LogsUnknownID", "ID", id))
+	case 1:
+		return &matches[0], nil
+	default:
+		return nil, fmt.Errorf("%s", trn("LogsAmbiguousID", len(matches), "ID", id))
+	}
+}
+
+// Prepare a log for sharing: redact secrets and personal paths
+func redactLog(content []byte) []byte {
+	content = outputRedactor.redact(content)
+	if home, err := os.UserHomeDir(); err == nil && home != "" && home != "/" {
+		content = bytes.ReplaceAll(content, []byte(home), []byte("~"))
+	}
+	return content
+}
+
+// bundleFile is one entry of a .tar.gz bundle
+type bundleFile struct {
+	name    string
+	content []byte
+}
+
+// Write files into a .tar.gz bundle under a top-level directory
+func writeBundle(path, dir string, modTime time.Time, files []bundleFile) error {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, file := range files {
+		header := &tar.Header{
+			Name:    dir + "/" + file.name,
+			Mode:    0644,
+			Size:    int64(len(file.content)),
+			ModTime: modTime,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := tw.Write(file.content); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// Write a redacted .tar.gz bundle with the log and its metadata
+func writeLogBundle(record *runRecord, log []byte, path string) error {
+	metadata, _ := json.MarshalIndent(record, "", "  ")
+	return writeBundle(path, record.ID, record.Started, []bundleFile{
+		{record.ID + ".json", redactLog(append(metadata, '\n'))},
+		{record.ID + ".log", log},
+	})
+}
+
+// Upload a log to a 0x0.st-compatible paste service and return its URL
+func uploadLog(pasteURL, name string, content []byte) (string, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("file", name)
+	if err != nil {
+		return "", err
+	}
+	part.Write(content)
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest("POST", pasteURL, &body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("User-Agent", "glot/"+version)
+
+	resp, err := (&http.Client{Timeout: 30 * time.Second}).Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	reply, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s", tr("LogsPasteFailed", "URL", pasteURL, "Status", resp.Status))
+	}
+	return strings.TrimSpace(string(reply)), nil
+}
+
+func newLogsCmd() *cobra.Command {
+	logsCmd := &cobra.Command{
+		Use:   "logs",
+		Short: "List recorded build and test runs",
+		Long:  "List build, test and check runs recorded under " + logsDir + ".",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			records, err := listRunRecords()
+			if err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			if len(records) == 0 {
+				info(tr("LogsNoneYet"))
+				return nil
+			}
+			for _, record := range records {
+				status := "✅"
+				if !record.Success {
+					status = "❌"
+				}
+				fmt.Printf("%s %-28s %-8s %8s  %s\n", plain(status), record.ID, record.Command,
+					record.Duration, record.Started.Format("2006-01-02 15:04:05"))
+			}
+			return nil
+		},
+	}
+
+	showCmd := &cobra.Command{
+		Use:   "show <id>",
+		Short: "Print a recorded log",
+		Long:  "Print a recorded log. The id may be a unique prefix or 'last'.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			record, err := findRunRecord(args[0])
+			if err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			content, err := os.ReadFile(filepath.Join(logsDir, record.ID+".log"))
+			if err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			os.Stdout.Write(content)
+			return nil
+		},
+	}
+
+	shareCmd := &cobra.Command{
+		Use:   "share <id>",
+		Short: "Share a recorded log",
+		Long: "Write a redacted bundle of a recorded log and its metadata, " +
+			"or upload the redacted log to a paste service with --upload.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			record, err := findRunRecord(args[0])
+			if err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			content, err := os.ReadFile(filepath.Join(logsDir, record.ID+".log"))
+			if err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			log := redactLog(content)
+
+			if upload, _ := cmd.Flags().GetBool("upload"); upload {
+				pasteURL, _ := cmd.Flags().GetString("paste-url")
+				info(tr("LogsUploading", "URL", pasteURL))
+				url, err := uploadLog(pasteURL, record.ID+".log", log)
+				if err != nil {
+					errorMsg(tr("LogsUploadFailed", "Error", err))
+					return err
+				}
+				success(tr("LogsShared", "URL", url))
+				return nil
+			}
+
+			output, _ := cmd.Flags().GetString("output")
+			if output == "" {
+				output = record.ID + ".tar.gz"
+			}
+			if err := writeLogBundle(record, log, output); err != nil {
+				errorMsg(tr("WriteFailed", "File", output, "Error", err))
+				return err
+			}
+			success(tr("LogsBundleWritten", "File", output))
+			return nil
+		},
+	}
+	shareCmd.Flags().StringP("output", "o", "", "Bundle path (default: <id>.tar.gz)")
+	shareCmd.Flags().Bool("upload", false, "Upload the redacted log to a paste service")
+	shareCmd.Flags().String("paste-url", defaultPasteURL, "Paste service accepting multipart 'file' uploads")
+
+	logsCmd.AddCommand(showCmd, shareCmd)
+	return logsCmd
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/spf13/cobra"
+)
+
+// The directory of the flake.nix enclosing dir, where editors may start a
+// language server in any directory of the project
+func flakeRoot(start string) (string, error) {
+	for dir := start; ; {
+		if _, err := os.Stat(filepath.Join(dir, "flake.nix")); err == nil {
+			return dir, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("%s", tr("LspNoFlake", "Dir", start))
+		}
+		dir = parent
+	}
+}
+
+func newLspCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "lsp [language] [args...]",
+		Short: "Run the dev shell's language server, for editors",
+		Long: "Run the language server of a language (default: the project's) from the dev shell, with the shell's environment, " +
+			"speaking LSP on stdin and stdout: gopls, rust-analyzer, zls, pyright, clangd, typescript-language-server and others. " +
+			"Configure an editor once with glot lsp go as the server command, and it always gets the server and toolchain the " +
+			"flake pins, whatever its own PATH. Arguments after the language (or --) go to the server. glot is replaced by the server.",
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) > 0 {
+				return nil, cobra.ShellCompDirectiveDefault
+			}
+			return languageNames(), cobra.ShellCompDirectiveNoFileComp
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// stdout carries the protocol: glot's own messages go to stderr
+			os.Stdout = os.Stderr
+			cwd, err := os.Getwd()
+			if err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			root, err := flakeRoot(cwd)
+			if err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			if root != cwd {
+				debug(fmt.Sprintf("Project root: %s", root))
+				if err := os.Chdir(root); err != nil {
+					errorMsg(err.Error())
+					return err
+				}
+				if currentConfig, err = loadProjectConfig(configFile); err != nil {
+					errorMsg(err.Error())
+					return err
+				}
+			}
+			if err := checkNix(); err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+
+			var language Language
+			if len(args) > 0 {
+				for _, candidate := range languages {
+					if candidate.Name() == args[0] {
+						language = candidate
+					}
+				}
+				if language == nil {
+					err := fmt.Errorf("%s", tr("LspUnknownLanguage", "Language", args[0], "Supported", strings.Join(languageNames(), ", ")))
+					errorMsg(err.Error())
+					return err
+				}
+				args = args[1:]
+			} else if language, err = detectLanguage(); err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			server, pkg := language.LanguageServer()
+			if len(server) == 0 {
+				err := fmt.Errorf("%s", tr("LspNoServer", "Language", language.Name()))
+				errorMsg(err.Error())
+				return err
+			}
+
+			env, tools, err := devShellTools()
+			if err != nil {
+				errorMsg(tr("LspShellFailed", "Error", err))
+				return err
+			}
+			path, ok := tools[server[0]]
+			if !ok {
+				err := fmt.Errorf("%s", tr("LspNotInShell", "Server", server[0], "Language", language.Name(), "Package", pkg, "Config", configFile))
+				errorMsg(err.Error())
+				return err
+			}
+			debug(fmt.Sprintf("%s is %s, from %s", server[0], path, toolPackage(path)))
+			if len(args) > 0 && args[0] == "--" {
+				args = args[1:]
+			}
+			command := append(append([]string{path}, server[1:]...), args...)
+			argv := append([]string{env.Bash}, env.protocolCommandArgs(command)...)
+			logCommand(&exec.Cmd{Path: env.Bash, Args: argv})
+			err = syscall.Exec(env.Bash, argv, os.Environ())
+			errorMsg(tr("LspExecFailed", "Server", server[0], "Error", err))
+			return err
+		},
+	}
+	// Everything after the language belongs to the server
+	cmd.Flags().SetInterspersed(false)
+	return cmd
+}
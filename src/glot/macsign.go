@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// macOSSigning is what `glot release --sign-macos` signs and notarizes with
+type macOSSigning struct {
+	// Developer ID Application identity, e.g. "Developer ID Application: Acme (TEAMID)"
+	identity string
+	// Keychain holding the identity; codesign's search list if empty
+	keychain string
+	// Credentials of xcrun notarytool: a stored keychain profile, or an
+	// Apple ID with an app-specific password
+	notaryArgs []string
+}
+
+// Read the signing and notarization credentials from the environment:
+//
+//	MACOS_SIGN_IDENTITY       codesign identity (required)
+//	MACOS_SIGN_KEYCHAIN       keychain holding it (optional)
+//	MACOS_NOTARY_PROFILE      profile stored with `xcrun notarytool store-credentials`, or
+//	APPLE_ID, APPLE_TEAM_ID and APPLE_APP_PASSWORD
+func macOSSigningFromEnv() (*macOSSigning, error) {
+	if runtime.GOOS != "darwin" {
+		return nil, fmt.Errorf("%s", tr("MacSignNotMacOS"))
+	}
+	for _, tool := range []string{"codesign", "xcrun", "hdiutil"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			return nil, fmt.Errorf("%s", tr("MacSignNoTool", "Tool", tool))
+		}
+	}
+	s := &macOSSigning{identity: os.Getenv("MACOS_SIGN_IDENTITY"), keychain: os.Getenv("MACOS_SIGN_KEYCHAIN")}
+	if s.identity == "" {
+		return nil, fmt.Errorf("%s", tr("MacSignNoIdentity"))
+	}
+	switch {
+	case os.Getenv("MACOS_NOTARY_PROFILE") != "":
+		s.notaryArgs = []string{"--keychain-profile", os.Getenv("MACOS_NOTARY_PROFILE")}
+	case os.Getenv("APPLE_ID") != "" && os.Getenv("APPLE_TEAM_ID") != "" && os.Getenv("APPLE_APP_PASSWORD") != "":
+		s.notaryArgs = []string{"--apple-id", os.Getenv("APPLE_ID"), "--team-id", os.Getenv("APPLE_TEAM_ID"), "--password", os.Getenv("APPLE_APP_PASSWORD")}
+	default:
+		return nil, fmt.Errorf("%s", tr("MacSignNoNotary"))
+	}
+	if s.keychain != "" {
+		s.notaryArgs = append(s.notaryArgs, "--keychain", s.keychain)
+	}
+	return s, nil
+}
+
+// Sign a binary or disk image with the hardened runtime and a secure
+// timestamp, as notarization requires
+func (s *macOSSigning) codesign(path string) error {
+	args := []string{"--force", "--sign", s.identity, "--options", "runtime", "--timestamp"}
+	if s.keychain != "" {
+		args = append(args, "--keychain", s.keychain)
+	}
+	if err := runTool("codesign", append(args, path)...); err != nil {
+		return fmt.Errorf("%s", tr("MacSignFailed", "Path", path, "Error", err))
+	}
+	return nil
+}
+
+// Submit a file to Apple's notary service and wait for the verdict
+func (s *macOSSigning) notarize(path string) error {
+	args := append([]string{"notarytool", "submit", path, "--wait", "--output-format", "json"}, s.notaryArgs...)
+	submit := exec.Command("xcrun", args...)
+	var stderr bytes.Buffer
+	submit.Stderr = &stderr
+	logCommand(submit)
+	step := startStep("notarize " + filepath.Base(path))
+	out, err := submit.Output()
+	step.finish(err)
+	var result struct {
+		ID      string `json:"id"`
+		Status  string `json:"status"`
+		Message string `json:"message"`
+	}
+	json.Unmarshal(out, &result)
+	if result.Status == "Accepted" {
+		return nil
+	}
+	if result.ID != "" {
+		return fmt.Errorf("%s", tr("MacSignNotaryStatus", "Path", path, "Status", result.Status, "ID", result.ID))
+	}
+	return fmt.Errorf("%s", tr("MacSignSubmitFailed", "Path", path,
+		"Error", outputRedactor.redactString(strings.TrimSpace(stderr.String()+" "+result.Message))))
+}
+
+// Sign the staged binaries, then put them on a signed disk image that is
+// notarized and stapled, so Gatekeeper accepts it offline. The binaries'
+// notarization covers copies from the tarball too, checked online.
+func (s *macOSSigning) signAndNotarize(r *release) error {
+	binaries, err := r.binaries()
+	if err != nil {
+		return err
+	}
+	for _, binary := range binaries {
+		info(tr("MacSignSigning", "File", filepath.Base(binary)))
+		if err := s.codesign(binary); err != nil {
+			return err
+		}
+		if err := runTool("codesign", "--verify", "--strict", "--verbose=2", binary); err != nil {
+			return fmt.Errorf("%s", tr("MacSignNotVerified", "File", binary, "Error", err))
+		}
+	}
+
+	image := filepath.Join(releaseDir, r.Name+".dmg")
+	info(tr("MacSignCreating", "Image", image))
+	if err := runTool("hdiutil", "create", "-volname", r.Name, "-srcfolder", r.Staging, "-ov", "-format", "UDZO", image); err != nil {
+		return fmt.Errorf("%s", tr("MacSignImageFailed", "Error", err))
+	}
+	if err := s.codesign(image); err != nil {
+		return err
+	}
+	info(tr("MacSignNotarizing"))
+	if err := s.notarize(image); err != nil {
+		return err
+	}
+	if err := runTool("xcrun", "stapler", "staple", image); err != nil {
+		return fmt.Errorf("%s", tr("MacSignStapleFailed", "Image", image, "Error", err))
+	}
+	success(tr("MacSignDone", "Image", image))
+	r.Artifacts = append(r.Artifacts, image)
+	return nil
+}
@@ -1,11 +1,16 @@
 package main
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
 	"golang.org/x/text/cases"
@@ -14,27 +19,10 @@ import (
 
 const version = "1.2.0"
 
-// Output helpers
-func success(msg string) {
-	fmt.Printf("✅ %s\n", msg)
-}
-
-func info(msg string) {
-	fmt.Printf("ℹ️  %s\n", msg)
-}
-
-func warning(msg string) {
-	fmt.Fprintf(os.Stderr, "⚠️  %s\n", msg)
-}
-
-func errorMsg(msg string) {
-	fmt.Fprintf(os.Stderr, "❌ Error: %s\n", msg)
-}
-
 // Check if nix is installed
 func checkNixInstalled() error {
 	if _, err := exec.LookPath("nix"); err != nil {
-		return fmt.Errorf("Nix is not installed or not in PATH. Please install Nix first")
+		return fmt.Errorf("%s", tr("NixNotInstalled"))
 	}
 	return nil
 }
@@ -45,78 +33,199 @@ func checkNix() error {
 		return err
 	}
 	if _, err := os.Stat("flake.nix"); os.IsNotExist(err) {
-		return fmt.Errorf("No flake.nix found in current directory. Are you in a nix polyglot project?")
+		return fmt.Errorf("%s", tr("NoFlake"))
 	}
 	return nil
 }
 
+// Options prepended to every nix invocation
+var nixGlobalArgs []string
+
+// Prefix nix arguments with the global options, and add the user's after
+// the subcommand
+func withNixGlobalArgs(args []string) []string {
+	words := 0
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		words = 1
+		if slices.Contains(nixMultiCommands, args[0]) && len(args) > 1 && !strings.HasPrefix(args[1], "-") {
+			words = 2
+		}
+	}
+	result := append(append([]string{}, nixGlobalArgs...), args[:words]...)
+	result = append(result, nixUserArgsFor(strings.Join(args[:words], " "))...)
+	return append(result, args[words:]...)
+}
+
+// Use an alternative nix store (chroot path, ssh-ng://, daemon) if requested
+func applyStoreOption(cmd *cobra.Command) {
+	store, _ := cmd.Flags().GetString("store")
+	if store == "" {
+		store = currentConfig.Store
+	}
+	if store != "" {
+		nixGlobalArgs = append(nixGlobalArgs, "--store", store)
+	}
+}
+
 // Execute nix command
 func runNix(args ...string) error {
-	cmd := exec.Command("nix", args...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	return runNixIn("", args...)
+}
+
+// Execute nix command in another directory
+func runNixIn(dir string, args ...string) error {
+	stdout := newRedactingWriter(logged(withBuildStatus(os.Stdout)))
+	stderr := newRedactingWriter(logged(withBuildStatus(stderrOutput())))
+	defer stdout.Flush()
+	defer stderr.Flush()
+
+	cmd := exec.Command("nix", withNixGlobalArgs(args)...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
 	cmd.Stdin = os.Stdin
-	return cmd.Run()
+	cmd.Dir = dir
+	logCommand(cmd)
+	step := startStep("nix " + strings.Join(args, " "))
+	started := time.Now()
+	err := cmd.Run()
+	step.finish(err)
+	debug(fmt.Sprintf("nix %s finished after %s: %s", args[0], time.Since(started).Round(time.Millisecond), exitStatus(err)))
+	return err
+}
+
+// Run nix and capture stdout, keeping stderr out of the way unless it fails
+func nixOutput(args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("nix", withNixGlobalArgs(args)...)
+	cmd.Stderr = &stderr
+	logCommand(cmd)
+	step := startStep("nix " + strings.Join(args, " "))
+	out, err := cmd.Output()
+	step.finish(err)
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, err
+	}
+	return out, nil
 }
 
 // Execute command in nix develop shell
 func runInDevShell(command ...string) error {
-	args := append([]string{"develop", "--command"}, command...)
-	return runNix(args...)
+	env, err := cachedShellEnv("", false)
+	if err != nil {
+		debug(fmt.Sprintf("Not using a cached dev environment: %s", err))
+		return runNix(append([]string{"develop", "--command"}, command...)...)
+	}
+	return runTool(env.Bash, env.commandArgs(command)...)
+}
+
+// Lint with the flake's lint app when it provides one, otherwise the
+// detected language's linter
+func runLint() error {
+	if flakeHasApp("lint") {
+		return runNix("run", ".#lint")
+	}
+	return runLanguageTask("lint", Language.Lint)
+}
+
+// What runLint runs at the project root, empty if nothing
+func lintRootName() string {
+	if flakeHasApp("lint") {
+		return "lint app"
+	}
+	if language, err := detectLanguage(); err == nil {
+		return language.Name()
+	}
+	return ""
+}
+
+// Test with the flake's test app when it provides one, otherwise the
+// detected language's test runner. The app gets the arguments, and the
+// filter and verbosity in GLOT_TEST_FILTER and GLOT_TEST_VERBOSE.
+func runTests(options testOptions) error {
+	if options.Coverage {
+		// Apps can't report coverage, so coverage always uses the language
+		if err := runLanguageTask("test", func(language Language) error { return language.Test(options) }); err != nil {
+			return err
+		}
+		return reportCoverage()
+	}
+	if flakeHasApp("test") {
+		os.Setenv("GLOT_TEST_FILTER", options.Filter)
+		if options.Verbose {
+			os.Setenv("GLOT_TEST_VERBOSE", "1")
+		}
+		return runNix(append([]string{"run", ".#test", "--"}, options.Args...)...)
+	}
+	return runLanguageTask("test", func(language Language) error { return language.Test(options) })
 }
 
 // Build command
-func buildCommand(release bool, _ string) error {
+func buildCommand(variant string, strictLock bool, target, cross string, overrides []string) error {
 	if err := checkNix(); err != nil {
 		errorMsg(err.Error())
 		return err
 	}
+	if err := checkFlakeLock(strictLock); err != nil {
+		errorMsg(err.Error())
+		return err
+	}
 
-	variant := "debug"
-	if release {
-		variant = "release"
+	buildTarget, name, err := currentConfig.installable(target, variant)
+	if err == nil && cross != "" {
+		buildTarget, name, err = crossInstallable(buildTarget, cross)
+	} else if err == nil && target != "" {
+		err = checkTarget(name, false)
+	}
+	if err != nil {
+		errorMsg(err.Error())
+		return err
 	}
 
-	info(fmt.Sprintf("Building (%s variant)...", variant))
-	
-	var buildTarget string
-	if variant == "release" {
-		buildTarget = ".#release"
-	} else {
-		buildTarget = ".#dev"
+	info(tr("BuildStarted", "Target", buildTarget))
+	last, estimate, known := buildTimes(buildTarget)
+	if known {
+		info(tr("BuildLastTook", "Duration", last.Round(time.Second)))
 	}
 
 	caser := cases.Title(language.English)
-	if err := runNix("build", buildTarget); err != nil {
-		errorMsg(fmt.Sprintf("%s build failed", caser.String(variant)))
+	installable := overriddenInstallable(buildTarget, overrides)
+	recordLastBuild(buildTarget, installable)
+	status := startBuildStatus(estimate)
+	started := time.Now()
+	summary, err := runNixBuild(status, append([]string{"build"}, installable...)...)
+	status.stop()
+	if err != nil {
+		errorMsg(tr("BuildFailed", "Name", caser.String(name)))
 		return err
 	}
+	duration := time.Since(started)
+	recordBuildTime(buildTarget, duration, summary)
 
-	success(fmt.Sprintf("%s build completed", caser.String(variant)))
+	success(tr("BuildCompleted", "Name", caser.String(name), "Duration", duration.Round(time.Second)))
 	return nil
 }
 
 // Run command
-func runCommand(release bool, _ string, runArgs []string) error {
+func runCommand(variant, target string, runArgs []string) error {
 	if err := checkNix(); err != nil {
 		errorMsg(err.Error())
 		return err
 	}
 
-	variant := "debug"
-	if release {
-		variant = "release"
+	runTarget, name, err := currentConfig.installable(target, variant)
+	if err == nil && target != "" {
+		err = checkTarget(name, true)
 	}
-
-	info(fmt.Sprintf("Running (%s variant)...", variant))
-	
-	var runTarget string
-	if variant == "release" {
-		runTarget = ".#release"
-	} else {
-		runTarget = ".#dev"
+	if err != nil {
+		errorMsg(err.Error())
+		return err
 	}
 
+	info(tr("RunStarted", "Target", runTarget))
+
 	nixArgs := append([]string{"run", runTarget}, runArgs...)
 	return runNix(nixArgs...)
 }
@@ -124,142 +233,505 @@ func runCommand(release bool, _ string, runArgs []string) error {
 
 
 func main() {
+	defer recoverCrash()
 	var rootCmd = &cobra.Command{
 		Use:     "glot",
 		Short:   "Nix Polyglot Project Interface",
 		Long:    "A tool for managing Nix-based polyglot development projects",
 		Version: version,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := applyLogging(cmd); err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			if err := applyProgressFormat(cmd); err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			if err := applyJSONOutput(cmd); err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			if err := enforceCommandPolicy(cmd); err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			if err := applyNixArgs(cmd); err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			applyImpureMode(cmd)
+			applyStoreOption(cmd)
+			applyBuilders()
+			applyOfflineMode(cmd)
+			applyNixFeatures()
+			applyCacheOption()
+			applyResourceLimits(cmd)
+			applyPrivateDeps(cmd)
+			applyConcurrencyOption(cmd)
+			return nil
+		},
 	}
+	rootCmd.PersistentFlags().String("store", "", "Nix store to use for all nix invocations (e.g. /tmp/store, ssh-ng://host, daemon)")
+	rootCmd.PersistentFlags().String("progress-format", "text", "Progress reporting: text, or json-lines for start/finish events on stderr")
+	addLoggingFlags(rootCmd)
+	rootCmd.PersistentFlags().Bool("json", false, "Print messages and step results as JSON events on stdout, other output on stderr")
+	addResourceFlags(rootCmd)
+	addNixArgFlags(rootCmd)
+	rootCmd.PersistentFlags().Bool("offline", false, "Work without the network: nix uses only the store, language tools don't fetch, and glot update refuses to run")
 
 	var buildCmd = &cobra.Command{
 		Use:   "build [target]",
 		Short: "Build project",
-		Long:  "Build the project or specific target.",
+		Long:  "Build the project or specific target: one of the flake's packages, or an attribute path like checks.x86_64-linux.lint.",
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) > 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			targets, _ := flakeTargets(false)
+			return targets, cobra.ShellCompDirectiveNoFileComp
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			release, _ := cmd.Flags().GetBool("release")
+			variant := selectedVariant(cmd)
+			strictLock, _ := cmd.Flags().GetBool("strict-lock")
+			overrides, err := selectedBuildOverrides(cmd)
+			if err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			system, _ := cmd.Flags().GetString("system")
+			cross, _ := cmd.Flags().GetString("target")
+			if system != "" && cross != "" {
+				err := fmt.Errorf("%s", tr("CrossSystemExclusive"))
+				errorMsg(err.Error())
+				return err
+			}
+			static, _ := cmd.Flags().GetBool("static")
+			all, _ := cmd.Flags().GetBool("all")
+			if static && (len(args) > 0 || variant != "" || all) {
+				err := fmt.Errorf("%s", tr("StaticExclusive"))
+				errorMsg(err.Error())
+				return err
+			}
+			wasm, _ := cmd.Flags().GetBool("wasm")
+			if wasm && (len(args) > 0 || variant != "" || all || static || system != "" || cross != "") {
+				err := fmt.Errorf("%s", tr("WasmExclusive"))
+				errorMsg(err.Error())
+				return err
+			}
+			out, _ := cmd.Flags().GetString("out")
+			if all && out != "" {
+				err := fmt.Errorf("%s", tr("OutAllExclusive"))
+				errorMsg(err.Error())
+				return err
+			}
+			distribute, _ := cmd.Flags().GetBool("distribute")
+			asOf, _ := cmd.Flags().GetString("as-of")
+			if asOf != "" && (all || distribute || static || wasm || out != "") {
+				err := fmt.Errorf("%s", tr("AsOfExclusive"))
+				errorMsg(err.Error())
+				return err
+			}
+			if distribute {
+				if all || system != "" || cross != "" || static || wasm || out != "" {
+					err := fmt.Errorf("%s", tr("DistributeExclusive"))
+					errorMsg(err.Error())
+					return err
+				}
+				target := ""
+				if len(args) > 0 {
+					target = args[0]
+				}
+				return recordRun("build", args, func() error {
+					if err := checkNix(); err != nil {
+						errorMsg(err.Error())
+						return err
+					}
+					if err := checkFlakeLock(strictLock); err != nil {
+						errorMsg(err.Error())
+						return err
+					}
+					if err := buildDistributed(target, variant, overrides); err != nil {
+						errorMsg(err.Error())
+						return err
+					}
+					return nil
+				})
+			}
+			if all {
+				if len(args) > 0 || variant != "" || cross != "" {
+					err := fmt.Errorf("%s", tr("BuildAllExclusive"))
+					errorMsg(err.Error())
+					return err
+				}
+				return recordRun("build", args, func() error {
+					if err := checkNix(); err != nil {
+						errorMsg(err.Error())
+						return err
+					}
+					if err := checkFlakeLock(strictLock); err != nil {
+						errorMsg(err.Error())
+						return err
+					}
+					if err := useBuildSystem(system); err != nil {
+						errorMsg(err.Error())
+						return err
+					}
+					if err := buildAll(buildJobs, overrides); err != nil {
+						errorMsg(err.Error())
+						return err
+					}
+					return nil
+				})
+			}
 			target := ""
 			if len(args) > 0 {
 				target = args[0]
 			}
-			return buildCommand(release, target)
+			build := func() error {
+				if err := useBuildSystem(system); err != nil {
+					errorMsg(err.Error())
+					return err
+				}
+				if wasm {
+					if checkTarget(wasmOutput, false) != nil {
+						err := fmt.Errorf("%s", tr("WasmNoOutput"))
+						errorMsg(err.Error())
+						return err
+					}
+					if err := buildCommand("", strictLock, wasmOutput, "", overrides); err != nil {
+						return err
+					}
+					if err := reportWasmModules("result"); err != nil {
+						errorMsg(err.Error())
+						return err
+					}
+					return nil
+				}
+				if asOf != "" {
+					return buildAsOf(asOf, variant, strictLock, target, cross, overrides)
+				}
+				if !static {
+					return buildCommand(variant, strictLock, target, cross, overrides)
+				}
+				if cross == "" && checkTarget("static", false) != nil {
+					err := fmt.Errorf("%s", tr("StaticNoOutput"))
+					errorMsg(err.Error())
+					return err
+				}
+				if err := buildCommand("", strictLock, "static", cross, overrides); err != nil {
+					return err
+				}
+				if err := verifyStaticBinaries("result"); err != nil {
+					errorMsg(err.Error())
+					return err
+				}
+				return nil
+			}
+			return recordRun("build", args, func() error {
+				if err := build(); err != nil {
+					return err
+				}
+				if out == "" {
+					return nil
+				}
+				if err := exportArtifacts("result", out); err != nil {
+					errorMsg(err.Error())
+					return err
+				}
+				return nil
+			})
 		},
 	}
-	buildCmd.Flags().Bool("release", false, "Build release variant (default: debug)")
+	addVariantFlags(buildCmd, "Build")
+	buildCmd.Flags().Bool("strict-lock", false, "Fail instead of warning when flake.lock is stale")
+	buildCmd.Flags().Bool("all", false, "Build every package of the flake concurrently, --jobs at a time")
+	buildCmd.Flags().Bool("distribute", false, "Build the target for every system of the flake at once, each on the remote builders for it")
+	buildCmd.Flags().String("as-of", "", "Build with flake.lock and the language lock files of a past git revision or date, linked as "+asOfResult)
+	buildCmd.Flags().StringSlice("features", nil, "Cargo features to build with, comma separated (Rust projects)")
+	buildCmd.Flags().Bool("no-default-features", false, "Build without the crate's default Cargo features (Rust projects)")
+	buildCmd.Flags().String("system", "", "Build the flake's packages of another system, e.g. aarch64-linux, by emulation or on a remote builder")
+	buildCmd.Flags().String("target", "", "Cross-compile for a system with the flake's <output>-<system> package, e.g. aarch64-linux")
+	buildCmd.Flags().Bool("impure", false, "Evaluate the flake with --impure, for builds that depend on the environment (default: impure in glot.toml)")
+	buildCmd.Flags().Bool("static", false, "Build fully static binaries with the flake's static package, and verify them with file or ldd")
+	buildCmd.Flags().Bool("wasm", false, "Build WebAssembly (wasm32-wasi) modules with the flake's wasm package")
+	buildCmd.Flags().String("out", "", "Copy the built binaries out of the nix store into this directory, as regular files")
+	buildCmd.Flags().StringSlice("tags", nil, "Go build tags, comma separated (Go projects)")
+	buildCmd.Flags().String("ldflags", "", "Go linker flags, added to the package's, e.g. \"-X main.version=1.2.0\" (Go projects)")
 
 	var runCmd = &cobra.Command{
 		Use:   "run [target] [-- args...]",
 		Short: "Run project",
-		Long:  "Run the project or specific target.",
+		Long:  "Run the project or specific target - one of the flake's apps or packages - with the project's secrets in its environment.",
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) > 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			targets, _ := flakeTargets(true)
+			return targets, cobra.ShellCompDirectiveNoFileComp
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			release, _ := cmd.Flags().GetBool("release")
+			if err := applySecrets(cmd); err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			variant := selectedVariant(cmd)
 			target := ""
 			runArgs := []string{}
 			
-			// Find -- separator
-			for i, arg := range args {
-				if arg == "--" {
-					runArgs = args[i+1:]
-					args = args[:i]
-					break
-				}
+			// Find -- separator, which cobra removes from args
+			if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+				runArgs = args[dash:]
+				args = args[:dash]
 			}
 			
 			if len(args) > 0 {
 				target = args[0]
 			}
-			
-			return runCommand(release, target, runArgs)
+
+			local, _ := cmd.Flags().GetBool("local")
+			eventFile, _ := cmd.Flags().GetString("event")
+			wasm, _ := cmd.Flags().GetBool("wasm")
+			if wasm {
+				if local || variant != "" {
+					err := fmt.Errorf("%s", tr("RunWasmExclusive"))
+					errorMsg(err.Error())
+					return err
+				}
+				if err := checkNix(); err != nil {
+					errorMsg(err.Error())
+					return err
+				}
+				module, err := buildWasmModule(target)
+				if err == nil {
+					info(tr("RunWasmStarted", "Module", filepath.Base(module)))
+					err = runWasmModule(module, runArgs)
+				}
+				if err != nil {
+					errorMsg(err.Error())
+					return err
+				}
+				return nil
+			}
+			if !local && eventFile != "" {
+				err := fmt.Errorf("%s", tr("RunEventNeedsLocal"))
+				errorMsg(err.Error())
+				return err
+			}
+			if local {
+				if target != "" || len(runArgs) > 0 || variant != "" {
+					err := fmt.Errorf("%s", tr("RunLocalExclusive"))
+					errorMsg(err.Error())
+					return err
+				}
+				if err := checkNix(); err != nil {
+					errorMsg(err.Error())
+					return err
+				}
+				event, err := lambdaEvent(eventFile)
+				if err == nil {
+					info(tr("RunLocalStarted"))
+					err = invokeLambdaLocally(event)
+				}
+				if err != nil {
+					errorMsg(err.Error())
+					return err
+				}
+				return nil
+			}
+
+			return runCommand(variant, target, runArgs)
 		},
 	}
-	runCmd.Flags().Bool("release", false, "Run release variant (default: debug)")
+	addVariantFlags(runCmd, "Run")
+	addSecretsFlag(runCmd)
+	runCmd.Flags().Bool("local", false, "Invoke the flake's lambda package once in Lambda's runtime interface emulator, printing the response")
+	runCmd.Flags().String("event", "", "JSON event for --local, from a file or - for stdin (default: {})")
+	runCmd.Flags().Bool("wasm", false, "Run a module of the flake's wasm package under wasmtime, the target naming the module if there are several")
 
 	var fmtCmd = &cobra.Command{
 		Use:     "fmt",
 		Aliases: []string{"format"},
 		Short:   "Format code",
-		Long:    "Format code using nix fmt.",
+		Long: "Format code using nix fmt and the format pipeline in glot.toml. In workspaces with language roots below the project root " +
+			"(go.mod, Cargo.toml, package.json, ...), also run each language's formatter in its root. " +
+			"With --treefmt, format the whole tree with treefmt instead, creating treefmt.toml if missing.",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if err := checkNix(); err != nil {
 				errorMsg(err.Error())
 				return err
 			}
-			info("Formatting code...")
-			if err := runNix("fmt"); err != nil {
-				errorMsg("Code formatting failed")
+			if useTreefmt, _ := cmd.Flags().GetBool("treefmt"); useTreefmt {
+				currentConfig.Format.Treefmt = true
+			}
+			info(tr("FormatStarted"))
+			format := func() error { return runWorkspaceTask("format", formatProject, formatRootName, Language.Format) }
+			// treefmt formats every language root itself
+			if currentConfig.Format.Treefmt {
+				format = formatProject
+			}
+			if err := format(); err != nil {
+				errorMsg(tr("FormatFailed"))
 				return err
 			}
-			success("Code formatting completed")
+			success(tr("FormatCompleted"))
 			return nil
 		},
 	}
+	fmtCmd.Flags().Bool("treefmt", false, "Format with treefmt and treefmt.toml instead of nix fmt (default: format.treefmt in glot.toml)")
 
 	var lintCmd = &cobra.Command{
 		Use:   "lint",
 		Short: "Lint code",
-		Long:  "Run Rust linting (clippy) on the codebase.",
+		Long: "Lint the codebase using the flake's lint app, or the detected language's linter if it has none. " +
+			"In workspaces with language roots below the project root, also run each language's linter in its root. " +
+			"With a lint baseline, only findings not in the baseline fail.",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if err := checkNix(); err != nil {
 				errorMsg(err.Error())
 				return err
 			}
-			info("Running Rust linting (clippy)...")
-			if err := runInDevShell("cargo", "clippy", "--", "-D", "warnings"); err != nil {
-				errorMsg("Linting failed")
+			flag, _ := cmd.Flags().GetString("baseline")
+			mode, err := lintBaselineMode(flag)
+			if err != nil {
+				errorMsg(err.Error())
 				return err
 			}
-			success("Linting completed")
+			lint := func() error { return runWorkspaceTask("lint", runLint, lintRootName, Language.Lint) }
+			switch mode {
+			case "create":
+				info(tr("LintBaselineStarted"))
+				if err := createLintBaseline(lint); err != nil {
+					errorMsg(err.Error())
+					return err
+				}
+				return nil
+			case "use":
+				workspaceLint := lint
+				lint = func() error { return lintWithBaseline(workspaceLint) }
+			}
+			info(tr("LintStarted"))
+			if err := lint(); err != nil {
+				errorMsg(tr("LintFailed"))
+				return err
+			}
+			success(tr("LintCompleted"))
 			return nil
 		},
 	}
+	lintCmd.Flags().String("baseline", "", "create: record current findings in "+lintBaselineFile+"; use: fail only on new findings (default if the file exists); ignore")
 
 	var testCmd = &cobra.Command{
-		Use:   "test",
+		Use:   "test [flags] [-- args...]",
 		Short: "Run tests",
-		Long:  "Run Rust tests for the project.",
+		Long: "Run tests using the flake's test app, or the detected language's test runner if it has none. " +
+			"Arguments after -- are passed to the test runner. The project's secrets are in the tests' environment.",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if err := checkNix(); err != nil {
 				errorMsg(err.Error())
 				return err
 			}
-			info("Running Rust tests...")
-			if err := runInDevShell("cargo", "test"); err != nil {
-				errorMsg("Tests failed")
+			if err := applySecrets(cmd); err != nil {
+				errorMsg(err.Error())
 				return err
 			}
-			success("Tests completed")
-			return nil
+			options := testOptions{Args: args}
+			options.Filter, _ = cmd.Flags().GetString("filter")
+			options.Verbose = verbosity >= levelCommand
+			options.Coverage, _ = cmd.Flags().GetBool("coverage")
+			return recordRun("test", args, func() error {
+				info(tr("TestStarted"))
+				if err := runTests(options); err != nil {
+					errorMsg(tr("TestFailed"))
+					return err
+				}
+				success(tr("TestCompleted"))
+				return nil
+			})
 		},
 	}
+	testCmd.Flags().String("filter", "", "Run only the tests matching the pattern (cargo test NAME, go test -run, pytest -k, ...)")
+	addSecretsFlag(testCmd)
+	testCmd.Flags().Bool("coverage", false, "Collect coverage with the language's runner and write reports to .glot/coverage")
 
 	var checkCmd = &cobra.Command{
 		Use:   "check",
 		Short: "Run all checks",
-		Long:  "Run comprehensive checks including format, lint, test, and build.",
+		Long: "Run comprehensive checks including format, lint, test, build, and the smoke test of glot.toml. " +
+			"Once they pass, record metrics in " + metricsFile + " and evaluate the quality gates of glot.toml.",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if err := checkNix(); err != nil {
 				errorMsg(err.Error())
 				return err
 			}
-			info("Running comprehensive checks...")
-			if err := runNix("fmt"); err != nil ||
-				runInDevShell("cargo", "clippy", "--", "-D", "warnings") != nil ||
-				runInDevShell("cargo", "test") != nil ||
-				runNix("build") != nil {
-				errorMsg("Some checks failed. Please review the output above.")
-				return fmt.Errorf("checks failed")
+			if sandbox, _ := cmd.Flags().GetBool("sandbox"); sandbox {
+				if resume, _ := cmd.Flags().GetBool("resume"); resume {
+					err := fmt.Errorf("%s", tr("CheckResumeSandbox"))
+					errorMsg(err.Error())
+					return err
+				}
+				if diffLast, _ := cmd.Flags().GetBool("diff-last"); diffLast {
+					err := fmt.Errorf("%s", tr("CheckDiffSandbox"))
+					errorMsg(err.Error())
+					return err
+				}
+				return recordRun("check", args, func() error {
+					if err := sandboxedCheck(); err != nil {
+						errorMsg(tr("CheckSandboxFailed", "Error", err))
+						return err
+					}
+					return nil
+				})
 			}
-			success("All checks passed!")
-			return nil
+			strictLock, _ := cmd.Flags().GetBool("strict-lock")
+			enforceGates, _ := cmd.Flags().GetBool("gate")
+			resume, _ := cmd.Flags().GetBool("resume")
+			diffLast, _ := cmd.Flags().GetBool("diff-last")
+			return recordRun("check", args, func() error {
+				if err := checkFlakeLock(strictLock); err != nil {
+					errorMsg(err.Error())
+					return err
+				}
+				info(tr("CheckStarted"))
+				metrics := &checkMetrics{}
+				progress := loadCheckProgress(resume)
+				defer progress.finish(diffLast)
+				if err := progress.run("fmt", metrics, func(*checkMetrics) error { return formatProject() }); err != nil ||
+					progress.run("lint", metrics, (*checkMetrics).lint) != nil ||
+					progress.run("test", metrics, (*checkMetrics).test) != nil ||
+					progress.run("build", metrics, (*checkMetrics).build) != nil {
+					errorMsg(tr("CheckFailed"))
+					return fmt.Errorf("%s", tr("CheckChecksFailed"))
+				}
+				if currentConfig.Smoke.configured() {
+					if err := progress.run("smoke", metrics, func(*checkMetrics) error { return smokeTestRelease() }); err != nil {
+						errorMsg(err.Error())
+						return err
+					}
+				}
+				if err := checkGates(metrics, enforceGates); err != nil {
+					errorMsg(err.Error())
+					return err
+				}
+				success(tr("CheckPassed"))
+				return nil
+			})
 		},
 	}
 
+	checkCmd.Flags().Bool("strict-lock", false, "Fail instead of warning when flake.lock is stale")
+	checkCmd.Flags().Bool("gate", false, "Fail instead of warning when a quality gate fails")
+	checkCmd.Flags().Bool("sandbox", false, "Run the checks without network and with the project read-only, for untrusted code")
+	checkCmd.Flags().Bool("resume", false, "Skip the steps that passed before, if the project's files haven't changed since")
+	checkCmd.Flags().Bool("diff-last", false, "Report only the failures and warnings that are new since the last check on the branch")
+
 	var cleanCmd = &cobra.Command{
 		Use:   "clean",
 		Short: "Clean artifacts",
 		Long:  "Clean build artifacts and temporary files.",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			info("Cleaning build artifacts...")
-			targets := []string{"target/", "result", "result-*", ".cargo/"}
+			info(tr("CleanStarted"))
+			targets := []string{"result", "result-*"}
 			for _, target := range targets {
 				if matches, _ := filepath.Glob(target); len(matches) > 0 {
 					for _, match := range matches {
@@ -267,45 +739,67 @@ func main() {
 					}
 				}
 			}
-			success("Clean completed!")
+			if language, err := detectLanguage(); err == nil {
+				if err := language.Clean(); err != nil {
+					errorMsg(tr("CleanLanguageFailed", "Language", language.Name(), "Error", err))
+					return err
+				}
+			}
+			success(tr("CleanCompleted"))
 			return nil
 		},
 	}
 
 	var updateCmd = &cobra.Command{
-		Use:   "update",
+		Use:   "update [flake]",
 		Short: "Update dependencies",
-		Long:  "Update both Nix flake and Cargo dependencies, plus refresh glot CLI.",
+		Long:  "Update both Nix flake and language dependencies, plus refresh glot CLI. 'glot update flake' only updates flake.lock.",
+		Args:  cobra.MatchAll(cobra.MaximumNArgs(1), cobra.OnlyValidArgs),
+		ValidArgs: []string{"flake"},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if err := checkNix(); err != nil {
 				errorMsg(err.Error())
 				return err
 			}
+			if err := checkOnline("glot update"); err != nil {
+				errorMsg(err.Error())
+				return err
+			}
 			
-			info("Updating project dependencies...")
+			info(tr("UpdateStarted"))
 			if err := runNix("flake", "update"); err != nil {
-				errorMsg("Failed to update flake dependencies")
+				errorMsg(tr("UpdateFlakeFailed"))
 				return err
 			}
-			if err := runInDevShell("cargo", "update"); err != nil {
-				warning("Failed to update cargo dependencies")
+			if language, err := detectLanguage(); len(args) == 0 && err == nil {
+				err := language.UpdateDeps()
+				switch {
+				case errors.As(err, &unsupportedTaskError{}):
+					// Only the flake has dependencies glot can update
+				case err != nil:
+					warning(tr("UpdateLanguageFailed", "Language", language.Name()))
+				default:
+					if attrs, _ := findHashAttrs(); len(attrs) > 0 {
+						info(tr("UpdateHashesHint"))
+					}
+				}
 			}
-			success("Project dependencies updated!")
+			success(tr("UpdateCompleted"))
 			
 			// Self-update: remove cached glot CLI to force rebuild
-			info("Refreshing glot CLI...")
+			info(tr("RefreshStarted"))
 			cacheFile := ".cache/bin/glot"
 			if _, err := os.Stat(cacheFile); err == nil {
 				if err := os.Remove(cacheFile); err != nil {
-					warning("Could not remove cached glot CLI - you may need to run 'direnv reload'")
+					warning(tr("RefreshRemoveFailed"))
 				} else {
-					success("Cached glot CLI cleared - will be rebuilt automatically on next use")
+					success(tr("RefreshCleared"))
 				}
 			} else {
-				info("No cached glot CLI found - will be built automatically on next use")
+				info(tr("RefreshNoCache"))
 			}
 			
-			success("Update completed! Glot CLI will be refreshed automatically.")
+			success(tr("UpdateFinished"))
 			return nil
 		},
 	}
@@ -319,35 +813,93 @@ func main() {
 				errorMsg(err.Error())
 				return err
 			}
-			fmt.Println("📋 Project Information")
+			fmt.Println(plain("📋 " + tr("InfoTitle")))
 			fmt.Println("======================")
 			wd, _ := os.Getwd()
-			fmt.Printf("Working directory: %s\n", wd)
+			fmt.Println(tr("InfoWorkingDirectory", "Dir", wd))
 			fmt.Println()
-			fmt.Println("Project type: rust")
+			if language, err := detectLanguage(); err == nil {
+				fmt.Println(tr("InfoProjectType", "Language", language.Name()))
+				for _, line := range language.Info() {
+					fmt.Println(line)
+				}
+			} else {
+				fmt.Println(tr("InfoProjectTypeUnknown"))
+			}
+			for _, line := range currentConfig.summary() {
+				fmt.Println(line)
+			}
 			fmt.Println()
-			fmt.Println("Flake status:")
+			fmt.Println(tr("InfoFlakeStatus"))
 			if err := runNix("flake", "show"); err != nil {
-				errorMsg("Flake validation failed")
+				errorMsg(tr("FlakeInvalid"))
 				return err
 			} else {
-				success("Flake is valid")
+				success(tr("FlakeValid"))
 			}
 			return nil
 		},
 	}
 
 	var shellCmd = &cobra.Command{
-		Use:   "shell",
+		Use:   "shell [name]",
 		Short: "Enter dev environment",
-		Long:  "Enter the Nix development shell.",
+		Long: "Enter the Nix development shell, or the flake's dev shell of the given name. With --list, list the flake's dev shells. " +
+			"The shell's environment is cached in .glot/dev-env until flake.nix or flake.lock change, so entering it again is instant.",
+		Args:  cobra.MaximumNArgs(1),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) > 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			shells, _ := flakeDevShells()
+			return shells, cobra.ShellCompDirectiveNoFileComp
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if err := checkNix(); err != nil {
 				errorMsg(err.Error())
 				return err
 			}
-			info("Entering development shell...")
-			nixCmd := exec.Command("nix", "develop")
+			if list, _ := cmd.Flags().GetBool("list"); list {
+				shells, err := flakeDevShells()
+				if err != nil {
+					errorMsg(tr("ShellListFailed", "Error", err))
+					return err
+				}
+				if len(shells) == 0 {
+					info(tr("ShellNoneForSystem"))
+					return nil
+				}
+				for _, shell := range shells {
+					fmt.Println(shell)
+				}
+				return nil
+			}
+			developArgs := []string{"develop"}
+			name := ""
+			if len(args) > 0 {
+				name = args[0]
+				shells, err := flakeDevShells()
+				if err == nil && !slices.Contains(shells, name) {
+					err := fmt.Errorf("%s", tr("ShellUnknown", "Name", name, "Available", strings.Join(shells, ", ")))
+					errorMsg(err.Error())
+					return err
+				}
+				developArgs = append(developArgs, ".#"+name)
+				info(tr("ShellEnteringNamed", "Name", name))
+			} else {
+				info(tr("ShellEntering"))
+			}
+			argv := append([]string{"nix"}, withNixGlobalArgs(developArgs)...)
+			refresh, _ := cmd.Flags().GetBool("refresh")
+			if env, err := cachedShellEnv(name, refresh); err == nil {
+				if argv, err = env.interactiveCommand(); err != nil {
+					errorMsg(err.Error())
+					return err
+				}
+			} else {
+				debug(fmt.Sprintf("Not using a cached dev environment: %s", err))
+			}
+			nixCmd := exec.Command(argv[0], argv[1:]...)
 			nixCmd.Stdout = os.Stdout
 			nixCmd.Stderr = os.Stderr
 			nixCmd.Stdin = os.Stdin
@@ -363,100 +915,45 @@ func main() {
 		},
 	}
 
-	var newCmd = &cobra.Command{
-		Use:   "new [template] [name]",
-		Short: "Create new project from template",
-		Long:  "Create a new project from available nix-polyglot templates.",
-		Args:  cobra.RangeArgs(0, 2),
-		RunE: func(cmd *cobra.Command, args []string) error {
-			// For 'new' command, we only need nix installed, not flake.nix present
-			if err := checkNixInstalled(); err != nil {
-				errorMsg(err.Error())
-				return err
-			}
-			
-			// If no args, show available templates
-			if len(args) == 0 {
-				info("Available templates:")
-				// Try multiple sources for templates
-				templateSources := []string{
-					".#templates", // Current directory (if we're in nix-polyglot dev)
-					"path:/Users/ritzau/src/slask/nix/polyglot/nix-polyglot#templates", // Hardcoded dev path
-					"github:ritzau/nix-polyglot#templates", // GitHub fallback
-				}
-				
-				for _, source := range templateSources {
-					if err := runNix("run", source); err == nil {
-						return nil
-					}
-				}
-				
-				// All sources failed
-				errorMsg("Failed to list templates from all sources")
-				return fmt.Errorf("template listing failed")
-			}
-			
-			// If only template specified, show usage
-			if len(args) == 1 {
-				template := args[0]
-				errorMsg(fmt.Sprintf("Project name required. Usage: glot new %s <project-name>", template))
-				return fmt.Errorf("missing project name")
-			}
-			
-			// Create project from template
-			template := args[0]
-			projectName := args[1]
-			
-			info(fmt.Sprintf("Creating new %s project: %s", template, projectName))
-			
-			// Map common template names to nix app names
-			var appName string
-			switch template {
-			case "rust", "rust-cli":
-				appName = "new-rust"
-			case "csharp", "csharp-console":
-				appName = "new-csharp"
-			case "python", "python-console":
-				appName = "new-python"
-			default:
-				// Try the template name directly as an app
-				appName = fmt.Sprintf("new-%s", template)
-			}
-			
-			// Try multiple sources for nix-polyglot templates
-			var templateSources = []string{
-				fmt.Sprintf(".#%s", appName), // Current directory (if we're in nix-polyglot dev)
-				fmt.Sprintf("path:/Users/ritzau/src/slask/nix/polyglot/nix-polyglot#%s", appName), // Hardcoded dev path
-				fmt.Sprintf("github:ritzau/nix-polyglot#%s", appName), // GitHub fallback
-			}
-			
-			var lastErr error
-			for _, source := range templateSources {
-				if err := runNix("run", source, projectName); err == nil {
-					// Success - break out
-					goto templateSuccess
-				} else {
-					lastErr = err
-				}
-			}
-			
-			// All template sources failed
-			errorMsg(fmt.Sprintf("Failed to create project with template '%s'", template))
-			info("Available templates:")
-			runNix("run", "github:ritzau/nix-polyglot#templates")
-			return lastErr
-			
-			templateSuccess:
-			
-			success(fmt.Sprintf("Project '%s' created successfully!", projectName))
-			info(fmt.Sprintf("Next steps: cd %s && direnv allow", projectName))
-			return nil
-		},
-	}
+	shellCmd.Flags().Bool("list", false, "List the flake's dev shells for this system")
+	shellCmd.Flags().Bool("refresh", false, "Evaluate the dev shell again instead of using its cached environment")
+
+	planCmd := newPassthroughCmd("plan", "Plan infrastructure changes",
+		"Run the flake's plan app (e.g. tofu plan) for infrastructure projects.", false)
+	applyCmd := newPassthroughCmd("apply", "Apply infrastructure changes",
+		"Run the flake's apply app (e.g. tofu apply) after confirmation.", true)
+
+	rootCmd.AddCommand(buildCmd, runCmd, fmtCmd, lintCmd, testCmd, checkCmd, cleanCmd, updateCmd, infoCmd, shellCmd, newNewCmd(), newInitCmd(), newTaskCmd(), newWatchCmd(), planCmd, applyCmd, newFingerprintCmd(), newLogsCmd(), newLogCmd(), newStatsCmd(), newHealthCmd(), newBugReportCmd(), newCacheCmd(), newBuildersCmd(), newExportCmd(), newPackageCmd(), newTemplateCmd(), newHashCmd(), newEnvCmd(), newCompareCmd(), newPrivateCmd(), newSecretsCmd(), newExecCmd(), newToolCmd(), newLspCmd(), newReleaseCmd(), newPublishCmd(), newDoctorCmd(), newVerifyConsumerCmd(), newTryCmd(), newTryBranchCmd(), newReviewCmd(), newDeployCmd(), newManCmd())
 
-	rootCmd.AddCommand(buildCmd, runCmd, fmtCmd, lintCmd, testCmd, checkCmd, cleanCmd, updateCmd, infoCmd, shellCmd, newCmd)
+	var err error
+	currentConfig, err = loadProjectConfig(configFile)
+	if err != nil {
+		warning(err.Error())
+	}
+	applyLocale()
+	currentConfig.Redact.Env = append(currentConfig.Redact.Env, currentConfig.Private.tokenEnvs()...)
+	if outputRedactor, err = newRedactor(currentConfig.Redact); err != nil {
+		warning(err.Error())
+	}
 
-	if err := rootCmd.Execute(); err != nil {
+	// Extra commands declared by the project's template
+	templateCommands, err := loadTemplateCommands(commandsManifest)
+	if err != nil {
+		warning(err.Error())
+	}
+	// The project's own tasks take precedence over the template's commands
+	registerTemplateCommands(rootCmd, configFile, currentConfig.Tasks)
+	registerTemplateCommands(rootCmd, commandsManifest, templateCommands)
+	addCompletionInstallCmd(rootCmd)
+
+	var cliArgs []string
+	cliArgs, nixSeparatedArgs = splitNixArgs(os.Args[1:])
+	// Commands glot doesn't know may be plugins
+	rootCmd.SetArgs(addPluginCmd(rootCmd, cliArgs))
+
+	err = rootCmd.Execute()
+	commandStep.finish(err)
+	if err != nil {
 		os.Exit(1)
 	}
 }
\ No newline at end of file
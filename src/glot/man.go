@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/cobra/doc"
+)
+
+// Man pages of glot and each of its commands: glot.1, glot-build.1, ...
+// The flake's glot package installs them, so `man glot-build` works.
+func newManCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:    "man <dir>",
+		Short:  "Generate man pages",
+		Long:   "Write a man page for glot and each of its commands to a directory, as the flake's glot package does at build time.",
+		Args:   cobra.ExactArgs(1),
+		Hidden: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := os.MkdirAll(args[0], 0755); err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			root := cmd.Root()
+			// Pages must not change between identical builds
+			root.DisableAutoGenTag = true
+			header := &doc.GenManHeader{
+				Title:   "GLOT",
+				Section: "1",
+				Source:  "glot " + version,
+				Manual:  "Nix Polyglot",
+			}
+			// The date is SOURCE_DATE_EPOCH's if set, as in nix builds
+			if err := doc.GenManTree(root, header, args[0]); err != nil {
+				err = fmt.Errorf("%s", tr("ManWriteFailed", "Error", err))
+				errorMsg(err.Error())
+				return err
+			}
+			success(tr("ManWritten", "Dir", args[0]))
+			return nil
+		},
+	}
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// Short names for the default template of a language, as in lib/templates.nix
+var templateAliases = map[string]string{
+	"csharp": "csharp-console",
+	"rust":   "rust-cli",
+	"python": "python-console",
+	"nim":    "nim-cli",
+	"zig":    "zig-cli",
+	"go":     "go-cli",
+	"cpp":    "cpp-cli",
+}
+
+var packageNameUnsafe = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+// Substitution variables for a project name and Go-style module path
+func projectVariables(name, modulePath string) map[string]string {
+	if modulePath == "" {
+		modulePath = "example.com/" + name
+	}
+	return map[string]string{
+		"projectName": name,
+		"modulePath":  modulePath,
+		// Identifier-safe name for Python packages, Nim modules and the like
+		"packageName": strings.ToLower(packageNameUnsafe.ReplaceAllString(name, "_")),
+	}
+}
+
+// Find a template by name or alias
+func findTemplate(templates []*templateManifest, name string) *templateManifest {
+	if alias, ok := templateAliases[name]; ok {
+		name = alias
+	}
+	for _, template := range templates {
+		if template.Name == name {
+			return template
+		}
+	}
+	return nil
+}
+
+// Print the available templates, grouped by language
+func printTemplates(templates []*templateManifest) {
+	aliases := map[string]string{}
+	for alias, name := range templateAliases {
+		aliases[name] = alias
+	}
+	byLanguage := map[string][]*templateManifest{}
+	languages := map[string]string{}
+	for _, template := range templates {
+		byLanguage[template.Language] = append(byLanguage[template.Language], template)
+		languages[template.Language] = ""
+	}
+	for _, language := range sortedKeys(languages) {
+		fmt.Printf("\n  %s:\n", language)
+		for _, template := range byLanguage[language] {
+			name := template.Name
+			if alias := aliases[template.Name]; alias != "" {
+				name += " (" + alias + ")"
+			}
+			fmt.Printf("    %-28s %s\n", name, template.Description)
+		}
+	}
+	fmt.Println()
+	fmt.Println(tr("NewUsage"))
+}
+
+// Generate a project into dir: template files and features, a git repository,
+// the template's post-generate hooks and an initial commit. In a directory
+// that already has files (see scaffoldMode), the generated files are staged
+// for the user to commit.
+func createProject(template *templateManifest, dir string, vars map[string]string, platforms []string, features []*templateFeature, mode scaffoldMode, runHooks bool) error {
+	fresh, err := dirEmpty(dir)
+	if err != nil {
+		return err
+	}
+	plan, err := planProject(template, dir, vars, platforms, features, mode)
+	if err != nil {
+		return err
+	}
+	for _, feature := range features {
+		info(tr("NewAddingFeature", "Feature", feature.Name))
+	}
+	written, err := plan.write(dir)
+	if err != nil {
+		return err
+	}
+
+	// Flakes only see files tracked by git, so hooks need the files staged
+	if err := gitIn(dir, "init", "--quiet"); err != nil {
+		return err
+	}
+	stage := []string{"add", "-A"}
+	if !fresh {
+		stage = append([]string{"add", "--"}, written...)
+	}
+	if err := gitIn(dir, stage...); err != nil {
+		return err
+	}
+	if runHooks {
+		if err := runPostGenerateHooks(dir, template.PostGenerate); err != nil {
+			return err
+		}
+	} else if len(template.PostGenerate) > 0 {
+		warning(tr("NewSkippingHooks"))
+		for _, hook := range template.PostGenerate {
+			fmt.Printf("  %s\n", hook)
+		}
+	}
+
+	if !fresh {
+		info(tr("NewStaged", "Dir", dir))
+		return nil
+	}
+	message := fmt.Sprintf("Initial commit: %s project created with nix-polyglot", template.Description)
+	if err := gitCommitIn(dir, message); err != nil {
+		warning(tr("NewCommitFailed"))
+	}
+	return nil
+}
+
+func newNewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "new [template] [name]",
+		Short: "Create new project from template",
+		Long: "Create a new project from a nix-polyglot template, replacing the template's names " +
+			"with the project name and module path. Without arguments, list the available templates.",
+		Args: cobra.RangeArgs(0, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// For 'new' command, we only need nix installed, not flake.nix present
+			if err := checkNixInstalled(); err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+
+			root, err := resolvePolyglotRoot()
+			if err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			templates, err := loadTemplates(root)
+			if err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+
+			if len(args) == 0 {
+				info(tr("NewAvailableTemplates"))
+				printTemplates(templates)
+				return nil
+			}
+			if len(args) == 1 {
+				errorMsg(tr("NewNameRequired", "Template", args[0]))
+				return fmt.Errorf("%s", tr("NewNameMissing"))
+			}
+
+			template := findTemplate(templates, args[0])
+			if template == nil {
+				err := fmt.Errorf("%s", tr("NewUnknownTemplate", "Template", args[0]))
+				errorMsg(err.Error())
+				return err
+			}
+			dir := args[1]
+			mode := scaffoldFresh
+			if merge, _ := cmd.Flags().GetBool("merge"); merge {
+				mode = scaffoldMerge
+			}
+			if force, _ := cmd.Flags().GetBool("force"); force {
+				mode = scaffoldForce
+			}
+			if stat, err := os.Stat(dir); err == nil && !stat.IsDir() {
+				err := fmt.Errorf("%s", tr("NewNotDirectory", "Dir", dir))
+				errorMsg(err.Error())
+				return err
+			}
+			if empty, err := dirEmpty(dir); err != nil || !empty && mode == scaffoldFresh {
+				if err == nil {
+					err = fmt.Errorf("%s", tr("NewNotEmpty", "Dir", dir))
+				}
+				errorMsg(err.Error())
+				return err
+			}
+
+			org, err := selectedOrgDefaults(cmd)
+			if err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			withFeatures, _ := cmd.Flags().GetStringSlice("with")
+			if org != nil {
+				info(tr("NewOrgDefaults", "Org", org.Name, "Ref", org.Ref))
+				withFeatures = org.features(withFeatures)
+			}
+			features, err := loadTemplateFeatures(root, withFeatures, org)
+			if err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			if org != nil {
+				// Last, so its files replace the template's and the features'
+				features = append(features, org.feature(template.Language))
+			}
+
+			platformFlag, _ := cmd.Flags().GetStringSlice("platforms")
+			platforms, err := parsePlatforms(platformFlag)
+			if err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+
+			modulePath, _ := cmd.Flags().GetString("module")
+			noHooks, _ := cmd.Flags().GetBool("no-hooks")
+			// glot new go . --merge is named after the current directory
+			abs, err := filepath.Abs(dir)
+			if err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			name := filepath.Base(abs)
+			vars := projectVariables(name, modulePath)
+
+			if printPlan, _ := cmd.Flags().GetBool("print-plan"); printPlan {
+				plan, err := planProject(template, dir, vars, platforms, features, mode)
+				if err != nil {
+					errorMsg(err.Error())
+					return err
+				}
+				plan.print()
+				return nil
+			}
+
+			info(tr("NewCreating", "Template", template.Name, "Name", name))
+			if err := createProject(template, dir, vars, platforms, features, mode, !noHooks); err != nil {
+				errorMsg(err.Error())
+				warning(tr("NewLeftInPlace", "Dir", dir))
+				return err
+			}
+
+			success(tr("NewCreated", "Name", name))
+			fmt.Println()
+			fmt.Println(plain("📋 " + tr("NextSteps")))
+			fmt.Printf("  cd %s\n", dir)
+			fmt.Printf("  %-20s # %s\n", "direnv allow", tr("NextStepDirenv"))
+			fmt.Printf("  %-20s # %s\n", "glot build", tr("NextStepBuild"))
+			fmt.Printf("  %-20s # %s\n", "glot run", tr("NextStepRun"))
+			fmt.Printf("  %-20s # %s\n", "glot test", tr("NextStepTest"))
+			return nil
+		},
+	}
+	cmd.Flags().StringSlice("with", nil, "Template features to add (e.g. docker,ci,devcontainer,otel)")
+	cmd.Flags().StringSlice("platforms", nil, "Platforms to generate platform-specific content and flake outputs for: linux, darwin (default: all)")
+	cmd.Flags().String("module", "", "Module path for templates that use one (default: example.com/<name>)")
+	cmd.Flags().Bool("no-hooks", false, "Don't run the template's post-generate hooks")
+	cmd.Flags().String("org", "", "Flake of organization defaults to apply (default: $GLOT_ORG, or org in ~/.config/glot/config.toml)")
+	cmd.Flags().Bool("no-org", false, "Don't apply the organization defaults")
+	cmd.Flags().Bool("print-plan", false, "Print the files, substitutions and hooks of the project without writing anything")
+	cmd.Flags().Bool("merge", false, "Generate into a directory with files, keeping them and adding the features' appends")
+	cmd.Flags().Bool("force", false, "Generate into a directory with files, replacing those the template has")
+	cmd.MarkFlagsMutuallyExclusive("merge", "force")
+	return cmd
+}
+
+// The organization defaults glot new applies: --org, $GLOT_ORG or the
+// user's config; nil with --no-org or none of them
+func selectedOrgDefaults(cmd *cobra.Command) (*orgDefaults, error) {
+	if noOrg, _ := cmd.Flags().GetBool("no-org"); noOrg {
+		return nil, nil
+	}
+	ref, _ := cmd.Flags().GetString("org")
+	if ref == "" {
+		ref = os.Getenv("GLOT_ORG")
+	}
+	if ref == "" {
+		config, err := loadUserConfig()
+		if err != nil {
+			return nil, err
+		}
+		ref = config.Org
+	}
+	if ref == "" {
+		return nil, nil
+	}
+	return loadOrgDefaults(ref)
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+type nimLanguage struct{ toolchain }
+
+func newNimLanguage() Language {
+	return nimLanguage{toolchain{
+		name:       "nim",
+		markers:    []string{"*.nimble"},
+		lsp:        []string{"nimlangserver"},
+		lspPackage: "nimlangserver",
+		format:     []string{"bash", "-c", "find . -name '*.nim' -not -path './nimcache/*' -exec nimpretty {} +"},
+		test:       []string{"nimble", "test"},
+		artifacts:  []string{"nimcache/"},
+	}}
+}
+
+func (l nimLanguage) Info() []string {
+	if name, _ := l.Project(); name != "" {
+		return []string{tr("InfoPackage", "Name", name)}
+	}
+	return nil
+}
+
+func (nimLanguage) Project() (string, string) {
+	matches, _ := filepath.Glob("*.nimble")
+	if len(matches) == 0 {
+		return "", ""
+	}
+	return strings.TrimSuffix(matches[0], ".nimble"), ""
+}
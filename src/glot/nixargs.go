@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+)
+
+// Options of the user for nix, from --nix-arg, --nix-option and after
+// --nix, each a flag with its values. withNixGlobalArgs adds them after the
+// subcommand of the nix invocations that accept them.
+var nixUserArgs [][]string
+
+// The user's nix options after the --nix separator, taken out of the
+// command line before cobra sees it
+var nixSeparatedArgs []string
+
+// nix commands with subcommands of their own: nix flake lock
+var nixMultiCommands = []string{"config", "derivation", "flake", "hash", "key", "nar", "profile", "realisation", "registry", "store"}
+
+// nix commands that take a flake, and with it the flags of its lock file
+var nixFlakeCommands = []string{
+	"build", "bundle", "copy", "develop", "eval", "fmt", "log", "path-info", "print-dev-env", "run", "search", "shell", "why-depends",
+	"flake archive", "flake check", "flake info", "flake lock", "flake metadata", "flake show", "flake update",
+	"profile install", "profile upgrade",
+}
+
+// Flags of flake-taking commands only: nix config show doesn't know
+// --override-input
+var nixFlakeFlags = []string{
+	"--commit-lock-file", "--impure", "--inputs-from", "--no-registries", "--no-update-lock-file", "--no-write-lock-file",
+	"--output-lock-file", "--override-flake", "--override-input", "--recreate-lock-file", "--reference-lock-file", "--update-input",
+}
+
+// Flags only some commands have, by the commands that have them. Others
+// go to every nix command, as the settings (--option, --substituters, ...) do.
+var nixCommandFlags = map[string][]string{
+	"--rebuild": {"build"},
+	"--dry-run": {"build"},
+}
+
+// Take the arguments after --nix, up to a -- or the end, out of args
+func splitNixArgs(args []string) (rest, nixArgs []string) {
+	for i, arg := range args {
+		switch arg {
+		case "--":
+			return args, nil
+		case "--nix":
+			end := len(args)
+			if dash := slices.Index(args[i+1:], "--"); dash >= 0 {
+				end = i + 1 + dash
+			}
+			rest = append(append([]string{}, args[:i]...), args[end:]...)
+			return rest, args[i+1 : end]
+		}
+	}
+	return args, nil
+}
+
+// Group nix arguments by flag: a flag and the values up to the next flag
+func groupNixArgs(args []string) ([][]string, error) {
+	var groups [][]string
+	for _, arg := range args {
+		switch {
+		case strings.HasPrefix(arg, "-"):
+			groups = append(groups, []string{arg})
+		case len(groups) == 0:
+			return nil, fmt.Errorf("%s", tr("NixArgNoFlag", "Arg", arg))
+		default:
+			groups[len(groups)-1] = append(groups[len(groups)-1], arg)
+		}
+	}
+	return groups, nil
+}
+
+// Collect the user's nix options from --nix-arg, --nix-option and --nix
+func applyNixArgs(cmd *cobra.Command) error {
+	var args []string
+	values, _ := cmd.Flags().GetStringArray("nix-arg")
+	for _, value := range values {
+		args = append(args, strings.Fields(value)...)
+	}
+	options, _ := cmd.Flags().GetStringArray("nix-option")
+	for _, option := range options {
+		name, value, ok := strings.Cut(option, "=")
+		if !ok || strings.TrimSpace(name) == "" {
+			return fmt.Errorf("%s", tr("NixOptionInvalid", "Option", option))
+		}
+		args = append(args, "--option", strings.TrimSpace(name), value)
+	}
+	groups, err := groupNixArgs(append(args, nixSeparatedArgs...))
+	if err != nil {
+		return err
+	}
+	nixUserArgs = groups
+	return nil
+}
+
+// The user's nix options a nix invocation accepts, to add after its subcommand
+func nixUserArgsFor(subcommand string) []string {
+	var args []string
+	for _, group := range nixUserArgs {
+		if commands, ok := nixCommandFlags[group[0]]; ok && !slices.Contains(commands, subcommand) {
+			continue
+		}
+		if slices.Contains(nixFlakeFlags, group[0]) && !slices.Contains(nixFlakeCommands, subcommand) {
+			continue
+		}
+		if group[0] == "--impure" {
+			impureWarning.Do(func() { warning(tr("ImpureWarning")) })
+		}
+		args = append(args, group...)
+	}
+	return args
+}
+
+// Warns once that impure evaluation makes builds depend on the machine
+var impureWarning sync.Once
+
+// Evaluate the flake impurely with glot build --impure, or impure = true in
+// glot.toml
+func applyImpureMode(cmd *cobra.Command) {
+	impure := currentConfig.Impure
+	if flag := cmd.Flags().Lookup("impure"); flag != nil && flag.Changed {
+		impure, _ = cmd.Flags().GetBool("impure")
+	}
+	if impure {
+		nixUserArgs = append(nixUserArgs, []string{"--impure"})
+	}
+}
+
+// Register the nix passthrough flags on the root command
+func addNixArgFlags(root *cobra.Command) {
+	root.PersistentFlags().StringArray("nix-arg", nil, "Arguments for the nix commands glot runs, e.g. \"--override-input nixpkgs ../nixpkgs\" (repeatable; or put them after --nix)")
+	root.PersistentFlags().StringArray("nix-option", nil, "nix setting for the nix commands glot runs, as name=value, e.g. substituters=https://cache.example.com (repeatable)")
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// What glot build last built, so glot log finds its log without a target
+var lastBuildFile = filepath.Join(stateDir, "last-build.json")
+
+type lastBuild struct {
+	Installable string    `json:"installable"`
+	Args        []string  `json:"args"` // Of nix build, with overrides as an expression
+	Started     time.Time `json:"started"`
+}
+
+// Note the build before nix starts, so glot log finds a failed build's log
+// too; a build that can't be noted still runs
+func recordLastBuild(installable string, args []string) {
+	if err := ensureStateDir(stateDir); err != nil {
+		debug(fmt.Sprintf("Not recording the build: %s", err))
+		return
+	}
+	content, _ := json.MarshalIndent(lastBuild{installable, args, time.Now()}, "", "  ")
+	if err := os.WriteFile(lastBuildFile, append(content, '\n'), 0644); err != nil {
+		debug(fmt.Sprintf("Not recording the build: %s", err))
+	}
+}
+
+func loadLastBuild() *lastBuild {
+	content, err := os.ReadFile(lastBuildFile)
+	if err != nil {
+		return nil
+	}
+	var build lastBuild
+	if json.Unmarshal(content, &build) != nil || len(build.Args) == 0 {
+		return nil
+	}
+	return &build
+}
+
+// A build log as plain lines for grep: without color escapes, and only the
+// last state of lines redrawn with carriage returns
+func plainBuildLog(log []byte) []byte {
+	log = bytes.ReplaceAll(log, []byte("\r\n"), []byte("\n"))
+	lines := bytes.Split(log, []byte("\n"))
+	for i, line := range lines {
+		if cr := bytes.LastIndexByte(line, '\r'); cr >= 0 {
+			line = line[cr+1:]
+		}
+		lines[i] = []byte(ansiEscape.ReplaceAllString(string(line), ""))
+	}
+	return bytes.Join(lines, []byte("\n"))
+}
+
+// The last n lines of a log
+func logTail(log []byte, n int) []byte {
+	lines := bytes.SplitAfter(bytes.TrimSuffix(log, []byte("\n")), []byte("\n"))
+	if n < len(lines) {
+		lines = lines[len(lines)-n:]
+	}
+	return append(bytes.Join(lines, nil), '\n')
+}
+
+// The user's pager, $GLOT_PAGER or $PAGER, empty for less
+func userPager() string {
+	if pager := os.Getenv("GLOT_PAGER"); pager != "" {
+		return pager
+	}
+	return os.Getenv("PAGER")
+}
+
+// Show a log in the pager: $GLOT_PAGER, $PAGER or less
+func pageLog(log []byte) error {
+	pager := userPager()
+	if pager == "" {
+		pager = "less -R"
+	}
+	cmd := exec.Command("sh", "-c", pager)
+	cmd.Stdin = bytes.NewReader(log)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// The glot build running in the project, from the project lock it holds
+func runningBuild() (lockHolder, bool) {
+	holder := readLockHolder()
+	if holder.Command != "build" || holder.RunID == "" || syscall.Kill(holder.PID, 0) != nil {
+		return holder, false
+	}
+	return holder, true
+}
+
+// Print a running build's log as it is written, like tail -f, until the
+// build exits; from its last lines with tail
+func followLog(path string, pid, tail int, plain bool) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	content, err := io.ReadAll(f)
+	if err != nil {
+		return err
+	}
+	// Lines are written once complete, as plainBuildLog needs them whole
+	pending := content
+	if end := bytes.LastIndexByte(pending, '\n'); end >= 0 {
+		lines := pending[:end+1]
+		pending = pending[end+1:]
+		if tail > 0 {
+			lines = logTail(lines, tail)
+		}
+		writeLogLines(lines, plain)
+	}
+	for {
+		running := syscall.Kill(pid, 0) == nil
+		chunk, err := io.ReadAll(f)
+		if err != nil {
+			return err
+		}
+		pending = append(pending, chunk...)
+		if end := bytes.LastIndexByte(pending, '\n'); end >= 0 {
+			writeLogLines(pending[:end+1], plain)
+			pending = pending[end+1:]
+		}
+		if !running {
+			if len(pending) > 0 {
+				writeLogLines(append(pending, '\n'), plain)
+			}
+			return nil
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
+
+func writeLogLines(lines []byte, plain bool) {
+	if plain {
+		lines = plainBuildLog(lines)
+	}
+	os.Stdout.Write(lines)
+}
+
+func newLogCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "log [target]",
+		Short: "Show the nix build log of the last build or a target",
+		Long: "Show the full build log nix keeps of the last glot build, or of a target, --release or --variant, " +
+			"without looking up store paths. On a terminal the log opens in $GLOT_PAGER, $PAGER or less; " +
+			"piped, it is plain text without colors or redrawn progress lines, for grep. " +
+			"Only derivations built on this machine have a log; downloaded ones don't. " +
+			"With --follow, show the log of the glot build running in the project as it is written, until it ends.",
+		Args: cobra.MaximumNArgs(1),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) > 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			targets, _ := flakeTargets(false)
+			return targets, cobra.ShellCompDirectiveNoFileComp
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			target := ""
+			if len(args) > 0 {
+				target = args[0]
+			}
+			variant := selectedVariant(cmd)
+			noPager, _ := cmd.Flags().GetBool("no-pager")
+			tail, _ := cmd.Flags().GetInt("tail")
+			stat, statErr := os.Stdout.Stat()
+			terminal := statErr == nil && stat.Mode()&os.ModeCharDevice != 0
+			paged := terminal && !noPager && jsonOut == nil
+
+			if follow, _ := cmd.Flags().GetBool("follow"); follow {
+				if target != "" || variant != "" {
+					err := fmt.Errorf("%s", tr("LogFollowExclusive"))
+					errorMsg(err.Error())
+					return err
+				}
+				if paged && userPager() != "" {
+					err := fmt.Errorf("%s", tr("LogFollowPager"))
+					errorMsg(err.Error())
+					return err
+				}
+				holder, ok := runningBuild()
+				if !ok {
+					err := fmt.Errorf("%s", tr("LogFollowNoBuild"))
+					errorMsg(err.Error())
+					return err
+				}
+				path := filepath.Join(logsDir, holder.RunID+".log")
+				if paged {
+					// less keeps reading the file as the build writes it; Ctrl-C stops
+					less := exec.Command("less", "-R", "+F", path)
+					less.Stdin, less.Stdout, less.Stderr = os.Stdin, os.Stdout, os.Stderr
+					if err := less.Run(); err != nil {
+						errorMsg(tr("LogPagerFailed", "Error", err))
+						return err
+					}
+					return nil
+				}
+				return followLog(path, holder.PID, tail, !terminal || plainOutput)
+			}
+
+			if err := checkNix(); err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+
+			var installable []string
+			name := ""
+			if last := loadLastBuild(); last != nil && target == "" && variant == "" {
+				installable, name = last.Args, last.Installable
+				debug(fmt.Sprintf("Last build: %s, started %s", name, last.Started.Format(time.RFC3339)))
+			} else {
+				ref, _, err := currentConfig.installable(target, variant)
+				if err != nil {
+					errorMsg(err.Error())
+					return err
+				}
+				installable, name = []string{ref}, ref
+			}
+
+			log, err := nixOutput(append([]string{"log"}, installable...)...)
+			if err != nil {
+				err = fmt.Errorf("%s", tr("LogNotBuilt", "Target", name))
+				errorMsg(err.Error())
+				return err
+			}
+
+			if !terminal || plainOutput {
+				log = plainBuildLog(log)
+			}
+			if tail > 0 {
+				log = logTail(log, tail)
+			}
+			if paged {
+				if err := pageLog(log); err != nil {
+					errorMsg(tr("LogPagerFailed", "Error", err))
+					return err
+				}
+				return nil
+			}
+			if !strings.HasSuffix(string(log), "\n") && len(log) > 0 {
+				log = append(log, '\n')
+			}
+			os.Stdout.Write(log)
+			return nil
+		},
+	}
+	addVariantFlags(cmd, "Show the log of")
+	cmd.Flags().BoolP("follow", "f", false, "Show the log of the glot build running in the project as it is written, like tail -f")
+	cmd.Flags().Int("tail", 0, "Only the last lines of the log")
+	cmd.Flags().Bool("no-pager", false, "Print the log instead of paging it")
+	return cmd
+}
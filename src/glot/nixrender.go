@@ -0,0 +1,293 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Activity and result types of nix's internal-json log format
+// (libutil/logging.hh)
+const (
+	nixActCopyPath         = 100
+	nixActCopyPaths        = 103
+	nixActBuilds           = 104
+	nixActBuild            = 105
+	nixActSubstitute       = 108
+	nixResBuildLogLine     = 101
+	nixResSetPhase         = 104
+	nixResProgress         = 105
+	nixResPostBuildLogLine = 107
+)
+
+// Messages up to this level are shown, as nix shows them by default (info)
+const nixMessageLevel = 3
+
+// Build log lines kept per derivation, shown if it fails
+const nixLogTail = 25
+
+// A line of nix's internal-json log, after its "@nix " prefix
+type nixLogEvent struct {
+	Action string `json:"action"` // start, stop, result or msg
+	ID     int64  `json:"id"`
+	Level  int    `json:"level"`
+	Type   int    `json:"type"`
+	Msg    string `json:"msg"`
+	Fields []any  `json:"fields"`
+}
+
+// A running build or download
+type nixActivity struct {
+	kind  int
+	path  string
+	phase string
+	logs  []string
+}
+
+// Renders nix's log of a build: the status line says what is downloading
+// and building, with a progress bar, and the build logs are collapsed,
+// shown only with -v or for a derivation that fails
+type nixRenderer struct {
+	mu         sync.Mutex
+	status     *buildStatus
+	out        io.Writer // Above the status line
+	collapsed  io.Writer // The run's logs, for collapsed lines
+	verbose    bool
+	activities map[int64]*nixActivity
+	// Progress of all builds and of all downloads: done, expected, running, failed
+	builds, copies []int64
+	// Log tails of finished builds, by derivation, for failures nix reports later
+	finished  map[string][]string
+	lastDrawn time.Time
+}
+
+// The package name of a store path: /nix/store/<hash>-hello-2.12.drv is hello-2.12
+func storePathName(path string) string {
+	name := strings.TrimSuffix(filepath.Base(path), ".drv")
+	if _, rest, ok := strings.Cut(name, "-"); ok {
+		return rest
+	}
+	return name
+}
+
+var nixDrvPattern = regexp.MustCompile(`/nix/store/[0-9a-z]{32}-[^'"\s]+\.drv`)
+
+func (r *nixRenderer) handle(line string) {
+	payload, ok := strings.CutPrefix(line, "@nix ")
+	if !ok {
+		fmt.Fprintln(r.out, line)
+		return
+	}
+	var event nixLogEvent
+	if json.Unmarshal([]byte(payload), &event) != nil {
+		fmt.Fprintln(r.out, line)
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch event.Action {
+	case "start":
+		activity := &nixActivity{kind: event.Type}
+		if len(event.Fields) > 0 {
+			activity.path, _ = event.Fields[0].(string)
+		}
+		r.activities[event.ID] = activity
+	case "stop":
+		if activity := r.activities[event.ID]; activity != nil {
+			if activity.kind == nixActBuild {
+				r.finished[activity.path] = activity.logs
+			}
+			delete(r.activities, event.ID)
+		}
+	case "result":
+		r.result(event)
+	case "msg":
+		if event.Level <= nixMessageLevel {
+			fmt.Fprintln(r.out, event.Msg)
+		}
+		if event.Level == 0 {
+			r.showFailedLogs(event.Msg)
+		}
+	}
+	r.updateStatus()
+}
+
+func (r *nixRenderer) result(event nixLogEvent) {
+	activity := r.activities[event.ID]
+	if activity == nil {
+		return
+	}
+	switch event.Type {
+	case nixResBuildLogLine, nixResPostBuildLogLine:
+		line, _ := event.Fields[0].(string)
+		prefixed := storePathName(activity.path) + "> " + line
+		if r.verbose {
+			fmt.Fprintln(r.out, prefixed)
+		} else {
+			fmt.Fprintln(r.collapsed, prefixed)
+		}
+		activity.logs = append(activity.logs, line)
+		if len(activity.logs) > nixLogTail {
+			activity.logs = activity.logs[1:]
+		}
+	case nixResSetPhase:
+		activity.phase, _ = event.Fields[0].(string)
+	case nixResProgress:
+		progress := make([]int64, 4)
+		for i := range min(len(event.Fields), 4) {
+			if n, ok := event.Fields[i].(float64); ok {
+				progress[i] = int64(n)
+			}
+		}
+		switch activity.kind {
+		case nixActBuilds:
+			r.builds = progress
+		case nixActCopyPaths:
+			r.copies = progress
+		}
+	}
+}
+
+// Show the collapsed log tails of the derivations an error names, unless
+// nix's message has them already
+func (r *nixRenderer) showFailedLogs(msg string) {
+	if r.verbose || strings.Contains(msg, "log lines") {
+		return
+	}
+	for _, path := range nixDrvPattern.FindAllString(msg, -1) {
+		logs := r.finished[path]
+		for _, activity := range r.activities {
+			if activity.path == path {
+				logs = activity.logs
+			}
+		}
+		if len(logs) == 0 {
+			continue
+		}
+		fmt.Fprintln(r.out, tr("NixLogTail", "Name", storePathName(path), "Count", len(logs)))
+		for _, line := range logs {
+			fmt.Fprintln(r.out, storePathName(path)+"> "+line)
+		}
+	}
+}
+
+// Tell the status line what is going on: the running builds and their
+// phases, downloads, and the progress of both as a bar
+func (r *nixRenderer) updateStatus() {
+	var building, downloading []string
+	for _, activity := range r.activities {
+		switch activity.kind {
+		case nixActBuild:
+			name := storePathName(activity.path)
+			if activity.phase != "" {
+				name += " (" + activity.phase + ")"
+			}
+			building = append(building, name)
+		case nixActSubstitute, nixActCopyPath:
+			downloading = append(downloading, storePathName(activity.path))
+		}
+	}
+	var parts []string
+	if len(r.copies) == 4 && r.copies[1] > 0 {
+		parts = append(parts, tr("NixDownloaded", "Done", r.copies[0], "Expected", r.copies[1]))
+	}
+	if len(r.builds) == 4 && r.builds[1] > 0 {
+		parts = append(parts, tr("NixBuilt", "Done", r.builds[0], "Expected", r.builds[1]))
+	}
+	switch {
+	case len(building) == 1:
+		parts = append(parts, tr("NixBuilding", "Name", building[0]))
+	case len(building) > 1:
+		parts = append(parts, tr("NixBuildingMany", "Count", len(building), "Name", building[0]))
+	case len(downloading) > 0:
+		parts = append(parts, tr("NixDownloading", "Name", downloading[0]))
+	}
+	done, expected := int64(0), int64(0)
+	for _, progress := range [][]int64{r.builds, r.copies} {
+		if len(progress) == 4 {
+			done += progress[0]
+			expected += progress[1]
+		}
+	}
+	if expected > 0 {
+		parts = append([]string{progressBar(done, expected, 12)}, parts...)
+	}
+	draw := time.Since(r.lastDrawn) > 100*time.Millisecond
+	if draw {
+		r.lastDrawn = time.Now()
+	}
+	r.status.setDetail(strings.Join(parts, " · "), draw)
+}
+
+// A bar of width characters filled to done/expected
+func progressBar(done, expected int64, width int) string {
+	filled := int(min(done, expected) * int64(width) / expected)
+	return "[" + strings.Repeat("█", filled) + strings.Repeat("░", width-filled) + "]"
+}
+
+// What a rendered build did: derivations built, and store paths downloaded
+// from a binary cache instead
+type nixBuildSummary struct {
+	Built      int64
+	Downloaded int64
+}
+
+// Run nix build with its log rendered on the status line, or, without one,
+// as nix prints it. The summary is nil without a status line.
+func runNixBuild(status *buildStatus, args ...string) (*nixBuildSummary, error) {
+	if status == nil {
+		return nil, runNix(args...)
+	}
+	stdout := newRedactingWriter(logged(withBuildStatus(os.Stdout)))
+	out := newRedactingWriter(logged(withBuildStatus(stderrOutput())))
+	collapsed := newRedactingWriter(logged(io.Discard))
+	defer stdout.Flush()
+	defer out.Flush()
+	defer collapsed.Flush()
+
+	nixArgs := append([]string{args[0], "--log-format", "internal-json"}, args[1:]...)
+	cmd := exec.Command("nix", withNixGlobalArgs(nixArgs)...)
+	cmd.Stdout = stdout
+	cmd.Stdin = os.Stdin
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	logCommand(cmd)
+	step := startStep("nix " + strings.Join(args, " "))
+	if err := cmd.Start(); err != nil {
+		step.finish(err)
+		return nil, err
+	}
+	renderer := &nixRenderer{
+		status:     status,
+		out:        out,
+		collapsed:  collapsed,
+		verbose:    verbosity >= levelCommand,
+		activities: map[int64]*nixActivity{},
+		finished:   map[string][]string{},
+	}
+	scanner := bufio.NewScanner(stderr)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		renderer.handle(scanner.Text())
+	}
+	err = cmd.Wait()
+	step.finish(err)
+	summary := &nixBuildSummary{}
+	if len(renderer.builds) == 4 {
+		summary.Built = renderer.builds[0]
+	}
+	if len(renderer.copies) == 4 {
+		summary.Downloaded = renderer.copies[0]
+	}
+	return summary, err
+}
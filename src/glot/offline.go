@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// Whether glot runs with --offline: nix uses only what the store and its
+// caches have, language tools don't reach for their registries, and
+// commands that exist to download refuse to run
+var offlineMode bool
+
+// Environment of language tools for working without the network
+var offlineEnv = map[string]string{
+	"CARGO_NET_OFFLINE":  "true",
+	"GOPROXY":            "off",
+	"npm_config_offline": "true",
+	"PIP_NO_INDEX":       "1",
+	"UV_OFFLINE":         "1",
+}
+
+// Apply --offline to nix and, through the environment, to language tools
+func applyOfflineMode(cmd *cobra.Command) {
+	offlineMode, _ = cmd.Flags().GetBool("offline")
+	if !offlineMode {
+		return
+	}
+	// No substituters, and fetched flake inputs and tarballs count as fresh
+	nixGlobalArgs = append(nixGlobalArgs, "--offline")
+	for _, name := range sortedKeys(offlineEnv) {
+		os.Setenv(name, offlineEnv[name])
+	}
+}
+
+// Fail a command that needs the network, what it is for, under --offline
+func checkOnline(command string) error {
+	if offlineMode {
+		return fmt.Errorf("%s", tr("OfflineRefused", "Command", command))
+	}
+	return nil
+}
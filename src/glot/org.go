@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/BurntSushi/toml"
+)
+
+// userConfig is glot's configuration of the user, in ~/.config/glot/config.toml
+type userConfig struct {
+	// Flake of the organization's defaults for glot new, e.g. github:acme/glot-defaults
+	Org string `toml:"org"`
+}
+
+func userConfigFile() string {
+	return filepath.Join(configHome(), "glot", "config.toml")
+}
+
+// Load the user's configuration, an empty one if there is none
+func loadUserConfig() (*userConfig, error) {
+	var config userConfig
+	path := userConfigFile()
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return &config, nil
+	}
+	if _, err := toml.DecodeFile(path, &config); err != nil {
+		return &config, fmt.Errorf("%s", tr("ConfigInvalid", "File", path, "Error", err))
+	}
+	return &config, nil
+}
+
+// Manifest at the root of an organization's defaults flake
+const orgManifest = "org.nix"
+
+// orgDefaults are the organization's overrides of every generated project:
+// its features, files replacing the template's (a license, lint presets),
+// and files appended to (CI settings, the glot.toml of its cache)
+type orgDefaults struct {
+	Name     string            `json:"name"`
+	Features []string          `json:"features"`
+	Files    map[string]string `json:"files"`
+	Append   map[string]string `json:"append"`
+	// Files and appends for the templates of a language only, by language
+	Languages map[string]struct {
+		Files  map[string]string `json:"files"`
+		Append map[string]string `json:"append"`
+	} `json:"languages"`
+	Ref  string `json:"-"`
+	Root string `json:"-"` // The flake's source in the store
+}
+
+// Normalizes an imported org.nix, mapping file sources to paths on disk
+const orgManifestExpr = `o: let paths = builtins.mapAttrs (_: toString); in { name = o.name or ""; features = o.features or [ ]; ` +
+	`files = paths (o.files or { }); append = paths (o.append or { }); ` +
+	`languages = builtins.mapAttrs (_: l: { files = paths (l.files or { }); append = paths (l.append or { }); }) (o.languages or { }); }`
+
+// Fetch and evaluate the organization's defaults flake
+func loadOrgDefaults(ref string) (*orgDefaults, error) {
+	out, err := nixOutput("flake", "metadata", "--json", ref)
+	if err != nil {
+		return nil, fmt.Errorf("%s", tr("OrgFetchFailed", "Ref", ref, "Error", err))
+	}
+	var metadata struct {
+		Path string `json:"path"`
+	}
+	if err := json.Unmarshal(out, &metadata); err != nil || metadata.Path == "" {
+		return nil, fmt.Errorf("%s", tr("OrgNotFound", "Ref", ref))
+	}
+	manifest := filepath.Join(metadata.Path, orgManifest)
+	if _, err := os.Stat(manifest); err != nil {
+		return nil, fmt.Errorf("%s", tr("OrgNoManifest", "Ref", ref, "Manifest", orgManifest))
+	}
+	out, err = nixOutput("eval", "--json", "--file", manifest, "--apply", orgManifestExpr)
+	if err != nil {
+		return nil, fmt.Errorf("%s", tr("OrgManifestEval", "Manifest", orgManifest, "Ref", ref, "Error", err))
+	}
+	var org orgDefaults
+	if err := json.Unmarshal(out, &org); err != nil {
+		return nil, fmt.Errorf("%s", tr("OrgManifestInvalid", "Manifest", orgManifest, "Ref", ref, "Error", err))
+	}
+	org.Ref, org.Root = ref, metadata.Path
+	if org.Name == "" {
+		org.Name = ref
+	}
+	return &org, nil
+}
+
+// The features of a project: the organization's first, then those asked for
+func (o *orgDefaults) features(requested []string) []string {
+	features := append([]string{}, o.Features...)
+	for _, name := range requested {
+		if !slices.Contains(features, name) {
+			features = append(features, name)
+		}
+	}
+	return features
+}
+
+// The organization's files for a template of language, as a feature applied
+// after the template's, replacing its files
+func (o *orgDefaults) feature(language string) *templateFeature {
+	feature := &templateFeature{Name: o.Name + " defaults", Files: map[string]string{}, Append: map[string]string{}, overwrite: true}
+	for dest, source := range o.Files {
+		feature.Files[dest] = source
+	}
+	for dest, source := range o.Append {
+		feature.Append[dest] = source
+	}
+	if overrides, ok := o.Languages[language]; ok {
+		for dest, source := range overrides.Files {
+			feature.Files[dest] = source
+		}
+		for dest, source := range overrides.Append {
+			feature.Append[dest] = source
+		}
+	}
+	return feature
+}
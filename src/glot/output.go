@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// outputEvent is one line of `--json` output
+type outputEvent struct {
+	Command    string    `json:"command"`
+	Step       string    `json:"step,omitempty"`
+	Status     string    `json:"status"` // success, info, warning or error (messages); ok or failed (finished steps)
+	Message    string    `json:"message,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Time       time.Time `json:"time"`
+	DurationMs *int64    `json:"duration_ms,omitempty"`
+}
+
+var (
+	// Where --json events go, the real stdout; nil unless --json is given
+	jsonOut     io.Writer
+	jsonCommand string
+)
+
+func emitJSON(event outputEvent) {
+	event.Command = jsonCommand
+	line, _ := json.Marshal(event)
+	fmt.Fprintf(jsonOut, "%s\n", line)
+}
+
+// Report a message of the success/info/warning/error helpers as an event of
+// the innermost running step. Returns false, leaving the message to the
+// helper, unless --json is given.
+func emitMessage(status, msg string) bool {
+	if jsonOut == nil {
+		return false
+	}
+	progressMu.Lock()
+	defer progressMu.Unlock()
+	event := outputEvent{Status: status, Message: outputRedactor.redactString(msg), Time: time.Now()}
+	if len(progressSteps) > 0 && progressSteps[len(progressSteps)-1] != commandStep {
+		event.Step = progressSteps[len(progressSteps)-1].name
+	}
+	emitJSON(event)
+	return true
+}
+
+// Switch to JSON events if requested: stdout then carries only events, and
+// everything else written to stdout goes to stderr
+func applyJSONOutput(cmd *cobra.Command) error {
+	if enabled, _ := cmd.Flags().GetBool("json"); !enabled {
+		return nil
+	}
+	if progressOut != nil {
+		return fmt.Errorf("%s", tr("LoggingJSONProgress"))
+	}
+	jsonOut = os.Stdout
+	jsonCommand = cmd.CommandPath()
+	os.Stdout = os.Stderr
+	// Errors are reported as events; keep cobra's text out of the way
+	cmd.Root().SilenceErrors = true
+	cmd.Root().SilenceUsage = true
+	commandStep = startStep(jsonCommand)
+	return nil
+}
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Link to the script streaming the image, built from the flake's image
+	imageLink = filepath.Join(stateDir, "image")
+	// Layers of the last analyzed image, to tell which layers changed
+	imageLayersFile = filepath.Join(stateDir, "image-layers.json")
+	// The configuration streamLayeredImage's script streams the image from
+	imageConfPattern = regexp.MustCompile(`/nix/store/[0-9a-z]{32}-[^\s"']*-conf\.json`)
+)
+
+// imageConf is the part of a streamLayeredImage configuration glot reads
+type imageConf struct {
+	RepoTag string `json:"repo_tag"`
+	Config  struct {
+		Entrypoint []string `json:"Entrypoint"`
+	} `json:"config"`
+	StoreLayers        [][]string `json:"store_layers"`
+	CustomisationLayer string     `json:"customisation_layer"`
+}
+
+// Build the flake's image, returning the absolute path of its stream script
+func buildImage() (string, error) {
+	return buildImageOutput("image")
+}
+
+// Build a flake output that is an image stream script, linked to imageLink
+func buildImageOutput(output string) (string, error) {
+	if err := ensureStateDir(stateDir); err != nil {
+		return "", err
+	}
+	link, err := filepath.Abs(imageLink)
+	if err != nil {
+		return "", err
+	}
+	if err := runNix("build", ".#"+output, "--out-link", link); err != nil {
+		return "", fmt.Errorf("%s", tr("PackageOutputBuildFailed", "Output", output, "Error", err))
+	}
+	return link, nil
+}
+
+// Stream the image into a file, or into docker or podman
+func streamImage(script, output string) error {
+	streamStderr := newRedactingWriter(logged(stderrOutput()))
+	defer streamStderr.Flush()
+	stream := exec.Command(script)
+	stream.Stderr = streamStderr
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		stream.Stdout = f
+		logCommand(stream)
+		return stream.Run()
+	}
+
+	runtime, err := containerRuntime()
+	if err != nil {
+		return fmt.Errorf("%s", tr("PackageNoRuntime", "Error", err))
+	}
+	load := exec.Command(runtime, "load")
+	pipe, err := stream.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	loadStdout := newRedactingWriter(logged(os.Stdout))
+	loadStderr := newRedactingWriter(logged(stderrOutput()))
+	defer loadStdout.Flush()
+	defer loadStderr.Flush()
+	load.Stdin = pipe
+	load.Stdout = loadStdout
+	load.Stderr = loadStderr
+	logCommand(stream)
+	logCommand(load)
+	if err := load.Start(); err != nil {
+		return err
+	}
+	streamErr := stream.Run()
+	loadErr := load.Wait()
+	if streamErr != nil {
+		return streamErr
+	}
+	return loadErr
+}
+
+// Read the configuration a stream script was built with
+func readImageConf(script string) (*imageConf, error) {
+	content, err := os.ReadFile(script)
+	if err != nil {
+		return nil, err
+	}
+	path := imageConfPattern.Find(content)
+	if path == nil {
+		return nil, fmt.Errorf("%s", tr("PackageNotImageScript", "Script", script))
+	}
+	confContent, err := os.ReadFile(string(path))
+	if err != nil {
+		return nil, err
+	}
+	var conf imageConf
+	if err := json.Unmarshal(confContent, &conf); err != nil {
+		return nil, fmt.Errorf("unexpected image configuration: %w", err)
+	}
+	return &conf, nil
+}
+
+// NAR sizes of store paths. nix path-info --json prints a list of objects
+// before nix 2.19 and an object keyed by path since.
+func storePathSizes(paths []string) (map[string]int64, error) {
+	out, err := nixOutput(append([]string{"path-info", "--json"}, paths...)...)
+	if err != nil {
+		return nil, err
+	}
+	type pathInfo struct {
+		Path    string `json:"path"`
+		NarSize int64  `json:"narSize"`
+	}
+	sizes := map[string]int64{}
+	var byPath map[string]pathInfo
+	if json.Unmarshal(out, &byPath) == nil {
+		for path, info := range byPath {
+			sizes[path] = info.NarSize
+		}
+		return sizes, nil
+	}
+	var list []pathInfo
+	if err := json.Unmarshal(out, &list); err != nil {
+		return nil, fmt.Errorf("unexpected path-info output: %w", err)
+	}
+	for _, info := range list {
+		sizes[info.Path] = info.NarSize
+	}
+	return sizes, nil
+}
+
+// Package name of a store path, without hash and version
+func storePathPackage(path string) string {
+	_, pkg, _ := strings.Cut(filepath.Base(path), "-")
+	name, _ := splitPackageName(pkg)
+	return name
+}
+
+// Report the image's layers: their size, what they contain and what makes
+// them change, and which changed since the last analysis
+func analyzeImage(script string) error {
+	conf, err := readImageConf(script)
+	if err != nil {
+		return err
+	}
+	var paths []string
+	for _, layer := range conf.StoreLayers {
+		paths = append(paths, layer...)
+	}
+	sizes, err := storePathSizes(paths)
+	if err != nil {
+		return err
+	}
+	// The project's own build is the part that changes with the source
+	var projectPaths []string
+	if out, err := nixOutput("build", ".#release", "--no-link", "--print-out-paths"); err == nil {
+		projectPaths = strings.Fields(string(out))
+	}
+
+	var previous [][]string
+	if content, err := os.ReadFile(imageLayersFile); err == nil {
+		json.Unmarshal(content, &previous)
+	}
+	unchanged := map[string]bool{}
+	for _, layer := range previous {
+		unchanged[strings.Join(layer, " ")] = true
+	}
+
+	var total, sourceSize, changedSize int64
+	changedLayers := 0
+	fmt.Println()
+	fmt.Println(plain("📦 " + tr("PackageLayersHeader", "Tag", conf.RepoTag, "Layers", len(conf.StoreLayers)+1)))
+	fmt.Printf("  %3s  %9s  %-24s  %s\n", "#", tr("PackageLayerSize"), tr("PackageLayerCause"), tr("PackageLayerContents"))
+	for i, layer := range conf.StoreLayers {
+		var size int64
+		names := make([]string, 0, len(layer))
+		cause := ""
+		for _, path := range layer {
+			size += sizes[path]
+			_, name, _ := strings.Cut(filepath.Base(path), "-")
+			names = append(names, name)
+			for _, project := range projectPaths {
+				if path == project {
+					cause = tr("PackageCauseSource")
+				}
+			}
+		}
+		if cause == "" {
+			if len(layer) == 1 {
+				cause = tr("PackageCauseUpdates", "Package", storePathPackage(layer[0]))
+			} else {
+				cause = trn("PackageCauseManyUpdates", len(layer))
+			}
+		} else {
+			sourceSize += size
+		}
+		total += size
+		contents := strings.Join(names, ", ")
+		if len(contents) > 60 {
+			contents = contents[:57] + "..."
+		}
+		marker := ""
+		if previous != nil && !unchanged[strings.Join(layer, " ")] {
+			marker = "  " + tr("PackageLayerChanged")
+			changedLayers++
+			changedSize += size
+		}
+		fmt.Printf("  %3d  %9s  %-24s  %s%s\n", i+1, formatSize(size), cause, contents, marker)
+	}
+	fmt.Printf("  %3d  %9s  %-24s  %s\n", len(conf.StoreLayers)+1, "", tr("PackageConfigLayer"), tr("PackageCustomisationLayer"))
+	fmt.Println()
+	info(tr("PackageTotal", "Total", formatSize(total), "Source", formatSize(sourceSize)))
+	if previous != nil {
+		info(trn("PackageLayersChanged", changedLayers, "Size", formatSize(changedSize)))
+	}
+	if len(conf.StoreLayers) > 0 && len(conf.StoreLayers[len(conf.StoreLayers)-1]) > 1 {
+		warning(tr("PackageMergedLayer"))
+	}
+
+	content, _ := json.Marshal(conf.StoreLayers)
+	if err := os.WriteFile(imageLayersFile, content, 0644); err != nil {
+		warning(tr("PackageRecordFailed", "Error", err))
+	}
+	return nil
+}
+
+func newPackageCmd() *cobra.Command {
+	packageCmd := &cobra.Command{
+		Use:   "package",
+		Short: "Build the container image, a Linux package or a Lambda archive",
+		Long: "Build the flake's image output, a layered image of the release build with a layer per store path, " +
+			"and load it into docker or podman. Dependency layers are reused, so source-only changes produce one small new layer. " +
+			"With --verify-parity, also run the project's parity test in the dev shell and in the image. " +
+			"With --format appimage, deb or rpm, write a package of the release build and its closure to " + releaseDir + "/ instead, " +
+			"for users without nix, with the metadata of [package] in glot.toml. " +
+			"With --lambda, write the function.zip of the flake's lambda package to " + releaseDir + "/, to deploy to AWS Lambda.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkNix(); err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			output, _ := cmd.Flags().GetString("output")
+			analyze, _ := cmd.Flags().GetBool("analyze")
+			parity, _ := cmd.Flags().GetBool("verify-parity")
+			format, _ := cmd.Flags().GetString("format")
+			if lambda, _ := cmd.Flags().GetBool("lambda"); lambda {
+				if parity || analyze || cmd.Flags().Changed("format") {
+					err := fmt.Errorf("%s", tr("PackageLambdaExclusive"))
+					errorMsg(err.Error())
+					return err
+				}
+				info(tr("PackageBuildingLambda"))
+				path, err := packageLambda(output)
+				if err != nil {
+					errorMsg(err.Error())
+					return err
+				}
+				success(tr("PackageLambdaWritten", "Path", path))
+				return nil
+			}
+			if parity && (output != "" || analyze) {
+				err := fmt.Errorf("%s", tr("PackageParityExclusive"))
+				errorMsg(err.Error())
+				return err
+			}
+			if format != "image" {
+				if !slices.Contains(linuxPackageFormats, format) {
+					err := fmt.Errorf("%s", tr("PackageUnknownFormat", "Format", format, "Formats", strings.Join(linuxPackageFormats, ", ")))
+					errorMsg(err.Error())
+					return err
+				}
+				if parity || analyze {
+					err := fmt.Errorf("%s", tr("PackageImageOnly", "Format", format))
+					errorMsg(err.Error())
+					return err
+				}
+				info(tr("PackageBuildingFormat", "Format", format))
+				path, err := buildLinuxPackage(format, output)
+				if err != nil {
+					errorMsg(err.Error())
+					return err
+				}
+				success(tr("PackageWritten", "Path", path))
+				return nil
+			}
+
+			info(tr("PackageBuildingImage"))
+			script, err := buildImage()
+			if err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			if analyze {
+				if err := analyzeImage(script); err != nil {
+					errorMsg(tr("PackageAnalyzeFailed", "Error", err))
+					return err
+				}
+				return nil
+			}
+			if err := streamImage(script, output); err != nil {
+				errorMsg(tr("DeployImageWriteFailed", "Error", err))
+				return err
+			}
+			if output != "" {
+				success(tr("PackageImageWritten", "Output", output))
+				return nil
+			}
+			success(tr("PackageImageLoaded"))
+			if parity {
+				runtime, _ := containerRuntime()
+				conf, err := readImageConf(script)
+				if err == nil {
+					err = verifyParity(runtime, conf)
+				}
+				if err != nil {
+					errorMsg(err.Error())
+					return err
+				}
+				success(tr("PackageParityPassed"))
+			}
+			return nil
+		},
+	}
+	packageCmd.Flags().StringP("output", "o", "", "Write the image tarball, or the package of --format, to a file")
+	packageCmd.Flags().String("format", "image", "What to build: image, appimage, deb or rpm")
+	packageCmd.Flags().Bool("verify-parity", false, "Run package.parity-test of glot.toml in the dev shell and in the image, failing if only the image fails")
+	packageCmd.Flags().Bool("lambda", false, "Write the deployable zip of the flake's lambda package instead, for AWS Lambda")
+	packageCmd.Flags().Bool("analyze", false, "Report the image's layers, their sizes and what invalidates them, instead of loading it")
+	return packageCmd
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// packageConfig is the [package] section of glot.toml
+type packageConfig struct {
+	// Command testing the packaged program, run by --verify-parity in the
+	// dev shell and in the container with the release build on PATH
+	ParityTest string `toml:"parity-test"`
+	// nixpkgs packages the parity test needs besides the image
+	ParityTools []string `toml:"parity-tools"`
+	// Metadata of the deb and rpm packages of --format
+	Description string `toml:"description"`
+	Maintainer  string `toml:"maintainer"` // Name <email>
+	License     string `toml:"license"`
+	Homepage    string `toml:"homepage"`
+}
+
+// Tools of parity tests unless glot.toml lists others
+var defaultParityTools = []string{"bash", "coreutils"}
+
+// The container runtime to load and run images with
+func containerRuntime() (string, error) {
+	for _, runtime := range []string{"docker", "podman"} {
+		if _, err := exec.LookPath(runtime); err == nil {
+			return runtime, nil
+		}
+	}
+	return "", fmt.Errorf("%s", tr("ParityNoRuntime"))
+}
+
+// Build nixpkgs packages, returning their store paths and their closure
+func packageClosure(packages []string) (roots, closure []string, err error) {
+	installables := make([]string, len(packages))
+	for i, pkg := range packages {
+		installables[i] = "nixpkgs#" + pkg
+	}
+	out, err := nixOutput(append([]string{"build", "--no-link", "--print-out-paths"}, installables...)...)
+	if err != nil {
+		return nil, nil, err
+	}
+	roots = strings.Fields(string(out))
+	out, err = nixOutput(append([]string{"path-info", "--recursive"}, roots...)...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return roots, strings.Fields(string(out)), nil
+}
+
+// Run the project's parity test in the dev shell and in the loaded image.
+// The container has only the image's closure and the parity tools, so a
+// test passing in the dev shell but failing there found something the
+// package needs at runtime but doesn't contain.
+func verifyParity(runtime string, conf *imageConf) error {
+	config := currentConfig.Package
+	if config.ParityTest == "" {
+		return fmt.Errorf("%s", tr("ParityNoTest", "File", configFile))
+	}
+	if len(conf.Config.Entrypoint) == 0 {
+		return fmt.Errorf("%s", tr("ParityNoEntrypoint"))
+	}
+	// The release build's bin directory, where the entrypoint is
+	releaseBin := filepath.Dir(conf.Config.Entrypoint[0])
+
+	info(tr("ParityDevShell"))
+	devErr := runInDevShell("bash", "-c", `PATH="$1:$PATH" && shift && eval "$1"`, "glot-parity", releaseBin, config.ParityTest)
+	if devErr != nil {
+		return fmt.Errorf("%s", tr("ParityDevShellFailed", "Error", devErr))
+	}
+
+	tools := config.ParityTools
+	if tools == nil {
+		tools = defaultParityTools
+	}
+	toolPaths, closure, err := packageClosure(tools)
+	if err != nil {
+		return fmt.Errorf("%s", tr("ParityToolsFailed", "Error", err))
+	}
+	path := []string{releaseBin}
+	shell := ""
+	for _, tool := range toolPaths {
+		path = append(path, tool+"/bin")
+		if _, err := os.Stat(filepath.Join(tool, "bin", "bash")); err == nil {
+			shell = filepath.Join(tool, "bin", "bash")
+		}
+	}
+	if shell == "" {
+		return fmt.Errorf("%s", tr("ParityNoBash"))
+	}
+	project, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	args := []string{"run", "--rm", "-v", project + ":/src:ro", "-w", "/src", "-e", "PATH=" + strings.Join(path, ":"), "--entrypoint", shell}
+	for _, storePath := range closure {
+		args = append(args, "-v", storePath+":"+storePath+":ro")
+	}
+	args = append(args, conf.RepoTag, "-c", config.ParityTest)
+
+	info(tr("ParityContainer", "Image", conf.RepoTag))
+	run := exec.Command(runtime, args...)
+	stdout := newRedactingWriter(logged(os.Stdout))
+	stderr := newRedactingWriter(logged(stderrOutput()))
+	defer stdout.Flush()
+	defer stderr.Flush()
+	run.Stdout = stdout
+	run.Stderr = stderr
+	logCommand(run)
+	step := startStep("parity test in " + conf.RepoTag)
+	err = run.Run()
+	step.finish(err)
+	if err != nil {
+		return fmt.Errorf("%s", tr("ParityContainerFailed"))
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// Shared so buffered input isn't lost between prompts
+var stdinReader = bufio.NewReader(os.Stdin)
+
+// Ask the user to type a phrase before continuing with a risky action
+func confirmAction(prompt, phrase string) bool {
+	fmt.Print(plain(prompt + "\n" + tr("ConfirmPhrase", "Phrase", phrase)))
+	answer, err := stdinReader.ReadString('\n')
+	if err != nil {
+		return false
+	}
+	return strings.TrimSpace(answer) == phrase
+}
+
+// Ask the user for a value, falling back to def on an empty answer
+func prompt(label, def string) string {
+	if def != "" {
+		fmt.Printf("%s [%s]: ", label, def)
+	} else {
+		fmt.Printf("%s: ", label)
+	}
+	answer, _ := stdinReader.ReadString('\n')
+	if answer = strings.TrimSpace(answer); answer != "" {
+		return answer
+	}
+	return def
+}
+
+// Command that runs the flake app of the same name, e.g. `glot plan` -> `nix run .#plan`
+func newPassthroughCmd(name, short, long string, confirm bool) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   name + " [-- args...]",
+		Short: short,
+		Long:  long,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkNix(); err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			if !flakeHasApp(name) {
+				err := fmt.Errorf("%s", tr("PassthroughNoApp", "App", name))
+				errorMsg(err.Error())
+				return err
+			}
+
+			if confirm {
+				yes, _ := cmd.Flags().GetBool("yes")
+				if !yes && !confirmAction("⚠️  "+tr("PassthroughConfirm", "App", name), name) {
+					warning(tr("PassthroughAborted", "App", name))
+					return fmt.Errorf("%s", tr("PassthroughNotConfirmed", "App", name))
+				}
+			}
+
+			info(tr("PassthroughRunning", "App", name))
+			nixArgs := append([]string{"run", ".#" + name, "--"}, args...)
+			if err := runNix(nixArgs...); err != nil {
+				errorMsg(tr("PassthroughFailed", "App", name))
+				return err
+			}
+			success(tr("PassthroughDone", "App", name))
+			return nil
+		},
+	}
+	if confirm {
+		cmd.Flags().Bool("yes", false, "Skip the confirmation prompt")
+	}
+	return cmd
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Platforms `glot new --platforms` selects, and the nix systems of each
+var templatePlatforms = map[string][]string{
+	"linux":  {"x86_64-linux", "aarch64-linux"},
+	"darwin": {"x86_64-darwin", "aarch64-darwin"},
+}
+
+var (
+	// Template lines between `glot:if <platform>...` and `glot:endif`, usually
+	// in comments, are kept only for the selected platforms
+	platformIf    = regexp.MustCompile(`glot:if((?:\s+[a-z]+)+)\s*$`)
+	platformEndif = regexp.MustCompile(`glot:endif\s*$`)
+	// flake-utils' system loop, narrowed to the selected platforms' systems
+	flakeEachDefaultSystem = regexp.MustCompile(`\beachDefaultSystem\b`)
+)
+
+// Parse --platforms, checking the names. Empty means every platform.
+func parsePlatforms(names []string) ([]string, error) {
+	var platforms []string
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if _, ok := templatePlatforms[name]; !ok {
+			return nil, fmt.Errorf("%s", tr("PlatformUnknown", "Platform", name, "Available", strings.Join(platformNames(), ", ")))
+		}
+		if !slices.Contains(platforms, name) {
+			platforms = append(platforms, name)
+		}
+	}
+	return platforms, nil
+}
+
+func platformNames() []string {
+	names := make([]string, 0, len(templatePlatforms))
+	for name := range templatePlatforms {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Whether a platform is selected; no selection means all
+func platformSelected(platforms []string, platform string) bool {
+	return len(platforms) == 0 || slices.Contains(platforms, platform)
+}
+
+// Remove the conditional blocks of other platforms, and all marker lines
+func renderPlatformBlocks(text string, platforms []string) (string, error) {
+	if !strings.Contains(text, "glot:if") {
+		return text, nil
+	}
+	var out []string
+	inBlock, keep := false, true
+	for i, line := range strings.Split(text, "\n") {
+		if m := platformIf.FindStringSubmatch(line); m != nil {
+			if inBlock {
+				return "", fmt.Errorf("%s", tr("PlatformNestedIf", "Line", i+1))
+			}
+			inBlock, keep = true, false
+			for _, platform := range strings.Fields(m[1]) {
+				if _, ok := templatePlatforms[platform]; !ok {
+					return "", fmt.Errorf("%s", tr("PlatformUnknownInBlock", "Line", i+1, "Platform", platform))
+				}
+				keep = keep || platformSelected(platforms, platform)
+			}
+			continue
+		}
+		if platformEndif.MatchString(line) {
+			if !inBlock {
+				return "", fmt.Errorf("%s", tr("PlatformStrayEndif", "Line", i+1))
+			}
+			inBlock, keep = false, true
+			continue
+		}
+		if keep {
+			out = append(out, line)
+		}
+	}
+	if inBlock {
+		return "", fmt.Errorf("%s", tr("PlatformUnclosedIf"))
+	}
+	return strings.Join(out, "\n"), nil
+}
+
+// Build flake outputs only for the selected platforms' systems
+func gateFlakeSystems(flake string, platforms []string) string {
+	if len(platforms) == 0 || len(platforms) == len(templatePlatforms) {
+		return flake
+	}
+	var systems []string
+	for _, platform := range platformNames() {
+		if platformSelected(platforms, platform) {
+			for _, system := range templatePlatforms[platform] {
+				systems = append(systems, fmt.Sprintf("%q", system))
+			}
+		}
+	}
+	return flakeEachDefaultSystem.ReplaceAllLiteralString(flake, "eachSystem [ "+strings.Join(systems, " ")+" ]")
+}
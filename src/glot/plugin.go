@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// Plugins are executables named glot-<command>, found on PATH or in the dev
+// shell, that run as `glot <command>` when glot has no such command
+const pluginPrefix = "glot-"
+
+// The plugin a command line invokes: the name of an unknown command and the
+// arguments after it, or "" if the command is known
+func pluginInvocation(rootCmd *cobra.Command, args []string) (string, []string) {
+	// Cobra adds these commands only when it executes
+	rootCmd.InitDefaultHelpCmd()
+	rootCmd.InitDefaultCompletionCmd()
+	if len(args) > 0 && (args[0] == cobra.ShellCompRequestCmd || args[0] == cobra.ShellCompNoDescRequestCmd) {
+		return "", nil
+	}
+	if cmd, _, err := rootCmd.Find(args); err == nil || cmd != rootCmd {
+		return "", nil
+	}
+	// The first argument that isn't a global flag or its value
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			return "", nil
+		}
+		if !strings.HasPrefix(arg, "-") {
+			return arg, args[i+1:]
+		}
+		if strings.Contains(arg, "=") {
+			continue
+		}
+		var flag *pflag.Flag
+		if strings.HasPrefix(arg, "--") {
+			flag = rootCmd.PersistentFlags().Lookup(arg[2:])
+		} else {
+			flag = rootCmd.PersistentFlags().ShorthandLookup(arg[len(arg)-1:])
+		}
+		if flag != nil && flag.NoOptDefVal == "" {
+			i++
+		}
+	}
+	return "", nil
+}
+
+// Environment telling a plugin about the project it runs for
+func pluginEnv() []string {
+	env := os.Environ()
+	if executable, err := os.Executable(); err == nil {
+		env = append(env, "GLOT_BIN="+executable)
+	}
+	env = append(env, "GLOT_VERSION="+version)
+	if root, err := os.Getwd(); err == nil {
+		env = append(env, "GLOT_PROJECT_ROOT="+root, "GLOT_STATE_DIR="+filepath.Join(root, stateDir))
+		if _, err := os.Stat(configFile); err == nil {
+			env = append(env, "GLOT_CONFIG="+filepath.Join(root, configFile))
+		}
+	}
+	if language, err := detectLanguage(); err == nil {
+		env = append(env, "GLOT_LANGUAGE="+language.Name())
+	}
+	return env
+}
+
+// Whether the project's dev shell provides a command
+func devShellHas(command string) bool {
+	if _, err := os.Stat("flake.nix"); err != nil {
+		return false
+	}
+	check := exec.Command("nix", withNixGlobalArgs([]string{"develop", "--command", "sh", "-c", `command -v "$0"`, command})...)
+	logCommand(check)
+	return check.Run() == nil
+}
+
+// Add a command for the plugin a command line invokes, if it invokes one,
+// and return the command line to execute. The plugin runs after the
+// pre-run chain (logging, [policy], nix arguments) like the built-in
+// commands; its arguments follow a "--", so flags reach it as they are.
+func addPluginCmd(rootCmd *cobra.Command, args []string) []string {
+	name, pluginArgs := pluginInvocation(rootCmd, args)
+	if name == "" {
+		return args
+	}
+	// A near-miss of a built-in command is a typo, which cobra suggests the
+	// command for, unless there is a plugin of that name on PATH
+	if _, err := exec.LookPath(pluginPrefix + name); err != nil && len(rootCmd.SuggestionsFor(name)) > 0 {
+		return args
+	}
+	rootCmd.AddCommand(&cobra.Command{
+		Use:    name,
+		Short:  "Run the " + pluginPrefix + name + " plugin",
+		Hidden: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runPlugin(cmd, name, args)
+		},
+	})
+	end := len(args) - len(pluginArgs)
+	return append(append(slices.Clone(args[:end]), "--"), pluginArgs...)
+}
+
+// Run glot-<name> with args, from PATH or else the dev shell, and exit with
+// its status
+func runPlugin(cmd *cobra.Command, name string, args []string) error {
+	plugin := pluginPrefix + name
+	if path, err := exec.LookPath(plugin); err == nil {
+		debug("Running plugin " + path)
+		// Replace glot, so the plugin gets signals and the terminal directly
+		err := syscall.Exec(path, append([]string{plugin}, args...), pluginEnv())
+		errorMsg(tr("PluginRunFailed", "Plugin", path, "Error", err))
+		return err
+	}
+	if !devShellHas(plugin) {
+		// As cobra reports a command it doesn't know
+		err := fmt.Errorf("unknown command %q for %q", name, cmd.Root().CommandPath())
+		cmd.SilenceErrors, cmd.SilenceUsage = true, true
+		cmd.PrintErrln(cmd.ErrPrefix(), err.Error())
+		cmd.PrintErrf("Run '%v --help' for usage.\n", cmd.Root().CommandPath())
+		return err
+	}
+	debug("Running plugin " + plugin + " from the dev shell")
+	run := exec.Command("nix", withNixGlobalArgs(append([]string{"develop", "--command", plugin}, args...))...)
+	run.Env = pluginEnv()
+	run.Stdin = os.Stdin
+	run.Stdout = os.Stdout
+	run.Stderr = os.Stderr
+	logCommand(run)
+	if err := run.Run(); err != nil {
+		var exitError *exec.ExitError
+		if errors.As(err, &exitError) {
+			os.Exit(exitError.ExitCode())
+		}
+		errorMsg(tr("PluginRunFailed", "Plugin", plugin, "Error", err))
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// Variable that confirms a policy-protected command without a prompt
+const confirmEnv = "GLOT_CONFIRM"
+
+// policyConfig is the [policy] section of glot.toml: restrictions on
+// commands that reach production, e.g.
+//
+//	[policy.command."deploy"]
+//	allow = ["ci@*", "*@release-builder"]
+//	reason = "Production deploys run in CI"
+//
+//	[policy.command."cache push"]
+//	confirm = "push to the shared cache"
+//
+// Rules are keyed by the command words after glot, optionally followed by
+// flags that must be present for the rule to apply ("package --push").
+type policyConfig struct {
+	Commands map[string]commandPolicy `toml:"command"`
+}
+
+// commandPolicy restricts one command
+type commandPolicy struct {
+	Allow   []string `toml:"allow"`   // user@host glob patterns allowed to run it ("ci" is "ci@*")
+	Confirm string   `toml:"confirm"` // Phrase to type, or to set in $GLOT_CONFIRM
+	Reason  string   `toml:"reason"`  // Shown when the command is refused
+}
+
+// Whether a rule key matches the command path and command line arguments
+func policyMatches(rule, commandPath string, args []string) bool {
+	var words, flags []string
+	for _, field := range strings.Fields(rule) {
+		if strings.HasPrefix(field, "-") {
+			flags = append(flags, field)
+		} else {
+			words = append(words, field)
+		}
+	}
+	if strings.Join(words, " ") != commandPath {
+		return false
+	}
+	for _, flag := range flags {
+		found := false
+		for _, arg := range args {
+			if arg == "--" {
+				break
+			}
+			if arg == flag || strings.HasPrefix(arg, flag+"=") {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// The user@host identity allow patterns are matched against
+func policyIdentity() string {
+	name := os.Getenv("USER")
+	if current, err := user.Current(); err == nil {
+		name = current.Username
+	}
+	host, _ := os.Hostname()
+	return name + "@" + host
+}
+
+func policyAllows(patterns []string, identity string) bool {
+	for _, pattern := range patterns {
+		if !strings.Contains(pattern, "@") {
+			pattern += "@*"
+		}
+		if ok, _ := path.Match(pattern, identity); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Whether stdin is an interactive terminal that can answer a prompt
+func stdinIsTerminal() bool {
+	stat, err := os.Stdin.Stat()
+	return err == nil && stat.Mode()&os.ModeCharDevice != 0
+}
+
+// Refuse the command unless every matching policy rule is satisfied
+func enforceCommandPolicy(cmd *cobra.Command) error {
+	rules := currentConfig.Policy.Commands
+	if len(rules) == 0 {
+		return nil
+	}
+	commandPath := strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()+" ")
+	args := os.Args[1:]
+
+	keys := make([]string, 0, len(rules))
+	for key := range rules {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if !policyMatches(key, commandPath, args) {
+			continue
+		}
+		rule := rules[key]
+		refuse := func(why string) error {
+			if rule.Reason != "" {
+				why += " - " + rule.Reason
+			}
+			return fmt.Errorf("%s", tr("PolicyRefused", "Command", key, "File", configFile, "Reason", why))
+		}
+
+		if len(rule.Allow) > 0 {
+			identity := policyIdentity()
+			if !policyAllows(rule.Allow, identity) {
+				return refuse(tr("PolicyNotAllowed", "Identity", identity))
+			}
+		}
+		if rule.Confirm != "" {
+			switch {
+			case os.Getenv(confirmEnv) == rule.Confirm:
+			case os.Getenv(confirmEnv) != "":
+				return refuse(tr("PolicyPhraseMismatch", "Var", confirmEnv))
+			case !stdinIsTerminal():
+				return refuse(tr("PolicyConfirmationRequired", "Var", confirmEnv, "Phrase", rule.Confirm))
+			case !confirmAction("⚠️  "+tr("PolicyConfirm", "File", configFile, "Command", key), rule.Confirm):
+				return refuse(tr("PolicyNotConfirmed"))
+			}
+		}
+	}
+	return nil
+}
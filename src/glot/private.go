@@ -0,0 +1,270 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// privateConfig is the [private] section of glot.toml: credentials for
+// private git hosts, Go modules and cargo registries, e.g.
+//
+//	[private]
+//	goprivate = ["github.com/acme/*"]
+//
+//	[[private.host]]
+//	host = "github.com"
+//	auth = "token"
+//	token-env = "GITHUB_TOKEN"
+//
+//	[private.cargo-registries]
+//	acme = { index = "sparse+https://cargo.acme.dev/index/", token-env = "ACME_CARGO_TOKEN" }
+type privateConfig struct {
+	GoPrivate       []string                 `toml:"goprivate"` // Module patterns fetched directly instead of through the proxy
+	Hosts           []privateHost            `toml:"host"`
+	CargoRegistries map[string]cargoRegistry `toml:"cargo-registries"`
+}
+
+// privateHost is a git host that needs credentials
+type privateHost struct {
+	Host     string `toml:"host"`
+	Auth     string `toml:"auth"`      // token, netrc or ssh
+	TokenEnv string `toml:"token-env"` // Variable holding the token (auth = "token")
+	Netrc    string `toml:"netrc"`     // netrc file (auth = "netrc", default ~/.netrc)
+	Probe    string `toml:"probe"`     // Repository `glot private check` tries to list
+}
+
+// cargoRegistry is an alternative cargo registry
+type cargoRegistry struct {
+	Index    string `toml:"index"`
+	TokenEnv string `toml:"token-env"`
+}
+
+// Variables holding private tokens, which are masked in output
+func (c privateConfig) tokenEnvs() []string {
+	var names []string
+	for _, host := range c.Hosts {
+		if host.TokenEnv != "" {
+			names = append(names, host.TokenEnv)
+		}
+	}
+	for _, registry := range c.CargoRegistries {
+		if registry.TokenEnv != "" {
+			names = append(names, registry.TokenEnv)
+		}
+	}
+	return names
+}
+
+func expandHome(path string) string {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		home, _ := os.UserHomeDir()
+		return filepath.Join(home, rest)
+	}
+	return path
+}
+
+func (h privateHost) netrcFile() string {
+	if h.Netrc != "" {
+		return expandHome(h.Netrc)
+	}
+	return expandHome("~/.netrc")
+}
+
+// Check that the credentials a host needs are available
+func (h privateHost) problem() string {
+	switch h.Auth {
+	case "token":
+		if h.TokenEnv == "" {
+			return tr("PrivateTokenEnvRequired", "Host", h.Host)
+		}
+		if os.Getenv(h.TokenEnv) == "" {
+			return tr("PrivateTokenUnset", "Host", h.Host, "Var", h.TokenEnv)
+		}
+	case "netrc":
+		content, err := os.ReadFile(h.netrcFile())
+		if err != nil {
+			return tr("PrivateNetrcUnreadable", "Host", h.Host, "File", h.netrcFile())
+		}
+		if !strings.Contains(" "+strings.Join(strings.Fields(string(content)), " ")+" ", " machine "+h.Host+" ") {
+			return tr("PrivateNetrcNoEntry", "Host", h.Host, "File", h.netrcFile())
+		}
+	case "ssh":
+		if os.Getenv("SSH_AUTH_SOCK") == "" {
+			if keys, _ := filepath.Glob(expandHome("~/.ssh/id_*")); len(keys) == 0 {
+				return tr("PrivateNoSSH", "Host", h.Host)
+			}
+		}
+	default:
+		return tr("PrivateUnknownAuth", "Host", h.Host, "Auth", h.Auth)
+	}
+	return ""
+}
+
+// Validate all private dependency credentials
+func privateProblems(config privateConfig) []string {
+	var problems []string
+	for _, host := range config.Hosts {
+		if host.Host == "" {
+			problems = append(problems, tr("PrivateNoHost"))
+		} else if problem := host.problem(); problem != "" {
+			problems = append(problems, problem)
+		}
+	}
+	var names []string
+	for name := range config.CargoRegistries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		registry := config.CargoRegistries[name]
+		if registry.Index == "" {
+			problems = append(problems, tr("PrivateNoIndex", "Name", name))
+		}
+		if registry.TokenEnv != "" && os.Getenv(registry.TokenEnv) == "" {
+			problems = append(problems, tr("PrivateRegistryTokenUnset", "Name", name, "Var", registry.TokenEnv))
+		}
+	}
+	return problems
+}
+
+// Append git configuration through the environment, leaving any existing
+// GIT_CONFIG_* entries in place
+func addGitConfig(key, value string) {
+	count, _ := strconv.Atoi(os.Getenv("GIT_CONFIG_COUNT"))
+	os.Setenv(fmt.Sprintf("GIT_CONFIG_KEY_%d", count), key)
+	os.Setenv(fmt.Sprintf("GIT_CONFIG_VALUE_%d", count), value)
+	os.Setenv("GIT_CONFIG_COUNT", strconv.Itoa(count+1))
+}
+
+// Append a setting to NIX_CONFIG, which nix reads on top of nix.conf without
+// putting secrets on the command line
+func addNixConfig(line string) {
+	if existing := os.Getenv("NIX_CONFIG"); existing != "" {
+		line = existing + "\n" + line
+	}
+	os.Setenv("NIX_CONFIG", line)
+}
+
+// Warn about missing credentials and forward the configured ones to nix
+// fetchers and, through the environment, to git, go and cargo in the dev shell
+func applyPrivateDeps(cmd *cobra.Command) {
+	config := currentConfig.Private
+	if len(config.GoPrivate) == 0 && len(config.Hosts) == 0 && len(config.CargoRegistries) == 0 {
+		return
+	}
+	// `glot private check` reports problems itself
+	if cmd.CommandPath() != "glot private check" {
+		for _, problem := range privateProblems(config) {
+			warning(tr("PrivateProblem", "Problem", problem))
+		}
+	}
+
+	// Fail instead of hanging on a credential prompt
+	os.Setenv("GIT_TERMINAL_PROMPT", "0")
+	if len(config.GoPrivate) > 0 {
+		patterns := config.GoPrivate
+		if existing := os.Getenv("GOPRIVATE"); existing != "" {
+			patterns = append([]string{existing}, patterns...)
+		}
+		os.Setenv("GOPRIVATE", strings.Join(patterns, ","))
+	}
+
+	var accessTokens []string
+	netrcSet := false
+	for _, host := range config.Hosts {
+		if host.Host == "" {
+			continue
+		}
+		switch host.Auth {
+		case "token":
+			token := os.Getenv(host.TokenEnv)
+			if token == "" {
+				continue
+			}
+			accessTokens = append(accessTokens, host.Host+"="+token)
+			addGitConfig(fmt.Sprintf("url.https://oauth2:%s@%s/.insteadOf", token, host.Host), fmt.Sprintf("https://%s/", host.Host))
+		case "netrc":
+			// nix and go read a single netrc file
+			if !netrcSet {
+				addNixConfig("netrc-file = " + host.netrcFile())
+				os.Setenv("NETRC", host.netrcFile())
+				netrcSet = true
+			}
+		case "ssh":
+			addGitConfig(fmt.Sprintf("url.ssh://git@%s/.insteadOf", host.Host), fmt.Sprintf("https://%s/", host.Host))
+			os.Setenv("CARGO_NET_GIT_FETCH_WITH_CLI", "true")
+		}
+	}
+	if len(accessTokens) > 0 {
+		addNixConfig("access-tokens = " + strings.Join(accessTokens, " "))
+	}
+
+	for name, registry := range config.CargoRegistries {
+		env := "CARGO_REGISTRIES_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+		if registry.Index != "" {
+			os.Setenv(env+"_INDEX", registry.Index)
+		}
+		if token := os.Getenv(registry.TokenEnv); registry.TokenEnv != "" && token != "" {
+			os.Setenv(env+"_TOKEN", token)
+		}
+	}
+}
+
+func newPrivateCmd() *cobra.Command {
+	privateCmd := &cobra.Command{
+		Use:   "private",
+		Short: "Private dependency credentials",
+	}
+
+	checkCmd := &cobra.Command{
+		Use:   "check",
+		Short: "Validate private dependency credentials",
+		Long: "Check that the credentials configured in the [private] section of glot.toml are available, " +
+			"and list each host's probe repository with the forwarded credentials.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			config := currentConfig.Private
+			if len(config.Hosts) == 0 && len(config.CargoRegistries) == 0 && len(config.GoPrivate) == 0 {
+				info(tr("PrivateNoSection", "File", configFile))
+				return nil
+			}
+
+			failed := 0
+			for _, problem := range privateProblems(config) {
+				errorMsg(problem)
+				failed++
+			}
+			for _, host := range config.Hosts {
+				if host.Probe == "" || host.problem() != "" {
+					continue
+				}
+				probe := exec.Command("git", "ls-remote", "--exit-code", host.Probe, "HEAD")
+				if out, err := probe.CombinedOutput(); err != nil {
+					errorMsg(tr("PrivateProbeFailed", "Host", host.Host, "Probe", host.Probe,
+						"Output", outputRedactor.redactString(strings.TrimSpace(string(out)))))
+					failed++
+				} else {
+					success(tr("PrivateProbeOK", "Host", host.Host, "Probe", host.Probe))
+				}
+			}
+			if failed > 0 {
+				return fmt.Errorf("%s", trn("PrivateProblems", failed))
+			}
+			if len(config.GoPrivate) > 0 {
+				info("GOPRIVATE=" + os.Getenv("GOPRIVATE"))
+			}
+			success(tr("PrivateOK"))
+			return nil
+		},
+	}
+
+	privateCmd.AddCommand(checkCmd)
+	return privateCmd
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// progressEvent is one line of `--progress-format json-lines` output
+type progressEvent struct {
+	Event      string    `json:"event"` // start, finish or message
+	ID         string    `json:"id"`
+	Parent     string    `json:"parent,omitempty"`
+	Name       string    `json:"name,omitempty"`
+	Time       time.Time `json:"time"`
+	Status     string    `json:"status,omitempty"` // ok or failed (finish)
+	DurationMs *int64    `json:"duration_ms,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Level      string    `json:"level,omitempty"` // warning or error (message)
+	Message    string    `json:"message,omitempty"`
+}
+
+// A running step: the glot command itself, a nix invocation or a hook
+type progressStep struct {
+	id      string
+	parent  string
+	name    string
+	started time.Time
+}
+
+var (
+	// Where events go; nil unless --progress-format json-lines is given
+	progressOut   io.Writer
+	progressMu    sync.Mutex
+	progressSteps []*progressStep // Open steps, innermost last
+	progressCount int
+	// The step of the running glot command
+	commandStep *progressStep
+)
+
+// Writer for diagnostics. With json-lines progress, stderr carries only
+// events, so warnings and tool logs go to stdout.
+func stderrOutput() io.Writer {
+	if progressOut != nil {
+		return os.Stdout
+	}
+	return os.Stderr
+}
+
+func emitProgress(event progressEvent) {
+	if progressOut == nil {
+		return
+	}
+	line, _ := json.Marshal(event)
+	fmt.Fprintf(progressOut, "%s\n", line)
+}
+
+// Start a step nested in the innermost open step. Returns nil, which is safe
+// to finish, unless progress events or --json are enabled.
+func startStep(name string) *progressStep {
+	if progressOut == nil && jsonOut == nil {
+		return nil
+	}
+	progressMu.Lock()
+	defer progressMu.Unlock()
+	progressCount++
+	step := &progressStep{id: strconv.Itoa(progressCount), name: outputRedactor.redactString(name), started: time.Now()}
+	if len(progressSteps) > 0 {
+		step.parent = progressSteps[len(progressSteps)-1].id
+	}
+	progressSteps = append(progressSteps, step)
+	emitProgress(progressEvent{Event: "start", ID: step.id, Parent: step.parent, Name: step.name, Time: step.started})
+	return step
+}
+
+// Finish a step with the error it ended with, if any
+func (s *progressStep) finish(err error) {
+	if s == nil {
+		return
+	}
+	progressMu.Lock()
+	defer progressMu.Unlock()
+	for i, open := range progressSteps {
+		if open == s {
+			progressSteps = append(progressSteps[:i], progressSteps[i+1:]...)
+			break
+		}
+	}
+	event := progressEvent{Event: "finish", ID: s.id, Parent: s.parent, Name: s.name, Time: time.Now(), Status: "ok"}
+	duration := event.Time.Sub(s.started).Milliseconds()
+	event.DurationMs = &duration
+	if err != nil {
+		event.Status = "failed"
+		event.Error = outputRedactor.redactString(err.Error())
+	}
+	emitProgress(event)
+	if jsonOut != nil {
+		finished := outputEvent{Status: event.Status, Error: event.Error, Time: event.Time, DurationMs: event.DurationMs}
+		if s != commandStep {
+			finished.Step = s.name
+		}
+		emitJSON(finished)
+	}
+}
+
+// Report a warning or error as an event of the innermost open step
+func progressMessage(level, msg string) {
+	if progressOut == nil {
+		return
+	}
+	progressMu.Lock()
+	defer progressMu.Unlock()
+	event := progressEvent{Event: "message", Time: time.Now(), Level: level, Message: outputRedactor.redactString(msg)}
+	if len(progressSteps) > 0 {
+		event.ID = progressSteps[len(progressSteps)-1].id
+	}
+	emitProgress(event)
+}
+
+// Enable progress events if requested and open the command's step
+func applyProgressFormat(cmd *cobra.Command) error {
+	format, _ := cmd.Flags().GetString("progress-format")
+	switch format {
+	case "", "text":
+		return nil
+	case "json-lines":
+		progressOut = os.Stderr
+		// Errors are reported as events; keep cobra's text off stderr
+		cmd.Root().SilenceErrors = true
+		cmd.Root().SilenceUsage = true
+		commandStep = startStep(cmd.CommandPath())
+		return nil
+	}
+	return fmt.Errorf("%s", tr("LoggingUnknownProgressFormat", "Format", format))
+}
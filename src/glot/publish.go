@@ -0,0 +1,260 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Versions a flake is published with: semantic versions, as FlakeHub
+	// requires, optionally with a v
+	flakeVersionPattern = regexp.MustCompile(`^v?[0-9]+\.[0-9]+\.[0-9]+([-+][0-9A-Za-z.-]+)?$`)
+	// owner/repo of a GitHub remote URL
+	githubRemotePattern = regexp.MustCompile(`github\.com[:/]([^/]+)/([^/]+?)(\.git)?/?$`)
+)
+
+// License files, one of which a published flake needs
+var licenseFiles = []string{"LICENSE", "LICENSE.md", "LICENSE.txt", "COPYING"}
+
+// Outputs other flakes can use as inputs
+var consumableOutputs = []string{"packages", "apps", "overlays", "templates", "lib", "nixosModules", "darwinModules", "homeManagerModules", "devShells", "legacyPackages"}
+
+// GitHub workflow publishing tags to FlakeHub
+const flakeHubWorkflow = ".github/workflows/flakehub-publish-tagged.yml"
+
+const flakeHubWorkflowContent = `name: Publish tags to FlakeHub
+
+on:
+  push:
+    tags:
+      - "v?[0-9]+.[0-9]+.[0-9]+*"
+  workflow_dispatch:
+    inputs:
+      tag:
+        description: The existing tag to publish to FlakeHub
+        type: string
+        required: true
+
+jobs:
+  flakehub-publish:
+    runs-on: ubuntu-latest
+    permissions:
+      id-token: write
+      contents: read
+    steps:
+      - uses: actions/checkout@v4
+        with:
+          ref: "${{ (inputs.tag != null) && format('refs/tags/{0}', inputs.tag) || '' }}"
+      - uses: DeterminateSystems/nix-installer-action@main
+      - uses: DeterminateSystems/flakehub-push@main
+        with:
+          visibility: public
+          name: %s
+          tag: ${{ inputs.tag }}
+          include-output-paths: true
+`
+
+// owner/repo of the origin remote on GitHub, empty if it isn't there
+func githubRepo() string {
+	out, err := exec.Command("git", "remote", "get-url", "origin").Output()
+	if err != nil {
+		return ""
+	}
+	m := githubRemotePattern.FindStringSubmatch(strings.TrimSpace(string(out)))
+	if m == nil {
+		return ""
+	}
+	return m[1] + "/" + m[2]
+}
+
+// Check what users of the flake rely on, returning the problems
+func validateFlakeForPublishing() []string {
+	var problems []string
+	out, err := nixOutput("flake", "metadata", "--json")
+	if err != nil {
+		return []string{tr("PublishMetadataFailed", "Error", err)}
+	}
+	var metadata struct {
+		Description string `json:"description"`
+	}
+	if err := json.Unmarshal(out, &metadata); err != nil {
+		return []string{fmt.Sprintf("unexpected nix flake metadata output: %s", err)}
+	}
+	if metadata.Description == "" {
+		problems = append(problems, tr("PublishNoDescription"))
+	} else {
+		success(tr("PublishDescription", "Description", metadata.Description))
+	}
+
+	license := ""
+	for _, file := range licenseFiles {
+		if _, err := os.Stat(file); err == nil {
+			license = file
+			break
+		}
+	}
+	if license == "" {
+		problems = append(problems, tr("PublishNoLicense"))
+	} else {
+		success(tr("PublishLicense", "File", license))
+	}
+
+	out, err = nixOutput("flake", "show", "--json", "--all-systems")
+	if err != nil {
+		problems = append(problems, tr("PublishShowFailed", "Error", err))
+	} else {
+		var outputs map[string]json.RawMessage
+		if err := json.Unmarshal(out, &outputs); err != nil {
+			return append(problems, fmt.Sprintf("unexpected nix flake show output: %s", err))
+		}
+		var found []string
+		for _, name := range consumableOutputs {
+			if _, ok := outputs[name]; ok {
+				found = append(found, name)
+			}
+		}
+		if len(found) == 0 {
+			problems = append(problems, tr("PublishNoOutputs", "Outputs", strings.Join(consumableOutputs, ", ")))
+		} else {
+			success(tr("PublishOutputs", "Outputs", strings.Join(found, ", ")))
+		}
+	}
+
+	if err := runNix("flake", "check", "--no-build"); err != nil {
+		problems = append(problems, tr("PublishCheckFails"))
+	}
+
+	if out, err := exec.Command("git", "status", "--porcelain").Output(); err != nil {
+		problems = append(problems, tr("PublishNotGit"))
+	} else if len(strings.TrimSpace(string(out))) > 0 {
+		problems = append(problems, tr("PublishUncommitted"))
+	}
+	return problems
+}
+
+// Add the FlakeHub workflow, returning whether it was missing
+func addFlakeHubWorkflow(repo string) (bool, error) {
+	if _, err := os.Stat(flakeHubWorkflow); err == nil {
+		return false, nil
+	}
+	if repo == "" {
+		return false, fmt.Errorf("%s", tr("PublishNotGitHub"))
+	}
+	if err := os.MkdirAll(filepath.Dir(flakeHubWorkflow), 0755); err != nil {
+		return false, err
+	}
+	return true, os.WriteFile(flakeHubWorkflow, []byte(fmt.Sprintf(flakeHubWorkflowContent, repo)), 0644)
+}
+
+// Print how to consume and publish the tagged flake
+func printPublishSteps(dir, repo, tag string, pushed bool) {
+	name := strings.ToLower(filepath.Base(dir))
+	ref := "git+file://" + dir + "?ref=refs/tags/" + tag
+	if repo != "" {
+		ref = fmt.Sprintf("github:%s/%s", repo, tag)
+	}
+	fmt.Println()
+	fmt.Println(plain("📦 " + tr("NextSteps")))
+	if !pushed {
+		fmt.Printf("  %-20s git push origin %s\n", tr("PublishStepPush"), tag)
+	}
+	fmt.Printf("  %-20s inputs.%s.url = \"%s\";\n", tr("PublishStepInput"), name, ref)
+	fmt.Printf("  %-20s nix registry add %s %s\n", tr("PublishStepRegistry"), name, ref)
+	if repo != "" {
+		fmt.Printf("  %-20s %s\n", tr("PublishStepGlobal"), tr("PublishStepGlobalAction", "Name", name))
+	}
+	if _, err := os.Stat(flakeHubWorkflow); err == nil {
+		fmt.Printf("  %-20s %s\n", "FlakeHub:", tr("PublishStepFlakeHubWorkflow", "Workflow", flakeHubWorkflow))
+	} else {
+		fmt.Printf("  %-20s %s\n", "FlakeHub:", tr("PublishStepFlakeHub"))
+	}
+}
+
+func newPublishCmd() *cobra.Command {
+	publishCmd := &cobra.Command{
+		Use:   "publish --flake <version>",
+		Short: "Publish the project for others to use",
+		Long: "With --flake, check that the flake can be published - a description, a LICENSE file, outputs others can use, " +
+			"a passing 'nix flake check --no-build' and a clean working tree - then tag the version and print how to push it " +
+			"and publish it to the flake registry and FlakeHub. With --push, also push the tag. " +
+			"With --flakehub, add a GitHub workflow publishing tags to FlakeHub.",
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if flake, _ := cmd.Flags().GetBool("flake"); !flake {
+				err := fmt.Errorf("%s", tr("PublishWhat"))
+				errorMsg(err.Error())
+				return err
+			}
+			if err := checkNix(); err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			repo := githubRepo()
+			if flakeHub, _ := cmd.Flags().GetBool("flakehub"); flakeHub {
+				added, err := addFlakeHubWorkflow(repo)
+				if err != nil {
+					errorMsg(err.Error())
+					return err
+				}
+				if added {
+					success(tr("PublishWorkflowAdded", "Workflow", flakeHubWorkflow))
+					return nil
+				}
+			}
+			if len(args) == 0 {
+				err := fmt.Errorf("%s", tr("PublishNoVersion"))
+				errorMsg(err.Error())
+				return err
+			}
+			if !flakeVersionPattern.MatchString(args[0]) {
+				err := fmt.Errorf("%s", tr("PublishNotSemver", "Version", args[0]))
+				errorMsg(err.Error())
+				return err
+			}
+			tag := "v" + strings.TrimPrefix(args[0], "v")
+			if exec.Command("git", "rev-parse", "--verify", "--quiet", "refs/tags/"+tag).Run() == nil {
+				err := fmt.Errorf("%s", tr("PublishTagExists", "Tag", tag))
+				errorMsg(err.Error())
+				return err
+			}
+
+			info(tr("PublishChecking"))
+			if problems := validateFlakeForPublishing(); len(problems) > 0 {
+				for _, problem := range problems {
+					errorMsg(problem)
+				}
+				return fmt.Errorf("%s", tr("PublishNotReady"))
+			}
+			if err := gitIn("", "tag", "-a", tag, "-m", "Release "+tag); err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			success(tr("PublishTagged", "Tag", tag))
+			push, _ := cmd.Flags().GetBool("push")
+			if push {
+				if err := gitIn("", "push", "origin", tag); err != nil {
+					errorMsg(tr("PublishPushFailed", "Tag", tag, "Error", err))
+					return err
+				}
+				success(tr("PublishPushed", "Tag", tag))
+			}
+			cwd, err := os.Getwd()
+			if err != nil {
+				return err
+			}
+			printPublishSteps(cwd, repo, tag, push)
+			return nil
+		},
+	}
+	publishCmd.Flags().Bool("flake", false, "Publish the flake: check it, tag the version and show how to publish it")
+	publishCmd.Flags().Bool("push", false, "Push the tag to origin")
+	publishCmd.Flags().Bool("flakehub", false, "Add a GitHub workflow publishing version tags to FlakeHub")
+	return publishCmd
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"path/filepath"
+
+	"github.com/BurntSushi/toml"
+)
+
+type pythonLanguage struct{ toolchain }
+
+func newPythonLanguage() Language {
+	return pythonLanguage{toolchain{
+		name:       "python",
+		markers:    []string{"pyproject.toml", "setup.py", "requirements.txt"},
+		lsp:        []string{"pyright-langserver", "--stdio"},
+		lspPackage: "pyright",
+		format:     []string{"ruff", "format", "."},
+		lint:       []string{"ruff", "check", "."},
+		test:       []string{"pytest"},
+		testWith: func(options testOptions) ([]string, error) {
+			command := []string{"pytest"}
+			if options.Verbose {
+				command = append(command, "-v", "-s")
+			}
+			if options.Filter != "" {
+				command = append(command, "-k", options.Filter)
+			}
+			return append(command, options.Args...), nil
+		},
+		coverage: func(command []string, out string) ([]string, [][]string) {
+			data := "--data-file=" + filepath.Join(out, ".coverage")
+			test := append([]string{"coverage", "run", data, "-m"}, command...)
+			return test, [][]string{
+				{"coverage", "report", data},
+				{"coverage", "html", data, "-d", filepath.Join(out, "html")},
+				{"coverage", "lcov", data, "-o", filepath.Join(out, "lcov.info")},
+			}
+		},
+		artifacts: []string{"__pycache__/", ".pytest_cache/", ".ruff_cache/", ".mypy_cache/", "dist/", "*.egg-info"},
+	}}
+}
+
+type pyprojectManifest struct {
+	Project struct {
+		Name    string `toml:"name"`
+		Version string `toml:"version"`
+	} `toml:"project"`
+	Tool struct {
+		Poetry struct {
+			Name    string `toml:"name"`
+			Version string `toml:"version"`
+		} `toml:"poetry"`
+	} `toml:"tool"`
+}
+
+func readPyproject() (pyprojectManifest, error) {
+	var pyproject pyprojectManifest
+	_, err := toml.DecodeFile("pyproject.toml", &pyproject)
+	return pyproject, err
+}
+
+func (pythonLanguage) Info() []string {
+	pyproject, err := readPyproject()
+	if err != nil {
+		return nil
+	}
+	switch {
+	case pyproject.Project.Name != "":
+		return []string{tr("InfoPackageVersion", "Name", pyproject.Project.Name, "Version", pyproject.Project.Version)}
+	case pyproject.Tool.Poetry.Name != "":
+		return []string{tr("InfoPoetryPackage", "Name", pyproject.Tool.Poetry.Name, "Version", pyproject.Tool.Poetry.Version)}
+	}
+	return nil
+}
+
+func (pythonLanguage) Project() (string, string) {
+	pyproject, _ := readPyproject()
+	if pyproject.Project.Name != "" {
+		return pyproject.Project.Name, ""
+	}
+	return pyproject.Tool.Poetry.Name, ""
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const redacted = "[REDACTED]"
+
+// redactConfig is the [redact] section of glot.toml, e.g.
+//
+//	[redact]
+//	env = ["DATABASE_URL"]
+//	patterns = ['sk-[A-Za-z0-9]{32,}']
+type redactConfig struct {
+	Env      []string `toml:"env"`      // Extra environment variables whose values are masked
+	Patterns []string `toml:"patterns"` // Extra regular expressions to mask
+}
+
+// Environment variables whose values are always masked
+var sensitiveEnvName = regexp.MustCompile(`(?i)(^|_)(TOKEN|SECRET|PASSWORD|PASSWD|PASSPHRASE|API_?KEY|ACCESS_?KEY|PRIVATE_?KEY|CREDENTIALS?)(_|$)`)
+
+// Values shorter than this are never masked, to avoid mangling output
+const minSecretLength = 4
+
+// Well-known secret formats, masked wherever they appear
+var builtinRedactions = []struct {
+	pattern     *regexp.Regexp
+	replacement string
+}{
+	{regexp.MustCompile(`(?i)((?:token|secret|password|passwd|api[_-]?key|access[_-]?key)[A-Za-z0-9_]*\s*[=:]\s*)\S+`), "${1}" + redacted},
+	{regexp.MustCompile(`(?i)(authorization:\s*\w+\s+)\S+`), "${1}" + redacted},
+	{regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{20,}|github_pat_[A-Za-z0-9_]{20,})\b`), redacted},
+	{regexp.MustCompile(`\bAKIA[0-9A-Z]{16}\b`), redacted},
+	{regexp.MustCompile(`(https?://)[^/\s:@]+:[^/\s@]+@`), "${1}" + redacted + "@"},
+}
+
+// redactor masks secret values in text before it is printed or written
+type redactor struct {
+	values   [][]byte
+	patterns []*regexp.Regexp
+}
+
+// Returned for a [redact] pattern that doesn't compile
+type redactPatternError struct {
+	pattern string
+	err     error
+}
+
+func (e redactPatternError) Error() string {
+	return tr("RedactInvalidPattern", "Pattern", e.pattern, "File", configFile, "Error", e.err)
+}
+
+// Redactor applied to everything glot prints or writes to disk
+var outputRedactor, _ = newRedactor(redactConfig{})
+
+// Build a redactor from the environment and the project's [redact] settings
+func newRedactor(config redactConfig) (*redactor, error) {
+	r := &redactor{}
+
+	extra := map[string]bool{}
+	for _, name := range config.Env {
+		extra[name] = true
+	}
+	for _, entry := range os.Environ() {
+		name, value, _ := strings.Cut(entry, "=")
+		if len(value) >= minSecretLength && (extra[name] || sensitiveEnvName.MatchString(name)) {
+			r.values = append(r.values, []byte(value))
+		}
+	}
+	// Longest first, so a secret containing another is masked whole
+	sort.Slice(r.values, func(i, j int) bool { return len(r.values[i]) > len(r.values[j]) })
+
+	for _, pattern := range config.Patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return r, redactPatternError{pattern, err}
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+// Also mask value, e.g. a secret glot read from a provider
+func (r *redactor) addValue(value string) {
+	if len(value) < minSecretLength {
+		return
+	}
+	r.values = append(r.values, []byte(value))
+	sort.Slice(r.values, func(i, j int) bool { return len(r.values[i]) > len(r.values[j]) })
+}
+
+// Mask secrets in content
+func (r *redactor) redact(content []byte) []byte {
+	for _, value := range r.values {
+		content = bytes.ReplaceAll(content, value, []byte(redacted))
+	}
+	for _, b := range builtinRedactions {
+		content = b.pattern.ReplaceAll(content, []byte(b.replacement))
+	}
+	for _, re := range r.patterns {
+		content = re.ReplaceAll(content, []byte(redacted))
+	}
+	return content
+}
+
+func (r *redactor) redactString(s string) string {
+	return string(r.redact([]byte(s)))
+}
+
+// Redact every argument of a command line on its own
+func (r *redactor) redactArgs(args []string) []string {
+	result := make([]string, len(args))
+	for i, arg := range args {
+		result[i] = r.redactString(arg)
+	}
+	return result
+}
+
+// How long a partial line waits for its newline before being printed anyway,
+// so prompts from interactive programs still show up
+const partialLineDelay = 100 * time.Millisecond
+
+// redactingWriter masks secrets line by line before passing output on
+type redactingWriter struct {
+	mu      sync.Mutex
+	out     io.Writer
+	pending []byte
+	timer   *time.Timer
+}
+
+func newRedactingWriter(out io.Writer) *redactingWriter {
+	return &redactingWriter{out: out}
+}
+
+func (w *redactingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.pending = append(w.pending, p...)
+	if end := bytes.LastIndexAny(w.pending, "\n\r"); end >= 0 {
+		if _, err := w.out.Write(outputRedactor.redact(w.pending[:end+1])); err != nil {
+			return 0, err
+		}
+		w.pending = append([]byte(nil), w.pending[end+1:]...)
+	}
+
+	if len(w.pending) > 0 && w.timer == nil {
+		w.timer = time.AfterFunc(partialLineDelay, w.Flush)
+	}
+	return len(p), nil
+}
+
+// Write out any buffered partial line
+func (w *redactingWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.timer != nil {
+		w.timer.Stop()
+		w.timer = nil
+	}
+	if len(w.pending) > 0 {
+		w.out.Write(outputRedactor.redact(w.pending))
+		w.pending = nil
+	}
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// Release artifacts and their checksums go here
+const releaseDir = "dist"
+
+// releaseConfig is the [release] section of glot.toml
+type releaseConfig struct {
+	// Where the published artifacts are downloaded from, with {version} and
+	// {file} replaced, e.g. for GitHub releases
+	// https://github.com/me/app/releases/download/v{version}/{file}
+	URL string `toml:"url"`
+}
+
+// A release of the project for one system
+type release struct {
+	Name    string // <project>-<version>-<system>
+	Project string
+	System  string
+	Version string
+	// Staging directory with writable copies of the release binaries
+	Staging string
+	// Files to publish, in releaseDir
+	Artifacts []string
+}
+
+// Version of a release: the git tag or commit, or "dev" outside git
+func releaseVersion() string {
+	out, err := exec.Command("git", "describe", "--tags", "--always", "--dirty").Output()
+	if err != nil {
+		return "dev"
+	}
+	return strings.TrimPrefix(strings.TrimSpace(string(out)), "v")
+}
+
+// Run a tool, with its output redacted and logged
+func runTool(name string, args ...string) error {
+	return runToolIn("", name, args...)
+}
+
+// Run a tool in another directory
+func runToolIn(dir, name string, args ...string) error {
+	stdout := newRedactingWriter(logged(os.Stdout))
+	stderr := newRedactingWriter(logged(stderrOutput()))
+	defer stdout.Flush()
+	defer stderr.Flush()
+
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.Stdin = os.Stdin
+	cmd.Dir = dir
+	logCommand(cmd)
+	step := startStep(name + " " + strings.Join(args, " "))
+	err := cmd.Run()
+	step.finish(err)
+	return err
+}
+
+// Create releaseDir, ignored by git: artifacts are published, not committed
+func ensureReleaseDir() error {
+	if err := os.MkdirAll(releaseDir, 0755); err != nil {
+		return err
+	}
+	ignore := filepath.Join(releaseDir, ".gitignore")
+	if _, err := os.Stat(ignore); os.IsNotExist(err) {
+		return os.WriteFile(ignore, []byte("*\n"), 0644)
+	}
+	return nil
+}
+
+// Build the release output and copy its binaries into a staging directory.
+// Store paths are read-only, and signing modifies the binaries.
+func stageRelease() (*release, error) {
+	system, err := currentSystem()
+	if err != nil {
+		return nil, err
+	}
+	out, err := nixOutput("build", ".#release", "--no-link", "--print-out-paths")
+	if err != nil {
+		return nil, fmt.Errorf("%s", tr("ReleaseBuildFailed", "Error", err))
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	r := &release{Project: filepath.Base(cwd), System: system, Version: releaseVersion()}
+	r.Name = fmt.Sprintf("%s-%s-%s", r.Project, r.Version, system)
+	r.Staging = filepath.Join(releaseDir, r.Name)
+	if err := ensureReleaseDir(); err != nil {
+		return nil, err
+	}
+	if err := os.RemoveAll(r.Staging); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(r.Staging, 0755); err != nil {
+		return nil, err
+	}
+
+	copied := 0
+	for _, output := range strings.Fields(string(out)) {
+		entries, err := os.ReadDir(filepath.Join(output, "bin"))
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if err := copyExecutable(filepath.Join(output, "bin", entry.Name()), filepath.Join(r.Staging, entry.Name())); err != nil {
+				return nil, err
+			}
+			copied++
+		}
+	}
+	if copied == 0 {
+		return nil, fmt.Errorf("%s", tr("ReleaseNoBinaries"))
+	}
+	return r, nil
+}
+
+// Copy a file, following symlinks, as a writable executable
+func copyExecutable(source, target string) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0755)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// Copy the files of a build result's bin/ into dir (glot build --out),
+// following the symlinks into the nix store, as regular files the user
+// owns: executables with mode 0755, others - like .wasm modules - with 0644
+func exportArtifacts(result, dir string) error {
+	bin := filepath.Join(result, "bin")
+	entries, _ := os.ReadDir(bin)
+	var files []string
+	for _, entry := range entries {
+		source := filepath.Join(bin, entry.Name())
+		stat, err := os.Stat(source)
+		if err != nil || stat.IsDir() {
+			continue
+		}
+		if len(files) == 0 {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return err
+			}
+		}
+		target := filepath.Join(dir, entry.Name())
+		// A link or read-only copy left there would be written through or refuse
+		os.Remove(target)
+		if err := copyExecutable(source, target); err != nil {
+			return fmt.Errorf("%s", tr("ReleaseCopyFailed", "File", entry.Name(), "Dir", dir, "Error", err))
+		}
+		mode := os.FileMode(0644)
+		if stat.Mode()&0111 != 0 {
+			mode = 0755
+		}
+		if err := os.Chmod(target, mode); err != nil {
+			return err
+		}
+		files = append(files, entry.Name())
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("%s", tr("OutNothing", "Result", result, "Dir", dir))
+	}
+	success(tr("OutCopied", "Count", len(files), "Dir", dir, "Files", strings.Join(files, ", ")))
+	return nil
+}
+
+// The staged binaries, sorted
+func (r *release) binaries() ([]string, error) {
+	entries, err := os.ReadDir(r.Staging)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, entry := range entries {
+		paths = append(paths, filepath.Join(r.Staging, entry.Name()))
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// Pack the staged binaries into <name>.tar.gz
+func (r *release) archive() error {
+	path := filepath.Join(releaseDir, r.Name+".tar.gz")
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	binaries, err := r.binaries()
+	if err != nil {
+		return err
+	}
+	for _, binary := range binaries {
+		content, err := os.ReadFile(binary)
+		if err != nil {
+			return err
+		}
+		header := &tar.Header{Name: r.Name + "/" + filepath.Base(binary), Mode: 0755, Size: int64(len(content))}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := tw.Write(content); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	r.Artifacts = append(r.Artifacts, path)
+	return nil
+}
+
+// Write SHA256SUMS for the artifacts, in the format sha256sum -c reads
+func (r *release) writeChecksums() error {
+	var lines []string
+	for _, artifact := range r.Artifacts {
+		sum, err := fileSHA256(artifact)
+		if err != nil {
+			return err
+		}
+		lines = append(lines, sum+"  "+filepath.Base(artifact))
+	}
+	return os.WriteFile(filepath.Join(releaseDir, r.Name+".SHA256SUMS"), []byte(strings.Join(lines, "\n")+"\n"), 0644)
+}
+
+// Hex SHA-256 of a file
+func fileSHA256(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func newReleaseCmd() *cobra.Command {
+	releaseCmd := &cobra.Command{
+		Use:   "release",
+		Short: "Build release artifacts",
+		Long: "Build the release output for the current system and pack its binaries into " + releaseDir + "/, " +
+			"as <project>-<version>-<system>.tar.gz with a SHA256SUMS file. The version is the latest git tag or the commit. " +
+			"With --sign-macos, codesign and notarize the binaries first (macOS only). " +
+			"With --brew-tap, also update the project's Homebrew formula in a tap, for the tarballs of this version in " + releaseDir + "/.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkNix(); err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			signMacOS, _ := cmd.Flags().GetBool("sign-macos")
+			tap, _ := cmd.Flags().GetString("brew-tap")
+			if tap != "" && currentConfig.Release.URL == "" {
+				err := fmt.Errorf("%s", tr("ReleaseTapNeedsURL", "Config", configFile))
+				errorMsg(err.Error())
+				return err
+			}
+			var signing *macOSSigning
+			if signMacOS {
+				var err error
+				if signing, err = macOSSigningFromEnv(); err != nil {
+					errorMsg(err.Error())
+					return err
+				}
+			}
+
+			info(tr("ReleaseBuilding"))
+			r, err := stageRelease()
+			if err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			if signing != nil {
+				if err := signing.signAndNotarize(r); err != nil {
+					errorMsg(err.Error())
+					return err
+				}
+			}
+			if err := r.archive(); err != nil {
+				errorMsg(tr("ReleasePackFailed", "Error", err))
+				return err
+			}
+			if err := r.writeChecksums(); err != nil {
+				errorMsg(tr("ReleaseChecksumsFailed", "Error", err))
+				return err
+			}
+			for _, artifact := range r.Artifacts {
+				success(tr("PackageWritten", "Path", artifact))
+			}
+			if tap != "" {
+				if err := r.publishBrewFormula(tap); err != nil {
+					errorMsg(err.Error())
+					return err
+				}
+			}
+			return nil
+		},
+	}
+	releaseCmd.Flags().String("brew-tap", "", "Update the project's Homebrew formula in a tap repository (user/repo or git URL) and push it")
+	releaseCmd.Flags().Bool("sign-macos", false, "Codesign and notarize the binaries, producing a stapled .dmg (macOS; credentials from the environment or keychain)")
+	return releaseCmd
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"math"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// resourcesConfig is the [resources] section of glot.toml
+type resourcesConfig struct {
+	Jobs  int `toml:"jobs"`  // Derivations nix builds in parallel
+	Cores int `toml:"cores"` // Cores each build (and language tools) may use
+	// Keep building what doesn't depend on a failed derivation
+	KeepGoing bool `toml:"keep-going"`
+}
+
+// CPU limit imposed by the cgroup (e.g. a container or CI runner), or 0 if
+// there is none
+func cgroupCPULimit() int {
+	// cgroup v2: "<quota> <period>" or "max <period>"
+	if content, err := os.ReadFile("/sys/fs/cgroup/cpu.max"); err == nil {
+		fields := strings.Fields(string(content))
+		if len(fields) == 2 && fields[0] != "max" {
+			return cpuQuota(fields[0], fields[1])
+		}
+		return 0
+	}
+	// cgroup v1: quota is -1 when unlimited
+	quota, err1 := os.ReadFile("/sys/fs/cgroup/cpu/cpu.cfs_quota_us")
+	period, err2 := os.ReadFile("/sys/fs/cgroup/cpu/cpu.cfs_period_us")
+	if err1 == nil && err2 == nil {
+		return cpuQuota(strings.TrimSpace(string(quota)), strings.TrimSpace(string(period)))
+	}
+	return 0
+}
+
+func cpuQuota(quota, period string) int {
+	q, err1 := strconv.ParseFloat(quota, 64)
+	p, err2 := strconv.ParseFloat(period, 64)
+	if err1 != nil || err2 != nil || q <= 0 || p <= 0 {
+		return 0
+	}
+	return int(math.Ceil(q / p))
+}
+
+// Parallel builds from --jobs or glot.toml, 0 for nix's setting
+var buildJobs int
+
+// Work out jobs, cores and keep-going from flags, glot.toml and the cgroup
+// limit, and pass them on to nix and to language build tools through the
+// environment
+func applyResourceLimits(cmd *cobra.Command) {
+	jobs, _ := cmd.Flags().GetInt("jobs")
+	cores, _ := cmd.Flags().GetInt("cores")
+	if jobs <= 0 {
+		jobs, _ = cmd.Flags().GetInt("max-jobs")
+	}
+	if jobs <= 0 {
+		jobs = currentConfig.Resources.Jobs
+	}
+	if cores <= 0 {
+		cores = currentConfig.Resources.Cores
+	}
+	if cores <= 0 {
+		if limit := cgroupCPULimit(); limit > 0 && limit < runtime.NumCPU() {
+			cores = limit
+		}
+	}
+
+	buildJobs = jobs
+	if jobs > 0 {
+		nixGlobalArgs = append(nixGlobalArgs, "--max-jobs", strconv.Itoa(jobs))
+	}
+	if cores > 0 {
+		nixGlobalArgs = append(nixGlobalArgs, "--cores", strconv.Itoa(cores))
+		value := strconv.Itoa(cores)
+		os.Setenv("CARGO_BUILD_JOBS", value)
+		os.Setenv("GOMAXPROCS", value)
+		os.Setenv("CMAKE_BUILD_PARALLEL_LEVEL", value)
+		os.Setenv("MAKEFLAGS", strings.TrimSpace(os.Getenv("MAKEFLAGS")+" -j"+value))
+	}
+
+	keepGoing := currentConfig.Resources.KeepGoing
+	if flag := cmd.Flags().Lookup("keep-going"); flag != nil && flag.Changed {
+		keepGoing, _ = cmd.Flags().GetBool("keep-going")
+	}
+	if keepGoing {
+		nixGlobalArgs = append(nixGlobalArgs, "--keep-going")
+	}
+}
+
+// Register the resource flags on the root command
+func addResourceFlags(root *cobra.Command) {
+	root.PersistentFlags().Int("jobs", 0, "Maximum number of parallel nix builds (default: nix setting)")
+	root.PersistentFlags().Int("max-jobs", 0, "Same as --jobs, under nix's name")
+	root.PersistentFlags().Bool("keep-going", false, "Keep building the derivations that don't depend on a failed one (default: resources.keep-going in glot.toml)")
+	root.PersistentFlags().Int("cores", 0, "Cores per build, also passed to cargo/go/make (default: container CPU limit or nix setting)")
+	root.PersistentFlags().String("concurrency", "", "While another glot command runs in the project: queue, fail or share its result (default: [concurrency] in glot.toml, or queue)")
+}
@@ -0,0 +1,436 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// Where glot review checks out the reviewed revision
+var reviewDir = filepath.Join(stateDir, "review")
+
+// Hunk header of a diff with no context lines: @@ -start[,count] +start[,count] @@
+var hunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// A hunk of a diff without context lines
+type diffHunk struct {
+	oldPath, newPath   string
+	oldStart, oldCount int
+	newStart, newCount int
+	added              []string // The lines the hunk adds
+}
+
+func parseDiff(diff string) []diffHunk {
+	var hunks []diffHunk
+	var oldPath, newPath string
+	var hunk *diffHunk
+	for _, line := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "--- "):
+			oldPath = strings.TrimPrefix(strings.TrimPrefix(line, "--- "), "a/")
+		case strings.HasPrefix(line, "+++ "):
+			newPath = strings.TrimPrefix(strings.TrimPrefix(line, "+++ "), "b/")
+		case hunkHeader.MatchString(line):
+			m := hunkHeader.FindStringSubmatch(line)
+			count := func(s string) int {
+				if s == "" {
+					return 1
+				}
+				n, _ := strconv.Atoi(s)
+				return n
+			}
+			oldStart, _ := strconv.Atoi(m[1])
+			newStart, _ := strconv.Atoi(m[3])
+			hunks = append(hunks, diffHunk{oldPath, newPath, oldStart, count(m[2]), newStart, count(m[4]), nil})
+			hunk = &hunks[len(hunks)-1]
+		case hunk != nil && strings.HasPrefix(line, "+"):
+			hunk.added = append(hunk.added, line[1:])
+		}
+	}
+	return hunks
+}
+
+// Lines the diff from base to HEAD adds or changes, by path relative to the
+// project directory
+func changedLines(base string) (map[string][][2]int, error) {
+	out, err := exec.Command("git", "diff", "--unified=0", "--no-color", "--no-ext-diff", "--relative", base+"...HEAD").Output()
+	if err != nil {
+		return nil, err
+	}
+	changed := map[string][][2]int{}
+	for _, hunk := range parseDiff(string(out)) {
+		if hunk.newPath != "/dev/null" && hunk.newCount > 0 {
+			changed[hunk.newPath] = append(changed[hunk.newPath], [2]int{hunk.newStart, hunk.newStart + hunk.newCount - 1})
+		}
+	}
+	return changed, nil
+}
+
+// A finding of glot review on changed lines
+type reviewFinding struct {
+	path       string // Relative to the project directory
+	start, end int    // Lines of the reviewed revision
+	check      string
+	message    string
+	suggestion []string // Replacement of the lines, for formatting
+}
+
+// The changed file a tool's path means: the same, or one it is the end of,
+// as linters and test runners in a language root or package directory print
+func changedPath(changed map[string][][2]int, file string) string {
+	file = filepath.ToSlash(filepath.Clean(file))
+	if _, ok := changed[file]; ok {
+		return file
+	}
+	for _, path := range sortedKeys(changed) {
+		if strings.HasSuffix(path, "/"+file) {
+			return path
+		}
+	}
+	return ""
+}
+
+// The changed range of the file containing a line, if any
+func changedRange(ranges [][2]int, line int) ([2]int, bool) {
+	for _, r := range ranges {
+		if line >= r[0] && line <= r[1] {
+			return r, true
+		}
+	}
+	return [2]int{}, false
+}
+
+// Findings in the output of a check that are on changed lines, and how many
+// are elsewhere
+func changedFindings(changed map[string][][2]int, check, output string) ([]reviewFinding, int) {
+	var findings []reviewFinding
+	elsewhere := 0
+	seen := map[string]bool{}
+	for _, finding := range parseFindings(output) {
+		path := changedPath(changed, finding.file)
+		if _, ok := changedRange(changed[path], finding.line); path == "" || !ok {
+			elsewhere++
+			continue
+		}
+		// Test runners repeat failures in their summaries
+		key := fmt.Sprintf("%s:%d:%s", path, finding.line, finding.message)
+		if !seen[key] {
+			seen[key] = true
+			findings = append(findings, reviewFinding{path: path, start: finding.line, end: finding.line, check: check, message: finding.message})
+		}
+	}
+	return findings, elsewhere
+}
+
+// Changes of the formatters to changed lines, as findings suggesting the
+// formatted lines when the change is within the changed lines
+func formatFindings(changed map[string][][2]int, diff string) []reviewFinding {
+	var findings []reviewFinding
+	for _, hunk := range parseDiff(diff) {
+		ranges := changed[hunk.oldPath]
+		start, end := hunk.oldStart, hunk.oldStart+hunk.oldCount-1
+		if hunk.oldCount == 0 {
+			// An insertion after line start
+			end = start
+		}
+		for line := start; line <= end; line++ {
+			r, ok := changedRange(ranges, line)
+			if !ok {
+				continue
+			}
+			finding := reviewFinding{path: hunk.oldPath, start: line, end: line, check: "fmt", message: "Not formatted"}
+			if hunk.oldCount > 0 && start >= r[0] && end <= r[1] {
+				finding.start, finding.end, finding.suggestion = start, end, hunk.added
+				if finding.suggestion == nil {
+					finding.suggestion = []string{}
+				}
+			}
+			findings = append(findings, finding)
+			break
+		}
+	}
+	return findings
+}
+
+// Arguments of the test runner limiting it to the changed code, where the
+// language can: the Go packages with changed files
+func reviewTestArgs(changed map[string][][2]int) []string {
+	if flakeHasApp("test") {
+		return nil
+	}
+	language, err := detectLanguage()
+	if err != nil || language.Name() != "go" {
+		return nil
+	}
+	var packages []string
+	for _, file := range sortedKeys(changed) {
+		if strings.HasSuffix(file, ".go") {
+			if pkg := "./" + path.Dir(file); !slices.Contains(packages, pkg) {
+				packages = append(packages, pkg)
+			}
+		}
+	}
+	return packages
+}
+
+// n findings, in words
+func findingCount(n int) string {
+	if n == 1 {
+		return "1 finding"
+	}
+	return fmt.Sprintf("%d findings", n)
+}
+
+// What glot review found in the reviewed revision
+type reviewReport struct {
+	base, head  string
+	files       int
+	findings    []reviewFinding
+	elsewhere   int      // Findings not on changed lines
+	failedTests []string // Names of the failed tests
+	results     map[string]error
+}
+
+func (r *reviewReport) summary() string {
+	var md strings.Builder
+	fmt.Fprintf(&md, "## glot review of %s...%s\n\n", r.base, r.head)
+	fmt.Fprintf(&md, "Changed files: %d, findings on changed lines: %d", r.files, len(r.findings))
+	if r.elsewhere > 0 {
+		fmt.Fprintf(&md, " (%d more elsewhere, not shown)", r.elsewhere)
+	}
+	md.WriteString(".\n\n| Check | Result |\n| ----- | ------ |\n")
+	for _, check := range []string{"fmt", "lint", "test"} {
+		count := 0
+		for _, finding := range r.findings {
+			if finding.check == check {
+				count++
+			}
+		}
+		result := "✅ passed"
+		if r.results[check] != nil || count > 0 {
+			result = fmt.Sprintf("❌ %s on changed lines", findingCount(count))
+		}
+		if check == "test" && r.results[check] != nil {
+			result = fmt.Sprintf("❌ failed (failed tests: %d)", len(r.failedTests))
+		}
+		fmt.Fprintf(&md, "| %s | %s |\n", check, result)
+	}
+	if len(r.failedTests) > 0 {
+		md.WriteString("\n**Failed tests:**\n\n")
+		for _, test := range r.failedTests {
+			fmt.Fprintf(&md, "- `%s`\n", test)
+		}
+	}
+	return md.String()
+}
+
+// Body of a finding, with the formatted lines as a GitHub suggestion
+func (f reviewFinding) body() string {
+	body := fmt.Sprintf("**%s:** %s", f.check, f.message)
+	if f.suggestion != nil {
+		body += "\n\n```suggestion\n" + strings.Join(f.suggestion, "\n")
+		if len(f.suggestion) > 0 {
+			body += "\n"
+		}
+		body += "```"
+	}
+	return body
+}
+
+func (r *reviewReport) markdown() string {
+	var md strings.Builder
+	md.WriteString(r.summary())
+	sort.SliceStable(r.findings, func(i, j int) bool {
+		if r.findings[i].path != r.findings[j].path {
+			return r.findings[i].path < r.findings[j].path
+		}
+		return r.findings[i].start < r.findings[j].start
+	})
+	file := ""
+	for _, finding := range r.findings {
+		if finding.path != file {
+			file = finding.path
+			fmt.Fprintf(&md, "\n### `%s`\n\n", file)
+		}
+		lines := fmt.Sprintf("L%d", finding.start)
+		if finding.end > finding.start {
+			lines += fmt.Sprintf("-L%d", finding.end)
+		}
+		body := strings.Split(finding.body(), "\n")
+		for i, line := range body[1:] {
+			if line != "" {
+				body[i+1] = "  " + line
+			}
+		}
+		fmt.Fprintf(&md, "- **%s** %s\n", lines, strings.Join(body, "\n"))
+	}
+	return md.String()
+}
+
+// Post the report as a review of a GitHub pull request, with a comment on
+// the diff line of each finding
+func (r *reviewReport) postToGitHub(pr, commit string) error {
+	prefix, _ := exec.Command("git", "rev-parse", "--show-prefix").Output()
+	type comment struct {
+		Path      string `json:"path"`
+		Line      int    `json:"line"`
+		StartLine int    `json:"start_line,omitempty"`
+		Side      string `json:"side"`
+		Body      string `json:"body"`
+	}
+	review := struct {
+		CommitID string    `json:"commit_id"`
+		Event    string    `json:"event"`
+		Body     string    `json:"body"`
+		Comments []comment `json:"comments"`
+	}{CommitID: commit, Event: "COMMENT", Body: r.summary(), Comments: []comment{}}
+	for _, finding := range r.findings {
+		c := comment{Path: strings.TrimSpace(string(prefix)) + finding.path, Line: finding.end, Side: "RIGHT", Body: finding.body()}
+		if finding.end > finding.start {
+			c.StartLine = finding.start
+		}
+		review.Comments = append(review.Comments, c)
+	}
+	content, err := json.Marshal(review)
+	if err != nil {
+		return err
+	}
+	request := filepath.Join(reviewDir, "github-review.json")
+	if err := os.WriteFile(request, content, 0644); err != nil {
+		return err
+	}
+	return runTool("gh", "api", "--method", "POST", "repos/{owner}/{repo}/pulls/"+pr+"/reviews", "--input", request, "--silent")
+}
+
+// Run the checks in the reviewed revision's project directory, collecting
+// their findings on changed lines
+func reviewChecks(report *reviewReport, changed map[string][][2]int) {
+	info(tr("ReviewLinting"))
+	output, err := captureOutput(func() error { return runWorkspaceTask("lint", runLint, lintRootName, Language.Lint) })
+	report.results["lint"] = err
+	findings, elsewhere := changedFindings(changed, "lint", output)
+	report.findings = append(report.findings, findings...)
+	report.elsewhere += elsewhere
+
+	args := reviewTestArgs(changed)
+	if len(args) > 0 {
+		info(tr("ReviewTestingPackages", "Packages", strings.Join(args, " ")))
+	} else {
+		info(tr("ReviewTesting"))
+	}
+	output, err = captureOutput(func() error { return runTests(testOptions{Verbose: true, Args: args}) })
+	report.results["test"] = err
+	findings, elsewhere = changedFindings(changed, "test", output)
+	report.findings = append(report.findings, findings...)
+	report.elsewhere += elsewhere
+	for test, status := range parseTestResults(output) {
+		if status == "failed" {
+			report.failedTests = append(report.failedTests, test)
+		}
+	}
+	sort.Strings(report.failedTests)
+
+	// Last, as it changes the files the others report lines of
+	info(tr("ReviewFormatting"))
+	report.results["fmt"] = runWorkspaceTask("format", formatProject, formatRootName, Language.Format)
+	if diff, err := exec.Command("git", "diff", "--unified=0", "--no-color", "--no-ext-diff", "--relative").Output(); err == nil {
+		report.findings = append(report.findings, formatFindings(changed, string(diff))...)
+	}
+}
+
+func newReviewCmd() *cobra.Command {
+	reviewCmd := &cobra.Command{
+		Use:   "review <base-ref>",
+		Short: "Review the changes since a revision with fmt, lint and test",
+		Long: "Check out HEAD in a temporary worktree, run the formatters, linters and tests there (for Go, only the tests " +
+			"of the changed packages) and report what they find on the lines changed since the merge base with base-ref: " +
+			"a markdown report ready to paste into a code review, or with --github a review of the pull request with " +
+			"a comment on each line. Fails when there are findings on changed lines or tests fail.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkNix(); err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			if err := exec.Command("git", "rev-parse", "--verify", "--quiet", args[0]+"^{commit}").Run(); err != nil {
+				err = fmt.Errorf("%s", tr("ReviewNotRevision", "Rev", args[0]))
+				errorMsg(err.Error())
+				return err
+			}
+			head, err := exec.Command("git", "rev-parse", "HEAD").Output()
+			if err != nil {
+				errorMsg(tr("ReviewNoCommit"))
+				return err
+			}
+			changed, err := changedLines(args[0])
+			if err != nil {
+				errorMsg(tr("ReviewDiffFailed", "Rev", args[0], "Error", err))
+				return err
+			}
+			if len(changed) == 0 {
+				success(tr("ReviewNothingChanged", "Rev", args[0]))
+				return nil
+			}
+			if status, _ := exec.Command("git", "status", "--porcelain", "--untracked-files=no", ".").Output(); len(status) > 0 {
+				warning(tr("ReviewUncommitted"))
+			}
+
+			dir, remove, err := checkoutRevision("HEAD", filepath.Join(reviewDir, "head"))
+			if err != nil {
+				errorMsg(tr("ReviewCheckoutFailed", "Error", err))
+				return err
+			}
+			defer remove()
+			report := &reviewReport{base: args[0], head: strings.TrimSpace(string(head))[:12], files: len(changed), results: map[string]error{}}
+			inProjectDir(dir, func() error {
+				reviewChecks(report, changed)
+				return nil
+			})
+
+			fmt.Println()
+			output, _ := cmd.Flags().GetString("output")
+			pr, _ := cmd.Flags().GetString("github")
+			switch {
+			case pr != "":
+				info(tr("ReviewPosting", "PR", pr))
+				if err := report.postToGitHub(pr, strings.TrimSpace(string(head))); err != nil {
+					errorMsg(tr("ReviewPostFailed", "Error", err))
+					return err
+				}
+				success(trn("ReviewPosted", len(report.findings)))
+			case output != "":
+				if err := os.WriteFile(output, []byte(report.markdown()), 0644); err != nil {
+					errorMsg(tr("WriteFailed", "File", output, "Error", err))
+					return err
+				}
+				success(tr("ReviewWritten", "File", output))
+			default:
+				fmt.Print(report.markdown())
+			}
+
+			if len(report.findings) > 0 || report.results["test"] != nil {
+				err := fmt.Errorf("%s", trn("ReviewFindings", len(report.findings)))
+				if report.results["test"] != nil {
+					err = fmt.Errorf("%s", trn("ReviewFindingsTestsFailed", len(report.findings)))
+				}
+				errorMsg(err.Error())
+				return err
+			}
+			success(tr("ReviewNoFindings"))
+			return nil
+		},
+	}
+	reviewCmd.Flags().StringP("output", "o", "", "Write the markdown report to a file instead of printing it")
+	reviewCmd.Flags().String("github", "", "Post the report as a review of this GitHub pull request number, through gh")
+	return reviewCmd
+}
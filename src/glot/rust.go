@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/spf13/cobra"
+)
+
+type rustLanguage struct{ toolchain }
+
+func newRustLanguage() Language {
+	return rustLanguage{toolchain{
+		name:       "rust",
+		markers:    []string{"Cargo.toml"},
+		lsp:        []string{"rust-analyzer"},
+		lspPackage: "rust-analyzer",
+		format:     []string{"cargo", "fmt"},
+		lint:       []string{"cargo", "clippy", "--", "-D", "warnings"},
+		test:       []string{"cargo", "test"},
+		testWith: func(options testOptions) ([]string, error) {
+			command := []string{"cargo", "test"}
+			if options.Filter != "" {
+				command = append(command, options.Filter)
+			}
+			command = append(command, options.Args...)
+			if options.Verbose {
+				// --nocapture goes to the test binary, after --
+				if !slices.Contains(options.Args, "--") {
+					command = append(command, "--")
+				}
+				command = append(command, "--nocapture")
+			}
+			return command, nil
+		},
+		coverage: func(command []string, out string) ([]string, [][]string) {
+			// cargo llvm-cov takes cargo test's arguments
+			test := append([]string{"cargo", "llvm-cov", "--no-report"}, command[2:]...)
+			return test, [][]string{
+				{"cargo", "llvm-cov", "report"},
+				{"cargo", "llvm-cov", "report", "--html", "--output-dir", out},
+				{"cargo", "llvm-cov", "report", "--lcov", "--output-path", filepath.Join(out, "lcov.info")},
+			}
+		},
+		update:    []string{"cargo", "update"},
+		artifacts: []string{"target/", ".cargo/"},
+	}}
+}
+
+type cargoManifest struct {
+	Package struct {
+		Name    string `toml:"name"`
+		Version string `toml:"version"`
+	} `toml:"package"`
+	Workspace struct {
+		Members []string `toml:"members"`
+	} `toml:"workspace"`
+}
+
+func readCargoManifest() (cargoManifest, error) {
+	var manifest cargoManifest
+	_, err := toml.DecodeFile("Cargo.toml", &manifest)
+	return manifest, err
+}
+
+func (rustLanguage) Info() []string {
+	manifest, err := readCargoManifest()
+	if err != nil {
+		return nil
+	}
+	var lines []string
+	if manifest.Package.Name != "" {
+		lines = append(lines, tr("InfoRustCrate", "Name", manifest.Package.Name, "Version", manifest.Package.Version))
+	}
+	if len(manifest.Workspace.Members) > 0 {
+		lines = append(lines, tr("InfoRustWorkspace", "Members", strings.Join(manifest.Workspace.Members, ", ")))
+	}
+	return lines
+}
+
+func (rustLanguage) Project() (string, string) {
+	manifest, _ := readCargoManifest()
+	return manifest.Package.Name, ""
+}
+
+// Cargo features of glot build --features and --no-default-features
+type cargoFeatures struct {
+	features          []string
+	noDefaultFeatures bool
+}
+
+func (f cargoFeatures) empty() bool {
+	return len(f.features) == 0 && !f.noDefaultFeatures
+}
+
+// Attributes of the package overriding its cargo features, only those
+// given. rust.nix passes cargoBuildFeatures and friends to every cargo
+// command of the build, as nixpkgs' cargo hooks do.
+func (f cargoFeatures) overrides() []string {
+	var overrides []string
+	if len(f.features) > 0 {
+		list := nixStringList(f.features)
+		overrides = append(overrides, "cargoBuildFeatures = "+list+";", "cargoCheckFeatures = "+list+";")
+	}
+	if f.noDefaultFeatures {
+		overrides = append(overrides, "cargoBuildNoDefaultFeatures = true;", "cargoCheckNoDefaultFeatures = true;")
+	}
+	return overrides
+}
+
+// Features requested with --features and --no-default-features, which only
+// a Cargo project has
+func selectedFeatures(cmd *cobra.Command) (cargoFeatures, error) {
+	var f cargoFeatures
+	list, _ := cmd.Flags().GetStringSlice("features")
+	for _, feature := range list {
+		// cargo also takes space separated features
+		f.features = append(f.features, strings.Fields(feature)...)
+	}
+	f.noDefaultFeatures, _ = cmd.Flags().GetBool("no-default-features")
+	if f.empty() {
+		return f, nil
+	}
+	if _, err := os.Stat("Cargo.toml"); err != nil {
+		return f, fmt.Errorf("%s", tr("FeaturesNeedCargo"))
+	}
+	return f, nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// Set for the glot running inside glot check --sandbox
+const sandboxedEnv = "GLOT_SANDBOXED"
+
+// Host paths the sandboxed check sees read-only, when they exist: the nix
+// store and daemon socket, nix's settings and the system's tools
+var checkSandboxPaths = []string{"/nix", "/etc/nix", "/usr", "/bin", "/lib", "/lib64", "/run/current-system"}
+
+// bubblewrap of PATH if it has --tmp-overlay (0.10 and later), or else from
+// nixpkgs, as the command to run it with
+func overlayBwrap() []string {
+	if out, err := exec.Command("bwrap", "--version").Output(); err == nil {
+		if match := regexp.MustCompile(`(\d+)\.(\d+)`).FindStringSubmatch(string(out)); match != nil {
+			major, _ := strconv.Atoi(match[1])
+			minor, _ := strconv.Atoi(match[2])
+			if major > 0 || minor >= 10 {
+				return []string{"bwrap"}
+			}
+		}
+	}
+	return []string{"nix", "shell", "nixpkgs#bubblewrap", "--command", "bwrap"}
+}
+
+// Directories of the tools the sandboxed glot runs, resolved to the store
+// or system paths the sandbox has: the home with the user's profiles is
+// hidden
+func checkSandboxPATH() string {
+	var dirs []string
+	for _, tool := range []string{"nix", "git"} {
+		if path, err := exec.LookPath(tool); err == nil {
+			if resolved, err := filepath.EvalSymlinks(path); err == nil {
+				dirs = append(dirs, filepath.Dir(resolved))
+			}
+		}
+	}
+	return strings.Join(append(dirs, "/run/current-system/sw/bin", "/usr/bin", "/bin"), ":")
+}
+
+// bubblewrap arguments running glot with args in the project at root,
+// without network and with nothing of the host but checkSandboxPaths, some
+// of /etc and the project. The project is an overlay whose changes are
+// thrown away; only glot's state in .glot is written through.
+func checkSandboxArgs(self, root, cwd string, args []string) []string {
+	bwrap := []string{"--die-with-parent", "--unshare-all", "--new-session", "--proc", "/proc", "--dev", "/dev", "--tmpfs", "/tmp"}
+	for _, path := range checkSandboxPaths {
+		bwrap = append(bwrap, "--ro-bind-try", path, path)
+	}
+	for _, path := range sandboxEtc {
+		bwrap = append(bwrap, "--ro-bind-try", path, path)
+	}
+	bwrap = append(bwrap, "--ro-bind", self, self, "--tmpfs", sandboxHome,
+		"--overlay-src", root, "--tmp-overlay", root,
+		"--bind", filepath.Join(cwd, stateDir), filepath.Join(cwd, stateDir),
+		"--chdir", cwd, "--clearenv")
+	env := map[string]string{"HOME": sandboxHome, "TMPDIR": "/tmp", "PATH": checkSandboxPATH(), sandboxedEnv: "1"}
+	for _, name := range append(sandboxPassEnv, "USER", "NO_COLOR", "CI", "GLOT_LOCALE", "GLOT_AUTO_FEATURES") {
+		if value, ok := os.LookupEnv(name); ok {
+			env[name] = value
+		}
+	}
+	for _, name := range sortedKeys(env) {
+		bwrap = append(bwrap, "--setenv", name, env[name])
+	}
+	return append(append(bwrap, "--", self), args...)
+}
+
+// The command line of this glot without --sandbox, for the glot in the sandbox
+func argsWithoutSandbox() []string {
+	var args []string
+	for _, arg := range os.Args[1:] {
+		if arg != "--sandbox" && !strings.HasPrefix(arg, "--sandbox=") {
+			args = append(args, arg)
+		}
+	}
+	return args
+}
+
+// Run glot check for untrusted code. On Linux, glot runs the checks again in
+// bubblewrap; elsewhere only the flake's checks run, in nix's build sandbox.
+func sandboxedCheck() error {
+	// Fetching the inputs evaluates only the flake's inputs, not its outputs
+	info(tr("CheckSandboxFetching"))
+	if err := runNix("flake", "archive"); err != nil {
+		return fmt.Errorf("%s", tr("CheckSandboxFetchFailed", "Error", err))
+	}
+
+	if runtime.GOOS != "linux" {
+		warning(tr("CheckSandboxNoBwrap"))
+		info(tr("CheckSandboxFlakeCheck"))
+		return runNix("flake", "check", "--print-build-logs")
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	// The flake sees the whole repository, so all of it is in the overlay
+	root := cwd
+	if out, err := exec.Command("git", "rev-parse", "--show-toplevel").Output(); err == nil {
+		root = strings.TrimSpace(string(out))
+	}
+	if err := ensureStateDir(stateDir); err != nil {
+		return err
+	}
+
+	info(tr("CheckSandboxStarted"))
+	bwrap := overlayBwrap()
+	args := append(bwrap[1:], checkSandboxArgs(self, root, cwd, argsWithoutSandbox())...)
+	if bwrap[0] == "nix" {
+		return runNix(args...)
+	}
+	return runTool(bwrap[0], args...)
+}
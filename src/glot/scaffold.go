@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// What glot new does with files the target directory already has
+type scaffoldMode string
+
+const (
+	scaffoldFresh scaffoldMode = ""      // The directory must be missing or empty
+	scaffoldMerge scaffoldMode = "merge" // Existing files are kept
+	scaffoldForce scaffoldMode = "force" // Existing files are replaced
+)
+
+// A file of a project plan: where its content comes from and what writing
+// it does to the directory
+type plannedFile struct {
+	Path    string   // In the project, with the template's names replaced
+	Action  string   // create, overwrite, append or keep
+	Origins []string // template <name>, feature <name> or existing
+	// The file's content, nil for a file features only append to, and the
+	// fragments appended to it
+	base      func() ([]byte, error)
+	fragments []func() ([]byte, error)
+	existing  []byte
+	// Permissions of the source base renders, so scripts stay executable;
+	// 0 without a base
+	mode os.FileMode
+}
+
+// scaffoldPlan is everything glot new would write, in path order, so the
+// same template, features and names always give the same project
+type scaffoldPlan struct {
+	Files []*plannedFile
+	// The template's literal strings and what replaces them, longest first
+	Substitutions [][2]string
+	Hooks         []string
+}
+
+// Plan a project in dir without writing anything. Features are layered in
+// order on the template's files; a feature replacing an earlier file fails
+// unless it overwrites (organization defaults). With mode, files already
+// in dir are kept, with the features' appends added, or replaced.
+func planProject(template *templateManifest, dir string, vars map[string]string, platforms []string, features []*templateFeature, mode scaffoldMode) (*scaffoldPlan, error) {
+	files := map[string]*plannedFile{}
+	origin := "template " + template.Name
+	sources := template.files(platforms)
+	for _, dest := range sortedKeys(sources) {
+		source, err := os.Stat(sources[dest])
+		if err != nil {
+			return nil, err
+		}
+		path := template.render(dest, vars)
+		files[path] = &plannedFile{
+			Path:    path,
+			Origins: []string{origin},
+			base:    func() ([]byte, error) { return renderTemplateFile(template, dest, vars, platforms) },
+			mode:    source.Mode().Perm(),
+		}
+	}
+
+	for _, feature := range features {
+		origin := "feature " + feature.Name
+		for _, dest := range sortedKeys(feature.Files) {
+			if file, ok := files[dest]; ok && !feature.overwrite {
+				return nil, fmt.Errorf("%s", tr("ScaffoldFeatureConflict", "Feature", feature.Name, "File", dest, "Origin", file.Origins[0]))
+			}
+			source := feature.Files[dest]
+			stat, err := os.Stat(source)
+			if err != nil {
+				return nil, err
+			}
+			files[dest] = &plannedFile{
+				Path:    dest,
+				Origins: []string{origin},
+				base:    func() ([]byte, error) { return renderFeatureFile(source, vars["projectName"]) },
+				mode:    stat.Mode().Perm(),
+			}
+		}
+		for _, dest := range sortedKeys(feature.Append) {
+			source := feature.Append[dest]
+			file, ok := files[dest]
+			if !ok {
+				file = &plannedFile{Path: dest}
+				files[dest] = file
+			}
+			file.Origins = append(file.Origins, origin)
+			file.fragments = append(file.fragments, func() ([]byte, error) { return renderFeatureFile(source, vars["projectName"]) })
+		}
+	}
+
+	plan := &scaffoldPlan{Hooks: template.PostGenerate}
+	for _, path := range sortedKeys(files) {
+		file := files[path]
+		file.Action = "create"
+		existing, err := os.ReadFile(filepath.Join(dir, path))
+		switch {
+		case os.IsNotExist(err):
+		case err != nil:
+			return nil, err
+		case mode == scaffoldForce:
+			file.Action = "overwrite"
+		case mode == scaffoldMerge:
+			// The file stays, with the features' appends it doesn't have yet
+			file.Action = "keep"
+			appends := file.Origins
+			if file.base != nil {
+				appends = file.Origins[1:]
+			}
+			file.Origins = append([]string{"existing"}, appends...)
+			file.base = nil
+			file.mode = 0
+			file.existing = existing
+			if merged, err := file.content(); err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			} else if !bytes.Equal(merged, existing) {
+				file.Action = "append"
+			}
+		default:
+			return nil, fmt.Errorf("%s", tr("ScaffoldExists", "File", filepath.Join(dir, path)))
+		}
+		plan.Files = append(plan.Files, file)
+	}
+
+	literals := sortedKeys(template.Substitute)
+	sort.SliceStable(literals, func(i, j int) bool { return len(literals[i]) > len(literals[j]) })
+	for _, literal := range literals {
+		plan.Substitutions = append(plan.Substitutions, [2]string{literal, template.render(literal, vars)})
+	}
+	return plan, nil
+}
+
+// The file's content: its base or the existing file, and the fragments
+// appended to it. A fragment the existing file already has is skipped, so
+// merging again changes nothing.
+func (f *plannedFile) content() ([]byte, error) {
+	content := f.existing
+	if f.base != nil {
+		base, err := f.base()
+		if err != nil {
+			return nil, err
+		}
+		content = base
+	}
+	for _, fragment := range f.fragments {
+		text, err := fragment()
+		if err != nil {
+			return nil, err
+		}
+		if f.existing != nil && bytes.Contains(f.existing, text) {
+			continue
+		}
+		if f.existing != nil && len(content) > 0 && !bytes.HasSuffix(content, []byte("\n")) {
+			content = append(content, '\n')
+		}
+		content = append(content, text...)
+	}
+	return content, nil
+}
+
+// Write the plan's files into dir, returning the paths written
+func (p *scaffoldPlan) write(dir string) ([]string, error) {
+	var written []string
+	for _, file := range p.Files {
+		if file.Action == "keep" {
+			continue
+		}
+		content, err := file.content()
+		if err != nil {
+			return written, fmt.Errorf("%s: %w", file.Path, err)
+		}
+		target := filepath.Join(dir, file.Path)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return written, err
+		}
+		mode := file.mode
+		if mode == 0 {
+			mode = 0644
+		}
+		if err := os.WriteFile(target, content, mode); err != nil {
+			return written, err
+		}
+		// WriteFile keeps the permissions of a file it overwrites
+		if file.mode != 0 && file.Action == "overwrite" {
+			if err := os.Chmod(target, file.mode); err != nil {
+				return written, err
+			}
+		}
+		written = append(written, file.Path)
+	}
+	return written, nil
+}
+
+// Message naming each action in the printed plan
+var scaffoldActionMessages = map[string]string{
+	"create":    "ScaffoldActionCreate",
+	"overwrite": "ScaffoldActionOverwrite",
+	"append":    "ScaffoldActionAppend",
+	"keep":      "ScaffoldActionKeep",
+}
+
+// Print the plan of glot new --print-plan
+func (p *scaffoldPlan) print() {
+	width := 0
+	for _, file := range p.Files {
+		width = max(width, len(file.Path))
+	}
+	fmt.Println(tr("ScaffoldPlanFiles"))
+	for _, file := range p.Files {
+		fmt.Printf("  %-9s  %-*s  %s\n", tr(scaffoldActionMessages[file.Action]), width, file.Path, strings.Join(file.Origins, ", "))
+	}
+	if len(p.Substitutions) > 0 {
+		fmt.Println(tr("ScaffoldPlanSubstitutions"))
+		for _, substitution := range p.Substitutions {
+			fmt.Printf("  %q -> %q\n", substitution[0], substitution[1])
+		}
+	}
+	if len(p.Hooks) > 0 {
+		fmt.Println(tr("ScaffoldPlanHooks"))
+		for _, hook := range p.Hooks {
+			fmt.Printf("  %s\n", hook)
+		}
+	}
+}
+
+// Whether dir has no entries, or doesn't exist
+func dirEmpty(dir string) (bool, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return true, nil
+	}
+	return len(entries) == 0, err
+}
+
+// Commit everything staged in dir. With SOURCE_DATE_EPOCH set, the commit
+// has its time, so the same project gets the same commit.
+func gitCommitIn(dir, message string) error {
+	cmd := exec.Command("git", "commit", "--quiet", "-m", message)
+	cmd.Dir = dir
+	if epoch := os.Getenv("SOURCE_DATE_EPOCH"); epoch != "" {
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_DATE=@"+epoch+" +0000", "GIT_COMMITTER_DATE=@"+epoch+" +0000")
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git commit: %s", strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// The shell template with every file of its directory, which is what its
+// template.nix lists, without evaluating it with nix
+func shellTemplate(t *testing.T) *templateManifest {
+	t.Helper()
+	dir := filepath.Join("..", "..", "templates", "shell", "scripts")
+	if _, err := os.Stat(dir); err != nil {
+		// The nix build only has the module's source
+		t.Skip("templates/shell isn't in the source tree")
+	}
+	template := &templateManifest{Name: "shell-scripts", Files: map[string]string{}, Dir: dir}
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || entry.Name() == "template.nix" {
+			return err
+		}
+		dest, err := filepath.Rel(dir, path)
+		template.Files[filepath.ToSlash(dest)] = path
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return template
+}
+
+func assertExecutable(t *testing.T, path string) {
+	t.Helper()
+	stat, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.Mode().Perm()&0111 == 0 {
+		t.Errorf("%s is not executable: %s", path, stat.Mode())
+	}
+}
+
+func TestScaffoldKeepsExecutableFiles(t *testing.T) {
+	template := shellTemplate(t)
+	dir := t.TempDir()
+	plan, err := planProject(template, dir, projectVariables("greeter", ""), nil, nil, scaffoldFresh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := plan.write(dir); err != nil {
+		t.Fatal(err)
+	}
+	assertExecutable(t, filepath.Join(dir, "bin", "greet"))
+
+	// Overwriting keeps the template's permissions too
+	if err := os.Chmod(filepath.Join(dir, "bin", "greet"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	plan, err = planProject(template, dir, projectVariables("greeter", ""), nil, nil, scaffoldForce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := plan.write(dir); err != nil {
+		t.Fatal(err)
+	}
+	assertExecutable(t, filepath.Join(dir, "bin", "greet"))
+}
+
+func TestWriteTemplateFileKeepsExecutableFiles(t *testing.T) {
+	template := shellTemplate(t)
+	dir := t.TempDir()
+	if err := writeTemplateFile(template, dir, "bin/greet", projectVariables("greeter", ""), nil); err != nil {
+		t.Fatal(err)
+	}
+	assertExecutable(t, filepath.Join(dir, "bin", "greet"))
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// Loaded by `glot run` and `glot test` when glot.toml declares no secrets
+const defaultEnvFile = ".env"
+
+// secretsConfig is the [secrets] section of glot.toml: where `glot run` and
+// `glot test` get their secrets from, e.g.
+//
+//	[[secrets.source]]
+//	provider = "sops"
+//	file = "secrets.enc.yaml"
+//
+//	[[secrets.source]]
+//	provider = "1password"
+//	env = { DATABASE_URL = "op://dev/postgres/url" }
+//
+// Sources are read in order, later ones overriding earlier ones, and
+// decrypted into glot's memory only: the values reach the commands through
+// their environment and are masked in all output.
+type secretsConfig struct {
+	Sources []secretSource `toml:"source"`
+}
+
+// secretSource is a provider and what to read from it
+type secretSource struct {
+	Provider string            `toml:"provider"` // dotenv, age, sops, 1password or bitwarden
+	File     string            `toml:"file"`     // dotenv, age and sops: the file to decrypt
+	Identity string            `toml:"identity"` // age: identity file (default: age's own lookup)
+	Env      map[string]string `toml:"env"`      // 1password and bitwarden: variable to secret reference
+}
+
+// secretProvider reads the variables of a source
+type secretProvider struct {
+	// CLI the provider needs, checked before running it
+	tool string
+	// Whether the source is a file, or env's secret references
+	file bool
+	read func(source secretSource) (map[string]string, error)
+}
+
+var secretProviders = map[string]secretProvider{
+	"dotenv": {
+		file: true,
+		read: func(source secretSource) (map[string]string, error) {
+			content, err := os.ReadFile(source.File)
+			if err != nil {
+				return nil, err
+			}
+			return parseDotenv(content)
+		},
+	},
+	"age": {
+		tool: "age",
+		file: true,
+		read: func(source secretSource) (map[string]string, error) {
+			args := []string{"--decrypt"}
+			if source.Identity != "" {
+				args = append(args, "--identity", expandHome(source.Identity))
+			}
+			out, err := secretOutput("age", append(args, source.File)...)
+			if err != nil {
+				return nil, err
+			}
+			return parseDotenv(out)
+		},
+	},
+	"sops": {
+		tool: "sops",
+		file: true,
+		read: func(source secretSource) (map[string]string, error) {
+			ageKeyFile()
+			out, err := secretOutput("sops", "--decrypt", "--output-type", "dotenv", source.File)
+			if err != nil {
+				return nil, err
+			}
+			return parseDotenv(out)
+		},
+	},
+	"1password": {
+		tool: "op",
+		read: func(source secretSource) (map[string]string, error) {
+			return readSecretRefs(source.Env, func(ref string) ([]byte, error) {
+				return secretOutput("op", "read", "--no-newline", ref)
+			})
+		},
+	},
+	"bitwarden": {
+		tool: "bw",
+		read: func(source secretSource) (map[string]string, error) {
+			if os.Getenv("BW_SESSION") == "" {
+				return nil, fmt.Errorf("%s", tr("SecretsBitwardenLocked"))
+			}
+			return readSecretRefs(source.Env, func(item string) ([]byte, error) {
+				return secretOutput("bw", "get", "password", item)
+			})
+		},
+	},
+}
+
+// Check the [secrets] section, so mistakes show up before any command runs
+func validateSecretsConfig(path string, config secretsConfig) error {
+	for i, source := range config.Sources {
+		provider, ok := secretProviders[source.Provider]
+		if !ok {
+			return fmt.Errorf("%s", tr("SecretsUnknownProvider", "Path", path, "Source", i+1, "Provider", source.Provider,
+				"Providers", strings.Join(secretProviderNames(), ", ")))
+		}
+		if provider.file && source.File == "" {
+			return fmt.Errorf("%s", tr("SecretsNeedsFile", "Path", path, "Source", i+1, "Provider", source.Provider))
+		}
+		if !provider.file && len(source.Env) == 0 {
+			return fmt.Errorf("%s", tr("SecretsNeedsEnv", "Path", path, "Source", i+1, "Provider", source.Provider))
+		}
+	}
+	return nil
+}
+
+func secretProviderNames() []string {
+	var names []string
+	for name := range secretProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Run a provider CLI, returning its output. It's kept in memory only, and
+// its error output, which may quote secrets, is redacted.
+func secretOutput(name string, args ...string) ([]byte, error) {
+	cmd := exec.Command(name, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	cmd.Stdin = os.Stdin
+	logCommand(cmd)
+	out, err := cmd.Output()
+	if err != nil {
+		message := strings.TrimSpace(outputRedactor.redactString(stderr.String()))
+		if message == "" {
+			message = err.Error()
+		}
+		return nil, fmt.Errorf("%s", tr("SecretsToolFailed", "Tool", name, "Message", message))
+	}
+	return out, nil
+}
+
+// Read each variable's secret reference with read
+func readSecretRefs(refs map[string]string, read func(ref string) ([]byte, error)) (map[string]string, error) {
+	values := map[string]string{}
+	for _, name := range sortedKeys(refs) {
+		out, err := read(refs[name])
+		if err != nil {
+			return nil, fmt.Errorf("%s", tr("SecretsReading", "Error", err, "Name", name))
+		}
+		values[name] = strings.TrimRight(string(out), "\r\n")
+	}
+	return values, nil
+}
+
+// Parse dotenv content: NAME=value lines, optionally with `export`, single
+// quotes taking the value literally and double quotes expanding \n, \t, \"
+// and \\. Blank lines and # comments are skipped.
+func parseDotenv(content []byte) (map[string]string, error) {
+	values := map[string]string{}
+	for i, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		name, value, ok := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("%s", tr("SecretsDotenvLine", "Line", i+1))
+		}
+		value = strings.TrimSpace(value)
+		switch {
+		case len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'':
+			value = value[1 : len(value)-1]
+		case len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"':
+			unquoted, err := strconv.Unquote(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s", tr("SecretsDotenvQuote", "Line", i+1))
+			}
+			value = unquoted
+		default:
+			// Unquoted values end at a comment
+			if comment := strings.Index(value, " #"); comment >= 0 {
+				value = strings.TrimSpace(value[:comment])
+			}
+		}
+		values[name] = value
+	}
+	return values, nil
+}
+
+// The sources to read: those of glot.toml, or .env if there are none and
+// the project has one
+func secretSources(config secretsConfig) []secretSource {
+	if len(config.Sources) > 0 {
+		return config.Sources
+	}
+	if _, err := os.Stat(defaultEnvFile); err == nil {
+		return []secretSource{{Provider: "dotenv", File: defaultEnvFile}}
+	}
+	return nil
+}
+
+// Read the project's secrets and put them in glot's environment, which the
+// commands it runs inherit. Variables already set are left alone, so the
+// environment can override a secret for one run.
+func injectSecrets(config secretsConfig) error {
+	sources := secretSources(config)
+	if len(sources) == 0 {
+		return nil
+	}
+	values := map[string]string{}
+	for _, source := range sources {
+		provider := secretProviders[source.Provider]
+		if provider.tool != "" {
+			if _, err := exec.LookPath(provider.tool); err != nil {
+				return fmt.Errorf("%s", tr("SecretsToolMissing", "Tool", provider.tool, "Provider", source.Provider))
+			}
+		}
+		debug(fmt.Sprintf("Reading secrets from %s", source.Provider))
+		read, err := provider.read(source)
+		if err != nil {
+			label := source.Provider
+			if source.File != "" {
+				label += " " + source.File
+			}
+			// Not "secrets: ...", which the redactor would take for a secret
+			return fmt.Errorf("%s", tr("SecretsReadFailed", "Source", label, "Error", err))
+		}
+		for name, value := range read {
+			values[name] = value
+		}
+	}
+
+	var injected []string
+	for _, name := range sortedKeys(values) {
+		if _, set := os.LookupEnv(name); set {
+			debug(fmt.Sprintf("$%s is set already, not overriding it", name))
+			continue
+		}
+		os.Setenv(name, values[name])
+		outputRedactor.addValue(values[name])
+		injected = append(injected, name)
+	}
+	if len(injected) > 0 {
+		info(tr("SecretsInjected", "Names", strings.Join(injected, ", ")))
+	}
+	return nil
+}
+
+// Inject the project's secrets unless --no-secrets is given
+func applySecrets(cmd *cobra.Command) error {
+	if skip, _ := cmd.Flags().GetBool("no-secrets"); skip {
+		return nil
+	}
+	return injectSecrets(currentConfig.Secrets)
+}
+
+func addSecretsFlag(cmd *cobra.Command) {
+	cmd.Flags().Bool("no-secrets", false, "Don't inject the secrets of glot.toml's [secrets] sources, or .env")
+}
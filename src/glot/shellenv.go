@@ -0,0 +1,159 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Cached environments of the dev shells, from nix print-dev-env, so commands
+// in the dev shell start without evaluating the flake
+var shellEnvDir = filepath.Join(stateDir, "dev-env")
+
+// Files whose changes make the cached environments stale: the flake, its
+// lock, glot.toml and the project's other nix files, which the flake may
+// import. Nix only sees the files git tracks.
+func shellEnvInputs() []string {
+	inputs := []string{"flake.nix", "flake.lock", configFile}
+	if out, err := exec.Command("git", "ls-files", "--", "*.nix").Output(); err == nil {
+		for _, file := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+			if file != "" && !slices.Contains(inputs, file) {
+				inputs = append(inputs, file)
+			}
+		}
+	}
+	return inputs
+}
+
+// What a cached environment was made from
+type shellEnvMeta struct {
+	Key string `json:"key"`
+	// Bash to source the environment with: print-dev-env output needs a
+	// recent bash, which macOS doesn't have
+	Bash string `json:"bash"`
+}
+
+// A cached dev shell environment
+type shellEnv struct {
+	Script string // Sourcing it sets up the dev shell's environment
+	Bash   string
+}
+
+// Hash of the flake's inputs and the nix options, which the environment
+// depends on
+func shellEnvKey() (string, error) {
+	hash := sha256.New()
+	for _, input := range shellEnvInputs() {
+		content, err := os.ReadFile(input)
+		if err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+		fmt.Fprintf(hash, "%s %d\n", input, len(content))
+		hash.Write(content)
+	}
+	// Offline, the flake evaluates to the same environment
+	args := slices.DeleteFunc(withNixGlobalArgs([]string{"print-dev-env"}), func(arg string) bool { return arg == "--offline" })
+	fmt.Fprintf(hash, "nix %s\n", strings.Join(args, " "))
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// The cached environment of a dev shell ("" for the default one), evaluated
+// with nix print-dev-env when there is none, it is stale or refresh is set
+func cachedShellEnv(shell string, refresh bool) (*shellEnv, error) {
+	if _, err := os.Stat("flake.nix"); err != nil {
+		return nil, fmt.Errorf("%s", tr("ShellEnvNoFlake"))
+	}
+	key, err := shellEnvKey()
+	if err != nil {
+		return nil, err
+	}
+	name := shell
+	if name == "" {
+		name = "default"
+	}
+	dir, err := filepath.Abs(shellEnvDir)
+	if err != nil {
+		return nil, err
+	}
+	env := &shellEnv{Script: filepath.Join(dir, name+".sh")}
+	metaFile := filepath.Join(dir, name+".json")
+
+	var meta shellEnvMeta
+	if content, err := os.ReadFile(metaFile); err == nil && !refresh {
+		if json.Unmarshal(content, &meta) == nil && meta.Key == key {
+			_, scriptErr := os.Stat(env.Script)
+			_, bashErr := os.Stat(meta.Bash)
+			if scriptErr == nil && bashErr == nil {
+				debug(fmt.Sprintf("Using the cached environment of the %s dev shell", name))
+				env.Bash = meta.Bash
+				return env, nil
+			}
+		}
+	}
+
+	info(tr("ShellEnvEvaluating", "Shell", name))
+	if err := ensureStateDir(dir); err != nil {
+		return nil, err
+	}
+	// The profile is a GC root, so the environment's store paths stay
+	args := []string{"print-dev-env", "--profile", filepath.Join(dir, name+"-profile")}
+	if shell != "" {
+		args = append(args, ".#"+shell)
+	}
+	script, err := nixOutput(args...)
+	if err != nil {
+		return nil, err
+	}
+	out, err := nixOutput("build", "nixpkgs#bashInteractive", "--out-link", filepath.Join(dir, "bash"), "--print-out-paths")
+	if err != nil {
+		return nil, err
+	}
+	env.Bash = filepath.Join(strings.TrimSpace(string(out)), "bin", "bash")
+	if err := os.WriteFile(env.Script, script, 0644); err != nil {
+		return nil, err
+	}
+	content, _ := json.Marshal(shellEnvMeta{Key: key, Bash: env.Bash})
+	if err := os.WriteFile(metaFile, content, 0644); err != nil {
+		return nil, err
+	}
+	return env, nil
+}
+
+// Command line running a command in the dev shell: sourcing the cached
+// environment, or with nix develop if it can't be cached
+func devShellCommand(command []string) []string {
+	env, err := cachedShellEnv("", false)
+	if err != nil {
+		debug(fmt.Sprintf("Not using a cached dev environment: %s", err))
+		return append([]string{"nix"}, withNixGlobalArgs(append([]string{"develop", "--command"}, command...))...)
+	}
+	return append([]string{env.Bash}, env.commandArgs(command)...)
+}
+
+// Arguments of bash running a command in the environment
+func (env *shellEnv) commandArgs(command []string) []string {
+	return append([]string{"-c", `. "$0" && exec "$@"`, env.Script}, command...)
+}
+
+// Like commandArgs, with what the environment prints as it is set up, the
+// shellHook's banner, going to stderr: for commands whose stdout is a protocol
+func (env *shellEnv) protocolCommandArgs(command []string) []string {
+	return append([]string{"-c", `. "$0" >&2 && exec "$@"`, env.Script}, command...)
+}
+
+// Command line of an interactive shell in the environment: bash with the
+// user's ~/.bashrc, then the environment, like nix develop
+func (env *shellEnv) interactiveCommand() ([]string, error) {
+	rcFile := strings.TrimSuffix(env.Script, ".sh") + ".rc"
+	rc := "[ -e ~/.bashrc ] && . ~/.bashrc\n. " + shellQuote([]string{env.Script}) + "\n"
+	if err := os.WriteFile(rcFile, []byte(rc), 0644); err != nil {
+		return nil, err
+	}
+	return []string{env.Bash, "--rcfile", rcFile, "-i"}, nil
+}
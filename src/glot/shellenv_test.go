@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// Run the test in an empty project directory with a flake.nix
+func inProject(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	previous, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(previous) })
+	writeFile(t, "flake.nix", "{ outputs = _: { }; }\n")
+	return dir
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func mustShellEnvKey(t *testing.T) string {
+	t.Helper()
+	key, err := shellEnvKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestEditingConfigInvalidatesShellEnv(t *testing.T) {
+	dir := inProject(t)
+	writeFile(t, configFile, "language = \"go\"\n")
+
+	// A cached environment made from the current files
+	cache := filepath.Join(dir, shellEnvDir)
+	writeFile(t, filepath.Join(cache, "default.sh"), "export GREETING=hello\n")
+	bash := filepath.Join(cache, "bash")
+	writeFile(t, bash, "")
+	meta, _ := json.Marshal(shellEnvMeta{Key: mustShellEnvKey(t), Bash: bash})
+	writeFile(t, filepath.Join(cache, "default.json"), string(meta))
+
+	// Without nix, an environment can only come from the cache
+	t.Setenv("PATH", t.TempDir())
+	env, err := cachedShellEnv("", false)
+	if err != nil {
+		t.Fatalf("the fresh cache isn't used: %s", err)
+	}
+	if env.Bash != bash {
+		t.Errorf("bash is %s, want the cached %s", env.Bash, bash)
+	}
+
+	writeFile(t, configFile, "language = \"rust\"\n")
+	if _, err := cachedShellEnv("", false); err == nil {
+		t.Error("the cache is used after glot.toml changed")
+	}
+}
+
+func TestShellEnvKeyCoversTrackedNixFiles(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git isn't installed")
+	}
+	inProject(t)
+	writeFile(t, "nix/tools.nix", "{ pkgs }: [ pkgs.jq ]\n")
+	if err := exec.Command("git", "init", "--quiet").Run(); err != nil {
+		t.Fatal(err)
+	}
+	untracked := mustShellEnvKey(t)
+	if err := exec.Command("git", "add", "nix/tools.nix").Run(); err != nil {
+		t.Fatal(err)
+	}
+	tracked := mustShellEnvKey(t)
+	if tracked == untracked {
+		t.Error("tracking nix/tools.nix doesn't change the key")
+	}
+	writeFile(t, "nix/tools.nix", "{ pkgs }: [ pkgs.jq pkgs.yq ]\n")
+	if mustShellEnvKey(t) == tracked {
+		t.Error("editing nix/tools.nix doesn't change the key")
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// smokeConfig is the [smoke] section of glot.toml: a run of the freshly
+// built release binary, or of the image glot deploy pushes, that must
+// behave as expected
+type smokeConfig struct {
+	Args     []string `toml:"args"`      // Arguments of the binary, or of the image's entrypoint
+	Binary   string   `toml:"binary"`    // Binary of the release's bin/ (default: the only one)
+	ExitCode *int     `toml:"exit-code"` // Expected exit status (default: 0)
+	Output   string   `toml:"output"`    // Regular expression the output, stdout and stderr, must match
+	Timeout  string   `toml:"timeout"`   // How long the run may take, e.g. "10s" (default: 30s)
+}
+
+// How long a smoke test may take by default
+const defaultSmokeTimeout = 30 * time.Second
+
+func (s smokeConfig) configured() bool {
+	return len(s.Args) > 0 || s.Binary != "" || s.ExitCode != nil || s.Output != "" || s.Timeout != ""
+}
+
+// Run command as the smoke test: it must finish within the timeout, with
+// the expected exit status and output. The output is shown if it fails.
+func runSmokeTest(smoke smokeConfig, command []string) error {
+	timeout := defaultSmokeTimeout
+	if smoke.Timeout != "" {
+		timeout, _ = time.ParseDuration(smoke.Timeout)
+	}
+	expected := 0
+	if smoke.ExitCode != nil {
+		expected = *smoke.ExitCode
+	}
+	display := shellQuote(command)
+	info(tr("SmokeStarted", "Command", display))
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	var output bytes.Buffer
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	// Own process group, so a timeout also stops what the binary started
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error { return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL) }
+	cmd.WaitDelay = 5 * time.Second
+	logCommand(cmd)
+	started := time.Now()
+	err := cmd.Run()
+
+	code := 0
+	var exitErr *exec.ExitError
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		err = fmt.Errorf("%s", tr("SmokeTimeout", "Timeout", timeout, "Command", display))
+	case errors.As(err, &exitErr):
+		code = exitErr.ExitCode()
+		err = nil
+	case err != nil:
+		err = fmt.Errorf("%s", tr("SmokeNotStarted", "Command", display, "Error", err))
+	}
+	if err == nil && code != expected {
+		err = fmt.Errorf("%s", tr("SmokeExitCode", "Code", code, "Expected", expected, "Command", display))
+	}
+	if err == nil && smoke.Output != "" && !regexp.MustCompile(smoke.Output).Match(output.Bytes()) {
+		err = fmt.Errorf("%s", tr("SmokeOutput", "Pattern", smoke.Output, "Command", display))
+	}
+	if err != nil {
+		if output.Len() > 0 {
+			stderr := newRedactingWriter(logged(stderrOutput()))
+			stderr.Write(output.Bytes())
+			stderr.Flush()
+		}
+		return err
+	}
+	success(tr("SmokePassed", "Command", display, "Duration", time.Since(started).Round(time.Millisecond)))
+	return nil
+}
+
+// Build the release variant and smoke-test its binary, if [smoke] is
+// configured
+func smokeTestRelease() error {
+	smoke := currentConfig.Smoke
+	if !smoke.configured() {
+		return nil
+	}
+	ref, name, err := currentConfig.installable("", "release")
+	if err != nil {
+		return err
+	}
+	out, err := nixOutput("build", ref, "--no-link", "--print-out-paths")
+	if err != nil {
+		return fmt.Errorf("%s", tr("SmokeBuildFailed", "Target", name, "Error", strings.TrimSpace(err.Error())))
+	}
+	bin := filepath.Join(strings.Fields(string(out))[0], "bin")
+	entries, _ := os.ReadDir(bin)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	binary := smoke.Binary
+	switch {
+	case len(names) == 0:
+		return fmt.Errorf("%s", tr("SmokeNoBinaries", "Target", name))
+	case binary == "" && len(names) > 1:
+		return fmt.Errorf("%s", tr("SmokeBinaryAmbiguous", "Target", name, "Available", strings.Join(names, ", ")))
+	case binary == "":
+		binary = names[0]
+	}
+	if _, err := os.Stat(filepath.Join(bin, binary)); err != nil {
+		return fmt.Errorf("%s", tr("SmokeNoBinary", "Target", name, "Binary", binary, "Available", strings.Join(names, ", ")))
+	}
+	return runSmokeTest(smoke, append([]string{filepath.Join(bin, binary)}, smoke.Args...))
+}
+
+// Smoke-test an image tarball, if [smoke] is configured: load it into
+// docker or podman and run it with the arguments. Without either, the
+// release binary is tested instead.
+func smokeTestImage(tarball string) error {
+	smoke := currentConfig.Smoke
+	if !smoke.configured() {
+		return nil
+	}
+	runtime, err := containerRuntime()
+	if err != nil {
+		warning(tr("SmokeNoRuntime", "Error", err))
+		return smokeTestRelease()
+	}
+	load := exec.Command(runtime, "load", "--input", tarball)
+	logCommand(load)
+	out, err := load.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s load: %s: %s", runtime, exitStatus(err), strings.TrimSpace(string(out)))
+	}
+	// docker says "Loaded image: name:tag", podman "Loaded image: localhost/name:tag"
+	image := ""
+	for _, line := range strings.Split(string(out), "\n") {
+		if _, reference, ok := strings.Cut(line, "Loaded image"); ok {
+			image = strings.TrimSpace(reference[strings.Index(reference, ":")+1:])
+		}
+	}
+	if image == "" {
+		return fmt.Errorf("%s", tr("SmokeNoImageName", "Runtime", runtime, "Output", strings.TrimSpace(string(out))))
+	}
+	return runSmokeTest(smoke, append([]string{runtime, "run", "--rm", image}, smoke.Args...))
+}
+
+func validateSmokeConfig(path string, smoke smokeConfig) error {
+	if smoke.Timeout != "" {
+		if timeout, err := time.ParseDuration(smoke.Timeout); err != nil || timeout <= 0 {
+			return fmt.Errorf("%s", tr("ConfigInvalid", "File", path, "Error", tr("SmokeInvalidTimeout")))
+		}
+	}
+	if smoke.Output != "" {
+		if _, err := regexp.Compile(smoke.Output); err != nil {
+			return fmt.Errorf("%s", tr("ConfigInvalid", "File", path, "Error", fmt.Sprintf("smoke.output: %s", err)))
+		}
+	}
+	if strings.Contains(smoke.Binary, "/") {
+		return fmt.Errorf("%s", tr("ConfigInvalid", "File", path, "Error", tr("SmokeBinaryPath")))
+	}
+	return nil
+}
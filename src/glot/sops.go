@@ -0,0 +1,241 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	// sops configuration of a project, with the age recipients of its secrets
+	sopsConfigFile = ".sops.yaml"
+	// The developer's age key, ignored by git, unless $SOPS_AGE_KEY_FILE is set
+	projectAgeKeyFile = "keys.txt"
+	// Plaintext example `glot secrets init` encrypts, from the secrets feature
+	secretsExampleFile = "secrets/secrets.example.yaml"
+)
+
+// The age recipients of a .sops.yaml creation rule, comma-separated
+var sopsAgeRecipients = regexp.MustCompile(`(?m)^(\s*age:\s*)(.*)$`)
+
+// The age key sops decrypts with: $SOPS_AGE_KEY_FILE, or the project's
+// keys.txt, which is put in the environment so sops finds it without direnv
+func ageKeyFile() string {
+	if file := os.Getenv("SOPS_AGE_KEY_FILE"); file != "" {
+		return file
+	}
+	file, err := filepath.Abs(projectAgeKeyFile)
+	if err != nil {
+		return projectAgeKeyFile
+	}
+	if _, err := os.Stat(file); err == nil {
+		os.Setenv("SOPS_AGE_KEY_FILE", file)
+	}
+	return file
+}
+
+// The sops-encrypted files of the [secrets] sources
+func sopsSecretFiles(config secretsConfig) []string {
+	var files []string
+	for _, source := range config.Sources {
+		if source.Provider == "sops" {
+			files = append(files, source.File)
+		}
+	}
+	return files
+}
+
+// Files given as arguments, or those of the sops sources in glot.toml
+func sopsFilesFor(args []string) ([]string, error) {
+	if len(args) > 0 {
+		return args, nil
+	}
+	files := sopsSecretFiles(currentConfig.Secrets)
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%s", tr("SopsNoSource", "Config", configFile))
+	}
+	return files, nil
+}
+
+// Run sops in the dev shell, attached to the terminal for editors
+func runSops(args ...string) error {
+	ageKeyFile()
+	sops := exec.Command("nix", withNixGlobalArgs(append([]string{"develop", "--command", "sops"}, args...))...)
+	sops.Stdin = os.Stdin
+	sops.Stdout = os.Stdout
+	sops.Stderr = os.Stderr
+	logCommand(sops)
+	return sops.Run()
+}
+
+// Add an age recipient to the creation rules of .sops.yaml, returning
+// whether it was missing
+func addSopsRecipient(recipient string) (bool, error) {
+	content, err := os.ReadFile(sopsConfigFile)
+	if err != nil {
+		return false, fmt.Errorf("%s", tr("SopsConfigUnreadable", "File", sopsConfigFile, "Error", err))
+	}
+	if !sopsAgeRecipients.Match(content) {
+		return false, fmt.Errorf("%s", tr("SopsNoRecipients", "File", sopsConfigFile))
+	}
+	added := false
+	content = sopsAgeRecipients.ReplaceAllFunc(content, func(line []byte) []byte {
+		m := sopsAgeRecipients.FindSubmatch(line)
+		value := strings.Trim(strings.TrimSpace(string(m[2])), `"'`)
+		var recipients []string
+		for _, r := range strings.Split(value, ",") {
+			if r = strings.TrimSpace(r); r != "" {
+				recipients = append(recipients, r)
+			}
+		}
+		for _, r := range recipients {
+			if r == recipient {
+				return line
+			}
+		}
+		added = true
+		return []byte(string(m[1]) + strings.Join(append(recipients, recipient), ","))
+	})
+	if !added {
+		return false, nil
+	}
+	return true, os.WriteFile(sopsConfigFile, content, 0644)
+}
+
+// Create the developer's age key if needed, make it a recipient of the
+// project's secrets and encrypt the example secrets if there are none yet
+func initSecrets() error {
+	keyFile := ageKeyFile()
+	if _, err := os.Stat(keyFile); os.IsNotExist(err) {
+		info(tr("SopsGeneratingKey", "File", keyFile))
+		if err := os.MkdirAll(filepath.Dir(keyFile), 0700); err != nil {
+			return err
+		}
+		if err := runInDevShell("age-keygen", "-o", keyFile); err != nil {
+			return fmt.Errorf("%s", tr("SopsKeygenFailed", "Error", err))
+		}
+		os.Setenv("SOPS_AGE_KEY_FILE", keyFile)
+	}
+	out, err := nixOutput("develop", "--command", "age-keygen", "-y", keyFile)
+	if err != nil {
+		return fmt.Errorf("%s", tr("SopsPublicKeyFailed", "File", keyFile, "Error", err))
+	}
+	recipient := strings.TrimSpace(string(out))
+	added, err := addSopsRecipient(recipient)
+	if err != nil {
+		return err
+	}
+	if added {
+		success(tr("SopsRecipientAdded", "Recipient", recipient, "File", sopsConfigFile))
+	}
+
+	for _, file := range sopsSecretFiles(currentConfig.Secrets) {
+		if _, err := os.Stat(file); err == nil {
+			if added {
+				info(tr("SopsNeedsRotate", "File", file))
+			}
+			continue
+		}
+		if _, err := os.Stat(secretsExampleFile); err != nil {
+			continue
+		}
+		info(tr("SopsEncrypting", "Example", secretsExampleFile, "File", file))
+		if err := runSops("--encrypt", "--output", file, secretsExampleFile); err != nil {
+			return fmt.Errorf("%s", tr("SopsEncryptFailed", "File", file, "Error", err))
+		}
+		success(tr("SopsCreated", "File", file))
+	}
+	return nil
+}
+
+func newSecretsCmd() *cobra.Command {
+	secretsCmd := &cobra.Command{
+		Use:   "secrets",
+		Short: "Manage sops-encrypted secrets",
+		Long: "Manage the project's sops-encrypted secrets, as set up by the secrets feature of 'glot new'. " +
+			"sops runs in the dev shell, with the project's keys.txt as the age key unless $SOPS_AGE_KEY_FILE is set.",
+	}
+
+	initCmd := &cobra.Command{
+		Use:   "init",
+		Short: "Set up your age key for the project's secrets",
+		Long: "Generate an age key in keys.txt if there is none, add its public key to the recipients in .sops.yaml, " +
+			"and encrypt secrets/secrets.example.yaml into the sops sources of glot.toml that don't exist yet.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkNix(); err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			if err := initSecrets(); err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			return nil
+		},
+	}
+
+	editCmd := &cobra.Command{
+		Use:   "edit [file]",
+		Short: "Edit encrypted secrets",
+		Long: "Open an encrypted secrets file in $EDITOR with sops, which encrypts it again on save. " +
+			"Without a file, edit the first sops source of glot.toml.",
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkNix(); err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			files, err := sopsFilesFor(args)
+			if err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			if err := runSops(files[0]); err != nil {
+				errorMsg(tr("SopsEditFailed", "File", files[0]))
+				return err
+			}
+			return nil
+		},
+	}
+
+	rotateCmd := &cobra.Command{
+		Use:   "rotate [files...]",
+		Short: "Re-encrypt secrets for the current recipients",
+		Long: "Update the encrypted files' recipients to those of .sops.yaml and rotate their data keys, " +
+			"so keys added with 'glot secrets init' gain access and removed ones lose it. " +
+			"Without files, rotate the sops sources of glot.toml.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkNix(); err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			files, err := sopsFilesFor(args)
+			if err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			for _, file := range files {
+				info(tr("SopsRotating", "File", file))
+				if err := runSops("updatekeys", "--yes", file); err != nil {
+					errorMsg(tr("SopsUpdateKeysFailed", "File", file))
+					return err
+				}
+				if err := runSops("--rotate", "--in-place", file); err != nil {
+					errorMsg(tr("SopsRotateFailed", "File", file))
+					return err
+				}
+			}
+			success(trn("SopsRotated", len(files), "Config", sopsConfigFile))
+			return nil
+		},
+	}
+
+	secretsCmd.AddCommand(initCmd, editCmd, rotateCmd)
+	return secretsCmd
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// How a binary is linked, from file, or ldd where file isn't installed:
+// whether it is static, and the description or libraries to show for it.
+// ok is false when neither tool can tell.
+func binaryLinkage(path string) (static bool, description string, ok bool) {
+	if out, err := exec.Command("file", "-L", "-b", path).Output(); err == nil {
+		description := strings.TrimSpace(string(out))
+		if !strings.Contains(description, "ELF") {
+			// A script or data, which links to nothing
+			return true, description, true
+		}
+		static := strings.Contains(description, "statically linked") || strings.Contains(description, "static-pie linked")
+		return static, description, true
+	}
+	out, err := exec.Command("ldd", path).CombinedOutput()
+	if _, notFound := err.(*exec.Error); notFound {
+		return false, "", false
+	}
+	// ldd refuses binaries without a dynamic section
+	if strings.Contains(string(out), "not a dynamic executable") || strings.Contains(string(out), "statically linked") {
+		return true, "ldd: " + strings.TrimSpace(string(out)), true
+	}
+	if err != nil {
+		return false, "", false
+	}
+	return false, "ldd lists shared libraries", true
+}
+
+// Libraries a dynamically linked binary loads, as ldd lists them
+func linkedLibraries(path string) []string {
+	out, err := exec.Command("ldd", path).Output()
+	if err != nil {
+		return nil
+	}
+	var libraries []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if fields := strings.Fields(line); len(fields) > 0 && !strings.HasPrefix(fields[0], "linux-vdso") {
+			libraries = append(libraries, filepath.Base(fields[0]))
+		}
+	}
+	return libraries
+}
+
+// Check that the binaries of a build result are statically linked, telling
+// the user what file or ldd says about each
+func verifyStaticBinaries(result string) error {
+	entries, err := os.ReadDir(filepath.Join(result, "bin"))
+	if err != nil || len(entries) == 0 {
+		warning(tr("StaticNoBinaries", "Result", result))
+		return nil
+	}
+	var dynamic []string
+	for _, entry := range entries {
+		path := filepath.Join(result, "bin", entry.Name())
+		static, description, ok := binaryLinkage(path)
+		if !ok {
+			warning(tr("StaticNotVerified"))
+			return nil
+		}
+		if !static {
+			dynamic = append(dynamic, entry.Name())
+			warning(tr("StaticBinaryDynamic", "Binary", entry.Name(), "Description", description))
+			if libraries := linkedLibraries(path); len(libraries) > 0 {
+				info(tr("StaticLibraries", "Libraries", strings.Join(libraries, ", ")))
+			}
+			continue
+		}
+		success(tr("StaticBinaryVerified", "Binary", entry.Name(), "Description", description))
+	}
+	if len(dynamic) > 0 {
+		return fmt.Errorf("%s", tr("StaticDynamic", "Binaries", strings.Join(dynamic, ", ")))
+	}
+	return nil
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// A metric of glot stats export and its samples
+type metricFamily struct {
+	name    string // Without _total for counters
+	typ     string // counter, gauge or summary
+	help    string
+	samples []metricSample
+}
+
+type metricSample struct {
+	suffix string // _total, _count, _sum or empty
+	labels [][2]string
+	value  float64
+}
+
+func (f *metricFamily) add(suffix string, value float64, labels ...[2]string) {
+	f.samples = append(f.samples, metricSample{suffix: suffix, labels: labels, value: value})
+}
+
+func label(name, value string) [2]string {
+	return [2]string{name, value}
+}
+
+// The project's metrics, from its run records, build history and check
+// metrics, each sample labeled with project
+func collectStats(project string) ([]*metricFamily, error) {
+	records, err := listRunRecords()
+	if err != nil {
+		return nil, err
+	}
+	runs := &metricFamily{name: "glot_runs", typ: "counter", help: "glot commands run, by command and result"}
+	runDurations := &metricFamily{name: "glot_run_duration_seconds", typ: "summary", help: "How long glot commands took"}
+	passRatio := &metricFamily{name: "glot_run_pass_ratio", typ: "gauge", help: "Share of the recorded runs of a command that succeeded"}
+	lastSuccess := &metricFamily{name: "glot_run_last_success", typ: "gauge", help: "Whether the last run of a command succeeded"}
+	lastRun := &metricFamily{name: "glot_run_last_timestamp_seconds", typ: "gauge", help: "When a command last ran"}
+
+	type runTotals struct {
+		passed, failed int
+		seconds        float64
+		last           runRecord
+	}
+	byCommand := map[string]*runTotals{}
+	// Newest first, so the first record of a command is its last run
+	for _, record := range records {
+		totals := byCommand[record.Command]
+		if totals == nil {
+			totals = &runTotals{last: record}
+			byCommand[record.Command] = totals
+		}
+		if record.Success {
+			totals.passed++
+		} else {
+			totals.failed++
+		}
+		if duration, err := time.ParseDuration(record.Duration); err == nil {
+			totals.seconds += duration.Seconds()
+		}
+	}
+	for _, command := range sortedKeys(byCommand) {
+		totals := byCommand[command]
+		project, command := label("project", project), label("command", command)
+		runs.add("_total", float64(totals.passed), project, command, label("result", "success"))
+		runs.add("_total", float64(totals.failed), project, command, label("result", "failure"))
+		runDurations.add("_count", float64(totals.passed+totals.failed), project, command)
+		runDurations.add("_sum", totals.seconds, project, command)
+		passRatio.add("", float64(totals.passed)/float64(totals.passed+totals.failed), project, command)
+		lastSuccess.add("", boolMetric(totals.last.Success), project, command)
+		lastRun.add("", float64(totals.last.Started.Unix()), project, command)
+	}
+
+	builds := &metricFamily{name: "glot_build_duration_seconds", typ: "summary", help: "How long successful builds took, by installable"}
+	lastBuild := &metricFamily{name: "glot_build_last_duration_seconds", typ: "gauge", help: "How long the last successful build of an installable took"}
+	derivations := &metricFamily{name: "glot_build_derivations", typ: "counter", help: "Store paths of builds, built locally or downloaded from a binary cache"}
+	cacheHits := &metricFamily{name: "glot_build_cache_hit_ratio", typ: "gauge", help: "Share of the store paths of builds downloaded instead of built"}
+	history := loadBuildHistory()
+	for _, installable := range sortedKeys(history) {
+		entries := history[installable]
+		project, installableLabel := label("project", project), label("installable", installable)
+		var total, built, downloaded float64
+		for _, entry := range entries {
+			total += float64(entry.DurationMs) / 1000
+			if entry.Built != nil && entry.Downloaded != nil {
+				built += float64(*entry.Built)
+				downloaded += float64(*entry.Downloaded)
+			}
+		}
+		builds.add("_count", float64(len(entries)), project, installableLabel)
+		builds.add("_sum", total, project, installableLabel)
+		lastBuild.add("", float64(entries[len(entries)-1].DurationMs)/1000, project, installableLabel)
+		if built+downloaded > 0 {
+			derivations.add("_total", built, project, installableLabel, label("source", "built"))
+			derivations.add("_total", downloaded, project, installableLabel, label("source", "downloaded"))
+			cacheHits.add("", downloaded/(built+downloaded), project, installableLabel)
+		}
+	}
+
+	families := []*metricFamily{runs, runDurations, passRatio, lastSuccess, lastRun, builds, lastBuild, derivations, cacheHits}
+	if health := lastHealth(); health != nil {
+		score := &metricFamily{name: "glot_health_score", typ: "gauge", help: "Score of the last glot health, from 0 to 100, overall and by signal"}
+		score.add("", float64(health.Score), label("project", project), label("signal", "all"))
+		for _, signal := range sortedKeys(health.Scores) {
+			score.add("", float64(health.Scores[signal]), label("project", project), label("signal", signal))
+		}
+		families = append(families, score)
+	}
+	if metrics := lastCheckMetrics(); metrics != nil {
+		project := label("project", project)
+		check := func(name, help string, value float64) {
+			family := &metricFamily{name: name, typ: "gauge", help: help}
+			family.add("", value, project)
+			families = append(families, family)
+		}
+		check("glot_check_last_timestamp_seconds", "When glot check last passed", float64(metrics.Time.Unix()))
+		if metrics.LintWarnings != nil {
+			check("glot_check_lint_warnings", "Lint findings of the last passing glot check", float64(*metrics.LintWarnings))
+		}
+		if metrics.Coverage != nil {
+			check("glot_check_coverage_ratio", "Test coverage of the last passing glot check", *metrics.Coverage/100)
+		}
+		if metrics.BinarySize != nil {
+			check("glot_check_binary_size_bytes", "Largest binary of the last passing glot check", float64(*metrics.BinarySize))
+		}
+		if metrics.BuildTimeMs != nil {
+			check("glot_check_build_duration_seconds", "Build time of the last passing glot check", float64(*metrics.BuildTimeMs)/1000)
+		}
+	}
+	return families, nil
+}
+
+func boolMetric(value bool) float64 {
+	if value {
+		return 1
+	}
+	return 0
+}
+
+// Escape a label value of the text formats
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatMetricValue(value float64) string {
+	if math.IsNaN(value) {
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// Write metrics in the OpenMetrics text format, or Prometheus' (0.0.4) with
+// openMetrics false, which names counters with _total and has no # EOF
+func writeMetrics(w io.Writer, families []*metricFamily, openMetrics bool) {
+	for _, family := range families {
+		if len(family.samples) == 0 {
+			continue
+		}
+		name := family.name
+		if family.typ == "counter" && !openMetrics {
+			name += "_total"
+		}
+		fmt.Fprintf(w, "# HELP %s %s\n", name, family.help)
+		fmt.Fprintf(w, "# TYPE %s %s\n", name, family.typ)
+		for _, sample := range family.samples {
+			var labels []string
+			for _, l := range sample.labels {
+				labels = append(labels, fmt.Sprintf(`%s="%s"`, l[0], labelEscaper.Replace(l[1])))
+			}
+			fmt.Fprintf(w, "%s%s{%s} %s\n", family.name, sample.suffix, strings.Join(labels, ","), formatMetricValue(sample.value))
+		}
+	}
+	if openMetrics {
+		fmt.Fprintln(w, "# EOF")
+	}
+}
+
+// Replace the project's metrics on a Prometheus Pushgateway, grouped by
+// job and project
+func pushMetrics(gateway, job, project string, families []*metricFamily) error {
+	var body bytes.Buffer
+	writeMetrics(&body, families, false)
+	target := strings.TrimSuffix(gateway, "/") + "/metrics/job/" + url.PathEscape(job) + "/project/" + url.PathEscape(project)
+	req, err := http.NewRequest("PUT", target, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	req.Header.Set("User-Agent", "glot/"+version)
+	resp, err := (&http.Client{Timeout: 30 * time.Second}).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		reply, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%s", tr("StatsPushRejected", "Target", target, "Status", resp.Status, "Reply", strings.TrimSpace(string(reply))))
+	}
+	return nil
+}
+
+func newStatsCmd() *cobra.Command {
+	statsCmd := &cobra.Command{
+		Use:   "stats",
+		Short: "Export the project's build and check statistics",
+		Long: "Statistics of the project's glot runs: build durations and cache hits from " + buildHistoryFile +
+			", outcomes of build, test and check runs from " + logsDir + ", and the last passing check's metrics from " + metricsFile + ".",
+	}
+
+	exportCmd := &cobra.Command{
+		Use:   "export",
+		Short: "Print the statistics as OpenMetrics, or push them to a Pushgateway",
+		Long: "Print the project's statistics in the OpenMetrics text format (or Prometheus' with --format prometheus) " +
+			"for a scrape endpoint, or node_exporter's textfile collector with --format prometheus, or with --push, replace the project's group on a " +
+			"Prometheus Pushgateway. Samples are labeled with the project, the name of its directory unless --project is given.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format, _ := cmd.Flags().GetString("format")
+			if format != "openmetrics" && format != "prometheus" {
+				err := fmt.Errorf("%s", tr("StatsUnknownFormat", "Format", format))
+				errorMsg(err.Error())
+				return err
+			}
+			project, _ := cmd.Flags().GetString("project")
+			if project == "" {
+				cwd, err := os.Getwd()
+				if err != nil {
+					errorMsg(err.Error())
+					return err
+				}
+				project = filepath.Base(cwd)
+			}
+			families, err := collectStats(project)
+			if err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+
+			if gateway, _ := cmd.Flags().GetString("push"); gateway != "" {
+				job, _ := cmd.Flags().GetString("job")
+				if err := pushMetrics(gateway, job, project, families); err != nil {
+					errorMsg(tr("StatsPushFailed", "Error", err))
+					return err
+				}
+				success(tr("StatsPushed", "Project", project, "Gateway", gateway))
+				return nil
+			}
+
+			output, _ := cmd.Flags().GetString("output")
+			if output == "" {
+				writeMetrics(os.Stdout, families, format == "openmetrics")
+				return nil
+			}
+			var content bytes.Buffer
+			writeMetrics(&content, families, format == "openmetrics")
+			// Written whole, so a textfile collector never reads half a file
+			temp := output + ".tmp"
+			if err := os.WriteFile(temp, content.Bytes(), 0644); err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			if err := os.Rename(temp, output); err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			success(tr("StatsWritten", "Output", output))
+			return nil
+		},
+	}
+	exportCmd.Flags().String("format", "openmetrics", "Text format: openmetrics or prometheus")
+	exportCmd.Flags().StringP("output", "o", "", "File to write, replaced whole, e.g. for node_exporter's textfile collector (default: stdout)")
+	exportCmd.Flags().String("push", "", "Prometheus Pushgateway URL to push to instead, e.g. http://pushgateway:9091")
+	exportCmd.Flags().String("job", "glot", "Job label of the pushed group")
+	exportCmd.Flags().String("project", "", "Project label (default: the name of the project's directory)")
+
+	statsCmd.AddCommand(exportCmd)
+	return statsCmd
+}
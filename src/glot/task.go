@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// Find a task by name
+func findTask(tasks []templateCommand, name string) (templateCommand, bool) {
+	for _, task := range tasks {
+		if task.Name == name {
+			return task, true
+		}
+	}
+	return templateCommand{}, false
+}
+
+// Tasks to run for a task: its dependencies, each once and after its own
+// dependencies, then the task itself
+func taskPlan(tasks []templateCommand, task templateCommand) ([]templateCommand, error) {
+	var plan []templateCommand
+	done := map[string]bool{}
+	visiting := map[string]bool{}
+	var visit func(task templateCommand, chain []string) error
+	visit = func(task templateCommand, chain []string) error {
+		chain = append(chain, task.Name)
+		if visiting[task.Name] {
+			return fmt.Errorf("%s", tr("TaskCycle", "Chain", strings.Join(chain, " -> ")))
+		}
+		if done[task.Name] {
+			return nil
+		}
+		visiting[task.Name] = true
+		for _, name := range task.Depends {
+			dependency, ok := findTask(tasks, name)
+			if !ok {
+				return fmt.Errorf("%s", tr("TaskUnknownDependency", "Task", task.Name, "Dependency", name))
+			}
+			if err := visit(dependency, chain); err != nil {
+				return err
+			}
+		}
+		visiting[task.Name] = false
+		done[task.Name] = true
+		plan = append(plan, task)
+		return nil
+	}
+	if err := visit(task, nil); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// Check that every task's dependencies exist and don't form a cycle
+func validateTaskDependencies(path string, tasks []templateCommand) error {
+	for _, task := range tasks {
+		if _, err := taskPlan(tasks, task); err != nil {
+			return fmt.Errorf("%s", tr("ConfigInvalid", "File", path, "Error", err))
+		}
+	}
+	return nil
+}
+
+// Run a task after its dependencies. Only the task itself gets the arguments.
+func runTask(task templateCommand, args []string) error {
+	plan, err := taskPlan(currentConfig.Tasks, task)
+	if err != nil {
+		errorMsg(err.Error())
+		return err
+	}
+	for _, dependency := range plan[:len(plan)-1] {
+		info(tr("TaskRunningDependency", "Dependency", dependency.Name, "Task", task.Name))
+		if err := runTemplateCommand(dependency, nil); err != nil {
+			return err
+		}
+	}
+	return runTemplateCommand(task, args)
+}
+
+// Print the tasks in glot.toml with their dependencies
+func printTasks() {
+	if len(currentConfig.Tasks) == 0 {
+		info(tr("TaskNone", "File", configFile))
+		return
+	}
+	fmt.Println(plain("📋 " + tr("TaskList")))
+	for _, task := range currentConfig.Tasks {
+		line := fmt.Sprintf("  %-20s %s", task.Name, task.Short)
+		if len(task.Depends) > 0 {
+			line += " " + tr("TaskAfter", "Tasks", strings.Join(task.Depends, ", "))
+		}
+		fmt.Println(strings.TrimRight(line, " "))
+	}
+}
+
+func newTaskCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "task <name> [args...]",
+		Short: "Run a task from glot.toml",
+		Long: "Run a task defined in the [[task]] entries of glot.toml inside the dev shell, after the " +
+			"tasks it depends on. Arguments after the name are passed to the task. Without a name, list the tasks.",
+		DisableFlagParsing: true,
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) > 0 {
+				return nil, cobra.ShellCompDirectiveDefault
+			}
+			var names []string
+			seen := map[string]bool{}
+			for _, task := range currentConfig.Tasks {
+				words := strings.Fields(task.Name)
+				if seen[words[0]] {
+					continue
+				}
+				seen[words[0]] = true
+				if len(words) == 1 {
+					names = append(names, words[0]+"\t"+task.Short)
+				} else {
+					names = append(names, words[0])
+				}
+			}
+			return names, cobra.ShellCompDirectiveNoFileComp
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 0 && (args[0] == "-h" || args[0] == "--help") {
+				return cmd.Help()
+			}
+			if len(args) == 0 || args[0] == "-l" || args[0] == "--list" {
+				printTasks()
+				return nil
+			}
+
+			// Multi-word task names ("db seed") take the longest matching prefix
+			for n := len(args); n > 0; n-- {
+				if task, ok := findTask(currentConfig.Tasks, strings.Join(args[:n], " ")); ok {
+					return runTask(task, args[n:])
+				}
+			}
+			err := fmt.Errorf("%s", tr("TaskUnknown", "Task", args[0]))
+			errorMsg(err.Error())
+			return err
+		},
+	}
+	// Documented for help; parsed by hand since arguments go to the task
+	cmd.Flags().BoolP("list", "l", false, "List the tasks in glot.toml")
+	return cmd
+}
@@ -0,0 +1,414 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// templateManifest is the evaluated template.nix of a template directory
+type templateManifest struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Language    string            `json:"language"`
+	Files       map[string]string `json:"files"`
+	// Files generated only for a platform (linux, darwin), by platform
+	PlatformFiles map[string]map[string]string `json:"platformFiles"`
+	// Shell commands run in the dev shell after the files are written
+	PostGenerate []string `json:"postGenerate"`
+	// Literal strings in paths and files replaced by e.g. "@projectName@"
+	Substitute map[string]string `json:"substitute"`
+	Dir        string            `json:"dir"`
+}
+
+// Normalizes an imported template.nix, mapping file sources to paths on disk
+const templateManifestExpr = `t: { name = t.name or ""; description = t.description or ""; language = t.language or ""; ` +
+	`files = builtins.mapAttrs (_: toString) (t.files or { }); ` +
+	`platformFiles = builtins.mapAttrs (_: builtins.mapAttrs (_: toString)) (t.platformFiles or { }); postGenerate = t.postGenerate or [ ]; substitute = t.substitute or { }; }`
+
+// Variables available in substitutions
+var templateVariables = []string{"projectName", "modulePath", "packageName"}
+
+// Files every template must provide
+var requiredTemplateFiles = []string{"flake.nix", ".envrc", ".gitignore"}
+
+// Build output and cache directories that are never part of a template
+var templateIgnoredDirs = map[string]bool{
+	".git": true, ".direnv": true, ".cache": true, ".glot": true,
+	".zig-cache": true, "zig-cache": true, "zig-out": true,
+	"target": true, "node_modules": true, "__pycache__": true,
+	"result": true,
+}
+
+var placeholderPattern = regexp.MustCompile(`@[A-Za-z_][A-Za-z0-9_]*@`)
+
+// templateLint collects problems found in a template
+type templateLint struct {
+	errors   []string
+	warnings []string
+}
+
+func (l *templateLint) fail(message string) {
+	l.errors = append(l.errors, message)
+}
+
+func (l *templateLint) warn(message string) {
+	l.warnings = append(l.warnings, message)
+}
+
+// Evaluate the template.nix of a template directory
+func loadTemplateManifest(dir string) (*templateManifest, error) {
+	manifest := filepath.Join(dir, "template.nix")
+	if _, err := os.Stat(manifest); err != nil {
+		return nil, fmt.Errorf("%s", tr("TemplateManifestMissing", "Manifest", manifest))
+	}
+	out, err := nixOutput("eval", "--json", "--file", manifest, "--apply", templateManifestExpr)
+	if err != nil {
+		return nil, fmt.Errorf("%s", tr("TemplateManifestEval", "Error", err))
+	}
+	var template templateManifest
+	if err := json.Unmarshal(out, &template); err != nil {
+		return nil, fmt.Errorf("%s", tr("TemplateManifestInvalid", "Error", err))
+	}
+	template.Dir = dir
+	return &template, nil
+}
+
+// Evaluate every template in a nix-polyglot source tree in one nix call
+func loadTemplates(root string) ([]*templateManifest, error) {
+	manifests, _ := filepath.Glob(filepath.Join(root, "templates", "*", "*", "template.nix"))
+	var dirs []string
+	for _, manifest := range manifests {
+		dirs = append(dirs, filepath.Dir(manifest))
+	}
+	if len(dirs) == 0 {
+		return nil, fmt.Errorf("%s", tr("TemplateNoneFound", "Root", root))
+	}
+	list, _ := json.Marshal(dirs)
+	expr := fmt.Sprintf(`map (dir: (%s) (import (dir + "/template.nix")) // { inherit dir; }) (builtins.fromJSON ''%s'')`,
+		templateManifestExpr, list)
+	out, err := nixOutput("eval", "--json", "--impure", "--expr", expr)
+	if err != nil {
+		return nil, fmt.Errorf("%s", tr("TemplatesEvalFailed", "Error", err))
+	}
+	var templates []*templateManifest
+	if err := json.Unmarshal(out, &templates); err != nil {
+		return nil, fmt.Errorf("%s", tr("TemplatesInvalid", "Error", err))
+	}
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Name < templates[j].Name })
+	return templates, nil
+}
+
+// The files generated for the platforms, all platforms' if none are given
+func (t *templateManifest) files(platforms []string) map[string]string {
+	files := map[string]string{}
+	for dest, source := range t.Files {
+		files[dest] = source
+	}
+	for platform, platformFiles := range t.PlatformFiles {
+		if platformSelected(platforms, platform) {
+			for dest, source := range platformFiles {
+				files[dest] = source
+			}
+		}
+	}
+	return files
+}
+
+// Replace the template's substitutions in text. Longer strings go first, so
+// a module path is replaced before the project name it contains, and
+// strings of a length in order, so the result never depends on map order.
+func (t *templateManifest) render(text string, vars map[string]string) string {
+	literals := sortedKeys(t.Substitute)
+	sort.SliceStable(literals, func(i, j int) bool { return len(literals[i]) > len(literals[j]) })
+
+	var pairs []string
+	for _, literal := range literals {
+		value := t.Substitute[literal]
+		for _, name := range sortedKeys(vars) {
+			value = strings.ReplaceAll(value, "@"+name+"@", vars[name])
+		}
+		pairs = append(pairs, literal, value)
+	}
+	return strings.NewReplacer(pairs...).Replace(text)
+}
+
+// Check template metadata, files and placeholders
+func lintTemplateStructure(dir string, template *templateManifest, lint *templateLint) {
+	if template.Name == "" {
+		lint.fail(tr("TemplateMissingName"))
+	}
+	if template.Description == "" {
+		lint.fail(tr("TemplateMissingDescription"))
+	}
+	if template.Language == "" {
+		lint.fail(tr("TemplateMissingLanguage"))
+	}
+	for _, file := range requiredTemplateFiles {
+		if _, ok := template.Files[file]; !ok {
+			lint.fail(tr("TemplateRequiredFile", "File", file))
+		}
+	}
+
+	for _, literal := range sortedKeys(template.Substitute) {
+		for _, placeholder := range placeholderPattern.FindAllString(template.Substitute[literal], -1) {
+			if !slices.Contains(templateVariables, strings.Trim(placeholder, "@")) {
+				lint.fail(tr("TemplateUnknownVariable", "Literal", literal, "Placeholder", placeholder, "Available", strings.Join(templateVariables, ", ")))
+			}
+		}
+	}
+
+	for _, platform := range sortedKeys(template.PlatformFiles) {
+		if _, ok := templatePlatforms[platform]; !ok {
+			lint.fail(tr("TemplateUnknownPlatform", "Platform", platform, "Available", strings.Join(platformNames(), ", ")))
+		}
+	}
+	files := template.files(nil)
+
+	listed := map[string]bool{}
+	nixSources := ""
+	for _, dest := range sortedKeys(files) {
+		source := files[dest]
+		listed[source] = true
+		content, err := os.ReadFile(source)
+		if err != nil {
+			lint.fail(tr("TemplateSourceMissing", "File", dest))
+			continue
+		}
+		if strings.HasSuffix(dest, ".nix") {
+			nixSources += string(content)
+		}
+	}
+
+	// Placeholders must be substituted by one of the template's nix files
+	for _, dest := range sortedKeys(files) {
+		content, err := os.ReadFile(files[dest])
+		if err != nil || isBinary(content) {
+			continue
+		}
+		if _, err := renderPlatformBlocks(string(content), nil); err != nil {
+			lint.fail(fmt.Sprintf("%s: %s", dest, err))
+		}
+		if strings.HasSuffix(dest, ".nix") {
+			continue
+		}
+		seen := map[string]bool{}
+		for _, placeholder := range placeholderPattern.FindAllString(string(content), -1) {
+			if !seen[placeholder] && !strings.Contains(nixSources, placeholder) {
+				lint.fail(tr("TemplateUnsubstituted", "File", dest, "Placeholder", placeholder))
+			}
+			seen[placeholder] = true
+		}
+	}
+
+	// Files in the directory that would not end up in generated projects
+	root, _ := filepath.Abs(dir)
+	filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if entry.IsDir() {
+			if templateIgnoredDirs[entry.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, _ := filepath.Rel(root, path)
+		if rel != "template.nix" && !listed[path] {
+			lint.warn(tr("TemplateUnlisted", "File", rel))
+		}
+		return nil
+	})
+}
+
+// Heuristic: content with NUL bytes is binary
+func isBinary(content []byte) bool {
+	for _, b := range content {
+		if b == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// One of the template's files, with its substitutions applied and only the
+// platforms' conditional content
+func renderTemplateFile(template *templateManifest, dest string, vars map[string]string, platforms []string) ([]byte, error) {
+	content, err := os.ReadFile(template.files(platforms)[dest])
+	if err != nil {
+		return nil, err
+	}
+	if !isBinary(content) {
+		text, err := renderPlatformBlocks(string(content), platforms)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", dest, err)
+		}
+		if dest == "flake.nix" {
+			text = gateFlakeSystems(text, platforms)
+		}
+		content = []byte(template.render(text, vars))
+	}
+	return content, nil
+}
+
+// Copy one of the template's files into dir, rendered for the platforms
+func writeTemplateFile(template *templateManifest, dir, dest string, vars map[string]string, platforms []string) error {
+	content, err := renderTemplateFile(template, dest, vars, platforms)
+	if err != nil {
+		return err
+	}
+	// With the source's permissions, so scripts stay executable
+	source, err := os.Stat(template.files(platforms)[dest])
+	if err != nil {
+		return err
+	}
+	target := filepath.Join(dir, template.render(dest, vars))
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(target, content, source.Mode().Perm())
+}
+
+func gitIn(dir string, args ...string) error {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// Run post-generate hooks in the project's dev shell, stopping at the first
+// failure. The running glot is put on PATH so hooks can call it.
+func runPostGenerateHooks(projectDir string, hooks []string) error {
+	env := os.Environ()
+	if self, err := os.Executable(); err == nil {
+		env = append(env, "PATH="+filepath.Dir(self)+string(os.PathListSeparator)+os.Getenv("PATH"))
+	}
+	for _, hook := range hooks {
+		info(tr("TemplateHookRunning", "Hook", hook))
+		stdout := newRedactingWriter(logged(os.Stdout))
+		stderr := newRedactingWriter(logged(stderrOutput()))
+		cmd := exec.Command("nix", withNixGlobalArgs([]string{"develop", "--command", "bash", "-c", hook})...)
+		cmd.Dir = projectDir
+		cmd.Env = env
+		cmd.Stdout = stdout
+		cmd.Stderr = stderr
+		cmd.Stdin = os.Stdin
+		logCommand(cmd)
+		step := startStep("post-generate: " + hook)
+		err := cmd.Run()
+		step.finish(err)
+		stdout.Flush()
+		stderr.Flush()
+		if err != nil {
+			return fmt.Errorf("%s", tr("TemplateHookFailed", "Hook", hook, "Error", err))
+		}
+		// Flakes only see files known to git, so later hooks need new files staged
+		if _, err := os.Stat(filepath.Join(projectDir, ".git")); err == nil {
+			if err := gitIn(projectDir, "add", "-A"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Scaffold the template and check that the result evaluates, builds and tests
+func lintTemplateRoundtrip(template *templateManifest, build bool, lint *templateLint) {
+	dir, err := os.MkdirTemp("", "glot-template-lint-")
+	if err != nil {
+		lint.fail(tr("TemplateScratchFailed", "Error", err))
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	info(tr("TemplateScaffolding"))
+	if err := createProject(template, dir, projectVariables("glot-lint-test", ""), nil, nil, scaffoldFresh, true); err != nil {
+		lint.fail(tr("TemplateScaffoldFailed", "Error", err))
+		return
+	}
+
+	info(tr("TemplateEvaluating"))
+	if err := runNixIn(dir, "flake", "show"); err != nil {
+		lint.fail(tr("TemplateNoEval"))
+		return
+	}
+	if !build {
+		return
+	}
+
+	info(tr("TemplateBuilding"))
+	if err := runNixIn(dir, "build", "--no-link"); err != nil {
+		lint.fail(tr("TemplateNoBuild"))
+		return
+	}
+	info(tr("TemplateRunningChecks"))
+	if err := runNixIn(dir, "flake", "check"); err != nil {
+		lint.fail(tr("TemplateChecksFail"))
+	}
+}
+
+func newTemplateCmd() *cobra.Command {
+	templateCmd := &cobra.Command{
+		Use:   "template",
+		Short: "Tools for template authors",
+	}
+
+	lintCmd := &cobra.Command{
+		Use:   "lint <dir>",
+		Short: "Validate a template directory",
+		Long: "Check a template's structure (template.nix metadata, required files, placeholders), " +
+			"then scaffold it and check that the generated project evaluates, builds and passes its checks.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkNixInstalled(); err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			dir := args[0]
+			quick, _ := cmd.Flags().GetBool("quick")
+			noBuild, _ := cmd.Flags().GetBool("no-build")
+
+			info(tr("TemplateLinting", "Dir", dir))
+			template, err := loadTemplateManifest(dir)
+			if err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+
+			lint := &templateLint{}
+			lintTemplateStructure(dir, template, lint)
+			if !quick && len(lint.errors) == 0 {
+				lintTemplateRoundtrip(template, !noBuild, lint)
+			}
+
+			sort.Strings(lint.warnings)
+			for _, w := range lint.warnings {
+				warning(w)
+			}
+			for _, e := range lint.errors {
+				errorMsg(e)
+			}
+			if len(lint.errors) > 0 {
+				return fmt.Errorf("%s", trn("TemplateProblems", len(lint.errors), "Name", template.Name))
+			}
+			success(tr("TemplatePassed", "Name", template.Name))
+			return nil
+		},
+	}
+	lintCmd.Flags().Bool("quick", false, "Only check structure, skip the scaffold roundtrip")
+	lintCmd.Flags().Bool("no-build", false, "Scaffold and evaluate, but don't build or run checks")
+
+	templateCmd.AddCommand(lintCmd)
+	return templateCmd
+}
@@ -0,0 +1,27 @@
+package main
+
+type terraformLanguage struct{ toolchain }
+
+func newTerraformLanguage() Language {
+	return terraformLanguage{toolchain{
+		name:       "terraform",
+		markers:    []string{"*.tf"},
+		lsp:        []string{"terraform-ls", "serve"},
+		lspPackage: "terraform-ls",
+		format:     []string{"tofu", "fmt", "-recursive"},
+		lint:       []string{"tflint", "--recursive"},
+		test:       []string{"tofu", "test"},
+		testWith: func(options testOptions) ([]string, error) {
+			command := []string{"tofu", "test"}
+			if options.Verbose {
+				command = append(command, "-verbose")
+			}
+			// Filters select test files
+			if options.Filter != "" {
+				command = append(command, "-filter="+options.Filter)
+			}
+			return append(command, options.Args...), nil
+		},
+		artifacts: []string{".terraform/"},
+	}}
+}
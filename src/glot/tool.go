@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/spf13/cobra"
+)
+
+// The executables of the dev shell's packages, by name: those of its PATH
+// in the nix store, the first of a name winning as in the shell. The host's
+// PATH, which the dev shell keeps, doesn't count.
+func devShellTools() (*shellEnv, map[string]string, error) {
+	env, err := cachedShellEnv("", false)
+	if err != nil {
+		return nil, nil, err
+	}
+	out, err := exec.Command(env.Bash, "-c", `. "$0" && printf %s "$PATH"`, env.Script).Output()
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s", tr("ToolNoPath", "Error", err))
+	}
+	tools := map[string]string{}
+	for _, dir := range filepath.SplitList(string(out)) {
+		if !strings.HasPrefix(dir, "/nix/store/") {
+			continue
+		}
+		entries, _ := os.ReadDir(dir)
+		for _, entry := range entries {
+			path := filepath.Join(dir, entry.Name())
+			if _, ok := tools[entry.Name()]; ok {
+				continue
+			}
+			if stat, err := os.Stat(path); err == nil && stat.Mode().IsRegular() && stat.Mode()&0111 != 0 {
+				tools[entry.Name()] = path
+			}
+		}
+	}
+	return env, tools, nil
+}
+
+// The package of a tool: /nix/store/<hash>-sqlc-1.25.0/bin/sqlc is sqlc-1.25.0
+func toolPackage(path string) string {
+	return storePathName(strings.Join(strings.SplitN(path, "/", 5)[:4], "/"))
+}
+
+func newToolCmd() *cobra.Command {
+	toolCmd := &cobra.Command{
+		Use:   "tool <name> [args...]",
+		Short: "Run a tool of the dev shell",
+		Long: "Run one of the dev shell's tools, at the version the flake pins, without entering the shell: glot tool sqlc generate. " +
+			"Only the executables of the shell's packages count, not those the host has in PATH. " +
+			"glot is replaced by the tool, as with glot exec. With --list, list the tools and their packages.",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if list, _ := cmd.Flags().GetBool("list"); list {
+				return cobra.NoArgs(cmd, args)
+			}
+			return cobra.MinimumNArgs(1)(cmd, args)
+		},
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) > 0 {
+				return nil, cobra.ShellCompDirectiveDefault
+			}
+			_, tools, err := devShellTools()
+			if err != nil {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			var names []string
+			for name := range tools {
+				if strings.HasPrefix(name, toComplete) {
+					names = append(names, name)
+				}
+			}
+			sort.Strings(names)
+			return names, cobra.ShellCompDirectiveNoFileComp
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkNix(); err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			env, tools, err := devShellTools()
+			if err != nil {
+				errorMsg(tr("LspShellFailed", "Error", err))
+				return err
+			}
+			if list, _ := cmd.Flags().GetBool("list"); list {
+				names := sortedKeys(tools)
+				width := 0
+				for _, name := range names {
+					width = max(width, len(name))
+				}
+				for _, name := range names {
+					fmt.Printf("%-*s  %s\n", width, name, toolPackage(tools[name]))
+				}
+				return nil
+			}
+
+			path, ok := tools[args[0]]
+			if !ok {
+				var similar []string
+				for _, name := range sortedKeys(tools) {
+					if strings.Contains(name, args[0]) || strings.Contains(args[0], name) {
+						similar = append(similar, name)
+					}
+				}
+				err := fmt.Errorf("%s", tr("ToolUnknown", "Tool", args[0], "File", configFile))
+				if len(similar) > 0 {
+					err = fmt.Errorf("%s", tr("ToolDidYouMean", "Tool", args[0], "Similar", strings.Join(similar, ", ")))
+				}
+				errorMsg(err.Error())
+				return err
+			}
+			debug(fmt.Sprintf("%s is %s, from %s", args[0], path, toolPackage(path)))
+			argv := append([]string{env.Bash}, env.commandArgs(append([]string{path}, args[1:]...))...)
+			logCommand(&exec.Cmd{Path: env.Bash, Args: argv})
+			err = syscall.Exec(env.Bash, argv, os.Environ())
+			errorMsg(tr("ToolRunFailed", "Tool", args[0], "Error", err))
+			return err
+		},
+	}
+	// Everything after the tool belongs to it: glot tool sqlc generate -f sqlc.yaml
+	toolCmd.Flags().SetInterspersed(false)
+	toolCmd.Flags().Bool("list", false, "List the dev shell's tools and the packages they come from")
+	return toolCmd
+}
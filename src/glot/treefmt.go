@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// treefmt configuration files, in the order treefmt looks for them
+var treefmtConfigFiles = []string{"treefmt.toml", ".treefmt.toml"}
+
+// treefmtFormatter is a [formatter.<name>] entry of treefmt.toml
+type treefmtFormatter struct {
+	Command  string   `toml:"command"`
+	Options  []string `toml:"options,omitempty"`
+	Includes []string `toml:"includes"`
+}
+
+type treefmtConfig struct {
+	Global struct {
+		Excludes []string `toml:"excludes"`
+	} `toml:"global"`
+	Formatters map[string]treefmtFormatter `toml:"formatter"`
+}
+
+// Formatters for the generated treefmt.toml, by language. C# has none:
+// dotnet format works on projects, not files.
+var treefmtFormatters = map[string]treefmtFormatter{
+	"nix":        {Command: "nixpkgs-fmt", Includes: []string{"*.nix"}},
+	"go":         {Command: "gofmt", Options: []string{"-w"}, Includes: []string{"*.go"}},
+	"rust":       {Command: "rustfmt", Options: []string{"--edition", "2021"}, Includes: []string{"*.rs"}},
+	"python":     {Command: "ruff", Options: []string{"format"}, Includes: []string{"*.py", "*.pyi"}},
+	"zig":        {Command: "zig", Options: []string{"fmt"}, Includes: []string{"*.zig"}},
+	"nim":        {Command: "nimpretty", Includes: []string{"*.nim"}},
+	"cpp":        {Command: "clang-format", Options: []string{"-i"}, Includes: []string{"*.c", "*.cc", "*.cpp", "*.h", "*.hpp"}},
+	"terraform":  {Command: "tofu", Options: []string{"fmt"}, Includes: []string{"*.tf", "*.tfvars"}},
+	"javascript": {Command: "prettier", Options: []string{"--write"}, Includes: []string{"*.js", "*.jsx", "*.ts", "*.tsx", "*.css"}},
+}
+
+// Paths treefmt never touches: lock files, dependencies and build output
+var treefmtExcludes = []string{"*.lock", "result*", ".direnv/*", "node_modules/*", "target/*", "vendor/*", "build/*", "zig-out/*"}
+
+// The project's treefmt configuration file, empty if it has none
+func findTreefmtConfig() string {
+	for _, file := range treefmtConfigFiles {
+		if _, err := os.Stat(file); err == nil {
+			return file
+		}
+	}
+	return ""
+}
+
+// Write treefmt.toml with formatters for nix and the languages of the
+// project's language roots, returning the languages covered
+func generateTreefmtConfig() ([]string, error) {
+	roots, err := findLanguageRoots()
+	if err != nil {
+		return nil, err
+	}
+	config := treefmtConfig{Formatters: map[string]treefmtFormatter{"nix": treefmtFormatters["nix"]}}
+	config.Global.Excludes = treefmtExcludes
+	for _, root := range roots {
+		if formatter, ok := treefmtFormatters[root.language.Name()]; ok {
+			config.Formatters[root.language.Name()] = formatter
+		}
+	}
+
+	var content bytes.Buffer
+	content.WriteString("# Generated by glot fmt --treefmt from the languages in this project.\n")
+	content.WriteString("# Edit freely; glot only writes this file when it is missing.\n\n")
+	encoder := toml.NewEncoder(&content)
+	encoder.Indent = ""
+	if err := encoder.Encode(config); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(treefmtConfigFiles[0], content.Bytes(), 0644); err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(config.Formatters))
+	for name := range config.Formatters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Format the project with treefmt in the dev shell, generating its
+// configuration first if the project has none. treefmt caches what it
+// formatted, so repeated runs only format changed files.
+func runTreefmt() error {
+	if findTreefmtConfig() == "" {
+		languages, err := generateTreefmtConfig()
+		if err != nil {
+			return fmt.Errorf("%s", tr("TreefmtCreateFailed", "File", treefmtConfigFiles[0], "Error", err))
+		}
+		success(tr("TreefmtCreated", "File", treefmtConfigFiles[0], "Languages", strings.Join(languages, ", ")))
+	}
+	return runInDevShell("treefmt", "--tree-root", ".")
+}
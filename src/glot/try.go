@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// Home directory of the program in the sandbox
+const sandboxHome = "/home/glot"
+
+// Host files a program on a fresh machine would find: name resolution,
+// certificates, users and the time zone
+var sandboxEtc = []string{"/etc/resolv.conf", "/etc/hosts", "/etc/nsswitch.conf", "/etc/passwd", "/etc/group",
+	"/etc/localtime", "/etc/ssl", "/etc/static", "/etc/pki"}
+
+// Environment variables passed on to the program
+var sandboxPassEnv = []string{"TERM", "LANG", "LC_ALL", "COLORTERM", "DISPLAY", "WAYLAND_DISPLAY"}
+
+// Build the release output, returning its bin directories
+func releaseBinDirs() ([]string, error) {
+	out, err := nixOutput("build", ".#release", "--no-link", "--print-out-paths")
+	if err != nil {
+		return nil, fmt.Errorf("%s", tr("ReleaseBuildFailed", "Error", err))
+	}
+	var dirs []string
+	for _, output := range strings.Fields(string(out)) {
+		if info, err := os.Stat(filepath.Join(output, "bin")); err == nil && info.IsDir() {
+			dirs = append(dirs, filepath.Join(output, "bin"))
+		}
+	}
+	if len(dirs) == 0 {
+		return nil, fmt.Errorf("%s", tr("ReleaseNoBinaries"))
+	}
+	return dirs, nil
+}
+
+// The program to try and its path: the given one, the only one, or the one
+// named after the project
+func tryProgram(binDirs []string, name string) (string, string, error) {
+	var names []string
+	paths := map[string]string{}
+	for _, dir := range binDirs {
+		entries, _ := os.ReadDir(dir)
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+			paths[entry.Name()] = filepath.Join(dir, entry.Name())
+		}
+	}
+	if name == "" {
+		if len(names) == 1 {
+			return names[0], paths[names[0]], nil
+		}
+		if cwd, err := os.Getwd(); err == nil && slices.Contains(names, filepath.Base(cwd)) {
+			return filepath.Base(cwd), paths[filepath.Base(cwd)], nil
+		}
+		return "", "", fmt.Errorf("%s", tr("TrySeveralPrograms", "Programs", strings.Join(names, ", ")))
+	}
+	if !slices.Contains(names, name) {
+		return "", "", fmt.Errorf("%s", tr("TryUnknownProgram", "Name", name, "Programs", strings.Join(names, ", ")))
+	}
+	return name, paths[name], nil
+}
+
+// Environment of the program: a fresh home, the release on PATH and the
+// terminal and locale of the user
+func sandboxEnv(home string, binDirs []string) []string {
+	env := []string{"HOME=" + home, "PATH=" + strings.Join(binDirs, ":"), "USER=glot", "TMPDIR=/tmp"}
+	for _, name := range sandboxPassEnv {
+		if value, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+value)
+		}
+	}
+	return env
+}
+
+// bubblewrap arguments running argv with nothing of the host but the nix
+// store, some of /etc and the home, which is bound to sandboxHome
+func bwrapArgs(home string, binDirs []string, network bool, argv []string) []string {
+	args := []string{"--die-with-parent", "--unshare-all"}
+	if network {
+		args = append(args, "--share-net")
+	}
+	args = append(args, "--ro-bind", "/nix/store", "/nix/store", "--proc", "/proc", "--dev", "/dev", "--tmpfs", "/tmp")
+	for _, path := range sandboxEtc {
+		args = append(args, "--ro-bind-try", path, path)
+	}
+	args = append(args, "--bind", home, sandboxHome, "--chdir", sandboxHome, "--clearenv")
+	for _, variable := range sandboxEnv(sandboxHome, binDirs) {
+		name, value, _ := strings.Cut(variable, "=")
+		args = append(args, "--setenv", name, value)
+	}
+	return append(append(args, "--"), argv...)
+}
+
+// Run argv in a sandbox with home as its home directory: bubblewrap on
+// Linux, a fresh home and environment elsewhere
+func runSandboxed(home string, binDirs []string, network bool, argv []string) error {
+	if runtime.GOOS != "linux" {
+		warning(tr("TryNoBubblewrap"))
+		if !network {
+			warning(tr("TryNetworkNeedsBubblewrap"))
+		}
+		env := append([]string{"-i"}, sandboxEnv(home, binDirs)...)
+		return runToolIn(home, "env", append(env, argv...)...)
+	}
+	args := bwrapArgs(home, binDirs, network, argv)
+	if _, err := exec.LookPath("bwrap"); err == nil {
+		return runTool("bwrap", args...)
+	}
+	return runNix(append([]string{"shell", "nixpkgs#bubblewrap", "--command", "bwrap"}, args...)...)
+}
+
+// Files the program left in its home, relative to it
+func homeChanges(home string) []string {
+	var files []string
+	filepath.WalkDir(home, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return nil
+		}
+		if rel, err := filepath.Rel(home, path); err == nil {
+			files = append(files, rel)
+		}
+		return nil
+	})
+	return files
+}
+
+func newTryCmd() *cobra.Command {
+	tryCmd := &cobra.Command{
+		Use:   "try [-- args...]",
+		Short: "Run the release build in a throwaway sandbox",
+		Long: "Build the release output and run it in an ephemeral sandbox, as on a fresh machine where it was just installed: " +
+			"an empty home directory, only the release on PATH and no access to your files. On Linux the sandbox is bubblewrap, " +
+			"from nixpkgs if it isn't installed; elsewhere only the home and environment are fresh. " +
+			"Afterwards, glot lists the files the program wrote to its home, and removes them unless --keep is given.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkNix(); err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			info(tr("TryBuilding"))
+			binDirs, err := releaseBinDirs()
+			if err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			bin, _ := cmd.Flags().GetString("bin")
+			program, path, err := tryProgram(binDirs, bin)
+			if err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			home, err := os.MkdirTemp("", "glot-try-")
+			if err != nil {
+				return err
+			}
+			keep, _ := cmd.Flags().GetBool("keep")
+			if !keep {
+				defer os.RemoveAll(home)
+			}
+
+			noNetwork, _ := cmd.Flags().GetBool("no-network")
+			info(tr("TryStarted", "Program", program))
+			runErr := runSandboxed(home, binDirs, !noNetwork, append([]string{path}, args...))
+
+			if files := homeChanges(home); len(files) > 0 {
+				info(tr("TryWroteHome", "Program", program))
+				for _, file := range files {
+					fmt.Println("  ~/" + file)
+				}
+			} else {
+				info(tr("TryHomeEmpty", "Program", program))
+			}
+			if keep {
+				info(tr("TryKeptHome", "Dir", home))
+			}
+			if runErr != nil {
+				errorMsg(tr("TryFailed", "Program", program, "Status", exitStatus(runErr)))
+				return runErr
+			}
+			return nil
+		},
+	}
+	tryCmd.Flags().String("bin", "", "Program of the release to run (default: the only one, or the one named after the project)")
+	tryCmd.Flags().Bool("keep", false, "Keep the sandbox's home directory to inspect what the program wrote")
+	tryCmd.Flags().Bool("no-network", false, "Run without network access")
+	return tryCmd
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// Worktrees of glot try-branch, one per branch, so runs on different
+// branches don't share result links, .glot state or language caches
+var tryBranchDir = filepath.Join(stateDir, "try-branch")
+
+var unsafeBranchChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// Resolve a branch to a commit: a local branch or other revision, the
+// branch of origin, or, unless offline, the branch fetched from origin
+func resolveBranch(branch string) (string, error) {
+	for _, rev := range []string{branch, "origin/" + branch} {
+		if out, err := exec.Command("git", "rev-parse", "--verify", "--quiet", rev+"^{commit}").Output(); err == nil {
+			return strings.TrimSpace(string(out)), nil
+		}
+	}
+	if offlineMode {
+		return "", fmt.Errorf("%s", tr("TryBranchOfflineUnknown", "Branch", branch))
+	}
+	info(tr("TryBranchFetching", "Branch", branch))
+	if err := gitIn(".", "fetch", "--quiet", "origin", branch); err != nil {
+		return "", fmt.Errorf("%s", tr("TryBranchUnknown", "Branch", branch))
+	}
+	out, err := exec.Command("git", "rev-parse", "FETCH_HEAD^{commit}").Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// Check out rev in the branch's worktree, reusing one a previous run kept,
+// so its result links and caches carry over. Returns the project's directory
+// in it and a function removing it.
+func branchWorktree(branch, rev string) (string, func(), error) {
+	dir := filepath.Join(tryBranchDir, unsafeBranchChars.ReplaceAllString(branch, "-"))
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err != nil {
+		return checkoutRevision(rev, dir)
+	}
+	worktree, err := filepath.Abs(dir)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := gitIn(worktree, "checkout", "--quiet", "--force", "--detach", rev); err != nil {
+		// Not a usable worktree any more; start over
+		return checkoutRevision(rev, dir)
+	}
+	debug(fmt.Sprintf("Reusing the worktree %s", worktree))
+	prefix, _ := exec.Command("git", "rev-parse", "--show-prefix").Output()
+	remove := func() {
+		if err := gitIn(".", "worktree", "remove", "--force", worktree); err != nil {
+			warning(tr("TryBranchRemoveFailed", "Worktree", worktree, "Error", err))
+		}
+	}
+	return filepath.Join(worktree, strings.TrimSpace(string(prefix))), remove, nil
+}
+
+func newTryBranchCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "try-branch <branch> [-- command [args...]]",
+		Short: "Run glot check, or another glot command, on a branch in a temporary worktree",
+		Long: "Check out a branch, a branch of origin (fetched if needed) or any revision in a worktree of its own and run " +
+			"glot check there, or the glot command after --, then report the result. The working tree, its result links, " +
+			"dev shell and caches are left alone, so a pull request can be tested mid-change, and runs on different " +
+			"branches can go on in parallel. Fails when the command fails.",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if dash := cmd.ArgsLenAtDash(); len(args) == 0 || !(dash == 1 || dash < 0 && len(args) == 1) {
+				return fmt.Errorf("%s", tr("TryBranchArgs"))
+			}
+			return nil
+		},
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) > 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			out, _ := exec.Command("git", "for-each-ref", "--format=%(refname:short)", "refs/heads", "refs/remotes").Output()
+			return strings.Fields(string(out)), cobra.ShellCompDirectiveNoFileComp
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			branch := args[0]
+			command := args[1:]
+			if len(command) == 0 {
+				command = []string{"check"}
+			}
+			if command[0] == "glot" {
+				command = command[1:]
+			}
+			self, err := os.Executable()
+			if err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			rev, err := resolveBranch(branch)
+			if err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+
+			dir, remove, err := branchWorktree(branch, rev)
+			if err != nil {
+				errorMsg(tr("TryBranchCheckoutFailed", "Branch", branch, "Error", err))
+				return err
+			}
+			if keep, _ := cmd.Flags().GetBool("keep"); keep {
+				defer info(tr("TryBranchKeeping", "Branch", branch, "Dir", dir))
+			} else {
+				defer remove()
+			}
+
+			label := tr("TryBranchLabel", "Command", strings.Join(command, " "), "Branch", branch, "Rev", rev[:12])
+			info(tr("TryBranchRunning", "Label", label))
+			started := time.Now()
+			err = runToolIn(dir, self, command...)
+			elapsed := time.Since(started).Round(time.Second)
+			fmt.Println()
+			if err != nil {
+				err = fmt.Errorf("%s", tr("TryBranchFailed", "Label", label, "Elapsed", elapsed))
+				errorMsg(err.Error())
+				return err
+			}
+			success(tr("TryBranchPassed", "Label", label, "Elapsed", elapsed))
+			return nil
+		},
+	}
+	cmd.Flags().Bool("keep", false, "Keep the worktree in "+tryBranchDir+", with its result links and caches, for the next run on the branch")
+	return cmd
+}
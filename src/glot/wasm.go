@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// The flake package of WebAssembly builds: WASI modules in bin/, which
+// nix-polyglot's Go, Rust and Zig outputs build for wasm32
+const wasmOutput = "wasm"
+
+// The .wasm modules in bin/ of a build result, sorted
+func wasmModules(result string) []string {
+	modules, _ := filepath.Glob(filepath.Join(result, "bin", "*.wasm"))
+	sort.Strings(modules)
+	return modules
+}
+
+// Tell the user which modules a wasm build produced, and how large they are
+func reportWasmModules(result string) error {
+	modules := wasmModules(result)
+	if len(modules) == 0 {
+		return fmt.Errorf("%s", tr("WasmNoModules", "Result", result))
+	}
+	for _, module := range modules {
+		size := "?"
+		if stat, err := os.Stat(module); err == nil {
+			size = formatSize(stat.Size())
+		}
+		success(tr("WasmModule", "Module", module, "Size", size))
+	}
+	return nil
+}
+
+// Build the flake's wasm package and pick the module to run: the only one,
+// or the one named by name, with or without .wasm
+func buildWasmModule(name string) (string, error) {
+	if err := checkTarget(wasmOutput, false); err != nil {
+		return "", fmt.Errorf("%s", tr("WasmNoOutput"))
+	}
+	out, err := nixOutput("build", ".#"+wasmOutput, "--no-link", "--print-out-paths")
+	if err != nil {
+		return "", fmt.Errorf("%s", tr("WasmBuildFailed", "Error", strings.TrimSpace(err.Error())))
+	}
+	modules := wasmModules(strings.Fields(string(out))[0])
+	var names []string
+	for _, module := range modules {
+		names = append(names, strings.TrimSuffix(filepath.Base(module), ".wasm"))
+	}
+	switch {
+	case len(modules) == 0:
+		return "", fmt.Errorf("%s", tr("WasmNoModules", "Result", ".#"+wasmOutput))
+	case name != "":
+		for i, module := range modules {
+			if names[i] == strings.TrimSuffix(name, ".wasm") {
+				return module, nil
+			}
+		}
+		return "", fmt.Errorf("%s", tr("WasmNoModule", "Module", name, "Available", strings.Join(names, ", ")))
+	case len(modules) > 1:
+		return "", fmt.Errorf("%s", tr("WasmAmbiguous", "Available", strings.Join(names, ", ")))
+	}
+	return modules[0], nil
+}
+
+// Run a wasm module under wasmtime, with the current directory preopened:
+// wasmtime from PATH, the dev shell, or else nixpkgs
+func runWasmModule(module string, args []string) error {
+	wasmtimeArgs := append([]string{"run", "--dir", ".", module}, args...)
+	if _, err := exec.LookPath("wasmtime"); err == nil {
+		return runTool("wasmtime", wasmtimeArgs...)
+	}
+	if devShellHas("wasmtime") {
+		return runInDevShell(append([]string{"wasmtime"}, wasmtimeArgs...)...)
+	}
+	return runNix(append([]string{"shell", "nixpkgs#wasmtime", "--command", "wasmtime"}, wasmtimeArgs...)...)
+}
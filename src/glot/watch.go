@@ -0,0 +1,276 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// watchConfig is the [watch] section of glot.toml
+type watchConfig struct {
+	Ignore     []string `toml:"ignore"`      // Globs of paths whose changes are ignored
+	DebounceMs int      `toml:"debounce-ms"` // Quiet time before re-running (default: 300)
+}
+
+const defaultWatchDebounce = 300 * time.Millisecond
+
+// Paths whose changes never trigger a re-run: editor and nix output
+var defaultWatchIgnore = []string{"*~", "*.swp", "*.swx", "4913", "#*#", "result", "result-*"}
+
+// Watches the project tree for changes worth re-running a command for
+type projectWatcher struct {
+	watcher *fsnotify.Watcher
+	ignore  []string
+}
+
+func newProjectWatcher(ignore []string) (*projectWatcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	w := &projectWatcher{watcher: watcher, ignore: append(append([]string{}, defaultWatchIgnore...), ignore...)}
+	if err := w.addTree("."); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// Whether a change to path should be ignored. Hidden directories (.git,
+// .direnv, .glot) and dependency and build directories are never watched.
+func (w *projectWatcher) ignored(path string) bool {
+	path = filepath.ToSlash(filepath.Clean(path))
+	for _, part := range strings.Split(path, "/") {
+		if part != "." && (strings.HasPrefix(part, ".") || workspaceSkipDirs[part]) {
+			return true
+		}
+	}
+	return matchAnyGlob(w.ignore, path)
+}
+
+// fsnotify watches single directories, so add every directory below root
+func (w *projectWatcher) addTree(root string) error {
+	return filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			// Directories can vanish while we walk them
+			return nil
+		}
+		if !entry.IsDir() {
+			return nil
+		}
+		if path != "." && w.ignored(path) {
+			return filepath.SkipDir
+		}
+		return w.watcher.Add(path)
+	})
+}
+
+// Relevant changed paths, with new directories added to the watch
+func (w *projectWatcher) changes() <-chan string {
+	changes := make(chan string)
+	go func() {
+		defer recoverCrash()
+		for {
+			select {
+			case event, ok := <-w.watcher.Events:
+				if !ok {
+					close(changes)
+					return
+				}
+				if event.Op == fsnotify.Chmod || w.ignored(event.Name) {
+					continue
+				}
+				if event.Op&fsnotify.Create != 0 {
+					if stat, err := os.Stat(event.Name); err == nil && stat.IsDir() {
+						w.addTree(event.Name)
+					}
+				}
+				changes <- filepath.Clean(event.Name)
+			case err, ok := <-w.watcher.Errors:
+				if !ok {
+					return
+				}
+				warning(tr("WatchWatcherError", "Error", err))
+			}
+		}
+	}()
+	return changes
+}
+
+// A run of the watched glot command in a child process
+type watchRun struct {
+	cmd  *exec.Cmd
+	done chan error
+}
+
+func startWatchRun(args []string) (*watchRun, error) {
+	self, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(self, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	// Own process group, so stopping it also stops nix and the program it runs
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	logCommand(cmd)
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	run := &watchRun{cmd: cmd, done: make(chan error, 1)}
+	go func() { run.done <- cmd.Wait() }()
+	return run, nil
+}
+
+// Stop the run and everything it started, waiting briefly for it to exit
+func (r *watchRun) stop() {
+	syscall.Kill(-r.cmd.Process.Pid, syscall.SIGTERM)
+	select {
+	case <-r.done:
+	case <-time.After(5 * time.Second):
+		syscall.Kill(-r.cmd.Process.Pid, syscall.SIGKILL)
+		<-r.done
+	}
+}
+
+func newWatchCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "watch <command> [args...]",
+		Short: "Re-run a command when sources change",
+		Long: "Run a glot command, such as test, run, build or lint, and run it again whenever files in the " +
+			"project change. A command still running when files change is stopped and restarted, so " +
+			"'glot watch run' restarts the application.",
+		Example: "  glot watch test\n  glot watch run --release -- --port 8080\n  glot watch --ignore 'docs/**' lint",
+		Args:    cobra.MinimumNArgs(1),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) > 0 {
+				return nil, cobra.ShellCompDirectiveDefault
+			}
+			return []string{"test", "run", "build", "lint", "check", "fmt"}, cobra.ShellCompDirectiveNoFileComp
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkNix(); err != nil {
+				errorMsg(err.Error())
+				return err
+			}
+			if args[0] == cmd.Name() {
+				err := fmt.Errorf("%s", tr("WatchItself"))
+				errorMsg(err.Error())
+				return err
+			}
+
+			debounce, _ := cmd.Flags().GetDuration("debounce")
+			if !cmd.Flags().Changed("debounce") && currentConfig.Watch.DebounceMs > 0 {
+				debounce = time.Duration(currentConfig.Watch.DebounceMs) * time.Millisecond
+			}
+			ignore, _ := cmd.Flags().GetStringSlice("ignore")
+			watcher, err := newProjectWatcher(append(currentConfig.Watch.Ignore, ignore...))
+			if err != nil {
+				errorMsg(tr("WatchFailed", "Error", err))
+				return err
+			}
+			defer watcher.watcher.Close()
+
+			interrupt := make(chan os.Signal, 1)
+			signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+			defer signal.Stop(interrupt)
+
+			command := "glot " + strings.Join(args, " ")
+			// The command runs in a fresh glot, which needs the global flags again
+			var globalArgs []string
+			cmd.Flags().Visit(func(flag *pflag.Flag) {
+				if cmd.InheritedFlags().Lookup(flag.Name) == nil {
+					return
+				}
+				// String() of a repeatable flag is "[a,b]": once per value
+				if slice, ok := flag.Value.(pflag.SliceValue); ok {
+					for _, value := range slice.GetSlice() {
+						globalArgs = append(globalArgs, "--"+flag.Name+"="+value)
+					}
+					return
+				}
+				globalArgs = append(globalArgs, "--"+flag.Name+"="+flag.Value.String())
+			})
+			args = append(globalArgs, args...)
+			if len(nixSeparatedArgs) > 0 {
+				// --nix takes what follows it up to a --, so it goes before the command's own --
+				dash := slices.Index(args, "--")
+				if dash < 0 {
+					dash = len(args)
+				}
+				nixArgs := append([]string{"--nix"}, nixSeparatedArgs...)
+				args = append(append(append([]string{}, args[:dash]...), nixArgs...), args[dash:]...)
+			}
+			changes := watcher.changes()
+			var run *watchRun
+			var runDone <-chan error
+			start := func() {
+				info(tr("WatchRunning", "Command", command))
+				if run, err = startWatchRun(args); err != nil {
+					errorMsg(err.Error())
+					run, runDone = nil, nil
+					return
+				}
+				runDone = run.done
+			}
+			start()
+
+			var pending <-chan time.Time
+			var changed string
+			for {
+				select {
+				case path, ok := <-changes:
+					if !ok {
+						return nil
+					}
+					changed = path
+					pending = time.After(debounce)
+				case <-pending:
+					pending = nil
+					info(tr("WatchRestarting", "File", changed, "Command", command))
+					if run != nil {
+						run.stop()
+					}
+					start()
+				case err := <-runDone:
+					runDone = nil
+					var exitError *exec.ExitError
+					switch {
+					case err == nil:
+						success(tr("WatchFinished", "Command", command))
+					case errors.As(err, &exitError):
+						warning(tr("WatchCommandFailed", "Command", command))
+					default:
+						errorMsg(err.Error())
+					}
+					run = nil
+				case <-interrupt:
+					if run != nil {
+						run.stop()
+					}
+					fmt.Println()
+					info(tr("WatchStopped"))
+					return nil
+				}
+			}
+		},
+	}
+	// Everything after the command belongs to it
+	cmd.Flags().SetInterspersed(false)
+	cmd.Flags().Duration("debounce", defaultWatchDebounce, "Quiet time after a change before re-running")
+	cmd.Flags().StringSlice("ignore", nil, "Globs of paths whose changes are ignored, added to [watch] ignore in glot.toml")
+	return cmd
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+// Directories never searched for language roots: dependencies, build output
+// and hidden directories such as .git and .direnv
+var workspaceSkipDirs = map[string]bool{
+	"node_modules": true,
+	"target":       true,
+	"vendor":       true,
+	"build":        true,
+	"dist":         true,
+	"zig-out":      true,
+	"nimcache":     true,
+	"bin":          true,
+	"obj":          true,
+	"result":       true,
+}
+
+// A directory with a language's markers
+type languageRoot struct {
+	dir      string
+	language Language
+}
+
+// Find the language roots of the project: every directory with a language's
+// markers, except directories nested in a root of the same language unless
+// the language treats them as separate projects
+func findLanguageRoots() ([]languageRoot, error) {
+	var roots []languageRoot
+	err := filepath.WalkDir(".", func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() {
+			return nil
+		}
+		name := entry.Name()
+		if path != "." && (strings.HasPrefix(name, ".") || workspaceSkipDirs[name] || strings.HasPrefix(name, "result-")) {
+			return filepath.SkipDir
+		}
+		for _, language := range languages {
+			if !language.At(path).Detect() || insideRoot(roots, language, path) {
+				continue
+			}
+			roots = append(roots, languageRoot{dir: path, language: language.At(path)})
+		}
+		return nil
+	})
+	return roots, err
+}
+
+// Whether dir is covered by an enclosing root of the same language
+func insideRoot(roots []languageRoot, language Language, dir string) bool {
+	if language.NestedProjects() {
+		return false
+	}
+	for _, root := range roots {
+		if root.language.Name() == language.Name() &&
+			(root.dir == "." || strings.HasPrefix(dir, root.dir+string(filepath.Separator))) {
+			return true
+		}
+	}
+	return false
+}
+
+// Outcome of a task at one root
+type rootResult struct {
+	name   string // Language, or the flake app or formatter run at the project root
+	dir    string
+	err    error
+	reason string // Why the root was skipped
+}
+
+// Run a task at the project root and at every nested language root, then
+// print one summary. rootTask handles the project root, where the flake's
+// app or formatter applies, and rootName names what it runs there, or is
+// empty if nothing applies. languageTask runs a language's tool in its root.
+// Projects whose only root is the project root run rootTask alone.
+func runWorkspaceTask(task string, rootTask func() error, rootName func() string, languageTask func(Language) error) error {
+	roots, err := findLanguageRoots()
+	if err != nil {
+		warning(tr("WorkspaceSearchFailed", "Error", err))
+	}
+	var nested []languageRoot
+	for _, root := range roots {
+		if root.dir != "." {
+			nested = append(nested, root)
+		}
+	}
+	if len(nested) == 0 {
+		return rootTask()
+	}
+
+	var results []rootResult
+	if name := rootName(); name != "" {
+		info(tr("WorkspaceRunningRoot", "Task", task, "Name", name))
+		step := startStep(task + " .")
+		err := rootTask()
+		step.finish(err)
+		results = append(results, rootResult{name: name, dir: ".", err: err})
+	}
+
+	for _, root := range nested {
+		info(tr("WorkspaceRunning", "Task", task, "Language", root.language.Name(), "Dir", root.dir))
+		step := startStep(task + " " + root.dir)
+		err := languageTask(root.language)
+		step.finish(err)
+		result := rootResult{name: root.language.Name(), dir: root.dir, err: err}
+		var unsupported unsupportedTaskError
+		if errors.As(err, &unsupported) {
+			result.err = nil
+			result.reason = tr("WorkspaceNoCommand", "Task", task, "Language", root.language.Name())
+		}
+		results = append(results, result)
+	}
+	return printWorkspaceSummary(task, results)
+}
+
+// Print the outcome of every root and fail if any root failed
+func printWorkspaceSummary(task string, results []rootResult) error {
+	failed := 0
+	fmt.Println()
+	fmt.Println(plain("📋 " + tr("WorkspaceSummary", "Task", strings.ToUpper(task[:1])+task[1:])))
+	for _, result := range results {
+		switch {
+		case result.err != nil:
+			failed++
+			fmt.Printf(plain("  ❌ %-12s %-30s %s\n"), result.name, result.dir, result.err)
+		case result.reason != "":
+			fmt.Printf(plain("  ⏭️  %-12s %-30s %s\n"), result.name, result.dir, tr("WorkspaceSkipped", "Reason", result.reason))
+		default:
+			fmt.Printf(plain("  ✅ %-12s %s\n"), result.name, result.dir)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%s", trn("WorkspaceFailed", len(results), "Task", task, "Failed", failed))
+	}
+	return nil
+}
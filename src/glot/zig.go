@@ -0,0 +1,28 @@
+package main
+
+import "fmt"
+
+type zigLanguage struct{ toolchain }
+
+func newZigLanguage() Language {
+	return zigLanguage{toolchain{
+		name:       "zig",
+		markers:    []string{"build.zig"},
+		lsp:        []string{"zls"},
+		lspPackage: "zls",
+		format:     []string{"zig", "fmt", "."},
+		lint:       []string{"zig", "fmt", "--check", "."},
+		test:       []string{"zig", "build", "test"},
+		testWith: func(options testOptions) ([]string, error) {
+			if options.Filter != "" {
+				return nil, fmt.Errorf("%s", tr("LanguageNoZigFilter"))
+			}
+			command := []string{"zig", "build", "test"}
+			if options.Verbose {
+				command = append(command, "--summary", "all")
+			}
+			return append(command, options.Args...), nil
+		},
+		artifacts: []string{"zig-out/", "zig-cache/", ".zig-cache/"},
+	}}
+}
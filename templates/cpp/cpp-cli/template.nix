@@ -21,4 +21,9 @@
     ".editorconfig" = ./.editorconfig;
     ".clang-format" = ./.clang-format;
   };
+
+  # Names replaced by `glot new` in file contents and paths
+  substitute = {
+    "hello-cpp" = "@projectName@";
+  };
 }
@@ -0,0 +1,48 @@
+{
+  description = "Example of a project using hello-lib";
+
+  # `glot verify-consumer` builds this against the local source of the
+  # library: nix build ./consumer --override-input hello-lib .
+  inputs = {
+    nixpkgs.url = "github:NixOS/nixpkgs/nixos-25.05";
+    flake-utils.url = "github:numtide/flake-utils";
+    hello-lib = {
+      url = "github:your-name/hello-lib"; # Update this URL
+      inputs.nixpkgs.follows = "nixpkgs";
+    };
+  };
+
+  outputs = { self, nixpkgs, flake-utils, hello-lib }:
+    flake-utils.lib.eachDefaultSystem (system:
+      let
+        pkgs = import nixpkgs {
+          inherit system;
+          overlays = [ hello-lib.overlays.default ];
+        };
+      in
+      {
+        packages.default = pkgs.stdenv.mkDerivation {
+          pname = "hello-lib-consumer";
+          version = "0.1.0";
+          src = ./.;
+
+          nativeBuildInputs = [ pkgs.pkg-config ];
+          # Or, without the overlay: (hello-lib.lib.mkPackage pkgs)
+          buildInputs = [ pkgs.hello-lib ];
+
+          buildPhase = ''
+            $CXX -std=c++17 main.cpp $(pkg-config --cflags --libs hello-lib) -o hello-lib-consumer
+          '';
+          installPhase = ''
+            install -Dm755 hello-lib-consumer $out/bin/hello-lib-consumer
+          '';
+
+          # The example works if it runs
+          doInstallCheck = true;
+          installCheckPhase = ''
+            $out/bin/hello-lib-consumer
+          '';
+        };
+      }
+    );
+}
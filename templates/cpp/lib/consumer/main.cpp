@@ -0,0 +1,8 @@
+#include <hello_lib/greeting.hpp>
+#include <iostream>
+
+int main() {
+    std::cout << hello_lib::get_greeting("consumer") << std::endl;
+    std::cout << "Built against hello-lib " << hello_lib::get_version() << std::endl;
+    return 0;
+}
@@ -0,0 +1,53 @@
+{
+  description = "C++ library with nix-polyglot";
+
+  inputs = {
+    nixpkgs.url = "github:NixOS/nixpkgs/nixos-25.05";
+    flake-utils.url = "github:numtide/flake-utils";
+    nix-polyglot.url = "github:ritzau/nix-polyglot";
+  };
+
+  outputs = { self, nixpkgs, flake-utils, nix-polyglot }:
+    let
+      # The library for a package set, built with its compiler and
+      # dependencies, so consumers get it for their own nixpkgs
+      cppProject = pkgs: nix-polyglot.lib.cpp {
+        inherit pkgs self;
+        inherit (pkgs.stdenv.hostPlatform) system;
+        projectName = "hello-lib";
+        buildTarget = "./CMakeLists.txt";
+
+        # C++ configuration
+        cppStandard = "17";
+        compiler = "gcc"; # or "clang"
+        enableTests = true;
+
+        # Optional customizations:
+        # extraBuildInputs = [ ];
+        # extraNativeBuildInputs = [ ];
+        # extraCmakeFlags = [ ];
+        # extraDevTools = [ ];
+      };
+    in
+    flake-utils.lib.eachDefaultSystem (system:
+      let
+        outputs = (cppProject nixpkgs.legacyPackages.${system}).defaultOutputs;
+      in
+      outputs // {
+        # A library has no program to run or put in an image
+        apps = removeAttrs outputs.apps [ "default" "dev" "release" ];
+        packages = removeAttrs outputs.packages [ "image" ];
+      }
+    ) // {
+      # Adds pkgs.hello-lib for flakes applying the overlay to their nixpkgs
+      overlays.default = final: prev: {
+        hello-lib = (cppProject final).releasePackage;
+      };
+
+      lib = {
+        # The library built for a package set, without the overlay:
+        # buildInputs = [ (hello-lib.lib.mkPackage pkgs) ];
+        mkPackage = pkgs: (cppProject pkgs).releasePackage;
+      };
+    };
+}
@@ -0,0 +1,18 @@
+#pragma once
+
+#include <string>
+
+namespace hello_lib {
+    /**
+     * @brief Get a greeting message
+     * @param name The name to greet
+     * @return A personalized greeting
+     */
+    std::string get_greeting(const std::string& name = "World");
+
+    /**
+     * @brief Get the library version
+     * @return The version as major.minor.patch
+     */
+    std::string get_version();
+}
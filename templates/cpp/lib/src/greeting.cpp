@@ -0,0 +1,13 @@
+#include "hello_lib/greeting.hpp"
+
+namespace hello_lib {
+
+std::string get_greeting(const std::string& name) {
+    return "Hello, " + name + " from hello-lib!";
+}
+
+std::string get_version() {
+    return "0.1.0";
+}
+
+}
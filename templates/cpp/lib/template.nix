@@ -0,0 +1,33 @@
+# C++ Library Template
+{
+  name = "cpp-lib";
+  description = "C++ library with CMake, a nixpkgs overlay and a consumer example";
+
+  # Template metadata
+  language = "cpp";
+  category = "lib";
+
+  # Files to create in new project
+  files = {
+    "flake.nix" = ./flake.nix;
+    "CMakeLists.txt" = ./CMakeLists.txt;
+    "hello-lib.pc.in" = ./hello-lib.pc.in;
+    "include/hello_lib/greeting.hpp" = ./include/hello_lib/greeting.hpp;
+    "src/greeting.cpp" = ./src/greeting.cpp;
+    "tests/test_greeting.cpp" = ./tests/test_greeting.cpp;
+    "tests/CMakeLists.txt" = ./tests/CMakeLists.txt;
+    "consumer/flake.nix" = ./consumer/flake.nix;
+    "consumer/main.cpp" = ./consumer/main.cpp;
+    "README.md" = ./README.md;
+    ".envrc" = ./.envrc;
+    ".gitignore" = ./.gitignore;
+    ".editorconfig" = ./.editorconfig;
+    ".clang-format" = ./.clang-format;
+  };
+
+  # Names replaced by `glot new` in file contents and paths
+  substitute = {
+    "hello-lib" = "@projectName@";
+    "hello_lib" = "@packageName@";
+  };
+}
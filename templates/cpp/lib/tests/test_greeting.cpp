@@ -0,0 +1,48 @@
+#include "hello_lib/greeting.hpp"
+#include <iostream>
+#include <string>
+
+// Simple test framework
+int test_count = 0;
+int test_passed = 0;
+
+void test_assert(bool condition, const std::string& test_name) {
+    test_count++;
+    if (condition) {
+        test_passed++;
+        std::cout << "✅ PASS: " << test_name << std::endl;
+    } else {
+        std::cout << "❌ FAIL: " << test_name << std::endl;
+    }
+}
+
+int main() {
+    std::cout << "Running C++ tests...\n" << std::endl;
+
+    // Test get_greeting function
+    {
+        std::string greeting = hello_lib::get_greeting("Test");
+        test_assert(greeting.find("Hello, Test") != std::string::npos,
+                   "get_greeting should contain 'Hello, Test'");
+    }
+
+    // Test default greeting
+    {
+        std::string default_greeting = hello_lib::get_greeting();
+        test_assert(default_greeting.find("World") != std::string::npos,
+                   "default greeting should contain 'World'");
+    }
+
+    // Test version
+    {
+        test_assert(!hello_lib::get_version().empty(), "get_version should not be empty");
+    }
+
+    // Results
+    std::cout << "\n📊 Test Results:" << std::endl;
+    std::cout << "Tests run: " << test_count << std::endl;
+    std::cout << "Tests passed: " << test_passed << std::endl;
+    std::cout << "Tests failed: " << (test_count - test_passed) << std::endl;
+
+    return test_passed == test_count ? 0 : 1;
+}
@@ -0,0 +1,9 @@
+# CI Feature
+{
+  name = "ci";
+  description = "GitHub Actions workflow running glot check";
+
+  files = {
+    ".github/workflows/ci.yml" = ./.github/workflows/ci.yml;
+  };
+}
@@ -0,0 +1,9 @@
+# Devcontainer Feature
+{
+  name = "devcontainer";
+  description = "Dev container with Nix and direnv preinstalled";
+
+  files = {
+    ".devcontainer/devcontainer.json" = ./.devcontainer/devcontainer.json;
+  };
+}
@@ -0,0 +1,10 @@
+# Docker Feature
+{
+  name = "docker";
+  description = "Container image built from the release output";
+
+  files = {
+    "Dockerfile" = ./Dockerfile;
+    ".dockerignore" = ./dockerignore;
+  };
+}
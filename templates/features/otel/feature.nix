@@ -0,0 +1,14 @@
+# OpenTelemetry Feature
+{
+  name = "otel";
+  description = "Local OpenTelemetry collector and exporter settings";
+
+  files = {
+    "otel/collector.yaml" = ./otel/collector.yaml;
+    "otel/docker-compose.yaml" = ./otel/docker-compose.yaml;
+  };
+
+  append = {
+    ".envrc" = ./envrc.append;
+  };
+}
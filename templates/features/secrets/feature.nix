@@ -0,0 +1,16 @@
+# Secrets Feature
+{
+  name = "secrets";
+  description = "sops-encrypted secrets with per-developer age keys";
+
+  files = {
+    ".sops.yaml" = ./sops.yaml;
+    "secrets/secrets.example.yaml" = ./secrets/secrets.example.yaml;
+  };
+
+  append = {
+    ".envrc" = ./envrc.append;
+    ".gitignore" = ./gitignore.append;
+    "glot.toml" = ./glot.toml.append;
+  };
+}
@@ -13,4 +13,23 @@
     "main_test.go" = ./main_test.go;
     "go.mod" = ./go.mod;
   };
+
+  # Files only generated for projects targeting a platform (glot new --platforms)
+  platformFiles = {
+    linux = {
+      "deploy/go-project.service" = ./deploy/go-project.service;
+    };
+    darwin = {
+      "deploy/com.example.go-project.plist" = ./deploy/com.example.go-project.plist;
+    };
+  };
+
+  # Names replaced by `glot new` in file contents and paths
+  substitute = {
+    "example.com/go-project" = "@modulePath@";
+    "go-project" = "@projectName@";
+  };
+
+  # Run by `glot new` in the dev shell after the files are written
+  postGenerate = [ "go mod tidy" ];
 }
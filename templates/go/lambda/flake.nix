@@ -0,0 +1,51 @@
+{
+  description = "AWS Lambda function in Go built with nix-polyglot";
+
+  inputs = {
+    nixpkgs.url = "github:NixOS/nixpkgs/nixos-unstable";
+    nix-polyglot.url = "github:ritzau/nix-polyglot";
+    flake-utils.url = "github:numtide/flake-utils";
+  };
+
+  outputs = { self, nixpkgs, nix-polyglot, flake-utils }:
+    flake-utils.lib.eachDefaultSystem (system:
+      let
+        pkgs = nixpkgs.legacyPackages.${system};
+        polyglot = nix-polyglot.lib.go
+          {
+            inherit nixpkgs;
+          }
+          {
+            inherit pkgs self;
+            projectName = "go-lambda";
+            modulePath = "example.com/go-lambda";
+          };
+        outputs = polyglot.defaultOutputs;
+
+        # The function for Lambda's provided.al2023 runtime: the static build
+        # as bootstrap, and function.zip to deploy (glot package --lambda)
+        lambda = pkgs.runCommand "go-lambda-lambda"
+          {
+            nativeBuildInputs = [ pkgs.zip ];
+          } ''
+          mkdir -p $out
+          cp ${outputs.packages.static}/bin/go-lambda $out/bootstrap
+          cd $out
+          # zip stores timestamps, and can't before 1980
+          touch -d @$SOURCE_DATE_EPOCH bootstrap
+          zip -X function.zip bootstrap
+        '';
+      in
+      outputs // {
+        # Lambda runs Linux, so the function is built on Linux systems
+        packages = outputs.packages // pkgs.lib.optionalAttrs pkgs.stdenv.hostPlatform.isLinux {
+          inherit lambda;
+        };
+
+        # The runtime interface emulator of glot run --local, and the AWS CLI
+        devShells.default = outputs.devShells.default.overrideAttrs (old: {
+          nativeBuildInputs = (old.nativeBuildInputs or [ ]) ++ [ pkgs.aws-lambda-rie pkgs.awscli2 ];
+        });
+      }
+    );
+}
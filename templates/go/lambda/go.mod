@@ -0,0 +1,5 @@
+module example.com/go-lambda
+
+go 1.22
+
+// This Go Lambda function was created with nix-polyglot for reproducible development
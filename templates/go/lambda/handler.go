@@ -0,0 +1,25 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Event is the function's input, the JSON it is invoked with
+type Event struct {
+	Name string `json:"name"`
+}
+
+// Response is the function's output
+type Response struct {
+	Message string `json:"message"`
+}
+
+// handle is the function itself: replace it with yours
+func handle(ctx context.Context, event Event) (Response, error) {
+	if event.Name == "" {
+		return Response{}, errors.New("no name in the event")
+	}
+	return Response{Message: fmt.Sprintf("Hello, %s!", event.Name)}, nil
+}
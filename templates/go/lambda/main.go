@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+// A minimal client of the Lambda Runtime API, which the provided.al2023
+// runtime and the runtime interface emulator serve at AWS_LAMBDA_RUNTIME_API:
+// https://docs.aws.amazon.com/lambda/latest/dg/runtimes-api.html
+const runtimeAPIVersion = "2018-06-01"
+
+// runtimeError is how the Runtime API takes a failed invocation
+type runtimeError struct {
+	ErrorMessage string `json:"errorMessage"`
+	ErrorType    string `json:"errorType"`
+}
+
+// serve takes invocations from the Runtime API at api and answers each with
+// handler's response, until the API fails
+func serve(api string, handler func(context.Context, Event) (Response, error)) error {
+	base := fmt.Sprintf("http://%s/%s/runtime/invocation/", api, runtimeAPIVersion)
+	for {
+		next, err := http.Get(base + "next")
+		if err != nil {
+			return err
+		}
+		payload, err := io.ReadAll(next.Body)
+		next.Body.Close()
+		if err != nil {
+			return err
+		}
+		id := next.Header.Get("Lambda-Runtime-Aws-Request-Id")
+
+		deadline, err := parseDeadline(next.Header.Get("Lambda-Runtime-Deadline-Ms"))
+		if err != nil {
+			// Lambda's longest timeout
+			deadline = time.Now().Add(15 * time.Minute)
+		}
+		ctx, cancel := context.WithDeadline(context.Background(), deadline)
+		path, body := invoke(ctx, handler, payload)
+		cancel()
+
+		posted, err := http.Post(base+id+path, "application/json", bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		posted.Body.Close()
+	}
+}
+
+// invoke runs handler with an invocation's payload, returning where to post
+// the result under the invocation, and the result
+func invoke(ctx context.Context, handler func(context.Context, Event) (Response, error), payload []byte) (string, []byte) {
+	var event Event
+	if err := json.Unmarshal(payload, &event); err != nil {
+		body, _ := json.Marshal(runtimeError{ErrorMessage: err.Error(), ErrorType: "InvalidEvent"})
+		return "/error", body
+	}
+	response, err := handler(ctx, event)
+	if err != nil {
+		body, _ := json.Marshal(runtimeError{ErrorMessage: err.Error(), ErrorType: "HandlerError"})
+		return "/error", body
+	}
+	body, _ := json.Marshal(response)
+	return "/response", body
+}
+
+// parseDeadline reads the invocation's deadline, in milliseconds since the epoch
+func parseDeadline(ms string) (time.Time, error) {
+	var millis int64
+	if _, err := fmt.Sscan(ms, &millis); err != nil {
+		return time.Time{}, err
+	}
+	return time.UnixMilli(millis), nil
+}
+
+func main() {
+	api := os.Getenv("AWS_LAMBDA_RUNTIME_API")
+	if api == "" {
+		fmt.Fprintln(os.Stderr, "go-lambda runs in AWS Lambda: deploy it with 'glot package --lambda', or try it with 'glot run --local'")
+		os.Exit(1)
+	}
+	if err := serve(api, handle); err != nil {
+		log.Fatal(err)
+	}
+}
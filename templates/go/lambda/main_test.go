@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandle(t *testing.T) {
+	response, err := handle(context.Background(), Event{Name: "Alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Message != "Hello, Alice!" {
+		t.Errorf("Expected %q, got %q", "Hello, Alice!", response.Message)
+	}
+
+	if _, err := handle(context.Background(), Event{}); err == nil {
+		t.Error("Expected an error without a name")
+	}
+}
+
+func TestInvoke(t *testing.T) {
+	tests := []struct {
+		name     string
+		payload  string
+		path     string
+		contains string
+	}{
+		{"response", `{"name":"Bob"}`, "/response", `"message":"Hello, Bob!"`},
+		{"handler error", `{}`, "/error", `"errorType":"HandlerError"`},
+		{"invalid event", `not json`, "/error", `"errorType":"InvalidEvent"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, body := invoke(context.Background(), handle, []byte(tt.payload))
+			if path != tt.path {
+				t.Errorf("Expected %s, got %s", tt.path, path)
+			}
+			if !strings.Contains(string(body), tt.contains) {
+				t.Errorf("Expected %s in %s", tt.contains, body)
+			}
+		})
+	}
+}
+
+// TestServe runs the runtime loop against a fake Runtime API with one invocation
+func TestServe(t *testing.T) {
+	answered := make(chan string, 1)
+	served := false
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/invocation/next") && !served:
+			served = true
+			w.Header().Set("Lambda-Runtime-Aws-Request-Id", "request-1")
+			json.NewEncoder(w).Encode(Event{Name: "Carol"})
+		case r.URL.Path == "/2018-06-01/runtime/invocation/request-1/response":
+			body, _ := io.ReadAll(r.Body)
+			answered <- string(body)
+		default:
+			// Ends the loop after the invocation
+			http.Error(w, "no more invocations", http.StatusGone)
+			panic(http.ErrAbortHandler)
+		}
+	}))
+	defer api.Close()
+
+	serve(strings.TrimPrefix(api.URL, "http://"), handle)
+	if body := <-answered; !strings.Contains(body, "Hello, Carol!") {
+		t.Errorf("Unexpected response %s", body)
+	}
+}
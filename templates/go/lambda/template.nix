@@ -0,0 +1,25 @@
+# Go AWS Lambda Function Template
+{
+  name = "go-lambda";
+  language = "go";
+  description = "Go AWS Lambda function";
+
+  files = {
+    "flake.nix" = ./flake.nix;
+    ".envrc" = ./.envrc;
+    ".gitignore" = ./.gitignore;
+    ".editorconfig" = ./.editorconfig;
+    "README.md" = ./README.md;
+    "main.go" = ./main.go;
+    "handler.go" = ./handler.go;
+    "main_test.go" = ./main_test.go;
+    "go.mod" = ./go.mod;
+    "events/hello.json" = ./events/hello.json;
+  };
+
+  # Names replaced by `glot new` in file contents and paths
+  substitute = {
+    "example.com/go-lambda" = "@modulePath@";
+    "go-lambda" = "@projectName@";
+  };
+}
@@ -14,4 +14,9 @@
     "src/main.nim" = ./src/main.nim;
     "tests/test_main.nim" = ./tests/test_main.nim;
   };
+
+  # Names replaced by `glot new` in file contents and paths
+  substitute = {
+    "nim_project" = "@packageName@";
+  };
 }
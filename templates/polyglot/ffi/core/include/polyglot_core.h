@@ -0,0 +1,22 @@
+/* C interface to the Rust core. Keep in sync with core/src/lib.rs. */
+#ifndef POLYGLOT_CORE_H
+#define POLYGLOT_CORE_H
+
+#include <stdint.h>
+
+#ifdef __cplusplus
+extern "C" {
+#endif
+
+int64_t polyglot_add(int64_t a, int64_t b);
+
+/* Returns a newly allocated string; release it with polyglot_string_free. */
+char *polyglot_greet(const char *name);
+
+void polyglot_string_free(char *s);
+
+#ifdef __cplusplus
+}
+#endif
+
+#endif /* POLYGLOT_CORE_H */
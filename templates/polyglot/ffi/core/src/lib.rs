@@ -0,0 +1,81 @@
+//! Rust core exposed to Go and Python through a C ABI.
+//!
+//! The safe Rust API lives at the top; the `extern "C"` functions below wrap
+//! it and must stay in sync with `include/polyglot_core.h`.
+
+use std::ffi::{CStr, CString};
+use std::os::raw::c_char;
+
+/// Add two numbers.
+pub fn add(a: i64, b: i64) -> i64 {
+    a + b
+}
+
+/// Build a greeting for `name`.
+pub fn greeting(name: &str) -> String {
+    format!("Hello, {name}! (from Rust)")
+}
+
+#[no_mangle]
+pub extern "C" fn polyglot_add(a: i64, b: i64) -> i64 {
+    add(a, b)
+}
+
+/// Returns a newly allocated greeting, or NULL if `name` is NULL.
+///
+/// # Safety
+///
+/// `name` must be NULL or a valid NUL-terminated string. The result must be
+/// released with `polyglot_string_free`.
+#[no_mangle]
+pub unsafe extern "C" fn polyglot_greet(name: *const c_char) -> *mut c_char {
+    if name.is_null() {
+        return std::ptr::null_mut();
+    }
+    let name = CStr::from_ptr(name).to_string_lossy();
+    CString::new(greeting(&name))
+        .map(CString::into_raw)
+        .unwrap_or(std::ptr::null_mut())
+}
+
+/// Release a string returned by this library.
+///
+/// # Safety
+///
+/// `s` must be NULL or a pointer previously returned by this library.
+#[no_mangle]
+pub unsafe extern "C" fn polyglot_string_free(s: *mut c_char) {
+    if !s.is_null() {
+        drop(CString::from_raw(s));
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn adds() {
+        assert_eq!(add(2, 3), 5);
+        assert_eq!(polyglot_add(-1, 1), 0);
+    }
+
+    #[test]
+    fn greets_through_c_abi() {
+        let name = CString::new("Ferris").unwrap();
+        unsafe {
+            let raw = polyglot_greet(name.as_ptr());
+            assert!(!raw.is_null());
+            assert_eq!(
+                CStr::from_ptr(raw).to_str().unwrap(),
+                "Hello, Ferris! (from Rust)"
+            );
+            polyglot_string_free(raw);
+        }
+    }
+
+    #[test]
+    fn null_name_returns_null() {
+        assert!(unsafe { polyglot_greet(std::ptr::null()) }.is_null());
+    }
+}
@@ -0,0 +1,144 @@
+{
+  description = "Rust core with Go and Python bindings, built with nix-polyglot";
+
+  inputs = {
+    nixpkgs.url = "github:NixOS/nixpkgs/nixos-25.05";
+    flake-utils.url = "github:numtide/flake-utils";
+    nix-polyglot = {
+      url = "github:ritzau/nix-polyglot"; # Update this URL
+      # For local development, use: url = "path:/path/to/nix-polyglot";
+    };
+  };
+
+  outputs = { self, nixpkgs, flake-utils, nix-polyglot, ... }:
+    flake-utils.lib.eachDefaultSystem (system:
+      let
+        pkgs = import nixpkgs { inherit system; };
+        lib = pkgs.lib;
+
+        sharedLibrary = "libpolyglot_core${pkgs.stdenv.hostPlatform.extensions.sharedLibrary}";
+        libraryPathVar = if pkgs.stdenv.isDarwin then "DYLD_LIBRARY_PATH" else "LD_LIBRARY_PATH";
+
+        # Rust core: cdylib + staticlib with the C header installed alongside
+        core = pkgs.rustPlatform.buildRustPackage {
+          pname = "polyglot-core";
+          version = "0.1.0";
+          src = lib.cleanSource ./.;
+          cargoLock.lockFile = ./Cargo.lock;
+          postInstall = ''
+            mkdir -p $out/include
+            cp core/include/*.h $out/include/
+          '';
+        };
+
+        # Go bindings and demo CLI (cgo), tests run in checkPhase
+        mkGoBindings = { release ? false }: pkgs.buildGoModule {
+          pname = "polyglot-ffi-go";
+          version = "0.1.0";
+          src = ./go;
+          vendorHash = null;
+          buildInputs = [ core ];
+          env = {
+            CGO_ENABLED = "1";
+            CGO_CFLAGS = "-I${core}/include";
+            CGO_LDFLAGS = "-L${core}/lib";
+          };
+          ldflags = lib.optionals release [ "-s" "-w" ];
+        };
+
+        # Python bindings (cffi ABI mode), tests run with pytest
+        python = pkgs.python3Packages.buildPythonPackage {
+          pname = "polyglot-ffi";
+          version = "0.1.0";
+          pyproject = true;
+          src = ./python;
+          postPatch = ''
+            substituteInPlace polyglot_ffi/_paths.py \
+              --replace-fail "@coreLib@" "${core}/lib/${sharedLibrary}"
+          '';
+          build-system = [ pkgs.python3Packages.setuptools ];
+          dependencies = [ pkgs.python3Packages.cffi ];
+          nativeCheckInputs = [ pkgs.python3Packages.pytestCheckHook ];
+          pythonImportsCheck = [ "polyglot_ffi" ];
+        };
+
+        goDev = mkGoBindings { };
+        goRelease = mkGoBindings { release = true; };
+
+        # Building an aggregate builds (and tests) every layer
+        mkAggregate = name: go: pkgs.symlinkJoin {
+          inherit name;
+          paths = [ core go (pkgs.python3.withPackages (_: [ python ])) ];
+        };
+
+        devBuild = mkAggregate "polyglot-ffi-dev" goDev;
+        releaseBuild = mkAggregate "polyglot-ffi-release" goRelease;
+
+        pythonEnv = pkgs.python3.withPackages (ps: [ ps.cffi ps.pytest ]);
+      in
+      {
+        packages = {
+          default = devBuild;
+          dev = devBuild;
+          release = releaseBuild;
+          inherit core python;
+          go = goDev;
+          glot = nix-polyglot.packages.${system}.glot;
+        };
+
+        apps = {
+          default = {
+            type = "app";
+            program = "${goDev}/bin/ffi-demo";
+          };
+          dev = {
+            type = "app";
+            program = "${goDev}/bin/ffi-demo";
+          };
+          release = {
+            type = "app";
+            program = "${goRelease}/bin/ffi-demo";
+          };
+        };
+
+        checks = {
+          inherit core python;
+          go = goDev;
+        };
+
+        devShells.default = pkgs.mkShell {
+          packages = [
+            pkgs.rustc
+            pkgs.cargo
+            pkgs.clippy
+            pkgs.rustfmt
+            pkgs.rust-analyzer
+            pkgs.go
+            pkgs.gopls
+            pythonEnv
+            pkgs.ruff
+            nix-polyglot.packages.${system}.glot
+          ];
+
+          # Point cgo and cffi at the in-tree cargo build (run `cargo build` first)
+          shellHook = ''
+            export CGO_CFLAGS="-I$PWD/core/include"
+            export CGO_LDFLAGS="-L$PWD/target/debug"
+            export POLYGLOT_CORE_LIB="$PWD/target/debug/${sharedLibrary}"
+            export ${libraryPathVar}="$PWD/target/debug:''${${libraryPathVar}:-}"
+
+            echo "🦀🐹🐍 Polyglot FFI Development Environment"
+            echo ""
+            echo "  cargo build                  - Build the Rust core (needed by the bindings)"
+            echo "  cargo test                   - Test the Rust core"
+            echo "  (cd go && go test ./...)     - Test the Go bindings"
+            echo "  (cd python && pytest)        - Test the Python bindings"
+            echo "  glot build                   - Build and test every layer with nix"
+            echo ""
+          '';
+        };
+
+        formatter = pkgs.nixpkgs-fmt;
+      }
+    );
+}
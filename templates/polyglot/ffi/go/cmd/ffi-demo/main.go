@@ -0,0 +1,18 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	polyglot "example.com/polyglot-ffi/go"
+)
+
+func main() {
+	name := "World"
+	if len(os.Args) > 1 {
+		name = os.Args[1]
+	}
+
+	fmt.Println(polyglot.Greet(name))
+	fmt.Printf("2 + 3 = %d (computed in Rust)\n", polyglot.Add(2, 3))
+}
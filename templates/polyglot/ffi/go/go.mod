@@ -0,0 +1,5 @@
+module example.com/polyglot-ffi/go
+
+go 1.22
+
+// Go bindings for the Rust core, created with nix-polyglot
@@ -0,0 +1,32 @@
+// Package polyglot provides Go bindings for the Rust core library.
+//
+// Header and library search paths come from CGO_CFLAGS and CGO_LDFLAGS,
+// which the nix build and the development shell set up.
+package polyglot
+
+/*
+#cgo LDFLAGS: -lpolyglot_core
+#include <stdlib.h>
+#include "polyglot_core.h"
+*/
+import "C"
+
+import "unsafe"
+
+// Add adds two numbers using the Rust core
+func Add(a, b int64) int64 {
+	return int64(C.polyglot_add(C.int64_t(a), C.int64_t(b)))
+}
+
+// Greet returns a greeting built by the Rust core
+func Greet(name string) string {
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+
+	result := C.polyglot_greet(cname)
+	if result == nil {
+		return ""
+	}
+	defer C.polyglot_string_free(result)
+	return C.GoString(result)
+}
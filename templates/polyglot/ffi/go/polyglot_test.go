@@ -0,0 +1,16 @@
+package polyglot
+
+import "testing"
+
+func TestAdd(t *testing.T) {
+	if got := Add(2, 3); got != 5 {
+		t.Errorf("Add(2, 3) = %d, want 5", got)
+	}
+}
+
+func TestGreet(t *testing.T) {
+	expected := "Hello, Gopher! (from Rust)"
+	if got := Greet("Gopher"); got != expected {
+		t.Errorf("Greet(%q) = %q, want %q", "Gopher", got, expected)
+	}
+}
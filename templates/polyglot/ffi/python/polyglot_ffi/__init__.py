@@ -0,0 +1,35 @@
+"""Python bindings for the Rust core library (cffi ABI mode)."""
+
+import os
+
+from cffi import FFI
+
+from ._paths import CORE_LIB
+
+__all__ = ["add", "greet"]
+
+_ffi = FFI()
+_ffi.cdef(
+    """
+    int64_t polyglot_add(int64_t a, int64_t b);
+    char *polyglot_greet(const char *name);
+    void polyglot_string_free(char *s);
+    """
+)
+_lib = _ffi.dlopen(os.environ.get("POLYGLOT_CORE_LIB") or CORE_LIB)
+
+
+def add(a: int, b: int) -> int:
+    """Add two numbers using the Rust core."""
+    return _lib.polyglot_add(a, b)
+
+
+def greet(name: str) -> str:
+    """Return a greeting built by the Rust core."""
+    result = _lib.polyglot_greet(name.encode())
+    if result == _ffi.NULL:
+        return ""
+    try:
+        return _ffi.string(result).decode()
+    finally:
+        _lib.polyglot_string_free(result)
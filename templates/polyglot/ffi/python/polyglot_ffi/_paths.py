@@ -0,0 +1,7 @@
+"""Location of the Rust core shared library.
+
+The nix build replaces the placeholder below with the store path of the
+library; in the development shell POLYGLOT_CORE_LIB takes precedence.
+"""
+
+CORE_LIB = "@coreLib@"
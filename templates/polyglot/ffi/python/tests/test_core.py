@@ -0,0 +1,11 @@
+"""Tests for the Python bindings."""
+
+from polyglot_ffi import add, greet
+
+
+def test_add():
+    assert add(2, 3) == 5
+
+
+def test_greet():
+    assert greet("Pythonista") == "Hello, Pythonista! (from Rust)"
@@ -0,0 +1,32 @@
+# Polyglot FFI Template
+{
+  name = "polyglot-ffi";
+  description = "Rust core with Go and Python bindings";
+
+  # Template metadata
+  language = "polyglot";
+  category = "library";
+
+  # Files to create in the new project
+  files = {
+    "flake.nix" = ./flake.nix;
+    "Cargo.toml" = ./Cargo.toml;
+    "Cargo.lock" = ./Cargo.lock;
+    "core/Cargo.toml" = ./core/Cargo.toml;
+    "core/src/lib.rs" = ./core/src/lib.rs;
+    "core/include/polyglot_core.h" = ./core/include/polyglot_core.h;
+    "go/go.mod" = ./go/go.mod;
+    "go/polyglot.go" = ./go/polyglot.go;
+    "go/polyglot_test.go" = ./go/polyglot_test.go;
+    "go/cmd/ffi-demo/main.go" = ./go/cmd/ffi-demo/main.go;
+    "python/pyproject.toml" = ./python/pyproject.toml;
+    "python/polyglot_ffi/__init__.py" = ./python/polyglot_ffi/__init__.py;
+    "python/polyglot_ffi/_paths.py" = ./python/polyglot_ffi/_paths.py;
+    "python/tests/__init__.py" = ./python/tests/__init__.py;
+    "python/tests/test_core.py" = ./python/tests/test_core.py;
+    "README.md" = ./README.md;
+    ".envrc" = ./.envrc;
+    ".gitignore" = ./.gitignore;
+    ".editorconfig" = ./.editorconfig;
+  };
+}
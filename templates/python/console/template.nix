@@ -20,4 +20,9 @@
     ".editorconfig" = ./.editorconfig;
     "README.md" = ./README.md;
   };
+
+  # Names replaced by `glot new` in file contents and paths
+  substitute = {
+    "myapp" = "@packageName@";
+  };
 }
@@ -0,0 +1,9 @@
+"""Reusable analysis code shared by notebooks and tests.
+
+Keep logic here rather than in notebooks so it can be unit tested and
+imported from any notebook.
+"""
+
+from .summary import load_measurements, summarize
+
+__all__ = ["load_measurements", "summarize"]
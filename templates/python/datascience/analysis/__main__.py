@@ -0,0 +1,14 @@
+"""Command-line entry point: summarize a measurements CSV."""
+
+import sys
+
+from .summary import load_measurements, summarize
+
+
+def main() -> None:
+    path = sys.argv[1] if len(sys.argv) > 1 else "data/measurements.csv"
+    print(summarize(load_measurements(path)).to_string())
+
+
+if __name__ == "__main__":
+    main()
@@ -0,0 +1,20 @@
+"""Loading and summarizing measurement data."""
+
+from pathlib import Path
+
+import pandas as pd
+
+
+def load_measurements(path: str | Path) -> pd.DataFrame:
+    """Load a measurements CSV with `group` and `value` columns."""
+    frame = pd.read_csv(path)
+    missing = {"group", "value"} - set(frame.columns)
+    if missing:
+        raise ValueError(f"missing columns: {', '.join(sorted(missing))}")
+    return frame
+
+
+def summarize(frame: pd.DataFrame) -> pd.DataFrame:
+    """Per-group count, mean, and standard deviation of `value`."""
+    summary = frame.groupby("group")["value"].agg(["count", "mean", "std"])
+    return summary.fillna(0.0).sort_index()
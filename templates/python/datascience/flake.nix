@@ -0,0 +1,129 @@
+{
+  description = "Data science project with Jupyter, built with nix-polyglot";
+
+  inputs = {
+    nixpkgs.url = "github:NixOS/nixpkgs/nixos-25.05";
+    flake-utils.url = "github:numtide/flake-utils";
+    nix-polyglot = {
+      url = "github:ritzau/nix-polyglot"; # Update this URL
+      # For local development, use: url = "path:/path/to/nix-polyglot";
+    };
+  };
+
+  outputs = { self, nixpkgs, flake-utils, nix-polyglot, ... }:
+    flake-utils.lib.eachDefaultSystem (system:
+      let
+        pkgs = import nixpkgs { inherit system; };
+        python = pkgs.python3;
+
+        # Pinned scientific stack shared by the package, notebooks, and tests
+        scientificPackages = ps: with ps; [
+          numpy
+          pandas
+          matplotlib
+        ];
+
+        notebookPackages = ps: with ps; [
+          jupyterlab
+          ipykernel
+          papermill
+          pytest
+        ];
+
+        analysis = python.pkgs.buildPythonPackage {
+          pname = "analysis";
+          version = "0.1.0";
+          pyproject = true;
+          src = ./.;
+          build-system = [ python.pkgs.setuptools ];
+          dependencies = scientificPackages python.pkgs;
+          nativeCheckInputs = [ python.pkgs.pytestCheckHook ];
+          # Notebook tests need a Jupyter kernel; they run in checks.notebooks
+          disabledTestPaths = [ "tests/test_notebooks.py" ];
+          pythonImportsCheck = [ "analysis" ];
+        };
+
+        notebookEnv = python.withPackages (ps:
+          scientificPackages ps ++ notebookPackages ps
+        );
+
+        # `glot run notebook` launches JupyterLab in the project directory
+        notebook = pkgs.writeShellApplication {
+          name = "notebook";
+          runtimeInputs = [ notebookEnv ];
+          text = ''
+            export PYTHONPATH="$PWD''${PYTHONPATH:+:$PYTHONPATH}"
+            exec jupyter lab --notebook-dir=notebooks "$@"
+          '';
+        };
+      in
+      {
+        packages = {
+          default = analysis;
+          dev = analysis;
+          release = analysis;
+          inherit notebook;
+          glot = nix-polyglot.packages.${system}.glot;
+        };
+
+        apps = {
+          default = {
+            type = "app";
+            program = "${analysis}/bin/analysis";
+          };
+          dev = {
+            type = "app";
+            program = "${analysis}/bin/analysis";
+          };
+          release = {
+            type = "app";
+            program = "${analysis}/bin/analysis";
+          };
+          notebook = {
+            type = "app";
+            program = "${notebook}/bin/notebook";
+          };
+        };
+
+        checks = {
+          inherit analysis;
+
+          # Execute every notebook with papermill
+          notebooks = pkgs.runCommand "notebook-tests"
+            {
+              nativeBuildInputs = [ notebookEnv ];
+              src = ./.;
+            } ''
+            cp -r $src project
+            chmod -R u+w project
+            cd project
+            export HOME=$TMPDIR
+            export PYTHONPATH=$PWD
+            pytest -p no:cacheprovider tests/test_notebooks.py
+            touch $out
+          '';
+        };
+
+        devShells.default = pkgs.mkShell {
+          packages = [
+            notebookEnv
+            pkgs.ruff
+            nix-polyglot.packages.${system}.glot
+          ];
+
+          shellHook = ''
+            export PYTHONPATH="$PWD''${PYTHONPATH:+:$PYTHONPATH}"
+
+            echo "📊 Data Science Development Environment"
+            echo ""
+            echo "  glot run notebook  - Launch JupyterLab"
+            echo "  glot run           - Summarize data/measurements.csv"
+            echo "  glot test          - Run unit and notebook tests"
+            echo ""
+          '';
+        };
+
+        formatter = pkgs.nixpkgs-fmt;
+      }
+    );
+}
@@ -0,0 +1,28 @@
+# Python Data Science Template
+{
+  name = "python-datascience";
+  description = "Python data science project with Jupyter";
+
+  # Template metadata
+  language = "python";
+  category = "datascience";
+
+  # Files to create in the new project
+  files = {
+    "flake.nix" = ./flake.nix;
+    "pyproject.toml" = ./pyproject.toml;
+    "analysis/__init__.py" = ./analysis/__init__.py;
+    "analysis/__main__.py" = ./analysis/__main__.py;
+    "analysis/summary.py" = ./analysis/summary.py;
+    "notebooks/exploration.ipynb" = ./notebooks/exploration.ipynb;
+    "data/measurements.csv" = ./data/measurements.csv;
+    "tests/__init__.py" = ./tests/__init__.py;
+    "tests/test_summary.py" = ./tests/test_summary.py;
+    "tests/test_notebooks.py" = ./tests/test_notebooks.py;
+    ".envrc" = ./.envrc;
+    ".gitignore" = ./.gitignore;
+    ".editorconfig" = ./.editorconfig;
+    "README.md" = ./README.md;
+    "glot-commands.toml" = ./glot-commands.toml;
+  };
+}
@@ -0,0 +1,20 @@
+"""Execute every notebook with papermill so broken notebooks fail the tests."""
+
+from pathlib import Path
+
+import papermill as pm
+import pytest
+
+PROJECT_ROOT = Path(__file__).resolve().parent.parent
+NOTEBOOKS = sorted((PROJECT_ROOT / "notebooks").glob("*.ipynb"))
+
+
+@pytest.mark.parametrize("notebook", NOTEBOOKS, ids=lambda path: path.name)
+def test_notebook_executes(notebook, tmp_path):
+    pm.execute_notebook(
+        str(notebook),
+        str(tmp_path / notebook.name),
+        cwd=str(PROJECT_ROOT),
+        parameters={"data_path": "data/measurements.csv"},
+        kernel_name="python3",
+    )
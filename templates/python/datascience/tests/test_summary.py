@@ -0,0 +1,24 @@
+"""Tests for the analysis module."""
+
+import pandas as pd
+import pytest
+
+from analysis import load_measurements, summarize
+
+
+def test_summarize_groups():
+    frame = pd.DataFrame({"group": ["a", "a", "b"], "value": [1.0, 3.0, 5.0]})
+    summary = summarize(frame)
+
+    assert list(summary.index) == ["a", "b"]
+    assert summary.loc["a", "count"] == 2
+    assert summary.loc["a", "mean"] == pytest.approx(2.0)
+    assert summary.loc["b", "std"] == pytest.approx(0.0)
+
+
+def test_load_measurements_rejects_missing_columns(tmp_path):
+    path = tmp_path / "bad.csv"
+    path.write_text("name\nx\n")
+
+    with pytest.raises(ValueError, match="group, value"):
+        load_measurements(path)
@@ -18,7 +18,7 @@
         # Configure Rust project
         rustProject = nix-polyglot.lib.rust {
           inherit pkgs self;
-          cargoHash = "sha256-AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="; # Update after first build
+          cargoHash = "sha256-AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="; # Updated by `glot hash update`
         };
       in
       # Use the complete project structure with glot CLI
@@ -17,4 +17,12 @@
     ".gitignore" = ./.gitignore;
     ".editorconfig" = ./.editorconfig;
   };
+
+  # Names replaced by `glot new` in file contents and paths
+  substitute = {
+    "my-rust-app" = "@projectName@";
+  };
+
+  # Run by `glot new` in the dev shell after the files are written
+  postGenerate = [ "glot hash update" ];
 }
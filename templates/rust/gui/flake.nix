@@ -0,0 +1,104 @@
+{
+  description = "Rust GUI application (egui) with nix-polyglot integration";
+
+  inputs = {
+    nixpkgs.url = "github:NixOS/nixpkgs/nixos-25.05";
+    flake-utils.url = "github:numtide/flake-utils";
+    nix-polyglot = {
+      url = "github:ritzau/nix-polyglot"; # Update this URL
+      # For local development, use: url = "path:/path/to/nix-polyglot";
+    };
+  };
+
+  outputs = { self, nixpkgs, flake-utils, nix-polyglot, ... }:
+    flake-utils.lib.eachDefaultSystem (system:
+      let
+        pkgs = import nixpkgs { inherit system; };
+        lib = pkgs.lib;
+
+        cargoToml = builtins.fromTOML (builtins.readFile ./Cargo.toml);
+        binaryName = cargoToml.package.name;
+
+        # Libraries egui/winit load at runtime on Linux (dlopen), so they
+        # must be on LD_LIBRARY_PATH rather than just linked
+        runtimeLibraries = lib.optionals pkgs.stdenv.isLinux (with pkgs; [
+          libGL
+          libxkbcommon
+          vulkan-loader
+          wayland
+          xorg.libX11
+          xorg.libXcursor
+          xorg.libXi
+          xorg.libXrandr
+        ]);
+
+        mkApp = buildType: pkgs.rustPlatform.buildRustPackage {
+          pname = "${binaryName}-${if buildType == "release" then "release" else "dev"}";
+          version = cargoToml.package.version;
+          src = lib.cleanSource ./.;
+          cargoHash = "sha256-AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="; # Updated by `glot hash update`
+          inherit buildType;
+
+          nativeBuildInputs = [ pkgs.pkg-config pkgs.makeWrapper ];
+          buildInputs = runtimeLibraries;
+
+          postFixup = lib.optionalString pkgs.stdenv.isLinux ''
+            wrapProgram $out/bin/${binaryName} \
+              --prefix LD_LIBRARY_PATH : ${lib.makeLibraryPath runtimeLibraries}
+          '';
+        };
+
+        devBuild = mkApp "debug";
+        releaseBuild = mkApp "release";
+      in
+      {
+        packages = {
+          default = devBuild;
+          dev = devBuild;
+          release = releaseBuild;
+          glot = nix-polyglot.packages.${system}.glot;
+        };
+
+        apps = {
+          default = {
+            type = "app";
+            program = "${devBuild}/bin/${binaryName}";
+          };
+          dev = {
+            type = "app";
+            program = "${devBuild}/bin/${binaryName}";
+          };
+          release = {
+            type = "app";
+            program = "${releaseBuild}/bin/${binaryName}";
+          };
+        };
+
+        devShells.default = pkgs.mkShell {
+          packages = with pkgs; [
+            rustc
+            cargo
+            clippy
+            rustfmt
+            rust-analyzer
+            pkg-config
+            nix-polyglot.packages.${system}.glot
+          ] ++ runtimeLibraries;
+
+          # Let `cargo run` find the graphics libraries too
+          LD_LIBRARY_PATH = lib.makeLibraryPath runtimeLibraries;
+
+          shellHook = ''
+            echo "🖼️  Rust GUI Development Environment"
+            echo ""
+            echo "  cargo run          - Run the app from source"
+            echo "  glot run           - Run the nix-built app"
+            echo "  glot test          - Run tests (no display needed)"
+            echo ""
+          '';
+        };
+
+        formatter = pkgs.nixpkgs-fmt;
+      }
+    );
+}
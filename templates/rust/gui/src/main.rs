@@ -0,0 +1,61 @@
+use eframe::egui;
+
+fn main() -> eframe::Result {
+    let options = eframe::NativeOptions {
+        viewport: egui::ViewportBuilder::default().with_inner_size([360.0, 200.0]),
+        ..Default::default()
+    };
+    eframe::run_native(
+        "GUI App",
+        options,
+        Box::new(|_cc| Ok(Box::<App>::default())),
+    )
+}
+
+#[derive(Default)]
+struct App {
+    name: String,
+    clicks: u32,
+}
+
+impl eframe::App for App {
+    fn update(&mut self, ctx: &egui::Context, _frame: &mut eframe::Frame) {
+        egui::CentralPanel::default().show(ctx, |ui| {
+            ui.heading("Hello from egui");
+            ui.horizontal(|ui| {
+                ui.label("Name:");
+                ui.text_edit_singleline(&mut self.name);
+            });
+            if ui.button("Greet").clicked() {
+                self.clicks += 1;
+            }
+            if self.clicks > 0 {
+                ui.label(greeting(&self.name, self.clicks));
+            }
+        });
+    }
+}
+
+/// Build the greeting shown after clicking the button.
+fn greeting(name: &str, clicks: u32) -> String {
+    let name = match name.trim() {
+        "" => "World",
+        trimmed => trimmed,
+    };
+    format!("Hello, {name}! (clicked {clicks}x)")
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn greets_by_name() {
+        assert_eq!(greeting("Ferris", 1), "Hello, Ferris! (clicked 1x)");
+    }
+
+    #[test]
+    fn defaults_to_world() {
+        assert_eq!(greeting("  ", 3), "Hello, World! (clicked 3x)");
+    }
+}
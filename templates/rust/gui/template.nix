@@ -0,0 +1,28 @@
+# Rust GUI Application Template
+{
+  name = "rust-gui";
+  description = "Rust desktop GUI application with egui";
+
+  # Template metadata
+  language = "rust";
+  category = "gui";
+
+  # Files to create in the new project
+  files = {
+    "flake.nix" = ./flake.nix;
+    "Cargo.toml" = ./Cargo.toml;
+    "src/main.rs" = ./src/main.rs;
+    "README.md" = ./README.md;
+    ".envrc" = ./.envrc;
+    ".gitignore" = ./.gitignore;
+    ".editorconfig" = ./.editorconfig;
+  };
+
+  # Names replaced by `glot new` in file contents and paths
+  substitute = {
+    "gui-app" = "@projectName@";
+  };
+
+  # Run by `glot new` in the dev shell after the files are written
+  postGenerate = [ "cargo generate-lockfile" "glot hash update" ];
+}
@@ -0,0 +1,126 @@
+{
+  description = "Shell script project with shellcheck, shfmt, and bats";
+
+  inputs = {
+    nixpkgs.url = "github:NixOS/nixpkgs/nixos-25.05";
+    flake-utils.url = "github:numtide/flake-utils";
+    nix-polyglot = {
+      url = "github:ritzau/nix-polyglot"; # Update this URL
+      # For local development, use: url = "path:/path/to/nix-polyglot";
+    };
+  };
+
+  outputs = { self, nixpkgs, flake-utils, nix-polyglot, ... }:
+    flake-utils.lib.eachDefaultSystem (system:
+      let
+        pkgs = import nixpkgs { inherit system; };
+        lib = pkgs.lib;
+
+        shellTools = with pkgs; [ bash shellcheck shfmt bats ];
+
+        # Every shell source in the project (scripts in bin/ have no extension)
+        listScripts = ''
+          { find bin -type f; find lib tests -type f \( -name '*.sh' -o -name '*.bash' -o -name '*.bats' \); } 2>/dev/null | sort
+        '';
+
+        # `glot fmt` / `nix fmt`
+        formatter = pkgs.writeShellApplication {
+          name = "shfmt-project";
+          runtimeInputs = shellTools;
+          text = ''
+            ${listScripts} | xargs --no-run-if-empty shfmt --write
+          '';
+        };
+
+        # `glot lint`: shellcheck everything except bats files
+        lint = pkgs.writeShellApplication {
+          name = "lint";
+          runtimeInputs = shellTools;
+          text = ''
+            ${listScripts} | grep -v '\.bats$' | xargs --no-run-if-empty shellcheck
+            ${listScripts} | xargs --no-run-if-empty shfmt --diff
+          '';
+        };
+
+        # `glot test`: bats test suite
+        test = pkgs.writeShellApplication {
+          name = "test";
+          runtimeInputs = shellTools;
+          text = ''
+            bats tests
+          '';
+        };
+
+        # Installable scripts; lib/ is resolved relative to bin/
+        scripts = pkgs.stdenvNoCC.mkDerivation {
+          pname = "shell-scripts";
+          version = "0.1.0";
+          src = lib.cleanSource ./.;
+
+          nativeBuildInputs = shellTools;
+          buildInputs = [ pkgs.bash ];
+
+          dontBuild = true;
+
+          doCheck = true;
+          checkPhase = ''
+            runHook preCheck
+            patchShebangs bin tests
+            ${lint}/bin/lint
+            bats tests
+            runHook postCheck
+          '';
+
+          installPhase = ''
+            runHook preInstall
+            mkdir -p $out
+            cp -r bin lib $out/
+            runHook postInstall
+          '';
+        };
+
+        mkApp = program: {
+          type = "app";
+          inherit program;
+        };
+      in
+      {
+        packages = {
+          default = scripts;
+          dev = scripts;
+          release = scripts;
+          glot = nix-polyglot.packages.${system}.glot;
+        };
+
+        apps = {
+          default = mkApp "${scripts}/bin/greet";
+          dev = mkApp "${scripts}/bin/greet";
+          release = mkApp "${scripts}/bin/greet";
+          lint = mkApp "${lint}/bin/lint";
+          test = mkApp "${test}/bin/test";
+        };
+
+        checks.scripts = scripts;
+
+        devShells.default = pkgs.mkShell {
+          packages = shellTools ++ [
+            pkgs.bash-language-server
+            nix-polyglot.packages.${system}.glot
+          ];
+
+          shellHook = ''
+            export PATH="$PWD/bin:$PATH"
+
+            echo "🐚 Shell Script Development Environment"
+            echo ""
+            echo "  glot fmt    - Format scripts with shfmt"
+            echo "  glot lint   - Check scripts with shellcheck"
+            echo "  glot test   - Run the bats test suite"
+            echo ""
+          '';
+        };
+
+        inherit formatter;
+      }
+    );
+}
@@ -0,0 +1,26 @@
+#!/usr/bin/env bash
+# Shared helpers for scripts in bin/. Source, don't execute.
+
+# Print an informational message to stderr
+log() {
+    printf '%s\n' "$*" >&2
+}
+
+# Print an error message and exit
+die() {
+    printf 'error: %s\n' "$*" >&2
+    exit 1
+}
+
+# Build a greeting for NAME, repeated COUNT times
+greeting() {
+    local name="${1:-World}"
+    local count="${2:-1}"
+    local i
+
+    [[ "${count}" =~ ^[1-9][0-9]*$ ]] || die "count must be a positive integer, got '${count}'"
+
+    for ((i = 1; i <= count; i++)); do
+        printf 'Hello, %s! (#%d)\n' "${name}" "${i}"
+    done
+}
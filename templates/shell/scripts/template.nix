@@ -0,0 +1,22 @@
+# Shell Script Project Template
+{
+  name = "shell-scripts";
+  description = "Shell script project with shellcheck, shfmt, and bats";
+
+  # Template metadata
+  language = "shell";
+  category = "scripts";
+
+  # Files to create in the new project
+  files = {
+    "flake.nix" = ./flake.nix;
+    "bin/greet" = ./bin/greet;
+    "lib/common.sh" = ./lib/common.sh;
+    "tests/greet.bats" = ./tests/greet.bats;
+    "README.md" = ./README.md;
+    ".envrc" = ./.envrc;
+    ".gitignore" = ./.gitignore;
+    ".editorconfig" = ./.editorconfig;
+    ".shellcheckrc" = ./.shellcheckrc;
+  };
+}
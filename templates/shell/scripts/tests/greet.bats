@@ -0,0 +1,30 @@
+#!/usr/bin/env bats
+
+setup() {
+    PROJECT_ROOT="$(cd "${BATS_TEST_DIRNAME}/.." && pwd)"
+    PATH="${PROJECT_ROOT}/bin:${PATH}"
+}
+
+@test "greets the world by default" {
+    run greet
+    [ "${status}" -eq 0 ]
+    [ "${output}" = "Hello, World! (#1)" ]
+}
+
+@test "greets by name multiple times" {
+    run greet -c 2 Alice
+    [ "${status}" -eq 0 ]
+    [ "${lines[0]}" = "Hello, Alice! (#1)" ]
+    [ "${lines[1]}" = "Hello, Alice! (#2)" ]
+}
+
+@test "rejects a non-numeric count" {
+    run greet -c many Bob
+    [ "${status}" -eq 1 ]
+    [[ "${output}" == *"count must be a positive integer"* ]]
+}
+
+@test "rejects unknown options" {
+    run greet -x
+    [ "${status}" -eq 2 ]
+}
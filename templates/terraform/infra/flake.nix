@@ -0,0 +1,135 @@
+{
+  description = "Infrastructure as code with OpenTofu, built with nix-polyglot";
+
+  inputs = {
+    nixpkgs.url = "github:NixOS/nixpkgs/nixos-25.05";
+    flake-utils.url = "github:numtide/flake-utils";
+    nix-polyglot = {
+      url = "github:ritzau/nix-polyglot"; # Update this URL
+      # For local development, use: url = "path:/path/to/nix-polyglot";
+    };
+  };
+
+  outputs = { self, nixpkgs, flake-utils, nix-polyglot, ... }:
+    flake-utils.lib.eachDefaultSystem (system:
+      let
+        pkgs = import nixpkgs { inherit system; };
+        lib = pkgs.lib;
+
+        # Providers are pinned by nixpkgs, so `tofu init` needs no registry
+        # access. Add providers here as you use them.
+        tofu = pkgs.opentofu.withPlugins (p: [ p.random p.local ]);
+
+        infraTools = [ tofu pkgs.tflint ];
+
+        # `glot fmt` / `nix fmt`
+        formatter = pkgs.writeShellApplication {
+          name = "tofu-fmt";
+          runtimeInputs = infraTools;
+          text = ''
+            tofu fmt -recursive
+          '';
+        };
+
+        mkApp = name: text: {
+          type = "app";
+          program = "${pkgs.writeShellApplication {
+            inherit name text;
+            runtimeInputs = infraTools;
+          }}/bin/${name}";
+        };
+
+        # Validated configuration: formatting, tflint, validate, and tofu test
+        validated = pkgs.stdenvNoCC.mkDerivation {
+          pname = "infra";
+          version = "0.1.0";
+          src = lib.cleanSource ./.;
+          nativeBuildInputs = infraTools;
+
+          buildPhase = ''
+            runHook preBuild
+            export HOME=$TMPDIR
+            tofu fmt -recursive -check
+            tofu init -backend=false -input=false
+            tofu validate
+            tflint --recursive
+            tofu test
+            runHook postBuild
+          '';
+
+          installPhase = ''
+            runHook preInstall
+            mkdir -p $out
+            cp *.tf $out/
+            runHook postInstall
+          '';
+        };
+      in
+      {
+        packages = {
+          default = validated;
+          dev = validated;
+          release = validated;
+          glot = nix-polyglot.packages.${system}.glot;
+        };
+
+        apps = {
+          default = mkApp "plan" ''tofu init -input=false && tofu plan "$@"'';
+          dev = mkApp "plan" ''tofu init -input=false && tofu plan "$@"'';
+          release = mkApp "plan" ''tofu init -input=false && tofu plan "$@"'';
+
+          lint = mkApp "lint" ''
+            tofu fmt -recursive -check
+            tofu init -backend=false -input=false >/dev/null
+            tofu validate
+            tflint --init
+            tflint --recursive
+          '';
+
+          test = mkApp "test" ''
+            tofu init -backend=false -input=false >/dev/null
+            tofu test "$@"
+          '';
+
+          # `glot plan`: saves the plan to ./tfplan for `glot apply`
+          plan = mkApp "plan" ''
+            tofu init -input=false
+            tofu plan -input=false -out=tfplan "$@"
+          '';
+
+          # `glot apply` asks for confirmation before running this
+          apply = mkApp "apply" ''
+            tofu init -input=false
+            if [[ -f tfplan && $# -eq 0 ]]; then
+              tofu apply -input=false tfplan
+              rm -f tfplan
+            else
+              tofu apply -input=false -auto-approve "$@"
+            fi
+          '';
+        };
+
+        checks.validated = validated;
+
+        devShells.default = pkgs.mkShell {
+          packages = infraTools ++ [
+            pkgs.terraform-ls
+            nix-polyglot.packages.${system}.glot
+          ];
+
+          shellHook = ''
+            echo "🏗️  Infrastructure Development Environment"
+            echo ""
+            echo "  glot fmt      - tofu fmt"
+            echo "  glot lint     - tofu validate + tflint"
+            echo "  glot test     - tofu test"
+            echo "  glot plan     - Plan changes (saved to ./tfplan)"
+            echo "  glot apply    - Apply the saved plan (asks for confirmation)"
+            echo ""
+          '';
+        };
+
+        inherit formatter;
+      }
+    );
+}
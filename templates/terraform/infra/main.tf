@@ -0,0 +1,15 @@
+# Example infrastructure using only local providers, so plan/apply work
+# without cloud credentials. Replace with your real resources.
+
+resource "random_pet" "name" {
+  prefix = var.environment
+  length = 2
+}
+
+resource "local_file" "inventory" {
+  filename = "${var.output_dir}/${var.environment}-inventory.json"
+  content = jsonencode({
+    environment = var.environment
+    name        = random_pet.name.id
+  })
+}
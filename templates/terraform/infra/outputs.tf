@@ -0,0 +1,9 @@
+output "name" {
+  description = "Generated resource name"
+  value       = random_pet.name.id
+}
+
+output "inventory_file" {
+  description = "Path of the generated inventory file"
+  value       = local_file.inventory.filename
+}
@@ -0,0 +1,25 @@
+# Infrastructure (OpenTofu) Template
+{
+  name = "terraform-infra";
+  description = "Infrastructure as code with OpenTofu and tflint";
+
+  # Template metadata
+  language = "terraform";
+  category = "infrastructure";
+
+  # Files to create in the new project
+  files = {
+    "flake.nix" = ./flake.nix;
+    "versions.tf" = ./versions.tf;
+    "variables.tf" = ./variables.tf;
+    "main.tf" = ./main.tf;
+    "outputs.tf" = ./outputs.tf;
+    "example.tfvars" = ./example.tfvars;
+    "tests/main.tftest.hcl" = ./tests/main.tftest.hcl;
+    "README.md" = ./README.md;
+    ".tflint.hcl" = ./.tflint.hcl;
+    ".envrc" = ./.envrc;
+    ".gitignore" = ./.gitignore;
+    ".editorconfig" = ./.editorconfig;
+  };
+}
@@ -0,0 +1,22 @@
+run "names_include_environment" {
+  command = plan
+
+  variables {
+    environment = "staging"
+  }
+
+  assert {
+    condition     = local_file.inventory.filename == "build/staging-inventory.json"
+    error_message = "inventory file should be named after the environment"
+  }
+}
+
+run "rejects_unknown_environment" {
+  command = plan
+
+  variables {
+    environment = "qa"
+  }
+
+  expect_failures = [var.environment]
+}
@@ -0,0 +1,16 @@
+variable "environment" {
+  description = "Deployment environment name"
+  type        = string
+  default     = "dev"
+
+  validation {
+    condition     = contains(["dev", "staging", "prod"], var.environment)
+    error_message = "environment must be one of dev, staging, or prod."
+  }
+}
+
+variable "output_dir" {
+  description = "Directory for generated files"
+  type        = string
+  default     = "build"
+}
@@ -12,4 +12,9 @@
     "build.zig" = ./build.zig;
     "src/main.zig" = ./src/main.zig;
   };
+
+  # Names replaced by `glot new` in file contents and paths
+  substitute = {
+    "zig-project" = "@projectName@";
+  };
 }
@@ -0,0 +1,118 @@
+{
+  description = "Zig game with raylib, built with nix-polyglot";
+
+  inputs = {
+    nixpkgs.url = "github:NixOS/nixpkgs/nixos-25.05";
+    flake-utils.url = "github:numtide/flake-utils";
+    nix-polyglot = {
+      url = "github:ritzau/nix-polyglot"; # Update this URL
+      # For local development, use: url = "path:/path/to/nix-polyglot";
+    };
+  };
+
+  outputs = { self, nixpkgs, flake-utils, nix-polyglot, ... }:
+    flake-utils.lib.eachDefaultSystem (system:
+      let
+        pkgs = import nixpkgs { inherit system; };
+        lib = pkgs.lib;
+        zig = pkgs.zig;
+        gameName = "zig-game";
+
+        # raylib plus the audio/input/graphics libraries it needs on Linux
+        gameLibraries = [ pkgs.raylib ] ++ lib.optionals pkgs.stdenv.isLinux (with pkgs; [
+          alsa-lib
+          libGL
+          libpulseaudio
+          udev
+          vulkan-loader
+          wayland
+          xorg.libX11
+          xorg.libXcursor
+          xorg.libXi
+          xorg.libXinerama
+          xorg.libXrandr
+        ]);
+
+        mkGame = optimize: pkgs.stdenv.mkDerivation {
+          pname = "${gameName}-${if optimize == "Debug" then "dev" else "release"}";
+          version = "0.1.0";
+          src = lib.cleanSource ./.;
+
+          nativeBuildInputs = [ zig pkgs.pkg-config pkgs.makeWrapper ];
+          buildInputs = gameLibraries;
+
+          dontConfigure = true;
+
+          buildPhase = ''
+            runHook preBuild
+            export ZIG_GLOBAL_CACHE_DIR=$TMPDIR/zig-cache
+            zig build -Doptimize=${optimize} --prefix $out
+            runHook postBuild
+          '';
+
+          doCheck = true;
+          checkPhase = ''
+            runHook preCheck
+            zig build test -Doptimize=${optimize}
+            runHook postCheck
+          '';
+
+          dontInstall = true;
+
+          # Audio and graphics backends are loaded at runtime
+          postFixup = lib.optionalString pkgs.stdenv.isLinux ''
+            wrapProgram $out/bin/${gameName} \
+              --prefix LD_LIBRARY_PATH : ${lib.makeLibraryPath gameLibraries}
+          '';
+        };
+
+        devBuild = mkGame "Debug";
+        releaseBuild = mkGame "ReleaseFast";
+      in
+      {
+        packages = {
+          default = devBuild;
+          dev = devBuild;
+          release = releaseBuild;
+          glot = nix-polyglot.packages.${system}.glot;
+        };
+
+        apps = {
+          default = {
+            type = "app";
+            program = "${devBuild}/bin/${gameName}";
+          };
+          dev = {
+            type = "app";
+            program = "${devBuild}/bin/${gameName}";
+          };
+          release = {
+            type = "app";
+            program = "${releaseBuild}/bin/${gameName}";
+          };
+        };
+
+        devShells.default = pkgs.mkShell {
+          packages = [
+            zig
+            pkgs.zls
+            pkgs.pkg-config
+            nix-polyglot.packages.${system}.glot
+          ] ++ gameLibraries;
+
+          LD_LIBRARY_PATH = lib.makeLibraryPath gameLibraries;
+
+          shellHook = ''
+            echo "🎮 Zig Game Development Environment"
+            echo ""
+            echo "  zig build run        - Run the game from source"
+            echo "  glot run --release   - Playtest the optimized build"
+            echo "  zig build test       - Run game logic tests"
+            echo ""
+          '';
+        };
+
+        formatter = pkgs.nixpkgs-fmt;
+      }
+    );
+}
@@ -0,0 +1,36 @@
+//! Game state and rules, kept free of raylib so it can be unit tested.
+
+const std = @import("std");
+
+pub const screen_width = 800;
+pub const screen_height = 450;
+
+pub const Player = struct {
+    x: f32 = screen_width / 2,
+    y: f32 = screen_height / 2,
+    radius: f32 = 20,
+    speed: f32 = 240,
+
+    /// Move by a direction vector scaled by elapsed time, staying on screen.
+    pub fn move(self: *Player, dx: f32, dy: f32, dt: f32) void {
+        self.x = std.math.clamp(self.x + dx * self.speed * dt, self.radius, screen_width - self.radius);
+        self.y = std.math.clamp(self.y + dy * self.speed * dt, self.radius, screen_height - self.radius);
+    }
+};
+
+test "player moves with elapsed time" {
+    var player = Player{};
+    player.move(1, 0, 0.5);
+    try std.testing.expectApproxEqAbs(@as(f32, screen_width / 2 + 120), player.x, 0.001);
+}
+
+test "player stays on screen" {
+    var player = Player{};
+    player.move(-1, -1, 100);
+    try std.testing.expectEqual(player.radius, player.x);
+    try std.testing.expectEqual(player.radius, player.y);
+
+    player.move(1, 1, 100);
+    try std.testing.expectEqual(screen_width - player.radius, player.x);
+    try std.testing.expectEqual(screen_height - player.radius, player.y);
+}
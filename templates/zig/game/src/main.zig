@@ -0,0 +1,47 @@
+const std = @import("std");
+const game = @import("game.zig");
+const rl = @cImport({
+    @cInclude("raylib.h");
+});
+
+/// Resolve an asset path relative to the installed binary
+/// (<prefix>/bin/zig-game -> <prefix>/share/zig-game/assets/<name>).
+fn assetPath(buf: []u8, name: []const u8) ![:0]const u8 {
+    const app_dir = std.mem.span(rl.GetApplicationDirectory());
+    return std.fmt.bufPrintZ(buf, "{s}../share/zig-game/assets/{s}", .{ app_dir, name });
+}
+
+pub fn main() !void {
+    rl.SetConfigFlags(rl.FLAG_VSYNC_HINT);
+    rl.InitWindow(game.screen_width, game.screen_height, "Zig Game");
+    defer rl.CloseWindow();
+
+    var path_buf: [std.fs.max_path_bytes]u8 = undefined;
+    const title_path = try assetPath(&path_buf, "title.txt");
+    const title = rl.LoadFileText(title_path.ptr);
+    defer if (title != null) rl.UnloadFileText(title);
+
+    var player = game.Player{};
+
+    while (!rl.WindowShouldClose()) {
+        var dx: f32 = 0;
+        var dy: f32 = 0;
+        if (rl.IsKeyDown(rl.KEY_RIGHT)) dx += 1;
+        if (rl.IsKeyDown(rl.KEY_LEFT)) dx -= 1;
+        if (rl.IsKeyDown(rl.KEY_DOWN)) dy += 1;
+        if (rl.IsKeyDown(rl.KEY_UP)) dy -= 1;
+        player.move(dx, dy, rl.GetFrameTime());
+
+        rl.BeginDrawing();
+        defer rl.EndDrawing();
+
+        rl.ClearBackground(rl.RAYWHITE);
+        rl.DrawText(if (title != null) title else "Zig Game", 20, 20, 20, rl.DARKGRAY);
+        rl.DrawCircleV(.{ .x = player.x, .y = player.y }, player.radius, rl.MAROON);
+        rl.DrawFPS(game.screen_width - 100, 20);
+    }
+}
+
+test {
+    _ = game;
+}
@@ -0,0 +1,20 @@
+# Zig Game Template
+{
+  name = "zig-game";
+  language = "zig";
+  description = "Zig game with raylib";
+  category = "game";
+
+  files = {
+    "flake.nix" = ./flake.nix;
+    ".envrc" = ./.envrc;
+    ".gitignore" = ./.gitignore;
+    ".editorconfig" = ./.editorconfig;
+    "build.zig" = ./build.zig;
+    "src/main.zig" = ./src/main.zig;
+    "src/game.zig" = ./src/game.zig;
+    "assets/README.md" = ./assets/README.md;
+    "assets/title.txt" = ./assets/title.txt;
+    "README.md" = ./README.md;
+  };
+}
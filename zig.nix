@@ -18,6 +18,7 @@
 let
   # Import organizational standard tools and build hooks
   standardTools = import ./lib/standard-tools.nix { inherit pkgs; };
+  container = import ./lib/container.nix { inherit pkgs; };
   buildHooks = import ./lib/build-hooks.nix { inherit pkgs; };
 
   # Use organizational standard tools
@@ -26,6 +27,7 @@ let
   # Zig-specific build tools (in addition to standard tools)
   buildTools = [
     zig
+    pkgs.wasmtime # Runs the wasm output (glot run --wasm)
   ]
   ++ standardTools.commonBuildTools;
 
@@ -40,7 +42,7 @@ let
 
   # Combine with user extras
   allBuildTools = buildTools ++ zigDevTools ++ extraBuildTools;
-  allGeneralTools = generalTools ++ extraGeneralTools;
+  allGeneralTools = generalTools ++ extraGeneralTools ++ standardTools.projectTools self;
 
   shellHook = ''
     echo "⚡ Zig Development Environment"
@@ -134,6 +136,26 @@ let
     buildMode = "ReleaseFast";
   });
 
+  # Static build - a release build against musl, which zig ships
+  staticBuild = pkgs.stdenv.mkDerivation (baseBuildArgs // {
+    pname = "${actualProjectName}-static";
+    buildPhase = ''
+      runHook preBuild
+      zig build -Doptimize=ReleaseFast -Dtarget=${pkgs.stdenv.hostPlatform.parsed.cpu.name}-linux-musl
+      runHook postBuild
+    '';
+  });
+
+  # WebAssembly build - a WASI module, installed from zig-out/bin as name.wasm
+  wasmBuild = pkgs.stdenv.mkDerivation (baseBuildArgs // {
+    pname = "${actualProjectName}-wasm";
+    buildPhase = ''
+      runHook preBuild
+      zig build -Doptimize=ReleaseSmall -Dtarget=wasm32-wasi
+      runHook postBuild
+    '';
+  });
+
 in
 {
   # Standard nix-polyglot outputs
@@ -142,6 +164,12 @@ in
       default = devBuild;
       dev = devBuild;
       release = releaseBuild;
+      image = container.layeredImage { name = actualProjectName; package = releaseBuild; };
+      wasm = wasmBuild;
+    } // pkgs.lib.optionalAttrs pkgs.stdenv.hostPlatform.isLinux {
+      # Fully static binaries only exist on Linux (glot build --static)
+      static = staticBuild;
+    } // {
 
       # Also expose glot CLI
       glot = self.packages.${pkgs.system}.glot;